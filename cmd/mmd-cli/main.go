@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/coolamit/mermaid-cli/internal/cli"
@@ -10,7 +9,7 @@ import (
 func main() {
 	cmd := cli.NewRootCommand()
 	if err := cmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "\033[31m\n%s\n\033[0m", err.Error())
-		os.Exit(1)
+		cli.PrintError(err)
+		os.Exit(cli.ExitCode(err))
 	}
 }
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/coolamit/mermaid-cli/internal/markdown"
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+// animationFrame is one diagram of gif output.
+type animationFrame struct {
+	definition string
+	opts       renderer.RenderOpts
+}
+
+// animationFrames returns the diagrams that make up gif output: the files given with
+// --frames, else the blocks of a markdown input, each with its own options such as a
+// per-block theme, else the input diagram alone.
+func animationFrames(files []string, definition string, isMarkdown bool, opts renderer.RenderOpts) ([]animationFrame, error) {
+	var frames []animationFrame
+	switch {
+	case len(files) > 0:
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, inputNotFoundError(fmt.Errorf("failed to read frame: %w", err))
+			}
+			frames = append(frames, animationFrame{definition: string(data), opts: opts})
+		}
+	case isMarkdown:
+		for _, block := range markdown.ExtractDiagrams(definition) {
+			frames = append(frames, animationFrame{definition: block.Definition, opts: blockRenderOpts(block, opts)})
+		}
+		if len(frames) == 0 {
+			return nil, usageError(fmt.Errorf("no mermaid charts found in Markdown input to animate"))
+		}
+	default:
+		frames = append(frames, animationFrame{definition: definition, opts: opts})
+	}
+	return frames, nil
+}
+
+// renderAnimation renders each frame as png and assembles them into an animated GIF
+// that shows each frame for delay.
+func renderAnimation(ctx context.Context, r diagramRenderer, frames []animationFrame, delay time.Duration) ([]byte, error) {
+	images := make([][]byte, len(frames))
+	for i, frame := range frames {
+		result, err := r.Render(ctx, frame.definition, "png", frame.opts)
+		if err != nil {
+			return nil, fmt.Errorf("frame %d: %w", i+1, err)
+		}
+		images[i] = result.Data
+	}
+	return renderer.EncodeGIF(images, delay)
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/gif"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+// frameRenderer renders every definition as a PNG as wide as the definition is long,
+// and fails definitions containing "broken".
+type frameRenderer struct {
+	formats []string
+}
+
+func (r *frameRenderer) Render(ctx context.Context, definition string, outputFormat string, opts renderer.RenderOpts) (*renderer.RenderResult, error) {
+	r.formats = append(r.formats, outputFormat)
+	if strings.Contains(definition, "broken") {
+		return nil, errors.New("mermaid rendering error: Parse error")
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, len(definition), 10))); err != nil {
+		return nil, err
+	}
+	return &renderer.RenderResult{Data: buf.Bytes()}, nil
+}
+
+func (r *frameRenderer) Close() {}
+
+func TestAnimationFrames(t *testing.T) {
+	opts := renderer.RenderOpts{Scale: 1}
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"1.mmd": "graph TD; A", "2.mmd": "graph TD; A-->B"})
+	frames, err := animationFrames([]string{filepath.Join(dir, "1.mmd"), filepath.Join(dir, "2.mmd")}, "", false, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(frames) != 2 || frames[0].definition != "graph TD; A" || frames[1].definition != "graph TD; A-->B" {
+		t.Errorf("unexpected frames from files: %+v", frames)
+	}
+
+	md := "```mermaid\ngraph TD; A\n```\n\n```mermaid {theme=dark}\ngraph TD; A-->B\n```\n"
+	frames, err = animationFrames(nil, md, true, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(frames) != 2 || frames[1].definition != "graph TD; A-->B" {
+		t.Fatalf("unexpected frames from markdown: %+v", frames)
+	}
+	if frames[1].opts.MermaidConfig["theme"] != "dark" {
+		t.Errorf("expected the block's theme, got %v", frames[1].opts.MermaidConfig)
+	}
+
+	frames, err = animationFrames(nil, "graph TD; A", false, opts)
+	if err != nil || len(frames) != 1 {
+		t.Errorf("expected the input diagram as the only frame, got %+v, %v", frames, err)
+	}
+
+	if _, err := animationFrames(nil, "# No diagrams\n", true, opts); ExitCode(err) != exitUsage {
+		t.Errorf("expected a usage error for markdown without diagrams, got %v", err)
+	}
+	if _, err := animationFrames([]string{filepath.Join(dir, "missing.mmd")}, "", false, opts); ExitCode(err) != exitInputNotFound {
+		t.Errorf("expected an input not found error, got %v", err)
+	}
+}
+
+func TestRenderAnimation(t *testing.T) {
+	r := &frameRenderer{}
+	frames := []animationFrame{{definition: "graph TD; A"}, {definition: "graph TD; A-->B"}}
+	data, err := renderAnimation(context.Background(), r, frames, 250*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(r.formats, ",") != "png,png" {
+		t.Errorf("expected frames to be rendered as png, got %v", r.formats)
+	}
+	anim, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected a GIF: %v", err)
+	}
+	if len(anim.Image) != 2 || anim.Delay[0] != 25 || anim.Config.Width != len("graph TD; A-->B") {
+		t.Errorf("unexpected animation: %d frames, delay %v, width %d", len(anim.Image), anim.Delay, anim.Config.Width)
+	}
+
+	_, err = renderAnimation(context.Background(), &frameRenderer{}, []animationFrame{{definition: "graph TD; A"}, {definition: "broken"}}, time.Second)
+	if err == nil || !strings.Contains(err.Error(), "frame 2") {
+		t.Errorf("expected frame 2 to fail, got %v", err)
+	}
+}
+
+func TestFramesFlag_KeepsCommas(t *testing.T) {
+	cmd := NewRootCommand()
+	if err := cmd.Flags().Parse([]string{"--frames", "step 1,draft.mmd", "--frames", "step2.mmd"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cmd.Flags().GetStringArray("frames")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != "step 1,draft.mmd" || got[1] != "step2.mmd" {
+		t.Errorf("frames = %q, want each flag as one file", got)
+	}
+}
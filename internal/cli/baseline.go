@@ -0,0 +1,246 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pixelTolerance is how much a channel of a pixel may differ from the baseline, out of
+// 255, before the pixel counts as changed. It absorbs antialiasing and JPEG noise.
+const pixelTolerance = 8
+
+// baselineChange is an output that differs from its baseline.
+type baselineChange struct {
+	path string
+	// pixels is the number of changed pixels, for PNG and JPEG output of the same size
+	pixels int
+	// missing is set when the baseline has no such file
+	missing bool
+	// diff is where the diff image was written, if any
+	diff string
+}
+
+func (c baselineChange) String() string {
+	switch {
+	case c.missing:
+		return fmt.Sprintf("%s (not in baseline)", c.path)
+	case c.pixels > 0 && c.diff != "":
+		return fmt.Sprintf("%s (%d pixels differ, see %s)", c.path, c.pixels, c.diff)
+	case c.pixels > 0:
+		return fmt.Sprintf("%s (%d pixels differ)", c.path, c.pixels)
+	}
+	return c.path
+}
+
+// baselineChecker compares rendered diagrams with the files at the same path in a
+// baseline directory, e.g. the output of a previous run, for --baseline. Paths are taken
+// relative to root, the directory the outputs are laid out under, so same-named files in
+// different subdirectories of a recursive --inputDir run each have their own baseline.
+type baselineChecker struct {
+	dir          string
+	diffDir      string
+	root         string
+	failOnChange bool
+	checked      int
+	changes      []baselineChange
+}
+
+// newBaselineChecker sets up --baseline for outputs laid out under root, creating
+// --diffDir, and routes summary's writes through the checker. It returns nil without
+// --baseline. Call it once every flag has been validated, so that a usage error leaves
+// nothing behind.
+func newBaselineChecker(flags *Flags, root string, summary *renderSummary) (*baselineChecker, error) {
+	if flags.Baseline == "" {
+		return nil, nil
+	}
+	if flags.DiffDir != "" {
+		if err := os.MkdirAll(flags.DiffDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create diff directory: %w", err)
+		}
+	}
+	b := &baselineChecker{dir: flags.Baseline, diffDir: flags.DiffDir, root: root, failOnChange: flags.FailOnChange}
+	write := summary.write
+	// Compare before writing, in case the baseline is the output directory itself
+	summary.write = func(path string, data []byte) error {
+		if err := b.check(path, data); err != nil {
+			return err
+		}
+		return write(path, data)
+	}
+	return b, nil
+}
+
+// relPath returns the path of an output relative to the output root, or just its name
+// if it was written outside the root, e.g. to an --artefacts directory elsewhere.
+func (b *baselineChecker) relPath(path string) string {
+	if b.root != "" {
+		if rel, err := filepath.Rel(b.root, path); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return rel
+		}
+	}
+	return filepath.Base(path)
+}
+
+// check compares the diagram written to path with its baseline and records whether it
+// changed. Files that aren't diagrams, such as rewritten markdown, are ignored.
+func (b *baselineChecker) check(path string, data []byte) error {
+	format := normalizeOutputFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	if format != "svg" && format != "png" && format != "jpeg" && format != "pdf" {
+		return nil
+	}
+	b.checked++
+
+	rel := b.relPath(path)
+	baseline, err := os.ReadFile(filepath.Join(b.dir, rel))
+	if errors.Is(err, fs.ErrNotExist) {
+		b.changes = append(b.changes, baselineChange{path: path, missing: true})
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	switch format {
+	case "svg":
+		if !sameSVG(baseline, data) {
+			b.changes = append(b.changes, baselineChange{path: path})
+		}
+	case "png", "jpeg":
+		pixels, diff, err := compareImages(baseline, data)
+		if err != nil {
+			return fmt.Errorf("failed to compare %s with its baseline: %w", path, err)
+		}
+		if pixels == 0 {
+			return nil
+		}
+		change := baselineChange{path: path, pixels: pixels}
+		if b.diffDir != "" && diff != nil {
+			change.diff = filepath.Join(b.diffDir, strings.TrimSuffix(rel, filepath.Ext(rel))+".diff.png")
+			if err := os.MkdirAll(filepath.Dir(change.diff), 0755); err != nil {
+				return fmt.Errorf("failed to create diff directory: %w", err)
+			}
+			if err := writeDiffImage(change.diff, diff); err != nil {
+				return err
+			}
+		}
+		b.changes = append(b.changes, change)
+	default:
+		if !bytes.Equal(baseline, data) {
+			b.changes = append(b.changes, baselineChange{path: path})
+		}
+	}
+	return nil
+}
+
+// report logs the diagrams that changed since the baseline.
+func (b *baselineChecker) report(lg logger) {
+	for _, change := range b.changes {
+		lg.logf(levelError, "Changed since baseline: %s", change)
+	}
+	lg.logf(levelInfo, "%d of %d diagrams changed since baseline", len(b.changes), b.checked)
+}
+
+// finish reports the changes once rendering is done and returns the run's error: err if
+// the render failed, otherwise an error for the changes with --failOnChange. It is a
+// no-op on a nil checker.
+func (b *baselineChecker) finish(lg logger, err error) error {
+	if b == nil {
+		return err
+	}
+	b.report(lg)
+	if err == nil && b.failOnChange && len(b.changes) > 0 {
+		return fmt.Errorf("%d of %d diagrams changed since baseline", len(b.changes), b.checked)
+	}
+	return err
+}
+
+// svgWhitespaceRegex matches whitespace between tags.
+var svgWhitespaceRegex = regexp.MustCompile(`>\s+<`)
+
+// xmlDeclarationPrefixRegex matches a leading XML declaration.
+var xmlDeclarationPrefixRegex = regexp.MustCompile(`^\x{feff}?\s*<\?xml\b[^>]*\?>`)
+
+// sameSVG reports whether two SVGs are equal apart from line endings, whitespace
+// between tags and an XML declaration.
+func sameSVG(a, b []byte) bool {
+	return normalizeSVGForDiff(a) == normalizeSVGForDiff(b)
+}
+
+// normalizeSVGForDiff strips the differences sameSVG ignores.
+func normalizeSVGForDiff(data []byte) string {
+	s := strings.ReplaceAll(string(data), "\r\n", "\n")
+	s = xmlDeclarationPrefixRegex.ReplaceAllString(s, "")
+	return strings.TrimSpace(svgWhitespaceRegex.ReplaceAllString(s, "><"))
+}
+
+// compareImages decodes two PNG or JPEG images and returns how many pixels differ by
+// more than pixelTolerance in any channel, with a diff image: the current image faded,
+// with changed pixels in red. Images of different sizes differ in every pixel of the
+// larger one, and have no diff image.
+func compareImages(baseline, current []byte) (int, *image.NRGBA, error) {
+	before, _, err := image.Decode(bytes.NewReader(baseline))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to decode baseline: %w", err)
+	}
+	after, _, err := image.Decode(bytes.NewReader(current))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bb, ab := before.Bounds(), after.Bounds()
+	if bb.Size() != ab.Size() {
+		size := ab.Size()
+		if bb.Dx()*bb.Dy() > size.X*size.Y {
+			size = bb.Size()
+		}
+		return size.X * size.Y, nil, nil
+	}
+
+	diff := image.NewNRGBA(image.Rectangle{Max: ab.Size()})
+	changed := 0
+	for y := 0; y < ab.Dy(); y++ {
+		for x := 0; x < ab.Dx(); x++ {
+			p := color.NRGBAModel.Convert(before.At(bb.Min.X+x, bb.Min.Y+y)).(color.NRGBA)
+			q := color.NRGBAModel.Convert(after.At(ab.Min.X+x, ab.Min.Y+y)).(color.NRGBA)
+			if pixelDelta(p, q) > pixelTolerance {
+				changed++
+				diff.SetNRGBA(x, y, color.NRGBA{R: 255, A: 255})
+				continue
+			}
+			grey := uint8((int(q.R) + int(q.G) + int(q.B)) / 3)
+			faded := 255 - (255-grey)/4
+			diff.SetNRGBA(x, y, color.NRGBA{R: faded, G: faded, B: faded, A: 255})
+		}
+	}
+	return changed, diff, nil
+}
+
+// pixelDelta returns the largest difference between the channels of two colors.
+func pixelDelta(p, q color.NRGBA) uint8 {
+	d := uint8(0)
+	for _, c := range [][2]uint8{{p.R, q.R}, {p.G, q.G}, {p.B, q.B}, {p.A, q.A}} {
+		d = max(d, max(c[0], c[1])-min(c[0], c[1]))
+	}
+	return d
+}
+
+// writeDiffImage writes a diff image as a PNG.
+func writeDiffImage(path string, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return fmt.Errorf("failed to encode diff image: %w", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write diff image: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testPNG encodes a w x h white image with the given pixels painted black.
+func testPNG(t *testing.T, w, h int, black ...image.Point) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 255})
+		}
+	}
+	for _, p := range black {
+		img.SetNRGBA(p.X, p.Y, color.NRGBA{0, 0, 0, 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompareImages_Identical(t *testing.T) {
+	a := testPNG(t, 20, 10, image.Pt(3, 4))
+	pixels, _, err := compareImages(a, testPNG(t, 20, 10, image.Pt(3, 4)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pixels != 0 {
+		t.Errorf("expected identical images, got %d changed pixels", pixels)
+	}
+}
+
+func TestCompareImages_Different(t *testing.T) {
+	a := testPNG(t, 20, 10, image.Pt(3, 4))
+	b := testPNG(t, 20, 10, image.Pt(5, 6), image.Pt(7, 8))
+	pixels, diff, err := compareImages(a, b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pixels != 3 {
+		t.Errorf("changed pixels = %d, want 3", pixels)
+	}
+	red := color.NRGBA{R: 255, A: 255}
+	for _, p := range []image.Point{{3, 4}, {5, 6}, {7, 8}} {
+		if got := diff.NRGBAAt(p.X, p.Y); got != red {
+			t.Errorf("diff at %v = %v, want red", p, got)
+		}
+	}
+	if got := diff.NRGBAAt(0, 0); got == red {
+		t.Error("expected unchanged pixels not to be red in the diff")
+	}
+}
+
+func TestCompareImages_WithinTolerance(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	img.SetNRGBA(0, 0, color.NRGBA{250, 250, 250, 255})
+	img.SetNRGBA(1, 0, color.NRGBA{255, 255, 255, 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	pixels, _, err := compareImages(testPNG(t, 2, 1), buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pixels != 0 {
+		t.Errorf("expected a 5-level difference to be tolerated, got %d changed pixels", pixels)
+	}
+}
+
+func TestCompareImages_DifferentSizes(t *testing.T) {
+	pixels, diff, err := compareImages(testPNG(t, 20, 10), testPNG(t, 30, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pixels != 300 || diff != nil {
+		t.Errorf("got %d pixels and diff %v, want 300 and no diff", pixels, diff != nil)
+	}
+}
+
+func TestSameSVG(t *testing.T) {
+	a := []byte(`<svg id="d"><g><rect/></g></svg>`)
+	if !sameSVG(a, []byte("<?xml version=\"1.0\"?>\r\n<svg id=\"d\">\r\n  <g>\r\n    <rect/>\r\n  </g>\r\n</svg>\n")) {
+		t.Error("expected formatting differences to be ignored")
+	}
+	if sameSVG(a, []byte(`<svg id="d"><g><circle/></g></svg>`)) {
+		t.Error("expected different content to differ")
+	}
+}
+
+func TestBaselineChecker(t *testing.T) {
+	baseline, out, diffs := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFiles(t, baseline, map[string]string{
+		"same.svg":    `<svg><g/></svg>`,
+		"changed.svg": `<svg><g/></svg>`,
+		"doc-1.png":   string(testPNG(t, 10, 10)),
+		"doc-2.png":   string(testPNG(t, 10, 10)),
+	})
+
+	b := &baselineChecker{dir: baseline, diffDir: diffs, root: out}
+	writes := map[string][]byte{
+		"same.svg":    []byte("<svg>\n  <g/>\n</svg>"),
+		"changed.svg": []byte(`<svg><g id="x"/></svg>`),
+		"doc-1.png":   testPNG(t, 10, 10),
+		"doc-2.png":   testPNG(t, 10, 10, image.Pt(1, 1)),
+		"new.png":     testPNG(t, 10, 10),
+		"doc.md":      []byte("# Doc"),
+	}
+	for _, name := range []string{"same.svg", "changed.svg", "doc-1.png", "doc-2.png", "new.png", "doc.md"} {
+		if err := b.check(filepath.Join(out, name), writes[name]); err != nil {
+			t.Fatalf("check(%s): %v", name, err)
+		}
+	}
+
+	if b.checked != 5 {
+		t.Errorf("checked = %d, want 5 (markdown isn't a diagram)", b.checked)
+	}
+	want := []baselineChange{
+		{path: filepath.Join(out, "changed.svg")},
+		{path: filepath.Join(out, "doc-2.png"), pixels: 1, diff: filepath.Join(diffs, "doc-2.diff.png")},
+		{path: filepath.Join(out, "new.png"), missing: true},
+	}
+	if len(b.changes) != len(want) {
+		t.Fatalf("changes = %v, want %v", b.changes, want)
+	}
+	for i := range want {
+		if b.changes[i] != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, b.changes[i], want[i])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(diffs, "doc-2.diff.png")); err != nil {
+		t.Errorf("expected a diff image: %v", err)
+	}
+}
+
+func TestBaselineChecker_MatchesRelativePaths(t *testing.T) {
+	baseline, out, diffs := t.TempDir(), t.TempDir(), t.TempDir()
+	writeFiles(t, baseline, map[string]string{
+		"a/flow.png": string(testPNG(t, 10, 10)),
+		"b/flow.png": string(testPNG(t, 10, 10, image.Pt(1, 1))),
+	})
+
+	b := &baselineChecker{dir: baseline, diffDir: diffs, root: out}
+	for _, dir := range []string{"a", "b"} {
+		if err := b.check(filepath.Join(out, dir, "flow.png"), testPNG(t, 10, 10)); err != nil {
+			t.Fatalf("check(%s): %v", dir, err)
+		}
+	}
+
+	want := baselineChange{path: filepath.Join(out, "b", "flow.png"), pixels: 1, diff: filepath.Join(diffs, "b", "flow.diff.png")}
+	if len(b.changes) != 1 || b.changes[0] != want {
+		t.Fatalf("changes = %+v, want [%+v]", b.changes, want)
+	}
+	if _, err := os.Stat(want.diff); err != nil {
+		t.Errorf("expected a diff image: %v", err)
+	}
+}
+
+func TestBaselineChecker_Finish(t *testing.T) {
+	lg := logger{level: levelError, w: io.Discard}
+	changed := &baselineChecker{checked: 2, changes: []baselineChange{{path: "a.svg"}}}
+	if err := changed.finish(lg, nil); err != nil {
+		t.Errorf("finish() = %v, want nil without --failOnChange", err)
+	}
+
+	changed.failOnChange = true
+	if err := changed.finish(lg, nil); err == nil || ExitCode(err) != exitFailed {
+		t.Errorf("finish() = %v, want an exit code 1 error with --failOnChange", err)
+	}
+	renderErr := renderError(errors.New("boom"))
+	if err := changed.finish(lg, renderErr); err != renderErr {
+		t.Errorf("finish() = %v, want the render error", err)
+	}
+
+	unchanged := &baselineChecker{checked: 2, failOnChange: true}
+	if err := unchanged.finish(lg, nil); err != nil {
+		t.Errorf("finish() = %v, want nil when nothing changed", err)
+	}
+	var none *baselineChecker
+	if err := none.finish(lg, nil); err != nil {
+		t.Errorf("finish() on a nil checker = %v, want nil", err)
+	}
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+// diagramFileExtensions are the files rendered from an --inputDir.
+var diagramFileExtensions = map[string]bool{".mmd": true, ".mermaid": true}
+
+// validateBatchFlags checks that --inputDir isn't combined with single-input options,
+// and that --outputDir and --recursive are only used with it.
+func validateBatchFlags(flags *Flags) error {
+	if flags.InputDir == "" {
+		if flags.OutputDir != "" || flags.Recursive {
+			return usageError(fmt.Errorf("--outputDir and --recursive can only be used with --inputDir"))
+		}
+		return nil
+	}
+
+	conflicts := []struct {
+		name string
+		set  bool
+	}{
+		{"--input", flags.Input != ""},
+		{"--definition", flags.Definition != ""},
+		{"--frames", len(flags.Frames) > 0},
+		{"--output", flags.Output != ""},
+		{"--artefacts", flags.Artefacts != ""},
+		{"--diagram", flags.Diagram > 0},
+		{"--dumpHtml", flags.DumpHTML != ""},
+		{"--dataUri", flags.DataURI},
+	}
+	for _, c := range conflicts {
+		if c.set {
+			return usageError(fmt.Errorf("%s can't be used with --inputDir", c.name))
+		}
+	}
+
+	stat, err := os.Stat(flags.InputDir)
+	if err != nil {
+		return inputNotFoundError(fmt.Errorf("input directory %q doesn't exist", flags.InputDir))
+	}
+	if !stat.IsDir() {
+		return usageError(fmt.Errorf("input directory %q is not a directory", flags.InputDir))
+	}
+	return nil
+}
+
+// collectDiagramFiles returns the sorted .mmd/.mermaid files in dir, descending into
+// subdirectories only if recursive is set.
+func collectDiagramFiles(dir string, recursive bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if diagramFileExtensions[strings.ToLower(filepath.Ext(path))] {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input directory: %w", err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// batchOutputPath maps a file under inputDir to the same relative path under outputDir,
+// with its extension replaced by the output format, e.g. (diagrams, images,
+// diagrams/flows/login.mmd, png) -> images/flows/login.png.
+func batchOutputPath(inputDir, outputDir, file, outputFormat string) (string, error) {
+	rel, err := filepath.Rel(inputDir, file)
+	if err != nil {
+		return "", fmt.Errorf("failed to map %q to the output directory: %w", file, err)
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q is not inside input directory %q", file, inputDir)
+	}
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel)) + "." + outputFormat
+	return filepath.Join(outputDir, rel), nil
+}
+
+// renderDir renders every diagram file in inputDir into outputDir, continuing past
+// failures. It logs one line per file and returns an error naming how many failed,
+// with the browser exit code if the browser couldn't start and the render one otherwise.
+func renderDir(ctx context.Context, r diagramRenderer, inputDir, outputDir string, recursive bool, outputFormat string, opts renderer.RenderOpts, summary *renderSummary, lg logger) error {
+	files, err := collectDiagramFiles(inputDir, recursive)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		lg.logf(levelInfo, "No .mmd or .mermaid files found in %s", inputDir)
+		return nil
+	}
+	lg.logf(levelInfo, "Found %d mermaid files in %s", len(files), inputDir)
+
+	failed := 0
+	code := exitRender
+	for _, file := range files {
+		outputFile, err := renderDirFile(ctx, r, inputDir, outputDir, file, outputFormat, opts, summary)
+		if err != nil {
+			failed++
+			if errors.Is(err, renderer.ErrBrowserStart) {
+				code = exitBrowser
+			}
+			lg.logf(levelInfo, " ❌ %s: %v", file, err)
+			continue
+		}
+		lg.logf(levelInfo, " ✅ %s", outputFile)
+	}
+
+	lg.logf(levelInfo, "%s", summary)
+	if failed > 0 {
+		return &exitError{code: code, err: fmt.Errorf("%d of %d diagrams failed to render", failed, len(files))}
+	}
+	return nil
+}
+
+// renderDirFile renders one file of a --inputDir run and returns the path it was written to.
+func renderDirFile(ctx context.Context, r diagramRenderer, inputDir, outputDir, file, outputFormat string, opts renderer.RenderOpts, summary *renderSummary) (string, error) {
+	outputFile, err := batchOutputPath(inputDir, outputDir, file, outputFormat)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	result, err := r.Render(ctx, string(data), outputFormat, opts)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputFile), 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %w", err)
+	}
+	if err := summary.writeDiagram(outputFile, result.Data); err != nil {
+		return "", fmt.Errorf("failed to write output file %q: %w", outputFile, err)
+	}
+	return outputFile, nil
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+func TestBatchOutputPath(t *testing.T) {
+	tests := []struct {
+		inputDir, outputDir, file, format string
+		want                              string
+	}{
+		{"diagrams", "images", "diagrams/login.mmd", "svg", "images/login.svg"},
+		{"diagrams", "images", "diagrams/flows/login.mermaid", "png", "images/flows/login.png"},
+		{"diagrams/", "out", "diagrams/a/b/c.mmd", "pdf", "out/a/b/c.pdf"},
+		{"diagrams", "diagrams", "diagrams/x.MMD", "jpeg", "diagrams/x.jpeg"},
+		{".", "build", "flow.mmd", "svg", "build/flow.svg"},
+	}
+	for _, tt := range tests {
+		got, err := batchOutputPath(filepath.FromSlash(tt.inputDir), filepath.FromSlash(tt.outputDir), filepath.FromSlash(tt.file), tt.format)
+		if err != nil {
+			t.Errorf("batchOutputPath(%q, %q, %q) error: %v", tt.inputDir, tt.outputDir, tt.file, err)
+			continue
+		}
+		if got != filepath.FromSlash(tt.want) {
+			t.Errorf("batchOutputPath(%q, %q, %q) = %q, want %q", tt.inputDir, tt.outputDir, tt.file, got, tt.want)
+		}
+	}
+
+	if _, err := batchOutputPath("diagrams", "images", filepath.FromSlash("other/a.mmd"), "svg"); err == nil {
+		t.Error("expected error for a file outside the input directory")
+	}
+}
+
+func TestCollectDiagramFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.mmd":             "",
+		"b.mermaid":         "",
+		"README.md":         "",
+		"notes.txt":         "",
+		"sub/c.mmd":         "",
+		"sub/deeper/d.mmd":  "",
+		"sub/deeper/e.json": "",
+	})
+
+	rel := func(files []string) string {
+		var names []string
+		for _, f := range files {
+			r, _ := filepath.Rel(dir, f)
+			names = append(names, filepath.ToSlash(r))
+		}
+		return strings.Join(names, ",")
+	}
+
+	files, err := collectDiagramFiles(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rel(files); got != "a.mmd,b.mermaid" {
+		t.Errorf("non-recursive = %s, want a.mmd,b.mermaid", got)
+	}
+
+	files, err = collectDiagramFiles(dir, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := rel(files); got != "a.mmd,b.mermaid,sub/c.mmd,sub/deeper/d.mmd" {
+		t.Errorf("recursive = %s", got)
+	}
+}
+
+func TestValidateBatchFlags(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.mmd")
+	writeFiles(t, dir, map[string]string{"a.mmd": ""})
+
+	tests := []struct {
+		name    string
+		flags   Flags
+		wantErr string
+	}{
+		{"single input", Flags{Input: "a.mmd"}, ""},
+		{"directory", Flags{InputDir: dir, OutputDir: "out", Recursive: true}, ""},
+		{"outputDir alone", Flags{OutputDir: "out"}, "only be used with --inputDir"},
+		{"recursive alone", Flags{Recursive: true}, "only be used with --inputDir"},
+		{"with --input", Flags{InputDir: dir, Input: "a.mmd"}, "--input can't be used"},
+		{"with --output", Flags{InputDir: dir, Output: "a.svg"}, "--output can't be used"},
+		{"with --definition", Flags{InputDir: dir, Definition: "graph TD; A-->B"}, "--definition can't be used"},
+		{"missing directory", Flags{InputDir: filepath.Join(dir, "missing")}, "doesn't exist"},
+		{"file", Flags{InputDir: file}, "not a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBatchFlags(&tt.flags)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// closableCheckRenderer adds a no-op Close to fakeCheckRenderer.
+type closableCheckRenderer struct{ fakeCheckRenderer }
+
+func (closableCheckRenderer) Close() {}
+
+func TestRenderDir(t *testing.T) {
+	in := t.TempDir()
+	out := filepath.Join(t.TempDir(), "images")
+	writeFiles(t, in, map[string]string{
+		"ok.mmd":         "graph TD; A-->B",
+		"nested/ok.mmd":  "graph TD; A-->B",
+		"nested/bad.mmd": "graph TD; broken",
+		"skip.md":        "```mermaid\ngraph TD; A-->B\n```",
+	})
+
+	summary := newRenderSummary()
+	err := renderDir(context.Background(), closableCheckRenderer{}, in, out, true, "svg", renderer.RenderOpts{}, summary, logger{level: levelError, w: io.Discard})
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 diagrams failed") {
+		t.Errorf("error = %v, want 1 of 3 failed", err)
+	}
+
+	for _, name := range []string{"ok.svg", "nested/ok.svg"} {
+		if _, err := os.Stat(filepath.Join(out, filepath.FromSlash(name))); err != nil {
+			t.Errorf("expected %s to be written: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "nested", "bad.svg")); !os.IsNotExist(err) {
+		t.Error("expected no output for the failed diagram")
+	}
+	if summary.diagrams != 2 {
+		t.Errorf("summary counted %d diagrams, want 2", summary.diagrams)
+	}
+}
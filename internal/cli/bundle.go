@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// zipBundle collects output files into an in-memory zip archive, naming each entry by
+// its path relative to root so links between the files keep working inside the archive.
+type zipBundle struct {
+	root string
+	buf  bytes.Buffer
+	zw   *zip.Writer
+}
+
+// newZipBundle creates an empty bundle whose entries are relative to root.
+func newZipBundle(root string) *zipBundle {
+	b := &zipBundle{root: filepath.Clean(root)}
+	b.zw = zip.NewWriter(&b.buf)
+	return b
+}
+
+// add stores data under path, which must lie within the bundle root.
+func (b *zipBundle) add(path string, data []byte) error {
+	rel, err := filepath.Rel(b.root, filepath.Clean(path))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%q is outside the archive root %q", path, b.root)
+	}
+
+	w, err := b.zw.Create(filepath.ToSlash(rel))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// bytes finalizes the archive and returns its contents.
+func (b *zipBundle) bytes() ([]byte, error) {
+	if err := b.zw.Close(); err != nil {
+		return nil, err
+	}
+	return b.buf.Bytes(), nil
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/coolamit/mermaid-cli/internal/markdown"
+)
+
+func TestZipBundle(t *testing.T) {
+	root := filepath.Join("docs", "out")
+	b := newZipBundle(root)
+
+	doc := "# Doc\n\n```mermaid\ngraph TD; A-->B\n```\n\n```mermaid\ngraph TD; C-->D\n```\n"
+	refs := []markdown.ImageRef{
+		{URL: "./bundle-1.svg"},
+		{URL: "./bundle-2.svg"},
+	}
+	for i, ref := range refs {
+		data := []byte("<svg>" + string(rune('1'+i)) + "</svg>")
+		if err := b.add(filepath.Join(root, strings.TrimPrefix(ref.URL, "./")), data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	processed, err := markdown.Process(doc, func(block markdown.DiagramBlock) (markdown.RenderResult, error) {
+		return markdown.RenderResult{URL: refs[block.Index-1].URL}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.add(filepath.Join(root, "bundle.md"), []byte(processed.Content)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := b.bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string][]byte{}
+	var names []string
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = content
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if want := []string{"bundle-1.svg", "bundle-2.svg", "bundle.md"}; strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("archive entries = %v, want %v", names, want)
+	}
+
+	// Every image link in the markdown must point at an entry in the archive
+	links := regexp.MustCompile(`!\[[^\]]*\]\(([^) ]+)`).FindAllStringSubmatch(string(files["bundle.md"]), -1)
+	if len(links) != 2 {
+		t.Fatalf("expected 2 image links, got %d in %q", len(links), files["bundle.md"])
+	}
+	for _, link := range links {
+		if _, ok := files[path.Clean(link[1])]; !ok {
+			t.Errorf("image link %q doesn't resolve within the archive", link[1])
+		}
+	}
+}
+
+func TestZipBundleRejectsPathsOutsideRoot(t *testing.T) {
+	b := newZipBundle("out")
+	if err := b.add(filepath.Join("elsewhere", "diagram.svg"), []byte("<svg/>")); err == nil {
+		t.Error("expected error for a path outside the archive root")
+	}
+}
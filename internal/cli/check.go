@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/coolamit/mermaid-cli/internal/config"
+	"github.com/coolamit/mermaid-cli/internal/markdown"
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+	"github.com/spf13/cobra"
+)
+
+// checkFailure is a single diagram that failed to render.
+type checkFailure struct {
+	Path    string `json:"path"`
+	Diagram int    `json:"diagram,omitempty"`
+	Error   string `json:"error"`
+}
+
+// checkReport is the machine-readable result of a `check` run.
+type checkReport struct {
+	Files    int            `json:"files"`
+	Diagrams int            `json:"diagrams"`
+	Failed   int            `json:"failed"`
+	Failures []checkFailure `json:"failures"`
+}
+
+// record adds the outcome of rendering one diagram. diagram is the 1-based index within
+// a markdown file, or 0 for a standalone diagram file.
+func (r *checkReport) record(path string, diagram int, err error) {
+	r.Diagrams++
+	if err == nil {
+		return
+	}
+	r.Failed++
+	r.Failures = append(r.Failures, checkFailure{Path: path, Diagram: diagram, Error: err.Error()})
+}
+
+// exitCode maps the report to the check exit code contract.
+func (r *checkReport) exitCode() int {
+	if r.Failed > 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// newCheckCommand creates the `check` subcommand, which renders every diagram matched by
+// the given glob patterns and reports failures for CI gating.
+func newCheckCommand() *cobra.Command {
+	var configFiles []string
+	var jsonc bool
+	var browserConfigFile string
+	var timeout int
+	var quiet bool
+
+	cmd := &cobra.Command{
+		Use:   "check <pattern>...",
+		Short: "Check that every matched diagram renders, for CI",
+		Long: "Renders every .mmd/.mermaid file and every mermaid block in .md/.markdown files matched by " +
+			"the glob patterns (** matches any number of directories) and prints a JSON report of failures.\n\n" +
+			"Exit codes: 0 = all diagrams rendered, 1 = some failed, 2 = usage error.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return usageError(fmt.Errorf("at least one file pattern is required"))
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files, err := expandPatterns(args)
+			if err != nil {
+				return usageError(err)
+			}
+			if len(files) == 0 {
+				return usageError(fmt.Errorf("no files match %s", strings.Join(args, " ")))
+			}
+
+			mermaidConfig, err := config.LoadMermaidConfig(configFiles, "default", jsonc)
+			if err != nil {
+				return usageError(err)
+			}
+			browserConfig, err := config.LoadBrowserConfig(browserConfigFile)
+			if err != nil {
+				return usageError(err)
+			}
+
+			opts := renderer.RenderOpts{
+				MermaidConfig:   mermaidConfig,
+				BackgroundColor: "white",
+				Scale:           1,
+				Timeout:         renderTimeout(timeout, browserConfig),
+			}
+
+			r := renderer.NewRenderer(renderer.NewBrowser(browserConfig))
+			defer r.Close()
+
+			report := runCheck(context.Background(), r, files, opts, newLogger(verbosityLevel(quiet, 0)))
+			if err := writeCheckReport(cmd.OutOrStdout(), report); err != nil {
+				return err
+			}
+			if code := report.exitCode(); code != exitOK {
+				return &exitError{code: code, err: fmt.Errorf("%d of %d diagrams failed to render", report.Failed, report.Diagrams)}
+			}
+			return nil
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError(err)
+	})
+
+	cmd.Flags().StringArrayVarP(&configFiles, "configFile", "c", nil, "JSON (or .yaml) configuration file for mermaid. Can be repeated")
+	cmd.Flags().BoolVar(&jsonc, "jsonc", false, "Allow // and /* */ comments in JSON config files, as in .jsonc files")
+	cmd.Flags().StringVarP(&browserConfigFile, "puppeteerConfigFile", "p", "", "JSON configuration file for the browser")
+	cmd.Flags().IntVar(&timeout, "timeout", 0, "Per-diagram render timeout in milliseconds. Default: the browser config \"timeout\", else 60000")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress per-file log output")
+
+	return cmd
+}
+
+// checkRenderer is the subset of the renderer used by runCheck.
+type checkRenderer interface {
+	Render(ctx context.Context, definition string, outputFormat string, opts renderer.RenderOpts) (*renderer.RenderResult, error)
+}
+
+// runCheck renders every diagram in files and collects the outcome.
+func runCheck(ctx context.Context, r checkRenderer, files []string, opts renderer.RenderOpts, lg logger) *checkReport {
+	report := &checkReport{Failures: []checkFailure{}}
+
+	for _, file := range files {
+		report.Files++
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			report.record(file, 0, fmt.Errorf("failed to read file: %w", err))
+			lg.logf(levelInfo, " ❌ %s: %v", file, err)
+			continue
+		}
+
+		if !markdownExtRegex.MatchString(file) {
+			_, err := r.Render(ctx, string(data), "svg", opts)
+			report.record(file, 0, err)
+			logCheck(lg, file, err)
+			continue
+		}
+
+		for _, diagram := range markdown.ExtractDiagrams(string(data)) {
+			_, err := r.Render(ctx, diagram.Definition, "svg", opts)
+			report.record(file, diagram.Index, err)
+			logCheck(lg, fmt.Sprintf("%s#%d", file, diagram.Index), err)
+		}
+	}
+
+	return report
+}
+
+func logCheck(lg logger, name string, err error) {
+	if err != nil {
+		lg.logf(levelInfo, " ❌ %s: %v", name, err)
+		return
+	}
+	lg.logf(levelInfo, " ✅ %s", name)
+}
+
+// writeCheckReport prints the report as indented JSON.
+func writeCheckReport(w io.Writer, report *checkReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// checkFileExtensions are the files `check` picks up when a pattern matches a directory tree.
+var checkFileExtensions = map[string]bool{".mmd": true, ".mermaid": true, ".md": true, ".markdown": true}
+
+// expandPatterns resolves glob patterns to a sorted, de-duplicated list of diagram files.
+// Besides the usual filepath.Match syntax, a "**" path segment matches any number of
+// directories. Patterns without wildcards are taken as literal paths.
+func expandPatterns(patterns []string) ([]string, error) {
+	seen := map[string]bool{}
+	var files []string
+	add := func(p string) {
+		if !seen[p] && checkFileExtensions[strings.ToLower(filepath.Ext(p))] {
+			seen[p] = true
+			files = append(files, p)
+		}
+	}
+
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "**") {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+			for _, m := range matches {
+				add(m)
+			}
+			continue
+		}
+
+		if _, err := path.Match(filepath.ToSlash(pattern), ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		root := globRoot(pattern)
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && matchGlob(filepath.ToSlash(pattern), filepath.ToSlash(p)) {
+				add(p)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// globRoot returns the directory a "**" pattern has to be walked from: its longest
+// leading run of segments without wildcards.
+func globRoot(pattern string) string {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	var fixed []string
+	for _, s := range segments {
+		if strings.ContainsAny(s, "*?[\\") {
+			break
+		}
+		fixed = append(fixed, s)
+	}
+	if len(fixed) == 0 {
+		return "."
+	}
+	root := strings.Join(fixed, "/")
+	if root == "" {
+		return "/"
+	}
+	return filepath.FromSlash(root)
+}
+
+// matchGlob reports whether a slash-separated name matches pattern, where a "**"
+// segment matches zero or more path segments.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(path.Clean(pattern), "/"), strings.Split(path.Clean(name), "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
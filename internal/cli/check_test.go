@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+// fakeCheckRenderer fails any definition containing "broken".
+type fakeCheckRenderer struct{}
+
+func (fakeCheckRenderer) Render(ctx context.Context, definition string, outputFormat string, opts renderer.RenderOpts) (*renderer.RenderResult, error) {
+	if strings.Contains(definition, "broken") {
+		return nil, errors.New("mermaid rendering error: Parse error")
+	}
+	return &renderer.RenderResult{Data: []byte("<svg/>")}, nil
+}
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"ok.mmd":  "graph TD; A-->B",
+		"bad.mmd": "graph TD; broken",
+		"doc.md":  "```mermaid\ngraph TD; A-->B\n```\n\n```mermaid\nbroken\n```\n",
+	})
+	files := []string{
+		filepath.Join(dir, "bad.mmd"),
+		filepath.Join(dir, "doc.md"),
+		filepath.Join(dir, "ok.mmd"),
+	}
+
+	report := runCheck(context.Background(), fakeCheckRenderer{}, files, renderer.RenderOpts{}, logger{level: levelError, w: io.Discard})
+
+	if report.Files != 3 || report.Diagrams != 4 || report.Failed != 2 {
+		t.Errorf("unexpected totals: %+v", report)
+	}
+	want := []checkFailure{
+		{Path: files[0], Error: "mermaid rendering error: Parse error"},
+		{Path: files[1], Diagram: 2, Error: "mermaid rendering error: Parse error"},
+	}
+	if fmt.Sprint(report.Failures) != fmt.Sprint(want) {
+		t.Errorf("failures = %+v, want %+v", report.Failures, want)
+	}
+	if report.exitCode() != exitFailed {
+		t.Errorf("exitCode() = %d, want %d", report.exitCode(), exitFailed)
+	}
+
+	var buf bytes.Buffer
+	if err := writeCheckReport(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	var decoded checkReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("report is not valid JSON: %v\n%s", err, buf.String())
+	}
+	if decoded.Failed != 2 || len(decoded.Failures) != 2 {
+		t.Errorf("unexpected decoded report %+v", decoded)
+	}
+}
+
+func TestRunCheck_AllPass(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"ok.mmd": "graph TD; A-->B"})
+
+	report := runCheck(context.Background(), fakeCheckRenderer{}, []string{filepath.Join(dir, "ok.mmd")}, renderer.RenderOpts{}, logger{level: levelError, w: io.Discard})
+	if report.exitCode() != exitOK {
+		t.Errorf("exitCode() = %d, want %d", report.exitCode(), exitOK)
+	}
+
+	var buf bytes.Buffer
+	_ = writeCheckReport(&buf, report)
+	if !strings.Contains(buf.String(), `"failures": []`) {
+		t.Errorf("expected an empty failures array, got %s", buf.String())
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"**/*.mmd", "a.mmd", true},
+		{"**/*.mmd", "docs/a/b.mmd", true},
+		{"docs/**/*.mmd", "docs/a.mmd", true},
+		{"docs/**/*.mmd", "docs/x/y/a.mmd", true},
+		{"docs/**/*.mmd", "other/a.mmd", false},
+		{"docs/**", "docs/x/a.md", true},
+		{"*.mmd", "docs/a.mmd", false},
+		{"docs/**/a?.mmd", "docs/x/ab.mmd", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExpandPatterns(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"a.mmd":           "",
+		"notes.txt":       "",
+		"docs/b.mermaid":  "",
+		"docs/guide.md":   "",
+		"docs/deep/c.mmd": "",
+	})
+
+	files, err := expandPatterns([]string{
+		filepath.Join(dir, "docs", "**", "*"),
+		filepath.Join(dir, "*.mmd"),
+		filepath.Join(dir, "docs", "deep", "c.mmd"), // duplicate of a ** match
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rel []string
+	for _, f := range files {
+		r, _ := filepath.Rel(dir, f)
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	want := "a.mmd,docs/b.mermaid,docs/deep/c.mmd,docs/guide.md"
+	if strings.Join(rel, ",") != want {
+		t.Errorf("expandPatterns() = %v, want %s", rel, want)
+	}
+
+	if _, err := expandPatterns([]string{"[bad"}); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+}
@@ -6,13 +6,19 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/coolamit/mermaid-cli/internal/config"
+	"github.com/coolamit/mermaid-cli/internal/daemon"
 	"github.com/coolamit/mermaid-cli/internal/icons"
 	"github.com/coolamit/mermaid-cli/internal/markdown"
+	"github.com/coolamit/mermaid-cli/internal/rendercache"
 	"github.com/coolamit/mermaid-cli/internal/renderer"
+	"github.com/coolamit/mermaid-cli/web"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // Version is set at build time.
@@ -21,23 +27,143 @@ var Version = "dev"
 // Flags holds all CLI flag values.
 type Flags struct {
 	Input                 string
+	Definition            string
+	Frames                []string
+	FrameDelay            int
+	InputFormat           string
 	Output                string
 	Artefacts             string
+	ArtefactsRelativeTo   string
+	ImageDir              string
 	Theme                 string
+	Preset                string
+	SecurityLevel         string
+	FontFamily            string
+	FontURLs              []string
 	Width                 int
 	Height                int
 	BackgroundColor       string
 	OutputFormat          string
-	Scale                 int
+	Scale                 float64
 	PdfFit                bool
+	PageRanges            string
+	DPI                   int
+	Clip                  string
+	Watermark             string
+	WatermarkPosition     string
+	WatermarkOpacity      float64
 	SvgFit                bool
+	SVGWidth              string
+	SVGHeight             string
+	SVGDecl               bool
+	SVGMode               string
+	Sanitize              bool
+	CleanSVG              bool
+	CleanSVGAttrs         []string
+	DeterministicIDs      bool
+	InputDir              string
+	OutputDir             string
+	Recursive             bool
+	Stream                bool
+	StreamDelimiter       string
+	AutoSize              bool
+	MinWidth              int
+	MaxWidth              int
+	NoZenUML              bool
+	DiagramType           string
+	PNGColors             int
+	DataURI               bool
 	SVGId                 string
-	ConfigFile            string
-	CSSFile               string
+	ConfigFiles           []string
+	NoConfig              bool
+	CSSFiles              []string
+	CSSScope              string
 	PuppeteerConfigFile   string
+	JSONC                 bool
+	ValidateConfig        bool
+	StrictConfig          bool
+	ExpandEnv             bool
+	StrictEnv             bool
+	BrowserFlags          []string
+	TabPool               int
+	Browsers              int
+	Sandbox               bool
 	IconPacks             []string
 	IconPacksNamesAndUrls []string
+	IconCDN               string
+	StrictIcons           bool
+	MaxOutputSize         string
+	Timeout               int
+	StdinTimeout          int
+	SettleDelay           int
+	WaitFor               string
+	WaitForFunction       string
+	PostScript            string
+	NameByTitle           bool
+	Diagram               int
+	Force                 bool
 	Quiet                 bool
+	Verbose               int
+	DumpHTML              string
+	CacheDir              string
+	Incremental           bool
+	Manifest              string
+	Baseline              string
+	DiffDir               string
+	FailOnChange          bool
+	MermaidJS             string
+	MermaidZenUMLJS       string
+	Daemon                bool
+	Socket                string
+	ListThemes            bool
+	ListFormats           bool
+
+	// set holds the names of the flags given on the command line. It is nil when the
+	// Flags weren't parsed from a command line, and every value then counts as given.
+	set map[string]bool
+}
+
+// markSetFlags records which flags were given on cmd's command line, so that defaults
+// can be told apart from the same value given explicitly.
+func markSetFlags(cmd *cobra.Command, flags *Flags) {
+	flags.set = map[string]bool{}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		flags.set[f.Name] = true
+	})
+}
+
+// isSet reports whether the flag called name was given explicitly.
+func (f *Flags) isSet(name string) bool {
+	return f.set == nil || f.set[name]
+}
+
+// optionSet returns the render options that were given explicitly.
+func (f *Flags) optionSet() renderer.OptionSet {
+	var set renderer.OptionSet
+	for name, option := range map[string]renderer.OptionSet{
+		"width":           renderer.SetWidth,
+		"height":          renderer.SetHeight,
+		"scale":           renderer.SetScale,
+		"backgroundColor": renderer.SetBackgroundColor,
+	} {
+		if f.isSet(name) {
+			set |= option
+		}
+	}
+	return set
+}
+
+// pageSize returns the --width and --height to render with; a side that wasn't given
+// is 0, leaving it to the size suited to the diagram type.
+func pageSize(flags *Flags) (width, height int) {
+	set := flags.optionSet()
+	if set.Has(renderer.SetWidth) {
+		width = flags.Width
+	}
+	if set.Has(renderer.SetHeight) {
+		height = flags.Height
+	}
+	return width, height
 }
 
 // NewRootCommand creates the cobra root command with all flags.
@@ -47,299 +173,1244 @@ func NewRootCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:     "mmd-cli",
 		Short:   "Mermaid CLI - Generate diagrams from mermaid definitions",
-		Long:    "A CLI tool to convert mermaid diagram definitions into SVG, PNG, and PDF files.",
+		Long:    "A CLI tool to convert mermaid diagram definitions into SVG, PNG, JPEG, and PDF files.",
 		Version: Version,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			// Listing the supported values needs no input and no browser
+			if flags.ListThemes || flags.ListFormats {
+				return listValues(cmd.OutOrStdout(), flags.ListThemes, flags.ListFormats)
+			}
+			markSetFlags(cmd, flags)
 			return run(flags)
 		},
 		SilenceUsage:  true,
 		SilenceErrors: true,
 	}
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError(err)
+	})
 
 	// Define flags to match the official mermaid-cli exactly
-	cmd.Flags().StringVarP(&flags.Input, "input", "i", "", "Input mermaid file. Files ending in .md will be treated as Markdown. Use `-` to read from stdin.")
-	cmd.Flags().StringVarP(&flags.Output, "output", "o", "", "Output file. It should be either md, svg, png, pdf or use `-` for stdout. Default: input + \".svg\"")
+	cmd.Flags().StringVarP(&flags.Input, "input", "i", "", "Input mermaid file or http(s)/file URL. Files ending in .md will be treated as Markdown. Use `-` to read from stdin.")
+	cmd.Flags().StringVarP(&flags.Definition, "definition", "D", "", "Diagram definition to render, given inline instead of an --input file, e.g. -D \"graph TD; A-->B\"")
+	cmd.Flags().StringArrayVar(&flags.Frames, "frames", nil, "Diagram file to render as a frame of gif output. Repeat it for each frame, in order. Without it, gif output animates the blocks of a Markdown input")
+	cmd.Flags().IntVar(&flags.FrameDelay, "frameDelay", int(renderer.DefaultFrameDelay/time.Millisecond), "How long each frame of gif output is shown, in milliseconds")
+	cmd.Flags().StringVar(&flags.InputFormat, "inputFormat", "auto", "How to treat the input: mermaid, markdown, or auto (by file extension, sniffing the content of stdin for mermaid fences)")
+	cmd.Flags().StringVarP(&flags.Output, "output", "o", "", "Output file. It should be either md, svg, png, jpg, pdf or use `-` for stdout. Default: input + \".svg\"")
+	cmd.Flags().StringVar(&flags.InputDir, "inputDir", "", "Render every .mmd/.mermaid file in this directory instead of a single --input")
+	cmd.Flags().StringVar(&flags.OutputDir, "outputDir", "", "Directory to write --inputDir renders to, mirroring the input structure. Default: --inputDir")
+	cmd.Flags().BoolVar(&flags.Recursive, "recursive", false, "Also render files in subdirectories of --inputDir")
+	cmd.Flags().BoolVar(&flags.Stream, "stream", false, "Render diagrams read from stdin one after another, separated by --streamDelimiter lines, printing one line per diagram: its numbered output file, its data URI with --dataUri, or \"error: ...\"")
+	cmd.Flags().StringVar(&flags.StreamDelimiter, "streamDelimiter", defaultStreamDelimiter, "Line separating diagrams on stdin with --stream")
 	cmd.Flags().StringVarP(&flags.Artefacts, "artefacts", "a", "", "Output artefacts path. Only used with Markdown input.")
-	cmd.Flags().StringVarP(&flags.Theme, "theme", "t", "default", "Theme of the chart (default, forest, dark, neutral)")
-	cmd.Flags().IntVarP(&flags.Width, "width", "w", 800, "Width of the page")
-	cmd.Flags().IntVarP(&flags.Height, "height", "H", 600, "Height of the page")
-	cmd.Flags().StringVarP(&flags.BackgroundColor, "backgroundColor", "b", "white", "Background color for pngs/svgs (not pdfs). Example: transparent, red, '#F0F0F0'.")
-	cmd.Flags().StringVarP(&flags.OutputFormat, "outputFormat", "e", "", "Output format for the generated image (svg, png, pdf). Default: from output file extension")
-	cmd.Flags().IntVarP(&flags.Scale, "scale", "s", 1, "Scale factor")
+	cmd.Flags().StringVar(&flags.ArtefactsRelativeTo, "artefactsRelativeTo", "cwd", "Directory a relative --artefacts path is resolved against: cwd (the current directory) or input (the input file's directory)")
+	cmd.Flags().StringVar(&flags.ImageDir, "imageDir", "", "For Markdown output, directory to write the images to, relative to the output file's directory. Links in the markdown point there")
+	cmd.Flags().IntVar(&flags.Diagram, "diagram", 0, "Render only the Nth (1-based) mermaid block of a Markdown input to the output file")
+	cmd.Flags().BoolVar(&flags.NameByTitle, "nameByTitle", false, "Name Markdown diagram images after their title (accTitle or frontmatter title) instead of their index")
+	cmd.Flags().StringVarP(&flags.Theme, "theme", "t", "default", "Theme of the chart ("+strings.Join(mermaidThemes, ", ")+")")
+	cmd.Flags().StringVar(&flags.Preset, "preset", "", "Built-in look: a theme, theme variables and CSS ("+strings.Join(config.PresetNames(), ", ")+"). --theme, --backgroundColor, --cssFile and config files override it")
+	cmd.Flags().StringVar(&flags.SecurityLevel, "securityLevel", "", "Mermaid securityLevel (strict, loose, antiscript, sandbox), overriding the config file. Default: the config file's, else strict")
+	cmd.Flags().StringVar(&flags.FontFamily, "fontFamily", "", "Font family for diagram text, e.g. \"Inter, sans-serif\". A fontFamily in --configFile takes precedence")
+	cmd.Flags().StringArrayVar(&flags.FontURLs, "fontUrl", nil, "Stylesheet URL to load web fonts from, e.g. a Google Fonts CSS URL. Rendering waits for the fonts to load. Can be repeated")
+	cmd.Flags().IntVarP(&flags.Width, "width", "w", renderer.DefaultWidth, "Width of the page. When not given, diagram types that need it, such as gitGraph and timeline, get a wider page")
+	cmd.Flags().IntVarP(&flags.Height, "height", "H", renderer.DefaultHeight, "Height of the page. When not given, diagram types that need it, such as mindmap, get a taller page")
+	cmd.Flags().StringVarP(&flags.BackgroundColor, "backgroundColor", "b", defaultBackgroundColor, "Background color for pngs/svgs (not pdfs). Example: transparent, red, '#F0F0F0', '#00000080', 'rgba(0,0,0,0.5)'. CSS background images such as 'linear-gradient(...)' are painted behind the diagram instead; svg output then has no background.")
+	cmd.Flags().StringVarP(&flags.OutputFormat, "outputFormat", "e", "", "Output format for the generated image (svg, png, jpeg, pdf). Case-insensitive, jpg is an alias for jpeg. Default: from output file extension")
+	cmd.Flags().Float64VarP(&flags.Scale, "scale", "s", 1, "Device scale factor, e.g. 2 or 1.5: the pixel density of PNG and JPEG output, and of the rasterized parts of PDF output")
 	cmd.Flags().BoolVarP(&flags.PdfFit, "pdfFit", "f", false, "Scale PDF to fit chart")
+	cmd.Flags().IntVar(&flags.DPI, "dpi", renderer.DefaultDPI, "Resolution of PDF output: the diagram's pixels per inch of paper")
+	cmd.Flags().StringVar(&flags.Clip, "clip", "", "Capture only the X,Y,W,H rectangle of the diagram, in pixels from its top-left corner. png, jpeg and pdf with --pdfFit only")
+	cmd.Flags().StringVar(&flags.Watermark, "watermark", "", "PNG or JPEG image, such as a logo, to draw over a corner of png, jpeg and svg output")
+	cmd.Flags().StringVar(&flags.WatermarkPosition, "watermarkPosition", renderer.DefaultWatermarkPosition, "Corner for --watermark: top-left, top-right, bottom-left or bottom-right")
+	cmd.Flags().Float64Var(&flags.WatermarkOpacity, "watermarkOpacity", 1, "Opacity of --watermark, from 0 to 1")
+	cmd.Flags().StringVar(&flags.PageRanges, "pageRanges", "", "PDF pages to emit, e.g. 1-3,5. Overrides the single page forced by --pdfFit")
 	cmd.Flags().BoolVar(&flags.SvgFit, "svgFit", false, "Set SVG dimensions to match diagram size (for standalone viewing)")
+	cmd.Flags().StringVar(&flags.SVGWidth, "svgWidth", "", "Set the SVG width attribute to an explicit length, e.g. 300px, 80mm, 10cm")
+	cmd.Flags().StringVar(&flags.SVGHeight, "svgHeight", "", "Set the SVG height attribute to an explicit length, e.g. 200px, 60mm, 8cm")
+	cmd.Flags().StringVar(&flags.SVGMode, "svgMode", "", "Post-process SVG output for where it is used: standalone (XML declaration plus xmlns and xmlns:xlink, for .svg files) or inline (no declaration or namespaces, for embedding in HTML5 with innerHTML)")
+	cmd.Flags().BoolVar(&flags.SVGDecl, "svgDecl", false, "Start SVG output with an <?xml ...?> declaration and make sure it declares the SVG namespace, for strict XML consumers")
+	cmd.Flags().BoolVar(&flags.Sanitize, "sanitize", false, "Remove <script> elements, event handler attributes and javascript: links from SVG output, for embedding in untrusted contexts")
+	cmd.Flags().BoolVar(&flags.CleanSVG, "cleanSvg", false, "Strip inline event handlers, javascript: links and empty class/style attributes from SVG output")
+	cmd.Flags().BoolVar(&flags.DeterministicIDs, "deterministicIds", false, "Rewrite the ids mermaid generates in SVG output, some of them random, to stable values derived from the diagram, so renders of the same diagram are byte-identical")
+	cmd.Flags().StringArrayVar(&flags.CleanSVGAttrs, "cleanSvgAttr", nil, "Extra attribute for --cleanSvg to strip, e.g. aria-roledescription. Can be repeated")
+	cmd.Flags().BoolVar(&flags.AutoSize, "autoSize", false, "Size the page to the rendered diagram instead of --width/--height; also sets the SVG width and height")
+	cmd.Flags().IntVar(&flags.MinWidth, "minWidth", 0, "Minimum page width in pixels with --autoSize. Default: no minimum")
+	cmd.Flags().IntVar(&flags.MaxWidth, "maxWidth", 0, "Maximum page width in pixels with --autoSize; wider diagrams are scaled down. Default: no maximum")
+	cmd.Flags().BoolVar(&flags.DataURI, "dataUri", false, "Write the diagram as a base64 data URI (data:image/png;base64,...) instead of raw bytes, to stdout unless --output is given. The output file may end in .txt")
+	cmd.Flags().BoolVar(&flags.NoZenUML, "noZenuml", false, "Don't load the mermaid-zenuml external diagram, for faster page loads when no zenuml diagrams are rendered")
+	cmd.Flags().StringVar(&flags.DiagramType, "diagramType", "", "Diagram type that picks the default page size, overriding the one detected from the definition, e.g. gitGraph")
+	cmd.Flags().IntVar(&flags.PNGColors, "pngColors", 0, "Reduce png output to a palette of at most this many colors (2-256) for smaller files. Default: keep all colors")
 	cmd.Flags().StringVarP(&flags.SVGId, "svgId", "I", "", "The id attribute for the SVG element to be rendered")
-	cmd.Flags().StringVarP(&flags.ConfigFile, "configFile", "c", "", "JSON configuration file for mermaid")
-	cmd.Flags().StringVarP(&flags.CSSFile, "cssFile", "C", "", "CSS file for the page")
-	cmd.Flags().StringVarP(&flags.PuppeteerConfigFile, "puppeteerConfigFile", "p", "", "JSON configuration file for the browser")
+	cmd.Flags().StringArrayVarP(&flags.ConfigFiles, "configFile", "c", nil, "JSON (or .yaml) configuration file for mermaid. Can be repeated; later files are deep-merged over earlier ones. Use `-` to read from stdin.")
+	cmd.Flags().BoolVar(&flags.JSONC, "jsonc", false, "Allow // and /* */ comments in JSON config files, as in .jsonc files")
+	cmd.Flags().BoolVar(&flags.ValidateConfig, "validateConfig", false, "Warn about unknown keys and wrongly typed values in mermaid config files, checked against a bundled schema of mermaid options")
+	cmd.Flags().BoolVar(&flags.StrictConfig, "strictConfig", false, "Like --validateConfig, but fail instead of warning")
+	cmd.Flags().BoolVar(&flags.ExpandEnv, "expandEnv", false, "Expand ${VAR} and $VAR environment variables in mermaid config values, the browser config and CSS files. $$ is a literal $. Unset variables expand to nothing, or fail with --strictEnv")
+	cmd.Flags().BoolVar(&flags.StrictEnv, "strictEnv", false, "With --expandEnv, fail on unset environment variables instead of expanding them to nothing")
+	cmd.Flags().BoolVar(&flags.NoConfig, "no-config", false, "Don't look for .mermaidrc.json, .mermaidrc.yaml or mermaid.config.json when --configFile isn't given")
+	cmd.Flags().StringArrayVarP(&flags.CSSFiles, "cssFile", "C", nil, "CSS file for the page. Can be repeated; files are concatenated in order")
+	cmd.Flags().StringVar(&flags.CSSScope, "cssScope", renderer.CSSScopeSVG, "Where --cssFile applies: svg (a <style> inside the SVG, kept in SVG output) or page (the page <head>, also affecting layout and text measurement)")
+	cmd.Flags().StringVarP(&flags.PuppeteerConfigFile, "puppeteerConfigFile", "p", "", "JSON configuration file for the browser. Use `-` to read from stdin.")
+	cmd.Flags().StringArrayVar(&flags.BrowserFlags, "browserFlag", nil, "Extra Chrome command-line flag, e.g. --browserFlag=--lang=en-US. Can be repeated")
+	cmd.Flags().BoolVar(&flags.Sandbox, "sandbox", false, "Run Chrome with its sandbox enabled (needs user namespaces or a setuid sandbox helper; not available as root)")
+	cmd.Flags().IntVar(&flags.TabPool, "tabPool", 0, "Maximum number of browser tabs rendering concurrently. Default: the browser config \"tabPool\", else 4")
+	cmd.Flags().IntVar(&flags.Browsers, "browsers", 1, "Number of independent browser processes to spread renders over, each with --tabPool tabs, so a crash only fails that browser's renders")
 	cmd.Flags().StringSliceVar(&flags.IconPacks, "iconPacks", nil, "Icon packs to use, e.g. @iconify-json/logos")
 	cmd.Flags().StringSliceVar(&flags.IconPacksNamesAndUrls, "iconPacksNamesAndUrls", nil, "Icon packs with name#url format")
-	cmd.Flags().BoolVarP(&flags.Quiet, "quiet", "q", false, "Suppress log output")
+	cmd.Flags().StringVar(&flags.IconCDN, "iconCdn", icons.DefaultCDN, "CDN to load --iconPacks from: unpkg or jsdelivr. The other is tried if it fails")
+	cmd.Flags().BoolVar(&flags.StrictIcons, "strictIcons", false, "Fail the render if an icon pack can't be loaded, instead of warning and leaving its icons blank")
+	cmd.Flags().StringVar(&flags.MaxOutputSize, "maxOutputSize", "", "Fail if a rendered diagram exceeds this size, e.g. 10MB. Default: no limit")
+	cmd.Flags().IntVar(&flags.StdinTimeout, "stdinTimeout", int(defaultStdinTimeout/time.Millisecond), "Give up reading the diagram from stdin if nothing arrives within this many milliseconds. 0 waits forever")
+	cmd.Flags().IntVar(&flags.Timeout, "timeout", 0, "Per-diagram render timeout in milliseconds. Default: the browser config \"timeout\", else 60000")
+	cmd.Flags().IntVar(&flags.SettleDelay, "settleDelay", 0, "Extra delay in milliseconds after fonts and images have loaded, before capturing")
+	cmd.Flags().StringVar(&flags.WaitFor, "waitFor", "", "CSS selector of an element to wait for after the SVG appears, for diagrams that keep drawing asynchronously. Waits until --timeout")
+	cmd.Flags().StringVar(&flags.WaitForFunction, "waitForFunction", "", "JavaScript expression to wait for to be truthy after the SVG appears, e.g. \"document.querySelectorAll('#container .done').length > 2\". Waits until --timeout")
+	cmd.Flags().StringVar(&flags.PostScript, "postScript", "", "JavaScript file to run after mermaid renders each diagram and before it is captured, with the rendered <svg> element in a variable named svg, e.g. to add annotations or adjust the viewBox. It may use await")
+	cmd.Flags().BoolVar(&flags.Force, "force", false, "Write output even if --outputFormat doesn't match the output file extension")
+	cmd.Flags().BoolVarP(&flags.Quiet, "quiet", "q", false, "Only print errors and warnings")
+	cmd.Flags().StringVar(&flags.DumpHTML, "dumpHtml", "", "Write the HTML page loaded into the browser to this file, for debugging. Markdown inputs get one file per diagram (page-1.html, ...)")
+	cmd.Flags().StringVar(&flags.CacheDir, "cacheDir", "", "Reuse rendered diagrams from this directory when the definition, options and mermaid version are unchanged, and store new renders in it")
+	cmd.Flags().BoolVar(&flags.Incremental, "incremental", false, "For Markdown input, reuse the images of diagrams unchanged since the last run, tracked in a hidden manifest next to the output")
+	cmd.Flags().StringVar(&flags.Manifest, "manifest", "", "For Markdown input, write a JSON file describing each rendered diagram: block index, image file, dimensions, title and definition hash")
+	cmd.Flags().StringVar(&flags.Baseline, "baseline", "", "Directory of previously rendered diagrams, e.g. from the last release. Each output file is compared with the baseline file at the same path relative to the output directory and changed diagrams are reported")
+	cmd.Flags().StringVar(&flags.DiffDir, "diffDir", "", "With --baseline, write an image of the changed pixels of each changed png or jpeg diagram to this directory")
+	cmd.Flags().BoolVar(&flags.FailOnChange, "failOnChange", false, "With --baseline, exit with code 1 if any diagram changed since the baseline, e.g. to fail a CI job")
+	cmd.Flags().CountVarP(&flags.Verbose, "verbose", "V", "Print the diagram type and page size each diagram renders with, how long each render phase took, and the browser console output captured while rendering. Repeat (-VV) to also print input and page HTML sizes")
+	cmd.Flags().StringVar(&flags.MermaidJS, "mermaidJs", "", "Path to an alternate mermaid.js bundle to use instead of the embedded one")
+	cmd.Flags().StringVar(&flags.MermaidZenUMLJS, "mermaidZenumlJs", "", "Path to an alternate mermaid-zenuml.js bundle to use instead of the embedded one")
+	cmd.Flags().BoolVar(&flags.Daemon, "daemon", false, "Render through a running `mmd-cli daemon`, falling back to a local browser if none is listening")
+	cmd.Flags().StringVar(&flags.Socket, "socket", daemon.DefaultSocketPath(), "Unix socket of the render daemon (used with --daemon)")
+
+	cmd.Flags().BoolVar(&flags.ListThemes, "list-themes", false, "Print the themes --theme accepts and exit")
+	cmd.Flags().BoolVar(&flags.ListFormats, "list-formats", false, "Print the formats --outputFormat accepts and exit")
+
+	cmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output (also honors the NO_COLOR environment variable)")
+
+	cmd.AddCommand(newDaemonCommand())
+	cmd.AddCommand(newVersionCommand())
+	cmd.AddCommand(newCheckCommand())
+	cmd.AddCommand(newSelfTestCommand())
 
 	return cmd
 }
 
-// info logs a message unless quiet mode is enabled.
-func info(quiet bool, format string, args ...interface{}) {
-	if !quiet {
-		fmt.Fprintf(os.Stderr, format+"\n", args...)
+// errorExit prints an error message, in red when colors are enabled, and exits.
+func errorExit(format string, args ...interface{}) {
+	PrintError(fmt.Errorf(format, args...))
+	os.Exit(1)
+}
+
+// renderTimeout picks the per-render timeout: --timeout wins over the browser config
+// "timeout", and 0 leaves the renderer's default in place.
+func renderTimeout(flagMillis int, browserConfig *config.BrowserConfig) time.Duration {
+	if flagMillis > 0 {
+		return time.Duration(flagMillis) * time.Millisecond
 	}
+	return browserConfig.TimeoutDuration()
 }
 
-// errorExit prints an error message in red and exits.
-func errorExit(format string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, "\033[31m\n%s\n\033[0m", fmt.Sprintf(format, args...))
-	os.Exit(1)
+// dumpPageHTML writes the page the renderer would load for definition to path.
+func dumpPageHTML(path, definition string, opts renderer.RenderOpts) error {
+	pageHTML, err := renderer.BuildPageHTML(definition, opts)
+	if err != nil {
+		return fmt.Errorf("failed to build page HTML: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(pageHTML), 0644); err != nil {
+		return fmt.Errorf("failed to write HTML dump %q: %w", path, err)
+	}
+	return nil
+}
+
+// numberedPath inserts -n before the extension of path, e.g. page.html -> page-2.html.
+func numberedPath(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// selectDiagram returns the block with 1-based index n, or an error naming how many
+// diagrams are available.
+func selectDiagram(blocks []markdown.DiagramBlock, n int) (markdown.DiagramBlock, error) {
+	if n < 1 || n > len(blocks) {
+		if len(blocks) == 0 {
+			return markdown.DiagramBlock{}, fmt.Errorf("diagram %d not found: no mermaid charts in Markdown input", n)
+		}
+		return markdown.DiagramBlock{}, fmt.Errorf("diagram %d not found: Markdown input has %d mermaid charts (1-%d)", n, len(blocks), len(blocks))
+	}
+	return blocks[n-1], nil
+}
+
+// blockRenderOpts returns the render options for one markdown block: opts with the
+// block's own theme, if it sets one, in place of the global one. The mermaid config is
+// copied, so other blocks are unaffected.
+func blockRenderOpts(block markdown.DiagramBlock, opts renderer.RenderOpts) renderer.RenderOpts {
+	theme := block.Theme()
+	if theme == "" {
+		return opts
+	}
+	cfg := opts.MermaidConfig.Clone()
+	if cfg == nil {
+		cfg = config.MermaidConfig{}
+	}
+	cfg["theme"] = theme
+	opts.MermaidConfig = cfg
+	opts.BackgroundColor = backgroundColor(opts.BackgroundColor, opts.Set, cfg)
+	return opts
 }
 
-func run(flags *Flags) error {
+// diagramSizing describes the diagram type and page size a definition renders with,
+// for --verbose, e.g. "diagram type: gitGraph (detected), page size: 1200x600".
+func diagramSizing(definition string, opts renderer.RenderOpts) string {
+	source := "detected"
+	if opts.DiagramType != "" {
+		source = "from --diagramType"
+	}
+	diagramType := renderer.DiagramType(definition, opts)
+	if diagramType == "" {
+		diagramType = "unknown"
+	}
+	width, height := renderer.ViewportSize(definition, opts)
+	sizeSource := ""
+	switch {
+	case opts.Set.Has(renderer.SetWidth | renderer.SetHeight):
+		sizeSource = " (from --width/--height)"
+	case opts.Set.Has(renderer.SetWidth):
+		sizeSource = " (from --width)"
+	case opts.Set.Has(renderer.SetHeight):
+		sizeSource = " (from --height)"
+	}
+	return fmt.Sprintf("diagram type: %s (%s), page size: %dx%d%s", diagramType, source, width, height, sizeSource)
+}
+
+// renderDebug describes a render for -VV: the size of the page the diagram was loaded
+// in and how long the render took, e.g. "page HTML: 3215042 bytes, rendered in 412ms".
+func renderDebug(definition string, opts renderer.RenderOpts, elapsed time.Duration) string {
+	pageHTML, err := renderer.BuildPageHTML(definition, opts)
+	if err != nil {
+		return fmt.Sprintf("page HTML: %v, rendered in %s", err, elapsed.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("page HTML: %d bytes, rendered in %s", len(pageHTML), elapsed.Round(time.Millisecond))
+}
+
+// titledFileName builds dir/slug+ext, appending -1, -2, ... if that path is already used.
+func titledFileName(dir, slug, ext string, used map[string]bool) string {
+	name := filepath.Join(dir, slug+ext)
+	for i := 1; used[name]; i++ {
+		name = filepath.Join(dir, fmt.Sprintf("%s-%d%s", slug, i, ext))
+	}
+	return name
+}
+
+// diagramImagePath returns the numbered image file of the diagram at index in a markdown
+// render to output, e.g. docs/guide-1.png. The image goes into artefacts if given, or
+// into imageDir under the output's directory, and otherwise next to output.
+func diagramImagePath(output, imgExt, artefacts, imageDir string, index int) string {
+	name := fmt.Sprintf("%s-%d%s", strings.TrimSuffix(output, filepath.Ext(output)), index, imgExt)
+	switch {
+	case artefacts != "":
+		return filepath.Join(artefacts, filepath.Base(name))
+	case imageDir != "":
+		return filepath.Join(filepath.Dir(output), imageDir, filepath.Base(name))
+	}
+	return name
+}
+
+// imageLinkPath returns the path of image relative to the directory of the output
+// markdown, which is how the rewritten markdown links to it.
+func imageLinkPath(output, image string) string {
+	rel, err := filepath.Rel(filepath.Dir(filepath.Clean(output)), filepath.Clean(image))
+	if err != nil {
+		return image
+	}
+	return rel
+}
+
+// artefactsDir resolves the --artefacts path. A relative path is taken from the current
+// directory for relativeTo "cwd" (or ""), and from the directory of the input file for
+// "input", so image links stay the same wherever the command is run from.
+func artefactsDir(artefacts, relativeTo, input string) (string, error) {
+	switch relativeTo {
+	case "", "cwd", "input":
+	default:
+		return "", fmt.Errorf("invalid --artefactsRelativeTo %q, must be cwd or input", relativeTo)
+	}
+	if artefacts == "" || relativeTo != "input" || filepath.IsAbs(artefacts) {
+		return artefacts, nil
+	}
+	if input == "" || input == "-" || isRemoteInput(input) {
+		return "", fmt.Errorf("--artefactsRelativeTo input needs a local --input file")
+	}
+	return filepath.Join(filepath.Dir(input), artefacts), nil
+}
+
+// checkFormatConflict returns an error if the output file's extension names a different
+// image format than outputFormat, e.g. `-o diagram.svg -e png`. Markdown outputs and
+// stdout are never in conflict.
+func checkFormatConflict(output, outputFormat string) error {
+	if output == "/dev/stdout" {
+		return nil
+	}
+	ext := normalizeOutputFormat(strings.TrimPrefix(filepath.Ext(output), "."))
+	if ext == "md" || ext == "markdown" || ext == "zip" || ext == "txt" || ext == outputFormat {
+		return nil
+	}
+	return fmt.Errorf("output format %q doesn't match the extension of output file %q", outputFormat, output)
+}
+
+// defaultBackgroundColor is the page background when neither --backgroundColor nor the
+// theme sets one.
+const defaultBackgroundColor = "white"
+
+// backgroundColor returns the page background: given if --backgroundColor is in set,
+// else the background the mermaid config's theme implies (e.g. the dark theme's), else
+// defaultBackgroundColor.
+func backgroundColor(given string, set renderer.OptionSet, cfg config.MermaidConfig) string {
+	if set.Has(renderer.SetBackgroundColor) {
+		return given
+	}
+	if bg := cfg.ThemeBackground(); bg != "" {
+		return bg
+	}
+	return defaultBackgroundColor
+}
+
+// stdoutFormat returns the format to write to stdout with `-o -`: the one given with
+// -e, which may be a binary format such as png or pdf, or else svg, in which case
+// defaulted is true so the caller can warn about it.
+func stdoutFormat(outputFormat string) (format string, defaulted bool) {
+	if outputFormat != "" {
+		return outputFormat, false
+	}
+	return "svg", true
+}
+
+// normalizeOutputFormat lowercases an output format or file extension and maps
+// aliases to their canonical name (jpg -> jpeg).
+func normalizeOutputFormat(format string) string {
+	format = strings.ToLower(format)
+	if format == "jpg" {
+		return "jpeg"
+	}
+	return format
+}
+
+func run(flags *Flags) (err error) {
+	summary := newRenderSummary()
+
 	input := flags.Input
 	output := flags.Output
-	outputFormat := flags.OutputFormat
-	quiet := flags.Quiet
+	outputFormat := normalizeOutputFormat(flags.OutputFormat)
+	lg := newLogger(verbosityLevel(flags.Quiet, flags.Verbose))
+
+	// --inputDir renders a directory of diagrams instead of a single input
+	if err := validateBatchFlags(flags); err != nil {
+		return err
+	}
+	batch := flags.InputDir != ""
+	inline := flags.Definition != ""
+	frames := len(flags.Frames) > 0
+
+	// --stream renders a sequence of diagrams from stdin
+	if err := validateStreamFlags(flags); err != nil {
+		return err
+	}
+	stream := flags.Stream
 
-	// Validate input
-	if input == "" {
-		info(false, "No input file specified, reading from stdin. "+
-			"If you want to specify an input file, please use `-i <input>.` "+
-			"You can use `-i -` to read from stdin and to suppress this warning.")
-	} else if input == "-" {
-		// stdin mode, suppress warning
-		input = ""
-	} else if _, err := os.Stat(input); os.IsNotExist(err) {
-		return fmt.Errorf("input file %q doesn't exist", input)
+	// Validate input (a batch run checks its files as it walks the directory)
+	if inline {
+		if input != "" {
+			return usageError(fmt.Errorf("--input and --definition can't be used together"))
+		}
+	}
+	if frames {
+		if input != "" || inline {
+			return usageError(fmt.Errorf("--frames can't be used with --input or --definition"))
+		}
+	} else if !inline && !batch {
+		if input == "" && !stream {
+			lg.logf(levelError, "No input file specified, reading from stdin. "+
+				"If you want to specify an input file, please use `-i <input>.` "+
+				"You can use `-i -` to read from stdin and to suppress this warning.")
+		} else if input == "-" {
+			// stdin mode, suppress warning
+			input = ""
+		} else if !isRemoteInput(input) {
+			if p, ok, err := fileURLPath(input); err != nil {
+				return usageError(err)
+			} else if ok {
+				input = p
+			}
+			if _, err := os.Stat(input); os.IsNotExist(err) {
+				return inputNotFoundError(fmt.Errorf("input file %q doesn't exist", input))
+			}
+		}
+	}
+
+	inputFormat := strings.ToLower(flags.InputFormat)
+	if inputFormat == "" {
+		inputFormat = "auto"
+	}
+	if inputFormat != "auto" && inputFormat != "mermaid" && inputFormat != "markdown" {
+		return usageError(fmt.Errorf("input format must be one of \"auto\", \"mermaid\" or \"markdown\""))
+	}
+
+	if flags.SecurityLevel != "" {
+		if err := config.ValidateSecurityLevel(flags.SecurityLevel); err != nil {
+			return usageError(err)
+		}
+	}
+
+	if flags.CSSScope != "" && flags.CSSScope != renderer.CSSScopeSVG && flags.CSSScope != renderer.CSSScopePage {
+		return usageError(fmt.Errorf("CSS scope must be either \"page\" or \"svg\""))
+	}
+
+	if flags.DiagramType != "" {
+		if err := renderer.ValidateDiagramType(flags.DiagramType, flags.NoZenUML); err != nil {
+			return usageError(err)
+		}
+	}
+
+	if flags.Scale <= 0 {
+		return usageError(fmt.Errorf("invalid --scale %v, must be greater than 0", flags.Scale))
+	}
+
+	if flags.StdinTimeout < 0 {
+		return usageError(fmt.Errorf("invalid --stdinTimeout %d, must not be negative", flags.StdinTimeout))
+	}
+
+	if flags.SettleDelay < 0 {
+		return usageError(fmt.Errorf("invalid --settleDelay %d, must not be negative", flags.SettleDelay))
+	}
+
+	if len(flags.CleanSVGAttrs) > 0 && !flags.CleanSVG {
+		return usageError(fmt.Errorf("--cleanSvgAttr requires --cleanSvg"))
+	}
+
+	if (flags.MinWidth != 0 || flags.MaxWidth != 0) && !flags.AutoSize {
+		return usageError(fmt.Errorf("--minWidth and --maxWidth require --autoSize"))
+	}
+	if err := renderer.ValidateAutoSize(flags.MinWidth, flags.MaxWidth); err != nil {
+		return usageError(err)
+	}
+
+	if flags.Diagram < 0 {
+		return usageError(fmt.Errorf("invalid --diagram %d, must be a positive number", flags.Diagram))
+	}
+
+	// inputPath names the input for markdown detection and default output names.
+	// For URLs it is the last segment of the URL path, so output lands in the current directory.
+	inputPath := input
+	if isRemoteInput(input) {
+		inputPath = remoteInputName(input)
+	}
+
+	// Only one option can consume stdin
+	stdinUsers := []string{}
+	if input == "" && !batch && !inline && !frames {
+		stdinUsers = append(stdinUsers, "--input")
+	}
+	for _, configFile := range flags.ConfigFiles {
+		if configFile == "-" {
+			stdinUsers = append(stdinUsers, "--configFile")
+		}
+	}
+	if flags.PuppeteerConfigFile == "-" {
+		stdinUsers = append(stdinUsers, "--puppeteerConfigFile")
+	}
+	if len(stdinUsers) > 1 {
+		return usageError(fmt.Errorf("stdin can only be read once, but it is requested by %s", strings.Join(stdinUsers, " and ")))
+	}
+
+	// A data URI is text, written to stdout unless an output file is given
+	if flags.DataURI && output == "" && !batch {
+		output = "-"
 	}
 
 	// Determine output
-	if output == "" {
+	if batch {
+		if outputFormat == "" {
+			outputFormat = "svg"
+		}
+	} else if output == "" {
 		if outputFormat != "" {
 			if input != "" {
-				output = input + "." + outputFormat
+				output = inputPath + "." + outputFormat
 			} else {
 				output = "out." + outputFormat
 			}
 		} else {
 			if input != "" {
-				output = input + ".svg"
+				output = inputPath + ".svg"
 			} else {
 				output = "out.svg"
 			}
 		}
 	} else if output == "-" {
 		output = "/dev/stdout"
-		quiet = true
-		if outputFormat == "" {
-			outputFormat = "svg"
-			info(false, "No output format specified, using svg. "+
+		lg.level = levelError
+		var defaulted bool
+		if outputFormat, defaulted = stdoutFormat(outputFormat); defaulted {
+			lg.logf(levelError, "No output format specified, using svg. "+
 				"If you want to specify an output format and suppress this warning, "+
 				"please use `-e <format>.`")
 		}
 	} else {
-		validExt := regexp.MustCompile(`\.(?:svg|png|pdf|md|markdown)$`)
-		if !validExt.MatchString(output) {
-			return fmt.Errorf("output file must end with \".md\"/\".markdown\", \".svg\", \".png\" or \".pdf\"")
-		}
-	}
-
-	// Validate artefacts
-	if flags.Artefacts != "" {
-		if input == "" || !regexp.MustCompile(`\.(?:md|markdown)$`).MatchString(input) {
-			return fmt.Errorf("artefacts [-a|--artefacts] path can only be used with Markdown input file")
-		}
-		if err := os.MkdirAll(flags.Artefacts, 0755); err != nil {
-			return fmt.Errorf("failed to create artefacts directory: %w", err)
+		validExt := regexp.MustCompile(`(?i)\.(?:svg|png|jpe?g|gif|pdf|md|markdown|zip)$`)
+		isText := flags.DataURI && strings.EqualFold(filepath.Ext(output), ".txt")
+		if !validExt.MatchString(output) && !isText {
+			return usageError(fmt.Errorf("output file must end with \".md\"/\".markdown\", \".zip\", \".svg\", \".png\", \".jpg\"/\".jpeg\", \".gif\" or \".pdf\""))
 		}
 	}
 
 	// Check output directory exists
-	if output != "/dev/stdout" {
+	if output != "/dev/stdout" && !batch {
 		outputDir := filepath.Dir(output)
 		if _, err := os.Stat(outputDir); os.IsNotExist(err) {
-			return fmt.Errorf("output directory %q/ doesn't exist", outputDir)
+			return usageError(fmt.Errorf("output directory %q/ doesn't exist", outputDir))
 		}
 	}
 
 	// Determine output format from extension
-	if outputFormat == "" {
-		ext := strings.TrimPrefix(filepath.Ext(output), ".")
-		if ext == "md" || ext == "markdown" {
+	if outputFormat == "" && !batch {
+		ext := normalizeOutputFormat(strings.TrimPrefix(filepath.Ext(output), "."))
+		if ext == "md" || ext == "markdown" || ext == "zip" || ext == "txt" {
 			outputFormat = "svg"
 		} else {
 			outputFormat = ext
 		}
 	}
 
-	validFormats := regexp.MustCompile(`^(?:svg|png|pdf)$`)
-	if !validFormats.MatchString(outputFormat) {
-		return fmt.Errorf("output format must be one of \"svg\", \"png\" or \"pdf\"")
+	if !slices.Contains(outputFormats, outputFormat) {
+		return usageError(fmt.Errorf("output format must be one of \"svg\", \"png\", \"jpeg\", \"gif\" or \"pdf\""))
+	}
+
+	// gif output is an animation with a frame per diagram, each rendered as png
+	if outputFormat == "gif" {
+		if batch || stream {
+			return usageError(fmt.Errorf("gif output can't be used with --inputDir or --stream"))
+		}
+		if markdownExtRegex.MatchString(output) || strings.EqualFold(filepath.Ext(output), ".zip") {
+			return usageError(fmt.Errorf("gif output is a single animation, so the output can't be a Markdown or zip file"))
+		}
+		if flags.FrameDelay <= 0 {
+			return usageError(fmt.Errorf("invalid --frameDelay %d, must be greater than 0", flags.FrameDelay))
+		}
+	} else if frames {
+		return usageError(fmt.Errorf("--frames can only be used with gif output"))
+	}
+
+	if flags.OutputFormat != "" && !batch {
+		if err := checkFormatConflict(output, outputFormat); err != nil {
+			if !flags.Force {
+				return usageError(fmt.Errorf("%w. Use --force to write it anyway", err))
+			}
+			lg.logf(levelInfo, "Warning: %v", err)
+		}
+	}
+
+	if err := renderer.ValidateSVGMode(flags.SVGMode); err != nil {
+		return usageError(err)
+	}
+	if flags.SVGMode != "" && outputFormat != "svg" {
+		return usageError(fmt.Errorf("--svgMode can only be used with svg output"))
+	}
+	if flags.DeterministicIDs && outputFormat != "svg" {
+		return usageError(fmt.Errorf("--deterministicIds can only be used with svg output"))
+	}
+	if flags.SVGMode == renderer.SVGModeInline && flags.SVGDecl {
+		return usageError(fmt.Errorf("--svgDecl can't be used with --svgMode inline"))
+	}
+
+	if err := icons.ValidateCDN(flags.IconCDN); err != nil {
+		return usageError(err)
+	}
+
+	if flags.optionSet().Has(renderer.SetScale) && outputFormat == "svg" {
+		lg.logf(levelInfo, "Warning: --scale has no effect on svg output")
+	}
+
+	if err := renderer.ValidatePNGColors(flags.PNGColors); err != nil {
+		return usageError(err)
+	}
+	if flags.PNGColors != 0 && outputFormat != "png" {
+		return usageError(fmt.Errorf("--pngColors can only be used with png output"))
+	}
+
+	if err := renderer.ValidateDPI(flags.DPI); err != nil {
+		return usageError(err)
+	}
+	if flags.DPI != 0 && flags.DPI != renderer.DefaultDPI && outputFormat != "pdf" {
+		return usageError(fmt.Errorf("--dpi can only be used with pdf output"))
+	}
+
+	var clip *renderer.Clip
+	if flags.Clip != "" {
+		var err error
+		if clip, err = renderer.ParseClip(flags.Clip); err != nil {
+			return usageError(err)
+		}
+		if outputFormat == "svg" {
+			return usageError(fmt.Errorf("--clip can't be used with svg output"))
+		}
+		if outputFormat == "pdf" && !flags.PdfFit {
+			return usageError(fmt.Errorf("--clip requires --pdfFit for pdf output"))
+		}
+	}
+
+	var watermark *renderer.Watermark
+	if flags.Watermark != "" {
+		if outputFormat == "pdf" {
+			return usageError(fmt.Errorf("--watermark can't be used with pdf output"))
+		}
+		data, err := os.ReadFile(flags.Watermark)
+		if err != nil {
+			return usageError(fmt.Errorf("failed to read watermark: %w", err))
+		}
+		if watermark, err = renderer.NewWatermark(data, flags.WatermarkPosition, flags.WatermarkOpacity); err != nil {
+			return usageError(err)
+		}
+	}
+
+	var postScript string
+	if flags.PostScript != "" {
+		data, err := os.ReadFile(flags.PostScript)
+		if err != nil {
+			return usageError(fmt.Errorf("failed to read post-render script: %w", err))
+		}
+		postScript = string(data)
+	}
+
+	if flags.PageRanges != "" {
+		if err := renderer.ValidatePageRanges(flags.PageRanges); err != nil {
+			return usageError(err)
+		}
+	}
+
+	// Validate explicit SVG dimensions
+	for _, length := range []string{flags.SVGWidth, flags.SVGHeight} {
+		if length == "" {
+			continue
+		}
+		if err := renderer.ValidateSVGLength(length); err != nil {
+			return usageError(err)
+		}
+	}
+
+	var maxOutputBytes int64
+	if flags.MaxOutputSize != "" {
+		n, err := parseByteSize(flags.MaxOutputSize)
+		if err != nil {
+			return usageError(err)
+		}
+		maxOutputBytes = n
+	}
+
+	// Without --configFile, use a project config found next to the input or in a parent directory
+	configFiles := flags.ConfigFiles
+	if len(configFiles) == 0 && !flags.NoConfig {
+		startDir := "."
+		if batch {
+			startDir = flags.InputDir
+		} else if input != "" && !isRemoteInput(input) {
+			startDir = filepath.Dir(input)
+		}
+		found, err := config.Discover(startDir)
+		if err != nil {
+			return err
+		}
+		if found != "" {
+			lg.logf(levelInfo, "Using config file %s", found)
+			configFiles = []string{found}
+		}
 	}
 
 	// Load configs
-	mermaidConfig, err := config.LoadMermaidConfig(flags.ConfigFile, flags.Theme)
+	var preset *config.Preset
+	if flags.Preset != "" {
+		var err error
+		if preset, err = config.LoadPreset(flags.Preset); err != nil {
+			return usageError(err)
+		}
+	}
+	baseConfig := config.BaseMermaidConfig(flags.Theme, preset, flags.isSet("theme"))
+	var mermaidConfig config.MermaidConfig
+	var configProblems []string
+	if flags.ValidateConfig || flags.StrictConfig {
+		mermaidConfig, configProblems, err = config.LoadMermaidConfigChecked(baseConfig, configFiles, flags.JSONC)
+	} else {
+		mermaidConfig, err = config.LoadMermaidConfigOver(baseConfig, configFiles, flags.JSONC)
+	}
 	if err != nil {
-		return err
+		return usageError(err)
+	}
+	if flags.StrictConfig && len(configProblems) > 0 {
+		return usageError(fmt.Errorf("invalid mermaid config: %s", strings.Join(configProblems, "; ")))
+	}
+	for _, problem := range configProblems {
+		lg.logf(levelError, "Warning: %s", problem)
+	}
+	if flags.StrictEnv && !flags.ExpandEnv {
+		return usageError(fmt.Errorf("--strictEnv requires --expandEnv"))
 	}
+	if flags.ExpandEnv {
+		if err := mermaidConfig.ExpandEnv(flags.StrictEnv); err != nil {
+			return usageError(err)
+		}
+	}
+	mermaidConfig.ApplyFontFamily(flags.FontFamily)
+	mermaidConfig.ApplySecurityLevel(flags.SecurityLevel)
 
 	browserConfig, err := config.LoadBrowserConfig(flags.PuppeteerConfigFile)
 	if err != nil {
-		return err
+		return usageError(err)
+	}
+	if flags.ExpandEnv {
+		if err := browserConfig.ExpandEnv(flags.StrictEnv); err != nil {
+			return usageError(err)
+		}
+	}
+	browserConfig.Args = append(browserConfig.Args, flags.BrowserFlags...)
+	if flags.TabPool < 0 {
+		return usageError(fmt.Errorf("invalid --tabPool %d, must be a positive number", flags.TabPool))
+	}
+	if flags.TabPool > 0 {
+		browserConfig.TabPoolSize = flags.TabPool
+	}
+	if flags.Sandbox {
+		browserConfig.Sandbox = true
+	}
+	if flags.Browsers < 0 {
+		return usageError(fmt.Errorf("invalid --browsers %d, must not be negative", flags.Browsers))
 	}
 
-	css, err := config.LoadCSSFile(flags.CSSFile)
+	css, err := config.LoadCSSFile(flags.CSSFiles...)
 	if err != nil {
-		return err
+		return usageError(err)
+	}
+	if flags.ExpandEnv {
+		if css, err = config.ExpandEnv(css, flags.StrictEnv); err != nil {
+			return usageError(fmt.Errorf("CSS file: %w", err))
+		}
+	}
+	if preset != nil && preset.CSS != "" {
+		// --cssFile comes after the preset's CSS, so its rules win
+		css = strings.TrimSpace(preset.CSS + "\n" + css)
+	}
+
+	scripts, err := web.NewFileLoader(flags.MermaidJS, flags.MermaidZenUMLJS)
+	if err != nil {
+		return usageError(err)
+	}
+
+	var cache *rendercache.Cache
+	if flags.CacheDir != "" {
+		cache, err = rendercache.New(flags.CacheDir, scripts)
+		if err != nil {
+			return err
+		}
+		summary.cache = cache
+	}
+
+	if flags.DiffDir != "" && flags.Baseline == "" {
+		return usageError(fmt.Errorf("--diffDir requires --baseline"))
+	}
+	if flags.Baseline != "" {
+		if output == "/dev/stdout" {
+			return usageError(fmt.Errorf("--baseline compares output files, so it can't be used with stdout output"))
+		}
+		if stat, err := os.Stat(flags.Baseline); err != nil || !stat.IsDir() {
+			return usageError(fmt.Errorf("baseline directory %q doesn't exist", flags.Baseline))
+		}
+	}
+	if flags.FailOnChange && flags.Baseline == "" {
+		return usageError(fmt.Errorf("--failOnChange requires --baseline"))
 	}
 
 	// Collect icon packs
 	var allIconPacks []icons.IconPack
 	if len(flags.IconPacks) > 0 {
-		allIconPacks = append(allIconPacks, icons.ParseIconPacks(flags.IconPacks)...)
+		allIconPacks = append(allIconPacks, icons.ParseIconPacks(flags.IconPacks, flags.IconCDN)...)
 	}
 	if len(flags.IconPacksNamesAndUrls) > 0 {
 		allIconPacks = append(allIconPacks, icons.ParseIconPacksNamesAndUrls(flags.IconPacksNamesAndUrls)...)
 	}
 
 	// Build render options
+	width, height := pageSize(flags)
+	set := flags.optionSet()
 	renderOpts := renderer.RenderOpts{
-		MermaidConfig:   mermaidConfig,
-		BackgroundColor: flags.BackgroundColor,
-		CSS:             css,
-		SVGId:           flags.SVGId,
-		Width:           flags.Width,
-		Height:          flags.Height,
-		Scale:           flags.Scale,
-		PdfFit:          flags.PdfFit,
-		SvgFit:          flags.SvgFit,
-		IconPacks:       allIconPacks,
+		MermaidConfig:    mermaidConfig,
+		BackgroundColor:  backgroundColor(flags.BackgroundColor, set, mermaidConfig),
+		CSS:              css,
+		CSSScope:         flags.CSSScope,
+		SVGId:            flags.SVGId,
+		Width:            width,
+		Height:           height,
+		Scale:            flags.Scale,
+		Set:              set,
+		PdfFit:           flags.PdfFit,
+		PageRanges:       flags.PageRanges,
+		DPI:              flags.DPI,
+		Clip:             clip,
+		Watermark:        watermark,
+		SvgFit:           flags.SvgFit,
+		SVGWidth:         flags.SVGWidth,
+		SVGHeight:        flags.SVGHeight,
+		SVGDecl:          flags.SVGDecl,
+		SVGMode:          flags.SVGMode,
+		Sanitize:         flags.Sanitize,
+		CleanSVG:         flags.CleanSVG,
+		CleanSVGAttrs:    flags.CleanSVGAttrs,
+		DeterministicIDs: flags.DeterministicIDs,
+		AutoSize:         flags.AutoSize,
+		MinWidth:         flags.MinWidth,
+		MaxWidth:         flags.MaxWidth,
+		NoZenUML:         flags.NoZenUML,
+		DiagramType:      flags.DiagramType,
+		PNGColors:        flags.PNGColors,
+		IconPacks:        allIconPacks,
+		StrictIcons:      flags.StrictIcons,
+		FontURLs:         flags.FontURLs,
+		SettleDelay:      time.Duration(flags.SettleDelay) * time.Millisecond,
+		WaitFor:          flags.WaitFor,
+		WaitForFunction:  flags.WaitForFunction,
+		PostScript:       postScript,
+		Scripts:          scripts,
+		MaxOutputBytes:   maxOutputBytes,
+		Timeout:          renderTimeout(flags.Timeout, browserConfig),
+	}
+
+	if batch {
+		outputDir := flags.OutputDir
+		if outputDir == "" {
+			outputDir = flags.InputDir
+		}
+		checker, err := newBaselineChecker(flags, outputDir, summary)
+		if err != nil {
+			return err
+		}
+		r := newDiagramRenderer(flags, browserConfig, lg)
+		if cache != nil {
+			r = cache.Wrap(r)
+		}
+		defer r.Close()
+		return checker.finish(lg, renderDir(context.Background(), r, flags.InputDir, outputDir, flags.Recursive, outputFormat, renderOpts, summary, lg))
+	}
+
+	if stream {
+		if markdownExtRegex.MatchString(output) || strings.EqualFold(filepath.Ext(output), ".zip") {
+			return usageError(fmt.Errorf("--stream renders single diagrams, so the output can't be a Markdown or zip file"))
+		}
+		checker, err := newBaselineChecker(flags, filepath.Dir(output), summary)
+		if err != nil {
+			return err
+		}
+		r := newDiagramRenderer(flags, browserConfig, lg)
+		if cache != nil {
+			r = cache.Wrap(r)
+		}
+		defer r.Close()
+		err = renderStream(context.Background(), r, os.Stdin, os.Stdout, flags.StreamDelimiter, output, outputFormat, flags.DataURI, renderOpts, summary)
+		lg.logf(levelInfo, "%s", summary)
+		return checker.finish(lg, err)
 	}
 
 	// Read input
+	readStart := time.Now()
 	var definition string
-	if input != "" {
-		data, err := os.ReadFile(input)
-		if err != nil {
-			return fmt.Errorf("failed to read input file: %w", err)
+	// With --frames, each frame is read from its own file when the animation is rendered
+	if !frames {
+		if inline {
+			definition = flags.Definition
+		} else if isRemoteInput(input) {
+			data, err := fetchURL(input, fetchTimeout, maxFetchBytes)
+			if err != nil {
+				return inputNotFoundError(err)
+			}
+			definition = string(data)
+		} else if input != "" {
+			data, err := os.ReadFile(input)
+			if err != nil {
+				return inputNotFoundError(fmt.Errorf("failed to read input file: %w", err))
+			}
+			definition = string(data)
+		} else {
+			data, err := readStdin(os.Stdin, time.Duration(flags.StdinTimeout)*time.Millisecond)
+			if err != nil {
+				return inputNotFoundError(err)
+			}
+			definition = string(data)
 		}
-		definition = string(data)
-	} else {
-		data, err := readStdin()
+	}
+	lg.logf(levelDebug, "Read %d bytes of input in %s", len(definition), time.Since(readStart))
+
+	isMarkdown := isMarkdownInput(inputFormat, input, inputPath, definition)
+	if warning := inputKindWarning(inputFormat, input, inputPath, definition); warning != "" {
+		lg.logf(levelInfo, "Warning: %s", warning)
+	}
+
+	// A .zip output bundles the rewritten markdown and its images into one archive
+	zipOutput := strings.EqualFold(filepath.Ext(output), ".zip")
+	if zipOutput && (!isMarkdown || flags.Diagram > 0) {
+		return usageError(fmt.Errorf("zip output can only be used when rendering a whole Markdown input"))
+	}
+	if zipOutput && flags.Baseline != "" {
+		return usageError(fmt.Errorf("--baseline can't be used with zip output"))
+	}
+	if zipOutput && flags.Artefacts != "" {
+		return usageError(fmt.Errorf("artefacts [-a|--artefacts] path can't be used with zip output"))
+	}
+	if flags.Incremental && (!isMarkdown || flags.Diagram > 0 || zipOutput) {
+		return usageError(fmt.Errorf("--incremental can only be used when rendering a whole Markdown input to files"))
+	}
+	if flags.Manifest != "" && (!isMarkdown || flags.Diagram > 0 || zipOutput) {
+		return usageError(fmt.Errorf("--manifest can only be used when rendering a whole Markdown input to files"))
+	}
+
+	// Validate artefacts
+	artefacts, err := artefactsDir(flags.Artefacts, flags.ArtefactsRelativeTo, input)
+	if err != nil {
+		return usageError(err)
+	}
+	if flags.ImageDir != "" {
+		if !isMarkdown || flags.Diagram > 0 || !(markdownExtRegex.MatchString(output) || zipOutput) {
+			return usageError(fmt.Errorf("--imageDir can only be used when rendering a Markdown input to a Markdown or zip output"))
+		}
+		if artefacts != "" {
+			return usageError(fmt.Errorf("--imageDir and --artefacts can't be used together"))
+		}
+		if !zipOutput {
+			if err := os.MkdirAll(filepath.Join(filepath.Dir(output), flags.ImageDir), 0755); err != nil {
+				return fmt.Errorf("failed to create image directory: %w", err)
+			}
+		}
+	}
+	if artefacts != "" {
+		if !isMarkdown {
+			return usageError(fmt.Errorf("artefacts [-a|--artefacts] path can only be used with Markdown input"))
+		}
+		if err := os.MkdirAll(artefacts, 0755); err != nil {
+			return fmt.Errorf("failed to create artefacts directory: %w", err)
+		}
+	}
+
+	// Render just one block of a markdown input, written like a single diagram
+	if flags.Diagram > 0 {
+		if !isMarkdown {
+			return usageError(fmt.Errorf("--diagram can only be used with Markdown input"))
+		}
+		if markdownExtRegex.MatchString(output) {
+			return usageError(fmt.Errorf("--diagram renders a single image, so the output can't be a Markdown file"))
+		}
+		block, err := selectDiagram(markdown.ExtractDiagrams(definition), flags.Diagram)
 		if err != nil {
-			return fmt.Errorf("failed to read stdin: %w", err)
+			return usageError(err)
 		}
-		definition = string(data)
+		definition = block.Definition
+		renderOpts = blockRenderOpts(block, renderOpts)
+		isMarkdown = false
+	}
+
+	if flags.DataURI && isMarkdown {
+		return usageError(fmt.Errorf("--dataUri can only be used with a single diagram, e.g. one picked with --diagram"))
+	}
+
+	checker, err := newBaselineChecker(flags, filepath.Dir(output), summary)
+	if err != nil {
+		return err
 	}
+	defer func() { err = checker.finish(lg, err) }()
 
 	// Set up renderer
-	browser := renderer.NewBrowser(browserConfig)
-	r := renderer.NewRenderer(browser)
+	r := newDiagramRenderer(flags, browserConfig, lg)
+	if cache != nil {
+		r = cache.Wrap(r)
+	}
 	defer r.Close()
 
 	ctx := context.Background()
 
+	if outputFormat == "gif" {
+		animation, err := animationFrames(flags.Frames, definition, isMarkdown, renderOpts)
+		if err != nil {
+			return err
+		}
+		lg.logf(levelInfo, "Generating animation of %d frames", len(animation))
+		data, err := renderAnimation(ctx, r, animation, time.Duration(flags.FrameDelay)*time.Millisecond)
+		if err != nil {
+			return renderError(err)
+		}
+		if flags.DataURI {
+			data = []byte(dataURI(outputFormat, data))
+		}
+		if output == "/dev/stdout" {
+			if _, err := os.Stdout.Write(data); err != nil {
+				return fmt.Errorf("failed to write to stdout: %w", err)
+			}
+			summary.diagrams++
+			summary.bytes += int64(len(data))
+		} else {
+			if err := summary.writeDiagram(output, data); err != nil {
+				return fmt.Errorf("failed to write output file: %w", err)
+			}
+			lg.logf(levelInfo, " ✅ %s", output)
+		}
+		lg.logf(levelInfo, "%s", summary)
+		return nil
+	}
+
 	// Handle markdown input
-	if input != "" && regexp.MustCompile(`\.(?:md|markdown)$`).MatchString(input) {
+	if isMarkdown {
 		if output == "/dev/stdout" {
-			return fmt.Errorf("cannot use `stdout` with markdown input")
+			return usageError(fmt.Errorf("cannot use `stdout` with markdown input"))
 		}
 
 		diagrams := markdown.ExtractDiagrams(definition)
 
 		if len(diagrams) > 0 {
-			info(quiet, "Found %d mermaid charts in Markdown input", len(diagrams))
+			lg.logf(levelInfo, "Found %d mermaid charts in Markdown input", len(diagrams))
 		} else {
-			info(quiet, "No mermaid charts found in Markdown input")
+			lg.logf(levelInfo, "No mermaid charts found in Markdown input")
 		}
 
-		imageRefs := make([]markdown.ImageRef, 0, len(diagrams))
+		// Collect files in memory for a zip output, so nothing else touches the filesystem
+		var bundle *zipBundle
+		if zipOutput {
+			bundle = newZipBundle(filepath.Dir(output))
+			summary.write = bundle.add
+		}
 
-		for _, diagram := range diagrams {
-			// Build numbered output filename
-			ext := filepath.Ext(output)
-			base := strings.TrimSuffix(output, ext)
-			// If output is .md/.markdown, use outputFormat extension for images
-			imgExt := ext
-			if ext == ".md" || ext == ".markdown" {
-				imgExt = "." + outputFormat
+		// With --incremental, blocks whose cache key matches the previous run reuse its images
+		keys := make([]string, len(diagrams))
+		var reusable map[string]reusedDiagram
+		var manifestPath string
+		manifest := &incrementalManifest{Diagrams: []incrementalEntry{}}
+		if flags.Incremental {
+			manifestPath = incrementalManifestPath(output)
+			previous, err := readIncrementalManifest(manifestPath)
+			if err != nil {
+				return err
 			}
-			outputFile := fmt.Sprintf("%s-%d%s", base, diagram.Index, imgExt)
-
-			if flags.Artefacts != "" {
-				outputFile = filepath.Join(flags.Artefacts, filepath.Base(outputFile))
+			version := rendercache.Version(scripts)
+			for i, diagram := range diagrams {
+				if keys[i], err = rendercache.Key(diagram.Definition, outputFormat, blockRenderOpts(diagram, renderOpts), version); err != nil {
+					return err
+				}
 			}
+			reusable = previous.unchanged(filepath.Dir(output), keys)
+		}
 
-			// Calculate relative path from output dir
-			outputDir := filepath.Dir(filepath.Clean(output))
-			relPath, err := filepath.Rel(outputDir, filepath.Clean(outputFile))
-			if err != nil {
-				relPath = outputFile
+		progress := newProgress(len(diagrams), !lg.enabled(levelInfo))
+		defer progress.clear()
+
+		// Render every block, remembering where each image goes; nothing is written
+		// until all of them have rendered
+		type renderedBlock struct {
+			definition string
+			file       string
+			rel        string
+			key        string
+			unchanged  bool
+			sizing     string
+			debug      string
+			result     *renderer.RenderResult
+		}
+		blocks := make(map[int]renderedBlock, len(diagrams))
+		rendered := &renderManifest{Diagrams: []manifestEntry{}}
+		// If output is .md/.markdown/.zip, use outputFormat extension for images
+		imgExt := filepath.Ext(output)
+		if strings.EqualFold(imgExt, ".md") || strings.EqualFold(imgExt, ".markdown") || zipOutput {
+			imgExt = "." + outputFormat
+		}
+		// Every diagram's numbered file is taken up front, so that a title slug such as
+		// "guide-2" can't claim the file of an untitled diagram rendered after it
+		usedFiles := make(map[string]bool, len(diagrams))
+		if flags.NameByTitle {
+			for i := range diagrams {
+				usedFiles[diagramImagePath(output, imgExt, artefacts, flags.ImageDir, i+1)] = true
 			}
-			outputFileRelative := "./" + relPath
+		}
 
-			result, err := r.Render(ctx, diagram.Definition, outputFormat, renderOpts)
-			if err != nil {
-				return fmt.Errorf("failed to render diagram %d: %w", diagram.Index, err)
+		processed, err := markdown.Process(definition, func(diagram markdown.DiagramBlock) (markdown.RenderResult, error) {
+			key := keys[diagram.Index-1]
+			progress.step(diagram.Index)
+
+			reused, unchanged := reusable[key]
+			opts := blockRenderOpts(diagram, renderOpts)
+			var result *renderer.RenderResult
+			var debug string
+			if unchanged {
+				result = &renderer.RenderResult{Data: reused.data, Title: reused.title, Desc: reused.desc}
+			} else {
+				// The first failure fails the run, so there's no point rendering the rest
+				if flags.DumpHTML != "" {
+					if err := dumpPageHTML(numberedPath(flags.DumpHTML, diagram.Index), diagram.Definition, opts); err != nil {
+						return markdown.RenderResult{}, markdown.Stop(err)
+					}
+				}
+
+				start := time.Now()
+				var err error
+				result, err = r.Render(ctx, diagram.Definition, outputFormat, opts)
+				if err != nil {
+					return markdown.RenderResult{}, markdown.Stop(err)
+				}
+				if lg.enabled(levelDebug) {
+					debug = renderDebug(diagram.Definition, opts, time.Since(start))
+				}
 			}
 
-			if err := os.WriteFile(outputFile, result.Data, 0644); err != nil {
-				return fmt.Errorf("failed to write output file %q: %w", outputFile, err)
+			outputFile := diagramImagePath(output, imgExt, artefacts, flags.ImageDir, diagram.Index)
+
+			// Name the file after the diagram title instead, if requested and available.
+			// A caption attribute on the fence takes precedence over the diagram's own title.
+			if flags.NameByTitle {
+				name := diagram.Attrs["caption"]
+				if name == "" {
+					name = result.Title
+				}
+				if name == "" {
+					name = markdown.DiagramTitle(diagram.Definition)
+				}
+				if slug := markdown.Slugify(name); slug != "" {
+					// The numbered file was only reserved for this diagram
+					delete(usedFiles, outputFile)
+					outputFile = titledFileName(filepath.Dir(outputFile), slug, imgExt, usedFiles)
+				}
 			}
+			usedFiles[outputFile] = true
+
+			relPath := imageLinkPath(output, outputFile)
 
-			info(quiet, " ✅ %s", outputFileRelative)
+			blocks[diagram.Index] = renderedBlock{definition: diagram.Definition, file: outputFile, rel: relPath, key: key, unchanged: unchanged, sizing: diagramSizing(diagram.Definition, opts), debug: debug, result: result}
+			return markdown.RenderResult{Data: result.Data, URL: "./" + relPath, Title: result.Title, Desc: result.Desc}, nil
+		})
+		progress.clear()
+		if err != nil {
+			return renderError(err)
+		}
 
-			imageRefs = append(imageRefs, markdown.ImageRef{
-				URL:   outputFileRelative,
-				Alt:   result.Desc,
-				Title: result.Title,
-			})
+		for _, img := range processed.Images {
+			block := blocks[img.Index]
+			if err := summary.writeDiagram(block.file, img.Data); err != nil {
+				return fmt.Errorf("failed to write output file %q: %w", block.file, err)
+			}
+
+			if block.unchanged {
+				lg.logf(levelInfo, " ✅ %s (unchanged)", img.URL)
+			} else {
+				lg.logf(levelInfo, " ✅ %s", img.URL)
+			}
+			if flags.Incremental {
+				manifest.Diagrams = append(manifest.Diagrams, incrementalEntry{
+					Key:   block.key,
+					File:  filepath.ToSlash(block.rel),
+					Title: block.result.Title,
+					Desc:  block.result.Desc,
+				})
+			}
+			if flags.Manifest != "" {
+				rendered.Diagrams = append(rendered.Diagrams, newManifestEntry(flags.Manifest, img.Index, block.definition, block.file, outputFormat, block.result))
+			}
+			for _, warning := range block.result.Warnings {
+				lg.logf(levelError, "Warning: diagram %d: %s", img.Index, warning)
+			}
+			lg.logf(levelVerbose, "    %s", block.sizing)
+			if block.result.Timings.Total() > 0 {
+				lg.logf(levelVerbose, "    timings: %s", block.result.Timings)
+			}
+			if block.debug != "" {
+				lg.logf(levelDebug, "    %s", block.debug)
+			}
+			for _, line := range block.result.Console {
+				lg.logf(levelVerbose, "    console: %s", line)
+			}
 		}
 
 		// If output is markdown, replace code blocks with image references
-		if regexp.MustCompile(`\.(?:md|markdown)$`).MatchString(output) {
-			outContent := markdown.ReplaceDiagrams(definition, imageRefs)
-			if err := os.WriteFile(output, []byte(outContent), 0644); err != nil {
+		if regexp.MustCompile(`(?i)\.(?:md|markdown)$`).MatchString(output) {
+			if err := summary.writeMarkdown(output, []byte(processed.Content)); err != nil {
 				return fmt.Errorf("failed to write markdown output: %w", err)
 			}
-			info(quiet, " ✅ %s", output)
+			lg.logf(levelInfo, " ✅ %s", output)
+		}
+
+		if flags.Incremental {
+			if err := writeIncrementalManifest(manifestPath, manifest); err != nil {
+				return err
+			}
+		}
+		if flags.Manifest != "" {
+			if err := writeRenderManifest(flags.Manifest, rendered); err != nil {
+				return err
+			}
+			lg.logf(levelInfo, " ✅ %s", flags.Manifest)
+		}
+
+		// Bundle the rewritten markdown next to the images and write the archive
+		if zipOutput {
+			mdName := strings.TrimSuffix(output, filepath.Ext(output)) + ".md"
+			if err := summary.writeMarkdown(mdName, []byte(processed.Content)); err != nil {
+				return fmt.Errorf("failed to add markdown to archive: %w", err)
+			}
+			data, err := bundle.bytes()
+			if err != nil {
+				return fmt.Errorf("failed to build archive: %w", err)
+			}
+			if err := writeFile(output, data); err != nil {
+				return fmt.Errorf("failed to write output file %q: %w", output, err)
+			}
+			summary.markdown = output
+			lg.logf(levelInfo, " ✅ %s", output)
 		}
 	} else {
 		// Single diagram rendering
-		info(quiet, "Generating single mermaid chart")
+		lg.logf(levelInfo, "Generating single mermaid chart")
 
+		if flags.DumpHTML != "" {
+			if err := dumpPageHTML(flags.DumpHTML, definition, renderOpts); err != nil {
+				return err
+			}
+		}
+
+		start := time.Now()
 		result, err := r.Render(ctx, definition, outputFormat, renderOpts)
 		if err != nil {
-			return err
+			return renderError(err)
+		}
+		for _, warning := range result.Warnings {
+			lg.logf(levelError, "Warning: %s", warning)
+		}
+		lg.logf(levelVerbose, "    %s", diagramSizing(definition, renderOpts))
+		if result.Timings.Total() > 0 {
+			lg.logf(levelVerbose, "    timings: %s", result.Timings)
+		}
+		if lg.enabled(levelDebug) {
+			lg.logf(levelDebug, "    %s", renderDebug(definition, renderOpts, time.Since(start)))
+		}
+		for _, line := range result.Console {
+			lg.logf(levelVerbose, "    console: %s", line)
+		}
+
+		data := result.Data
+		if flags.DataURI {
+			data = []byte(dataURI(outputFormat, data))
 		}
 
 		if output == "/dev/stdout" {
-			if _, err := os.Stdout.Write(result.Data); err != nil {
+			if _, err := os.Stdout.Write(data); err != nil {
 				return fmt.Errorf("failed to write to stdout: %w", err)
 			}
+			summary.diagrams++
+			summary.bytes += int64(len(data))
 		} else {
-			if err := os.WriteFile(output, result.Data, 0644); err != nil {
+			if err := summary.writeDiagram(output, data); err != nil {
 				return fmt.Errorf("failed to write output file: %w", err)
 			}
-			info(quiet, " ✅ %s", output)
+			lg.logf(levelInfo, " ✅ %s", output)
 		}
 	}
 
-	return nil
-}
+	lg.logf(levelInfo, "%s", summary)
 
-// readStdin reads all data from stdin.
-func readStdin() ([]byte, error) {
-	var data []byte
-	buf := make([]byte, 4096)
-	for {
-		n, err := os.Stdin.Read(buf)
-		if n > 0 {
-			data = append(data, buf[:n]...)
-		}
-		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			return nil, err
-		}
-	}
-	return data, nil
+	return nil
 }
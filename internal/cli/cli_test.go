@@ -0,0 +1,450 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coolamit/mermaid-cli/internal/config"
+	"github.com/coolamit/mermaid-cli/internal/markdown"
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+func TestNormalizeOutputFormat(t *testing.T) {
+	tests := map[string]string{
+		"PNG":  "png",
+		"Svg":  "svg",
+		"pdf":  "pdf",
+		"JPG":  "jpeg",
+		"jpg":  "jpeg",
+		"JPEG": "jpeg",
+		"MD":   "md",
+		"":     "",
+	}
+	for input, want := range tests {
+		if got := normalizeOutputFormat(input); got != want {
+			t.Errorf("normalizeOutputFormat(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestRenderTimeout(t *testing.T) {
+	withConfig := &config.BrowserConfig{Timeout: 30000}
+	noConfig := &config.BrowserConfig{}
+
+	if got := renderTimeout(5000, withConfig); got != 5*time.Second {
+		t.Errorf("expected --timeout to win, got %s", got)
+	}
+	if got := renderTimeout(0, withConfig); got != 30*time.Second {
+		t.Errorf("expected browser config timeout, got %s", got)
+	}
+	if got := renderTimeout(0, noConfig); got != 0 {
+		t.Errorf("expected 0 (renderer default), got %s", got)
+	}
+}
+
+func TestTitledFileName(t *testing.T) {
+	used := map[string]bool{}
+
+	first := titledFileName("docs", "user-flow", ".png", used)
+	if first != filepath.Join("docs", "user-flow.png") {
+		t.Errorf("unexpected first name %q", first)
+	}
+	used[first] = true
+
+	second := titledFileName("docs", "user-flow", ".png", used)
+	if second != filepath.Join("docs", "user-flow-1.png") {
+		t.Errorf("expected -1 suffix on collision, got %q", second)
+	}
+	used[second] = true
+
+	third := titledFileName("docs", "user-flow", ".png", used)
+	if third != filepath.Join("docs", "user-flow-2.png") {
+		t.Errorf("expected -2 suffix on second collision, got %q", third)
+	}
+}
+
+func TestDiagramImagePath(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    string
+		artefacts string
+		imageDir  string
+		want      string
+		link      string
+	}{
+		{"next to output", filepath.Join("docs", "doc.md"), "", "", filepath.Join("docs", "doc-2.png"), "doc-2.png"},
+		{"imageDir", filepath.Join("docs", "doc.md"), "", "assets", filepath.Join("docs", "assets", "doc-2.png"), filepath.Join("assets", "doc-2.png")},
+		{"nested imageDir", "doc.md", "", filepath.Join("assets", "img"), filepath.Join("assets", "img", "doc-2.png"), filepath.Join("assets", "img", "doc-2.png")},
+		{"imageDir outside the output dir", filepath.Join("docs", "doc.md"), "", filepath.Join("..", "static"), filepath.Join("static", "doc-2.png"), filepath.Join("..", "static", "doc-2.png")},
+		{"artefacts", filepath.Join("docs", "doc.md"), "build", "", filepath.Join("build", "doc-2.png"), filepath.Join("..", "build", "doc-2.png")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diagramImagePath(tt.output, ".png", tt.artefacts, tt.imageDir, 2)
+			if got != tt.want {
+				t.Errorf("diagramImagePath() = %q, want %q", got, tt.want)
+			}
+			if link := imageLinkPath(tt.output, got); link != tt.link {
+				t.Errorf("imageLinkPath() = %q, want %q", link, tt.link)
+			}
+		})
+	}
+}
+
+func TestArtefactsDir(t *testing.T) {
+	input := filepath.Join("docs", "guide", "index.md")
+	abs := filepath.Join(t.TempDir(), "images")
+	tests := []struct {
+		name       string
+		artefacts  string
+		relativeTo string
+		input      string
+		want       string
+	}{
+		{"none", "", "input", input, ""},
+		{"cwd", "images", "cwd", input, "images"},
+		{"cwd by default", "images", "", input, "images"},
+		{"input", "images", "input", input, filepath.Join("docs", "guide", "images")},
+		{"input with parent dir", filepath.Join("..", "assets"), "input", input, filepath.Join("docs", "assets")},
+		{"input with absolute path", abs, "input", input, abs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := artefactsDir(tt.artefacts, tt.relativeTo, tt.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("artefactsDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArtefactsDir_Errors(t *testing.T) {
+	if _, err := artefactsDir("images", "output", "doc.md"); err == nil {
+		t.Error("expected an error for an unknown --artefactsRelativeTo")
+	}
+	for _, input := range []string{"", "-"} {
+		if _, err := artefactsDir("images", "input", input); err == nil {
+			t.Errorf("expected an error for stdin input %q", input)
+		}
+	}
+	if _, err := artefactsDir("images", "input", "https://example.com/doc.md"); err == nil {
+		t.Error("expected an error for remote input")
+	}
+}
+
+func TestSelectDiagram(t *testing.T) {
+	blocks := markdown.ExtractDiagrams("```mermaid\ngraph TD; A-->B\n```\n\n```mermaid\ngraph TD; C-->D\n```\n")
+
+	block, err := selectDiagram(blocks, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Index != 2 || block.Definition != "graph TD; C-->D" {
+		t.Errorf("unexpected block %+v", block)
+	}
+
+	for _, n := range []int{0, 3} {
+		_, err := selectDiagram(blocks, n)
+		if err == nil || !strings.Contains(err.Error(), "has 2 mermaid charts") {
+			t.Errorf("selectDiagram(%d) error = %v, want one naming the available count", n, err)
+		}
+	}
+
+	if _, err := selectDiagram(nil, 1); err == nil {
+		t.Error("expected error for input without diagrams")
+	}
+}
+
+func TestStdoutFormat(t *testing.T) {
+	tests := []struct {
+		format        string
+		want          string
+		wantDefaulted bool
+	}{
+		{"", "svg", true},
+		{"svg", "svg", false},
+		{"png", "png", false},
+		{"jpeg", "jpeg", false},
+		{"pdf", "pdf", false},
+	}
+	for _, tt := range tests {
+		got, defaulted := stdoutFormat(tt.format)
+		if got != tt.want || defaulted != tt.wantDefaulted {
+			t.Errorf("stdoutFormat(%q) = %q, %v, want %q, %v", tt.format, got, defaulted, tt.want, tt.wantDefaulted)
+		}
+	}
+}
+
+func TestCheckFormatConflict(t *testing.T) {
+	tests := []struct {
+		output   string
+		format   string
+		conflict bool
+	}{
+		{"diagram.svg", "svg", false},
+		{"diagram.PNG", "png", false},
+		{"diagram.jpg", "jpeg", false},
+		{"doc.md", "png", false},
+		{"doc.markdown", "pdf", false},
+		{"/dev/stdout", "png", false},
+		{"diagram.svg", "png", true},
+		{"diagram.pdf", "svg", true},
+		{"diagram.png", "jpeg", true},
+	}
+	for _, tt := range tests {
+		err := checkFormatConflict(tt.output, tt.format)
+		if (err != nil) != tt.conflict {
+			t.Errorf("checkFormatConflict(%q, %q) = %v, want conflict=%v", tt.output, tt.format, err, tt.conflict)
+		}
+	}
+}
+
+func TestDumpPageHTML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "page.html")
+	definition := "graph TD; Start---Finish"
+
+	if err := dumpPageHTML(path, definition, renderer.RenderOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), definition) {
+		t.Error("expected the dumped page to contain the definition")
+	}
+	if !strings.Contains(string(data), "<!DOCTYPE html>") {
+		t.Error("expected a complete HTML page")
+	}
+}
+
+func TestNumberedPath(t *testing.T) {
+	if got := numberedPath(filepath.Join("debug", "page.html"), 2); got != filepath.Join("debug", "page-2.html") {
+		t.Errorf("unexpected path %q", got)
+	}
+	if got := numberedPath("page", 1); got != "page-1" {
+		t.Errorf("unexpected path %q", got)
+	}
+}
+
+func TestScaleFlagAcceptsFloats(t *testing.T) {
+	for arg, want := range map[string]float64{"1.5": 1.5, "2": 2, "2.5": 2.5} {
+		cmd := NewRootCommand()
+		if err := cmd.ParseFlags([]string{"--scale", arg}); err != nil {
+			t.Fatalf("--scale %s: %v", arg, err)
+		}
+		got, err := cmd.Flags().GetFloat64("scale")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("--scale %s = %v, want %v", arg, got, want)
+		}
+	}
+}
+
+func TestBackgroundColorPrecedence(t *testing.T) {
+	dark := config.MermaidConfig{"theme": "dark"}
+	custom := config.MermaidConfig{"theme": "default", "themeVariables": map[string]interface{}{"background": "#fdf6e3"}}
+	tests := []struct {
+		name string
+		args []string
+		cfg  config.MermaidConfig
+		want string
+	}{
+		{"default theme keeps white", nil, config.MermaidConfig{"theme": "default"}, "white"},
+		{"dark theme implies its background", nil, dark, "#333"},
+		{"themeVariables background", nil, custom, "#fdf6e3"},
+		{"explicit flag wins over the theme", []string{"--backgroundColor", "white"}, dark, "white"},
+		{"explicit short flag wins over themeVariables", []string{"-b", "transparent"}, custom, "transparent"},
+	}
+	for _, tt := range tests {
+		cmd := NewRootCommand()
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		bg, err := cmd.Flags().GetString("backgroundColor")
+		if err != nil {
+			t.Fatal(err)
+		}
+		flags := &Flags{BackgroundColor: bg}
+		markSetFlags(cmd, flags)
+		if got := backgroundColor(flags.BackgroundColor, flags.optionSet(), tt.cfg); got != tt.want {
+			t.Errorf("%s: background = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMarkSetFlags(t *testing.T) {
+	cmd := NewRootCommand()
+	if err := cmd.ParseFlags([]string{"-w", "800", "--scale", "1", "-q"}); err != nil {
+		t.Fatal(err)
+	}
+	flags := &Flags{}
+	markSetFlags(cmd, flags)
+
+	// Given at their default values, width and scale still count as set
+	for _, name := range []string{"width", "scale", "quiet"} {
+		if !flags.isSet(name) {
+			t.Errorf("expected %s to be set", name)
+		}
+	}
+	for _, name := range []string{"height", "backgroundColor", "theme"} {
+		if flags.isSet(name) {
+			t.Errorf("expected %s not to be set", name)
+		}
+	}
+
+	set := flags.optionSet()
+	if !set.Has(renderer.SetWidth|renderer.SetScale) || set.Has(renderer.SetHeight) || set.Has(renderer.SetBackgroundColor) {
+		t.Errorf("optionSet() = %b, want width and scale", set)
+	}
+}
+
+func TestIsSet_WithoutCommandLine(t *testing.T) {
+	// Flags built directly, as in tests and embedders, count every value as given
+	flags := &Flags{Width: 1024}
+	if !flags.isSet("width") || !flags.isSet("backgroundColor") {
+		t.Error("expected every flag to count as set without a parsed command line")
+	}
+	if w, h := pageSize(flags); w != 1024 || h != 0 {
+		t.Errorf("pageSize() = %dx%d, want 1024x0", w, h)
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	tests := []struct {
+		args []string
+		w, h int
+	}{
+		{nil, 0, 0},
+		{[]string{"--width", "800"}, 800, 0},
+		{[]string{"-H", "900"}, 0, 900},
+		{[]string{"-w", "640", "-H", "480"}, 640, 480},
+	}
+	for _, tt := range tests {
+		cmd := NewRootCommand()
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		flags := &Flags{}
+		flags.Width, _ = cmd.Flags().GetInt("width")
+		flags.Height, _ = cmd.Flags().GetInt("height")
+		markSetFlags(cmd, flags)
+
+		// Unset sides are 0 so the diagram type picks them, even though the flags default to 800x600
+		if w, h := pageSize(flags); w != tt.w || h != tt.h {
+			t.Errorf("%v: pageSize() = %dx%d, want %dx%d", tt.args, w, h, tt.w, tt.h)
+		}
+	}
+}
+
+func TestRunDefinition(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.mmd")
+	if err := os.WriteFile(file, []byte("graph TD; A-->B"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "out.png")
+	markdownDef := "# Doc\n\n```mermaid\ngraph TD; A-->B\n```\n"
+
+	tests := []struct {
+		name    string
+		flags   Flags
+		wantErr string
+	}{
+		{"with --input", Flags{Definition: "graph TD; A-->B", Input: file}, "--input and --definition can't be used together"},
+		{"with --input stdin", Flags{Definition: "graph TD; A-->B", Input: "-"}, "--input and --definition can't be used together"},
+		// The definition is used as the input: it is sniffed as markdown with one block, so
+		// selecting a second block fails without reading stdin or launching a browser
+		{"used as input", Flags{Definition: markdownDef, Diagram: 2}, "Markdown input has 1 mermaid charts"},
+		// stdin is free for a config file, since the diagram doesn't come from it
+		{"stdin for config", Flags{Definition: markdownDef, Diagram: 2, PuppeteerConfigFile: "-", ConfigFiles: []string{"-"}}, "stdin can only be read once, but it is requested by --configFile and --puppeteerConfigFile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := tt.flags
+			flags.Output = output
+			flags.Scale = 1
+			flags.NoConfig = true
+			err := run(&flags)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if got := ExitCode(err); got != exitUsage {
+				t.Errorf("exit code = %d, want %d", got, exitUsage)
+			}
+		})
+	}
+}
+
+func TestBlockRenderOpts_Theme(t *testing.T) {
+	blocks := markdown.ExtractDiagrams("```mermaid {theme=dark}\nsequenceDiagram\n  A->>B: hi\n```\n\n" +
+		"```mermaid\n---\ntheme: forest\n---\ngraph TD; A-->B\n```\n\n" +
+		"```mermaid\ngraph TD; A-->B\n```\n")
+	opts := renderer.RenderOpts{MermaidConfig: config.MermaidConfig{"theme": "default", "securityLevel": "strict"}}
+
+	want := []string{"dark", "forest", "default"}
+	for i, block := range blocks {
+		got := blockRenderOpts(block, opts).MermaidConfig
+		if got["theme"] != want[i] {
+			t.Errorf("block %d: theme = %v, want %q", block.Index, got["theme"], want[i])
+		}
+		if got["securityLevel"] != "strict" {
+			t.Errorf("block %d: expected the rest of the config to be kept, got %v", block.Index, got)
+		}
+	}
+	if opts.MermaidConfig["theme"] != "default" {
+		t.Errorf("expected the global config to be unchanged, got %v", opts.MermaidConfig)
+	}
+}
+
+func TestBlockRenderOpts_ThemeBackground(t *testing.T) {
+	blocks := markdown.ExtractDiagrams("```mermaid {theme=dark}\ngraph TD; A-->B\n```\n\n" +
+		"```mermaid {theme=default}\ngraph TD; A-->B\n```\n")
+	dark := renderer.RenderOpts{MermaidConfig: config.MermaidConfig{"theme": "dark"}, BackgroundColor: "#333"}
+	given := renderer.RenderOpts{MermaidConfig: config.MermaidConfig{"theme": "default"}, BackgroundColor: "red", Set: renderer.SetBackgroundColor}
+
+	tests := []struct {
+		name  string
+		block markdown.DiagramBlock
+		opts  renderer.RenderOpts
+		want  string
+	}{
+		{"block theme sets the background", blocks[0], renderer.RenderOpts{MermaidConfig: config.MermaidConfig{"theme": "default"}, BackgroundColor: "white"}, "#333"},
+		{"block theme without a background resets it", blocks[1], dark, "white"},
+		{"explicit flag wins over the block theme", blocks[0], given, "red"},
+	}
+	for _, tt := range tests {
+		if got := blockRenderOpts(tt.block, tt.opts).BackgroundColor; got != tt.want {
+			t.Errorf("%s: background = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiagramSizing(t *testing.T) {
+	tests := []struct {
+		def  string
+		opts renderer.RenderOpts
+		want string
+	}{
+		{"gitGraph\n  commit", renderer.RenderOpts{}, "diagram type: gitGraph (detected), page size: 1200x600"},
+		{"gitGraph\n  commit", renderer.RenderOpts{Width: 640}, "diagram type: gitGraph (detected), page size: 640x600"},
+		{"gitGraph\n  commit", renderer.RenderOpts{Width: 800, Set: renderer.SetWidth}, "diagram type: gitGraph (detected), page size: 800x600 (from --width)"},
+		{"mindmap\n  root", renderer.RenderOpts{Height: 500, Set: renderer.SetHeight}, "diagram type: mindmap (detected), page size: 1200x500 (from --height)"},
+		{"graph TD", renderer.RenderOpts{Width: 640, Height: 480, Set: renderer.SetWidth | renderer.SetHeight}, "diagram type: flowchart (detected), page size: 640x480 (from --width/--height)"},
+		{"myTimeline\n  2020 : a", renderer.RenderOpts{DiagramType: "timeline"}, "diagram type: timeline (from --diagramType), page size: 1400x600"},
+		{"", renderer.RenderOpts{}, "diagram type: unknown (detected), page size: 800x600"},
+	}
+	for _, tt := range tests {
+		if got := diagramSizing(tt.def, tt.opts); got != tt.want {
+			t.Errorf("diagramSizing(%q) = %q, want %q", tt.def, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+)
+
+// noColor is set by the --no-color flag.
+var noColor bool
+
+// colorEnabled reports whether ANSI colors should be written to stderr. Colors are
+// disabled by --no-color, by a non-empty NO_COLOR environment variable
+// (https://no-color.org) and when stderr is not a terminal.
+func colorEnabled() bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(os.Stderr)
+}
+
+// colorize wraps s in red ANSI escapes when enabled, returning it unchanged otherwise.
+func colorize(s string, enabled bool) string {
+	if !enabled {
+		return s
+	}
+	return "\033[31m" + s + "\033[0m"
+}
+
+// PrintError prints an error to stderr, in red when colors are enabled.
+func PrintError(err error) {
+	fmt.Fprintf(os.Stderr, "%s\n", colorize("\n"+err.Error(), colorEnabled()))
+}
@@ -0,0 +1,27 @@
+package cli
+
+import "testing"
+
+func TestColorize(t *testing.T) {
+	if got := colorize("boom", true); got != "\033[31mboom\033[0m" {
+		t.Errorf("expected red escapes, got %q", got)
+	}
+	if got := colorize("boom", false); got != "boom" {
+		t.Errorf("expected plain text, got %q", got)
+	}
+}
+
+func TestColorEnabled_NoColorEnv(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	if colorEnabled() {
+		t.Error("expected colors to be disabled when NO_COLOR is set")
+	}
+}
+
+func TestColorEnabled_NoColorFlag(t *testing.T) {
+	noColor = true
+	defer func() { noColor = false }()
+	if colorEnabled() {
+		t.Error("expected colors to be disabled by --no-color")
+	}
+}
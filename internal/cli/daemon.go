@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/coolamit/mermaid-cli/internal/config"
+	"github.com/coolamit/mermaid-cli/internal/daemon"
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+	"github.com/spf13/cobra"
+)
+
+// diagramRenderer renders diagrams either in-process or through the daemon.
+type diagramRenderer interface {
+	Render(ctx context.Context, definition string, outputFormat string, opts renderer.RenderOpts) (*renderer.RenderResult, error)
+	Close()
+}
+
+// newDiagramRenderer returns a daemon client if --daemon is set and a daemon is reachable,
+// falling back to a local browser otherwise.
+func newDiagramRenderer(flags *Flags, browserConfig *config.BrowserConfig, lg logger) diagramRenderer {
+	if flags.Daemon && (flags.MermaidJS != "" || flags.MermaidZenUMLJS != "") {
+		// The daemon renders with its own bundles, so custom ones require a local browser
+		lg.logf(levelInfo, "Custom mermaid bundles are not supported by the render daemon, rendering locally")
+	} else if flags.Daemon {
+		client := daemon.NewClient(flags.Socket)
+		if client.Available() {
+			lg.logf(levelInfo, "Using render daemon at %s", flags.Socket)
+			return client
+		}
+		lg.logf(levelInfo, "No render daemon listening at %s, rendering locally", flags.Socket)
+	}
+
+	return renderer.NewRenderer(renderer.NewBrowsers(browserConfig, flags.Browsers))
+}
+
+// newDaemonCommand creates the `daemon` subcommand which keeps a warm browser
+// listening on a Unix socket for `mmd-cli --daemon` clients.
+func newDaemonCommand() *cobra.Command {
+	var socketPath string
+	var browserConfigFile string
+	var browserFlags []string
+	var tabPool int
+	var browsers int
+	var sandbox bool
+	var metricsAddr string
+	var shutdownTimeout int
+	var quiet bool
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Run a render daemon that keeps the browser warm between invocations",
+		Long: "Starts a long-running process that holds a headless browser and serves render requests " +
+			"on a Unix socket. Use `mmd-cli --daemon` to render through it.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lg := newLogger(verbosityLevel(quiet, 0))
+
+			browserConfig, err := config.LoadBrowserConfig(browserConfigFile)
+			if err != nil {
+				return err
+			}
+			browserConfig.Args = append(browserConfig.Args, browserFlags...)
+			if tabPool < 0 {
+				return fmt.Errorf("invalid --tabPool %d, must be a positive number", tabPool)
+			}
+			if tabPool > 0 {
+				browserConfig.TabPoolSize = tabPool
+			}
+			if sandbox {
+				browserConfig.Sandbox = true
+			}
+			if browsers < 0 {
+				return fmt.Errorf("invalid --browsers %d, must not be negative", browsers)
+			}
+
+			if shutdownTimeout < 0 {
+				return fmt.Errorf("invalid --shutdownTimeout %d, must not be negative", shutdownTimeout)
+			}
+			grace := time.Duration(shutdownTimeout) * time.Millisecond
+
+			r := renderer.NewRenderer(renderer.NewBrowsers(browserConfig, browsers))
+			defer r.Close()
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			var handler daemon.DiagramRenderer = r
+			if metricsAddr != "" {
+				metrics := daemon.NewMetrics(r)
+				handler = metrics.Instrument(r)
+
+				metricsServer, err := serveMetrics(metricsAddr, metrics)
+				if err != nil {
+					return err
+				}
+				defer metricsServer.Close()
+				lg.logf(levelInfo, "Serving metrics on http://%s/metrics", metricsServer.Addr)
+			}
+
+			server := daemon.NewServer(handler)
+			if err := server.Listen(socketPath); err != nil {
+				return err
+			}
+			defer os.Remove(socketPath)
+
+			go func() {
+				<-ctx.Done()
+				lg.logf(levelInfo, "Shutting down render daemon, waiting up to %s for in-flight renders", grace)
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+				defer cancel()
+				if err := server.Shutdown(shutdownCtx); err != nil {
+					lg.logf(levelInfo, "Shutdown timeout reached, cancelled the remaining renders")
+				}
+			}()
+
+			lg.logf(levelInfo, "Render daemon listening on %s", socketPath)
+			// Renders use a background context so the shared browser outlives any single
+			// request and in-flight renders can finish after a shutdown signal.
+			if err := server.Serve(context.Background()); err != nil {
+				return err
+			}
+
+			// Serve has waited for every request, so this only closes the browser once
+			// any render still holding a tab has released it.
+			closeCtx, cancel := context.WithTimeout(context.Background(), grace)
+			defer cancel()
+			_ = r.Shutdown(closeCtx)
+			return nil
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.Flags().StringVar(&socketPath, "socket", daemon.DefaultSocketPath(), "Unix socket path to listen on")
+	cmd.Flags().StringVarP(&browserConfigFile, "puppeteerConfigFile", "p", "", "JSON configuration file for the browser")
+	cmd.Flags().StringArrayVar(&browserFlags, "browserFlag", nil, "Extra Chrome command-line flag, e.g. --browserFlag=--lang=en-US. Can be repeated")
+	cmd.Flags().BoolVar(&sandbox, "sandbox", false, "Run Chrome with its sandbox enabled (needs user namespaces or a setuid sandbox helper; not available as root)")
+	cmd.Flags().IntVar(&tabPool, "tabPool", 0, "Maximum number of browser tabs rendering concurrently. Default: the browser config \"tabPool\", else 4")
+	cmd.Flags().IntVar(&browsers, "browsers", 1, "Number of independent browser processes to spread renders over, each with --tabPool tabs, so a crash only fails that browser's renders")
+	cmd.Flags().IntVar(&shutdownTimeout, "shutdownTimeout", 30000, "Milliseconds to let in-flight renders finish after SIGTERM/SIGINT before cancelling them")
+	cmd.Flags().StringVar(&metricsAddr, "metrics", "", "Serve Prometheus metrics over HTTP at this address, e.g. :9464 (path /metrics). Default: disabled")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Suppress log output")
+
+	return cmd
+}
+
+// serveMetrics starts an HTTP server exposing metrics at /metrics on addr. The returned
+// server's Addr is the address actually bound, so ":0" picks a free port.
+func serveMetrics(addr string, metrics *daemon.Metrics) (*http.Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics on %q: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	server := &http.Server{Addr: l.Addr().String(), Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() { _ = server.Serve(l) }()
+	return server, nil
+}
@@ -0,0 +1,22 @@
+package cli
+
+import "encoding/base64"
+
+// dataURIMimeTypes maps output formats to the MIME type of their data URI.
+var dataURIMimeTypes = map[string]string{
+	"svg":  "image/svg+xml",
+	"png":  "image/png",
+	"jpeg": "image/jpeg",
+	"gif":  "image/gif",
+	"pdf":  "application/pdf",
+}
+
+// dataURI encodes a rendered diagram as a base64 data URI, e.g. "data:image/png;base64,iVBO...",
+// for inlining into HTML or CSS.
+func dataURI(outputFormat string, data []byte) string {
+	mime, ok := dataURIMimeTypes[outputFormat]
+	if !ok {
+		mime = "application/octet-stream"
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDataURI(t *testing.T) {
+	data := []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"/>")
+	tests := []struct {
+		format string
+		prefix string
+	}{
+		{"svg", "data:image/svg+xml;base64,"},
+		{"png", "data:image/png;base64,"},
+		{"jpeg", "data:image/jpeg;base64,"},
+		{"pdf", "data:application/pdf;base64,"},
+	}
+	for _, tt := range tests {
+		got := dataURI(tt.format, data)
+		payload, ok := strings.CutPrefix(got, tt.prefix)
+		if !ok {
+			t.Errorf("dataURI(%q) = %q, want prefix %q", tt.format, got, tt.prefix)
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			t.Errorf("dataURI(%q) payload is not base64: %v", tt.format, err)
+			continue
+		}
+		if string(decoded) != string(data) {
+			t.Errorf("dataURI(%q) decodes to %q, want %q", tt.format, decoded, data)
+		}
+	}
+}
+
+func TestDataURI_Empty(t *testing.T) {
+	if got := dataURI("png", nil); got != "data:image/png;base64," {
+		t.Errorf("unexpected data URI %q", got)
+	}
+}
+
+func TestRunDataURIRequiresSingleDiagram(t *testing.T) {
+	err := run(&Flags{Definition: "# Doc\n\n```mermaid\ngraph TD; A-->B\n```\n", DataURI: true, Scale: 1, NoConfig: true})
+	if err == nil || !strings.Contains(err.Error(), "--dataUri can only be used with a single diagram") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := ExitCode(err); got != exitUsage {
+		t.Errorf("exit code = %d, want %d", got, exitUsage)
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"errors"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+// Process exit codes. `check` uses exitFailed for diagrams that fail to render, so
+// its contract stays 0/1/2; rendering commands use the more specific codes.
+const (
+	exitOK            = 0
+	exitFailed        = 1 // any other error, e.g. failing to write output
+	exitUsage         = 2 // invalid flags, arguments or config
+	exitInputNotFound = 3 // the input file, directory or URL can't be read
+	exitRender        = 4 // mermaid failed to render a diagram, e.g. a syntax error
+	exitBrowser       = 5 // the browser could not be launched
+)
+
+// exitError carries a specific process exit code along with an error.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+func (e *exitError) Unwrap() error { return e.err }
+
+// usageError marks err as a usage error (exit code 2).
+func usageError(err error) error {
+	return &exitError{code: exitUsage, err: err}
+}
+
+// inputNotFoundError marks err as a failure to read the input (exit code 3).
+func inputNotFoundError(err error) error {
+	return &exitError{code: exitInputNotFound, err: err}
+}
+
+// renderError marks an error returned by a renderer: a browser that failed to launch
+// (exit code 5) or a diagram that failed to render (exit code 4).
+func renderError(err error) error {
+	if errors.Is(err, renderer.ErrBrowserStart) {
+		return &exitError{code: exitBrowser, err: err}
+	}
+	return &exitError{code: exitRender, err: err}
+}
+
+// ExitCode returns the process exit code for an error returned by the root command:
+// 0 for nil, the code carried by the error if any, and 1 otherwise.
+func ExitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	var ee *exitError
+	if errors.As(err, &ee) {
+		return ee.code
+	}
+	return exitFailed
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{errors.New("render failed"), 1},
+		{&exitError{code: exitFailed, err: errors.New("2 of 4 diagrams failed to render")}, 1},
+		{usageError(errors.New("no files match")), 2},
+		{fmt.Errorf("wrapped: %w", usageError(errors.New("bad flag"))), 2},
+		{inputNotFoundError(errors.New("input file doesn't exist")), 3},
+		{renderError(errors.New("mermaid rendering error: Parse error")), 4},
+		{renderError(fmt.Errorf("%w: exec: chrome not found", renderer.ErrBrowserStart)), 5},
+	}
+	for _, tt := range tests {
+		if got := ExitCode(tt.err); got != tt.want {
+			t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.mmd")
+	configDir := t.TempDir()
+	writeFiles(t, configDir, map[string]string{"typo.json": `{"them":"dark"}`})
+	tests := []struct {
+		name  string
+		flags Flags
+		want  int
+	}{
+		{"missing input", Flags{Input: missing, Scale: 1}, exitInputNotFound},
+		{"missing input dir", Flags{InputDir: missing, Scale: 1}, exitInputNotFound},
+		{"invalid scale", Flags{Input: "-", Scale: 0}, exitUsage},
+		{"invalid input format", Flags{Input: "-", InputFormat: "html", Scale: 1}, exitUsage},
+		{"invalid securityLevel", Flags{Input: "-", SecurityLevel: "none", Scale: 1}, exitUsage},
+		{"invalid diagramType", Flags{Input: "-", DiagramType: "myDiagram", Scale: 1}, exitUsage},
+		{"invalid pngColors", Flags{Input: "-", OutputFormat: "png", Output: "-", PNGColors: 300, Scale: 1}, exitUsage},
+		{"pngColors without png", Flags{Input: "-", OutputFormat: "svg", Output: "-", PNGColors: 16, Scale: 1}, exitUsage},
+		{"invalid dpi", Flags{Input: "-", OutputFormat: "pdf", Output: "-", DPI: 10, Scale: 1}, exitUsage},
+		{"dpi without pdf", Flags{Input: "-", OutputFormat: "png", Output: "-", DPI: 300, Scale: 1}, exitUsage},
+		{"invalid clip", Flags{Input: "-", OutputFormat: "png", Output: "-", Clip: "1,2,3", Scale: 1}, exitUsage},
+		{"clip with svg", Flags{Input: "-", OutputFormat: "svg", Output: "-", Clip: "0,0,10,10", Scale: 1}, exitUsage},
+		{"clip with pdf without pdfFit", Flags{Input: "-", OutputFormat: "pdf", Output: "-", Clip: "0,0,10,10", Scale: 1}, exitUsage},
+		{"watermark with pdf", Flags{Input: "-", OutputFormat: "pdf", Output: "-", Watermark: "logo.png", Scale: 1}, exitUsage},
+		{"missing postScript", Flags{Input: "-", Output: "-", OutputFormat: "svg", PostScript: missing, Scale: 1}, exitUsage},
+		{"missing watermark", Flags{Input: "-", OutputFormat: "png", Output: "-", Watermark: missing, Scale: 1}, exitUsage},
+		{"invalid browsers", Flags{Input: "-", OutputFormat: "svg", Output: "-", Browsers: -1, Scale: 1}, exitUsage},
+		{"unknown preset", Flags{Input: "-", OutputFormat: "svg", Output: "-", Preset: "solarized", Scale: 1}, exitUsage},
+		{"invalid iconCdn", Flags{Input: "-", OutputFormat: "svg", Output: "-", IconCDN: "fastly", Scale: 1}, exitUsage},
+		{"invalid svgMode", Flags{Input: "-", Output: "-", SVGMode: "embedded", Scale: 1}, exitUsage},
+		{"svgMode without svg", Flags{Input: "-", Output: "-", OutputFormat: "png", SVGMode: "inline", Scale: 1}, exitUsage},
+		{"deterministicIds without svg", Flags{Input: "-", Output: "-", OutputFormat: "png", DeterministicIDs: true, Scale: 1}, exitUsage},
+		{"svgDecl with inline svgMode", Flags{Input: "-", Output: "-", OutputFormat: "svg", SVGMode: "inline", SVGDecl: true, Scale: 1}, exitUsage},
+		{"invalid CSS scope", Flags{Input: "-", CSSScope: "document", Scale: 1}, exitUsage},
+		{"cleanSvgAttr without cleanSvg", Flags{Input: "-", CleanSVGAttrs: []string{"aria-roledescription"}, Scale: 1}, exitUsage},
+		{"manifest without markdown", Flags{Definition: "graph TD; A-->B", OutputFormat: "svg", Output: "-", Manifest: filepath.Join(t.TempDir(), "manifest.json"), Scale: 1}, exitUsage},
+		{"artefacts relative to stdin input", Flags{Input: "-", Output: filepath.Join(t.TempDir(), "out.md"), InputFormat: "markdown", Artefacts: "images", ArtefactsRelativeTo: "input", Scale: 1}, exitUsage},
+		{"invalid artefactsRelativeTo", Flags{Definition: "graph TD; A-->B", Output: "-", ArtefactsRelativeTo: "output", Scale: 1}, exitUsage},
+		{"imageDir without markdown output", Flags{Definition: "```mermaid\ngraph TD; A-->B\n```\n", Output: filepath.Join(t.TempDir(), "doc.svg"), ImageDir: "assets", Scale: 1}, exitUsage},
+		{"imageDir with artefacts", Flags{Definition: "```mermaid\ngraph TD; A-->B\n```\n", Output: filepath.Join(t.TempDir(), "doc.md"), Artefacts: t.TempDir(), ImageDir: "assets", Scale: 1}, exitUsage},
+		{"strictConfig with an unknown key", Flags{Definition: "graph TD; A-->B", Output: "-", ConfigFiles: []string{filepath.Join(configDir, "typo.json")}, StrictConfig: true, Scale: 1}, exitUsage},
+		{"frames without gif", Flags{Frames: []string{"a.mmd"}, OutputFormat: "png", Output: "-", Scale: 1}, exitUsage},
+		{"frames with input", Flags{Input: "a.mmd", Frames: []string{"b.mmd"}, OutputFormat: "gif", Output: "-", Scale: 1}, exitUsage},
+		{"gif to markdown", Flags{Definition: "graph TD; A-->B", OutputFormat: "gif", Output: filepath.Join(t.TempDir(), "doc.md"), FrameDelay: 1000, Force: true, Scale: 1}, exitUsage},
+		{"invalid frameDelay", Flags{Definition: "graph TD; A-->B", OutputFormat: "gif", Output: "-", Scale: 1}, exitUsage},
+		{"missing frame", Flags{Frames: []string{missing}, OutputFormat: "gif", Output: "-", FrameDelay: 1000, Scale: 1}, exitInputNotFound},
+		{"diffDir without baseline", Flags{Definition: "graph TD; A-->B", Output: filepath.Join(t.TempDir(), "out.svg"), DiffDir: t.TempDir(), Scale: 1}, exitUsage},
+		{"failOnChange without baseline", Flags{Definition: "graph TD; A-->B", Output: filepath.Join(t.TempDir(), "out.svg"), FailOnChange: true, Scale: 1}, exitUsage},
+		{"strictEnv without expandEnv", Flags{Definition: "graph TD; A-->B", Output: "-", StrictEnv: true, Scale: 1}, exitUsage},
+		{"missing baseline", Flags{Definition: "graph TD; A-->B", Output: filepath.Join(t.TempDir(), "out.svg"), Baseline: missing, Scale: 1}, exitUsage},
+		{"outputDir without inputDir", Flags{Input: "-", OutputDir: "out", Scale: 1}, exitUsage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := tt.flags
+			if got := ExitCode(run(&flags)); got != tt.want {
+				t.Errorf("exit code = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknownFlagIsUsageError(t *testing.T) {
+	cmd := NewRootCommand()
+	cmd.SetArgs([]string{"--noSuchFlag"})
+	if got := ExitCode(cmd.Execute()); got != exitUsage {
+		t.Errorf("exit code = %d, want %d", got, exitUsage)
+	}
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// incrementalManifest records what an --incremental markdown run rendered, so the next
+// run can reuse the images of blocks that haven't changed.
+type incrementalManifest struct {
+	Diagrams []incrementalEntry `json:"diagrams"`
+}
+
+// incrementalEntry is one rendered block. Key is the render cache key of the block, which
+// covers its definition, the output format, the render options and the mermaid bundle.
+type incrementalEntry struct {
+	Key   string `json:"key"`
+	File  string `json:"file"` // relative to the output markdown's directory
+	Title string `json:"title,omitempty"`
+	Desc  string `json:"desc,omitempty"`
+}
+
+// reusedDiagram is a previously rendered block whose image can be written again as is.
+type reusedDiagram struct {
+	data  []byte
+	title string
+	desc  string
+}
+
+// incrementalManifestPath returns the sidecar manifest of a markdown output,
+// e.g. docs/guide.md -> docs/.guide.md.mmd-incremental.json.
+func incrementalManifestPath(output string) string {
+	return filepath.Join(filepath.Dir(output), "."+filepath.Base(output)+".mmd-incremental.json")
+}
+
+// readIncrementalManifest reads the manifest at path. A missing or unreadable manifest
+// means there is nothing to reuse, so it yields an empty manifest.
+func readIncrementalManifest(path string) (*incrementalManifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &incrementalManifest{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read incremental manifest: %w", err)
+	}
+	var m incrementalManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return &incrementalManifest{}, nil
+	}
+	return &m, nil
+}
+
+// writeIncrementalManifest writes m to path.
+func writeIncrementalManifest(path string, m *incrementalManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize incremental manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write incremental manifest: %w", err)
+	}
+	return nil
+}
+
+// unchanged returns, by key, the previous renders that can be reused for the given block
+// keys: those recorded in the manifest whose image still exists under dir. Images are
+// read up front because this run may overwrite them, e.g. when blocks are reordered.
+func (m *incrementalManifest) unchanged(dir string, keys []string) map[string]reusedDiagram {
+	wanted := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		wanted[k] = true
+	}
+
+	reused := make(map[string]reusedDiagram)
+	for _, e := range m.Diagrams {
+		if !wanted[e.Key] {
+			continue
+		}
+		if _, ok := reused[e.Key]; ok {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(e.File)))
+		if err != nil {
+			continue
+		}
+		reused[e.Key] = reusedDiagram{data: data, title: e.Title, desc: e.Desc}
+	}
+	return reused
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIncrementalManifestPath(t *testing.T) {
+	got := incrementalManifestPath(filepath.Join("docs", "guide.md"))
+	want := filepath.Join("docs", ".guide.md.mmd-incremental.json")
+	if got != want {
+		t.Errorf("incrementalManifestPath() = %q, want %q", got, want)
+	}
+}
+
+func TestIncrementalManifest_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".out.md.mmd-incremental.json")
+
+	empty, err := readIncrementalManifest(path)
+	if err != nil {
+		t.Fatalf("missing manifest should not be an error: %v", err)
+	}
+	if len(empty.Diagrams) != 0 {
+		t.Errorf("expected an empty manifest, got %+v", empty)
+	}
+
+	m := &incrementalManifest{Diagrams: []incrementalEntry{{Key: "k1", File: "out-1.svg", Title: "Flow"}}}
+	if err := writeIncrementalManifest(path, m); err != nil {
+		t.Fatal(err)
+	}
+	read, err := readIncrementalManifest(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.Diagrams) != 1 || read.Diagrams[0] != m.Diagrams[0] {
+		t.Errorf("read back %+v, want %+v", read, m)
+	}
+
+	if err := os.WriteFile(path, []byte("{corrupt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if corrupt, err := readIncrementalManifest(path); err != nil || len(corrupt.Diagrams) != 0 {
+		t.Errorf("expected a corrupt manifest to be treated as empty, got %+v, %v", corrupt, err)
+	}
+}
+
+func TestIncrementalManifest_Unchanged(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"out-1.svg":        "<svg>one</svg>",
+		"out-2.svg":        "<svg>two</svg>",
+		"images/out-3.svg": "<svg>three</svg>",
+	})
+	previous := &incrementalManifest{Diagrams: []incrementalEntry{
+		{Key: "one", File: "out-1.svg", Title: "One"},
+		{Key: "two", File: "out-2.svg", Desc: "Second"},
+		{Key: "three", File: "images/out-3.svg"},
+		{Key: "gone", File: "out-4.svg"}, // image deleted since the last run
+	}}
+
+	tests := []struct {
+		name string
+		keys []string
+		want []string
+	}{
+		{"nothing changed", []string{"one", "two", "three"}, []string{"one", "two", "three"}},
+		{"one block edited", []string{"one", "edited", "three"}, []string{"one", "three"}},
+		{"blocks reordered", []string{"three", "one"}, []string{"three", "one"}},
+		{"options changed", []string{"one'", "two'", "three'"}, nil},
+		{"image missing", []string{"gone"}, nil},
+		{"duplicate blocks", []string{"two", "two"}, []string{"two"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reused := previous.unchanged(dir, tt.keys)
+			if len(reused) != len(tt.want) {
+				t.Fatalf("reused %d diagrams, want %d: %v", len(reused), len(tt.want), reused)
+			}
+			for _, k := range tt.want {
+				if _, ok := reused[k]; !ok {
+					t.Errorf("expected %q to be reused", k)
+				}
+			}
+		})
+	}
+
+	reused := previous.unchanged(dir, []string{"one", "two"})
+	if string(reused["one"].data) != "<svg>one</svg>" || reused["one"].title != "One" || reused["two"].desc != "Second" {
+		t.Errorf("unexpected reused diagrams %+v", reused)
+	}
+}
@@ -0,0 +1,211 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coolamit/mermaid-cli/internal/markdown"
+)
+
+const (
+	// defaultStdinTimeout is how long to wait for the first byte of a diagram on stdin.
+	defaultStdinTimeout = 30 * time.Second
+	// fetchTimeout bounds how long fetching a remote input may take.
+	fetchTimeout = 30 * time.Second
+	// maxFetchBytes caps the size of a remote input.
+	maxFetchBytes = 10 << 20
+)
+
+// readStdin reads the diagram from stdin r. If timeout is positive and nothing arrives
+// within it, e.g. because mmd-cli was started interactively without -i, it gives up
+// instead of hanging. Once data arrives, it reads to EOF however long that takes.
+func readStdin(r io.Reader, timeout time.Duration) ([]byte, error) {
+	if timeout <= 0 {
+		return readAll(r)
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	started := make(chan struct{})
+	go func() {
+		data, err := readAll(&firstReadNotifier{r: r, started: started})
+		done <- result{data, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case res := <-done:
+		return res.data, res.err
+	case <-started:
+		res := <-done
+		return res.data, res.err
+	case <-timer.C:
+		// The read stays blocked in its goroutine; the process is about to exit anyway
+		return nil, fmt.Errorf("no input received on stdin after %s. Use `-i <input>` to render a file, "+
+			"or pipe the diagram in, e.g. `cat diagram.mmd | mmd-cli -i - -o out.svg`", timeout)
+	}
+}
+
+// readAll reads r until EOF. Unlike io.ReadAll it also accepts a wrapped io.EOF as the
+// end of input; on any other error the partial data is discarded.
+func readAll(r io.Reader) ([]byte, error) {
+	var data []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			data = append(data, buf[:n]...)
+		}
+		if errors.Is(err, io.EOF) {
+			return data, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+	}
+}
+
+// firstReadNotifier closes started once the first data has been read from r.
+type firstReadNotifier struct {
+	r       io.Reader
+	started chan struct{}
+	once    sync.Once
+}
+
+func (f *firstReadNotifier) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if n > 0 || err != nil {
+		f.once.Do(func() { close(f.started) })
+	}
+	return n, err
+}
+
+// isRemoteInput reports whether input is an http:// or https:// URL.
+func isRemoteInput(input string) bool {
+	lower := strings.ToLower(input)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// fileURLPath converts a file:// URL to a local path. ok is false if input isn't a file URL.
+func fileURLPath(input string) (p string, ok bool, err error) {
+	if !strings.HasPrefix(strings.ToLower(input), "file://") {
+		return "", false, nil
+	}
+	u, err := url.Parse(input)
+	if err != nil {
+		return "", true, fmt.Errorf("invalid file URL %q: %w", input, err)
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", true, fmt.Errorf("file URL %q must refer to a local file", input)
+	}
+	return u.Path, true, nil
+}
+
+// remoteInputName returns the last path segment of a URL, used for markdown detection
+// and to derive default output names. Falls back to "out" if the URL has no path.
+func remoteInputName(input string) string {
+	u, err := url.Parse(input)
+	if err != nil {
+		return "out"
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." || name == "" {
+		return "out"
+	}
+	return name
+}
+
+// fetchURL downloads a remote input, failing on non-2xx responses, after timeout,
+// or if the body exceeds maxBytes.
+func fetchURL(rawURL string, timeout time.Duration, maxBytes int64) ([]byte, error) {
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %q: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to fetch %q: server returned %s", rawURL, resp.Status)
+	}
+
+	// Read one byte past the limit to detect oversized responses
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %q: %w", rawURL, err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, fmt.Errorf("response from %q exceeds the %s input size limit", rawURL, formatBytes(maxBytes))
+	}
+
+	return data, nil
+}
+
+// markdownExtRegex matches input names that are treated as markdown, in any case.
+var markdownExtRegex = regexp.MustCompile(`(?i)\.(?:md|markdown)$`)
+
+// inputKind is what an input file's extension says it holds.
+type inputKind int
+
+const (
+	// inputUnknown is any other extension, such as .txt, rendered as a single diagram
+	inputUnknown inputKind = iota
+	// inputDiagram is a .mmd or .mermaid file: one diagram
+	inputDiagram
+	// inputMarkdown is a .md or .markdown file, whose mermaid blocks are rendered
+	inputMarkdown
+)
+
+// classifyInput returns the kind of input a file or URL path is, by its extension.
+func classifyInput(name string) inputKind {
+	switch {
+	case markdownExtRegex.MatchString(name):
+		return inputMarkdown
+	case diagramFileExtensions[strings.ToLower(path.Ext(name))]:
+		return inputDiagram
+	}
+	return inputUnknown
+}
+
+// isMarkdownInput decides whether input goes through the markdown pipeline. With
+// inputFormat "auto", files and URLs are judged by their extension and stdin (an empty
+// input) by whether its content contains mermaid code blocks.
+func isMarkdownInput(inputFormat, input, inputPath, content string) bool {
+	switch inputFormat {
+	case "markdown":
+		return true
+	case "mermaid":
+		return false
+	}
+	if input == "" {
+		return markdown.LooksLikeMarkdownWithDiagrams(content)
+	}
+	return classifyInput(inputPath) == inputMarkdown
+}
+
+// inputKindWarning returns a warning for an input that --inputFormat auto renders as a
+// single diagram only because of its extension, although it looks like markdown with
+// mermaid blocks, e.g. notes.txt. .mmd and .mermaid files are always single diagrams, so
+// they get no warning. It returns "" when there is nothing to warn about.
+func inputKindWarning(inputFormat, input, inputPath, content string) string {
+	if inputFormat != "auto" || input == "" || classifyInput(inputPath) != inputUnknown {
+		return ""
+	}
+	if !markdown.LooksLikeMarkdownWithDiagrams(content) {
+		return ""
+	}
+	return fmt.Sprintf("%s contains mermaid code blocks but isn't a .md or .markdown file, so it is rendered as a single diagram. "+
+		"Use --inputFormat markdown or rename it to .md to render its blocks", inputPath)
+}
@@ -0,0 +1,260 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestIsRemoteInput(t *testing.T) {
+	tests := map[string]bool{
+		"https://example.com/d.mmd": true,
+		"HTTP://example.com/d.mmd":  true,
+		"file:///tmp/d.mmd":         false,
+		"diagram.mmd":               false,
+		"":                          false,
+	}
+	for input, want := range tests {
+		if got := isRemoteInput(input); got != want {
+			t.Errorf("isRemoteInput(%q) = %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestFileURLPath(t *testing.T) {
+	p, ok, err := fileURLPath("file:///tmp/docs/My%20Doc.md")
+	if err != nil || !ok {
+		t.Fatalf("unexpected result: ok=%v err=%v", ok, err)
+	}
+	if p != "/tmp/docs/My Doc.md" {
+		t.Errorf("expected decoded local path, got %q", p)
+	}
+
+	if _, ok, _ := fileURLPath("diagram.mmd"); ok {
+		t.Error("expected plain path not to be treated as a file URL")
+	}
+	if _, _, err := fileURLPath("file://remote-host/share/d.mmd"); err == nil {
+		t.Error("expected error for file URL with a remote host")
+	}
+}
+
+func TestRemoteInputName(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/docs/guide.md?raw=1": "guide.md",
+		"https://example.com/diagram.mmd":         "diagram.mmd",
+		"https://example.com/":                    "out",
+		"https://example.com":                     "out",
+	}
+	for input, want := range tests {
+		if got := remoteInputName(input); got != want {
+			t.Errorf("remoteInputName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestFetchURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/diagram.mmd":
+			w.Write([]byte("graph TD; A-->B;"))
+		case "/big.mmd":
+			w.Write([]byte(strings.Repeat("x", 100)))
+		case "/slow.mmd":
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte("graph TD; A-->B;"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	data, err := fetchURL(srv.URL+"/diagram.mmd", time.Second, 1024)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "graph TD; A-->B;" {
+		t.Errorf("unexpected body %q", data)
+	}
+
+	if _, err := fetchURL(srv.URL+"/missing.mmd", time.Second, 1024); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected 404 error, got %v", err)
+	}
+
+	if _, err := fetchURL(srv.URL+"/big.mmd", time.Second, 50); err == nil || !strings.Contains(err.Error(), "size limit") {
+		t.Errorf("expected size limit error, got %v", err)
+	}
+
+	if _, err := fetchURL(srv.URL+"/big.mmd", time.Second, 100); err != nil {
+		t.Errorf("expected body at exactly the limit to pass, got %v", err)
+	}
+
+	if _, err := fetchURL(srv.URL+"/slow.mmd", 50*time.Millisecond, 1024); err == nil {
+		t.Error("expected timeout error, got nil")
+	}
+}
+
+func TestIsMarkdownInput(t *testing.T) {
+	fenced := "# Doc\n\n```mermaid\ngraph TD;\n  A-->B;\n```\n"
+	bare := "graph TD;\n  A-->B;"
+
+	tests := []struct {
+		name                      string
+		format, input, path, data string
+		want                      bool
+	}{
+		{"auto stdin markdown", "auto", "", "", fenced, true},
+		{"auto stdin diagram", "auto", "", "", bare, false},
+		{"auto md file", "auto", "doc.md", "doc.md", bare, true},
+		{"auto mmd file with fences", "auto", "d.mmd", "d.mmd", fenced, false},
+		{"mermaid forces single diagram", "mermaid", "", "", fenced, false},
+		{"markdown forces markdown", "markdown", "d.txt", "d.txt", bare, true},
+	}
+	for _, tt := range tests {
+		if got := isMarkdownInput(tt.format, tt.input, tt.path, tt.data); got != tt.want {
+			t.Errorf("%s: isMarkdownInput() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyInput(t *testing.T) {
+	tests := []struct {
+		path string
+		want inputKind
+	}{
+		{"diagram.mmd", inputDiagram},
+		{"diagram.mermaid", inputDiagram},
+		{"docs/Flow.MMD", inputDiagram},
+		{"guide.md", inputMarkdown},
+		{"docs/guide.markdown", inputMarkdown},
+		{"Guide.MD", inputMarkdown},
+		{"docs/Guide.Markdown", inputMarkdown},
+		{"https://example.com/raw/flow.mermaid", inputDiagram},
+		{"notes.txt", inputUnknown},
+		{"diagram", inputUnknown},
+		{"archive.md.bak", inputUnknown},
+	}
+	for _, tt := range tests {
+		if got := classifyInput(tt.path); got != tt.want {
+			t.Errorf("classifyInput(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestInputKindWarning(t *testing.T) {
+	fenced := "# Notes\n\n```mermaid\ngraph TD;\n  A-->B;\n```\n"
+	bare := "graph TD;\n  A-->B;"
+
+	w := inputKindWarning("auto", "notes.txt", "notes.txt", fenced)
+	if !strings.Contains(w, "--inputFormat markdown") || !strings.Contains(w, "rename it to .md") {
+		t.Errorf("expected a warning for a .txt file with mermaid blocks, got %q", w)
+	}
+	if strings.Contains(w, ".mmd") || strings.Contains(w, ".mermaid") {
+		t.Errorf("expected the warning not to suggest a single-diagram extension, got %q", w)
+	}
+	for _, tt := range []struct{ name, format, input, data string }{
+		{"txt without blocks", "auto", "notes.txt", bare},
+		{"mmd with blocks", "auto", "d.mmd", fenced},
+		{"mermaid with blocks", "auto", "d.mermaid", fenced},
+		{"markdown file", "auto", "doc.md", fenced},
+		{"uppercase markdown file", "auto", "Guide.MD", fenced},
+		{"stdin", "auto", "", fenced},
+		{"explicit mermaid format", "mermaid", "notes.txt", fenced},
+	} {
+		if w := inputKindWarning(tt.format, tt.input, tt.input, tt.data); w != "" {
+			t.Errorf("%s: expected no warning, got %q", tt.name, w)
+		}
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	data, err := readAll(iotest.HalfReader(strings.NewReader(strings.Repeat("graph TD; A-->B\n", 1000))))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != strings.Repeat("graph TD; A-->B\n", 1000) {
+		t.Errorf("read %d bytes, want all %d", len(data), 16*1000)
+	}
+
+	data, err = readAll(strings.NewReader(""))
+	if err != nil || len(data) != 0 {
+		t.Errorf("empty input: got %q, %v", data, err)
+	}
+}
+
+func TestReadAll_MidStreamError(t *testing.T) {
+	broken := errors.New("connection reset")
+	r := io.MultiReader(strings.NewReader("graph TD;"), iotest.ErrReader(broken))
+
+	data, err := readAll(r)
+	if !errors.Is(err, broken) {
+		t.Fatalf("error = %v, want %v", err, broken)
+	}
+	if data != nil {
+		t.Errorf("expected no partial data on error, got %q", data)
+	}
+}
+
+func TestReadStdin_ReadsToEOF(t *testing.T) {
+	// DataErrReader returns io.EOF together with the last data, which must be kept
+	for _, timeout := range []time.Duration{0, time.Minute} {
+		data, err := readStdin(iotest.DataErrReader(iotest.OneByteReader(strings.NewReader("graph TD; A-->B"))), timeout)
+		if err != nil {
+			t.Fatalf("timeout %s: unexpected error: %v", timeout, err)
+		}
+		if string(data) != "graph TD; A-->B" {
+			t.Errorf("timeout %s: read %q", timeout, data)
+		}
+	}
+}
+
+func TestReadStdin_WrappedEOF(t *testing.T) {
+	r := io.MultiReader(strings.NewReader("graph TD; A-->B"), iotest.ErrReader(fmt.Errorf("closed: %w", io.EOF)))
+	data, err := readStdin(r, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "graph TD; A-->B" {
+		t.Errorf("read %q", data)
+	}
+}
+
+func TestReadStdin_TimesOutWithoutInput(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	_, err := readStdin(pr, 20*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "no input received on stdin") || !strings.Contains(err.Error(), "-i <input>") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestReadStdin_SlowInputAfterFirstByte(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write([]byte("graph TD;"))
+		time.Sleep(60 * time.Millisecond)
+		pw.Write([]byte(" A-->B"))
+		pw.Close()
+	}()
+
+	data, err := readStdin(pr, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "graph TD; A-->B" {
+		t.Errorf("read %q", data)
+	}
+}
+
+func TestReadStdin_ReadError(t *testing.T) {
+	_, err := readStdin(iotest.ErrReader(errors.New("bad file descriptor")), time.Minute)
+	if err == nil || !strings.Contains(err.Error(), "failed to read stdin: bad file descriptor") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
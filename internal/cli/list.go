@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// mermaidThemes are the built-in mermaid themes --theme accepts.
+var mermaidThemes = []string{"default", "forest", "dark", "neutral", "base"}
+
+// outputFormats are the --outputFormat values; jpg is accepted as an alias for jpeg.
+var outputFormats = []string{"svg", "png", "jpeg", "gif", "pdf"}
+
+// listValues prints the values --list-themes and --list-formats ask for, one per line,
+// themes first if both are given.
+func listValues(w io.Writer, themes, formats bool) error {
+	var lines []string
+	if themes {
+		lines = append(lines, mermaidThemes...)
+	}
+	if formats {
+		lines = append(lines, outputFormats...)
+	}
+	if _, err := fmt.Fprintln(w, strings.Join(lines, "\n")); err != nil {
+		return fmt.Errorf("failed to write list: %w", err)
+	}
+	return nil
+}
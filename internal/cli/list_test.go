@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestListFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--list-themes"}, "default\nforest\ndark\nneutral\nbase\n"},
+		{[]string{"--list-formats"}, "svg\npng\njpeg\ngif\npdf\n"},
+		{[]string{"--list-formats", "--list-themes"}, "default\nforest\ndark\nneutral\nbase\nsvg\npng\njpeg\ngif\npdf\n"},
+		// The other flags aren't validated and no input is read
+		{[]string{"--list-themes", "-i", "missing.mmd", "-e", "bmp"}, "default\nforest\ndark\nneutral\nbase\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		cmd := NewRootCommand()
+		cmd.SetArgs(tt.args)
+		cmd.SetOut(&out)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: unexpected error: %v", tt.args, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%v printed %q, want %q", tt.args, out.String(), tt.want)
+		}
+	}
+}
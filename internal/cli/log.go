@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// logLevel orders log messages by how much detail they add. A message is shown when its
+// level is at or below the level selected on the command line.
+type logLevel int
+
+const (
+	// levelError is for errors and warnings, which are shown even with --quiet
+	levelError logLevel = iota
+	// levelInfo is the default: progress and the files written
+	levelInfo
+	// levelVerbose (-V) adds the diagram type, page size and browser console output
+	levelVerbose
+	// levelDebug (-VV) adds page HTML sizes and per-step timings
+	levelDebug
+)
+
+// verbosityLevel maps --quiet and the number of -V flags to a log level. --quiet wins.
+func verbosityLevel(quiet bool, verbose int) logLevel {
+	switch {
+	case quiet:
+		return levelError
+	case verbose >= 2:
+		return levelDebug
+	case verbose == 1:
+		return levelVerbose
+	}
+	return levelInfo
+}
+
+// logger writes log messages up to its level, normally to stderr.
+type logger struct {
+	level logLevel
+	w     io.Writer
+}
+
+// newLogger returns a logger writing to stderr.
+func newLogger(level logLevel) logger {
+	return logger{level: level, w: os.Stderr}
+}
+
+// enabled reports whether messages at level are shown.
+func (l logger) enabled(level logLevel) bool {
+	return level <= l.level
+}
+
+// logf logs a message at level, if that level is enabled.
+func (l logger) logf(level logLevel, format string, args ...interface{}) {
+	if l.enabled(level) {
+		fmt.Fprintf(l.w, format+"\n", args...)
+	}
+}
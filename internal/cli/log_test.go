@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestVerbosityLevel(t *testing.T) {
+	tests := []struct {
+		quiet   bool
+		verbose int
+		want    logLevel
+	}{
+		{false, 0, levelInfo},
+		{false, 1, levelVerbose},
+		{false, 2, levelDebug},
+		{false, 3, levelDebug},
+		{true, 0, levelError},
+		{true, 2, levelError},
+	}
+	for _, tt := range tests {
+		if got := verbosityLevel(tt.quiet, tt.verbose); got != tt.want {
+			t.Errorf("verbosityLevel(%v, %d) = %d, want %d", tt.quiet, tt.verbose, got, tt.want)
+		}
+	}
+}
+
+func TestLoggerGating(t *testing.T) {
+	levels := []logLevel{levelError, levelInfo, levelVerbose, levelDebug}
+	names := map[logLevel]string{levelError: "error", levelInfo: "info", levelVerbose: "verbose", levelDebug: "debug"}
+	want := map[logLevel]string{
+		levelError:   "error\n",
+		levelInfo:    "error\ninfo\n",
+		levelVerbose: "error\ninfo\nverbose\n",
+		levelDebug:   "error\ninfo\nverbose\ndebug\n",
+	}
+
+	for _, level := range levels {
+		var buf bytes.Buffer
+		lg := logger{level: level, w: &buf}
+		for _, msgLevel := range levels {
+			lg.logf(msgLevel, "%s", names[msgLevel])
+		}
+		if buf.String() != want[level] {
+			t.Errorf("logger at %s logged %q, want %q", names[level], buf.String(), want[level])
+		}
+	}
+}
+
+func TestVerboseFlagCounts(t *testing.T) {
+	for args, want := range map[string]int{"-V": 1, "-VV": 2, "--verbose": 1} {
+		cmd := NewRootCommand()
+		if err := cmd.Flags().Parse([]string{args}); err != nil {
+			t.Fatalf("%s: %v", args, err)
+		}
+		got, err := cmd.Flags().GetCount("verbose")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: verbose = %d, want %d", args, got, want)
+		}
+	}
+}
+
+func TestVersionShorthand(t *testing.T) {
+	// -v stays cobra's shorthand for --version
+	cmd := NewRootCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"-v"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), Version) {
+		t.Errorf("expected -v to print the version, got %q", out.String())
+	}
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"image"
+	_ "image/jpeg" // registers the JPEG decoder for imageSize
+	_ "image/png"  // registers the PNG decoder for imageSize
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+// renderManifest describes the diagrams a markdown run rendered, written with --manifest.
+type renderManifest struct {
+	Diagrams []manifestEntry `json:"diagrams"`
+}
+
+// manifestEntry is one rendered block of the markdown input.
+type manifestEntry struct {
+	Index  int    `json:"index"` // 1-based position of the block in the markdown
+	File   string `json:"file"`  // relative to the manifest's directory
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Hash   string `json:"hash"` // sha256 of the block's definition
+}
+
+// newManifestEntry describes the block at index, rendered from definition to file.
+// Width and height are read from the image; they are left out for PDFs.
+func newManifestEntry(manifestPath string, index int, definition, file, format string, result *renderer.RenderResult) manifestEntry {
+	rel, err := filepath.Rel(filepath.Dir(filepath.Clean(manifestPath)), filepath.Clean(file))
+	if err != nil {
+		rel = file
+	}
+	width, height := imageSize(format, result.Data)
+	sum := sha256.Sum256([]byte(definition))
+	return manifestEntry{
+		Index:  index,
+		File:   filepath.ToSlash(rel),
+		Width:  width,
+		Height: height,
+		Title:  result.Title,
+		Hash:   hex.EncodeToString(sum[:]),
+	}
+}
+
+// imageSize returns the dimensions of a rendered image: the pixel size of a PNG or JPEG,
+// or the width and height attributes of an SVG, falling back to its viewBox. It returns
+// zeros when the size can't be determined, e.g. for PDFs.
+func imageSize(format string, data []byte) (width, height int) {
+	switch format {
+	case "png", "jpeg":
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			return 0, 0
+		}
+		return cfg.Width, cfg.Height
+	case "svg":
+		return svgSize(data)
+	}
+	return 0, 0
+}
+
+// svgSize returns the size of the root <svg> element in data.
+func svgSize(data []byte) (width, height int) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "svg" {
+			continue
+		}
+		var viewBox []string
+		for _, attr := range start.Attr {
+			switch attr.Name.Local {
+			case "width":
+				width = svgLength(attr.Value)
+			case "height":
+				height = svgLength(attr.Value)
+			case "viewBox":
+				viewBox = strings.Fields(strings.ReplaceAll(attr.Value, ",", " "))
+			}
+		}
+		if len(viewBox) == 4 {
+			if width == 0 {
+				width = svgLength(viewBox[2])
+			}
+			if height == 0 {
+				height = svgLength(viewBox[3])
+			}
+		}
+		return width, height
+	}
+}
+
+// svgLength parses an absolute SVG length such as "120", "120.5" or "120px", rounded
+// to whole pixels. Relative lengths such as "100%" yield 0.
+func svgLength(s string) int {
+	n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "px"), 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return int(n + 0.5)
+}
+
+// writeRenderManifest writes m to path.
+func writeRenderManifest(path string, m *renderManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
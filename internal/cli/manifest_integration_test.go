@@ -0,0 +1,94 @@
+//go:build integration
+
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIntegration_MarkdownManifest(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "doc.md")
+	doc := "# Doc\n\n```mermaid\ngraph TD; A-->B\n```\n\n```mermaid\n---\ntitle: Second\n---\ngraph LR; C-->D\n```\n"
+	if err := os.WriteFile(input, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifestPath := filepath.Join(dir, "manifest.json")
+
+	cmd := NewRootCommand()
+	cmd.SetArgs([]string{"-q", "-i", input, "-o", filepath.Join(dir, "out.md"), "-e", "png", "--manifest", manifestPath})
+	err := cmd.Execute()
+	if ExitCode(err) == exitBrowser {
+		t.Skip("Chrome/Chromium not installed")
+	}
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m renderManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("manifest isn't valid JSON: %v", err)
+	}
+	if len(m.Diagrams) != 2 {
+		t.Fatalf("expected 2 manifest entries, got %+v", m.Diagrams)
+	}
+	for i, e := range m.Diagrams {
+		if e.Index != i+1 {
+			t.Errorf("entry %d: index = %d", i, e.Index)
+		}
+		img, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(e.File)))
+		if err != nil {
+			t.Errorf("entry %d: %v", i, err)
+			continue
+		}
+		if w, h := imageSize("png", img); w != e.Width || h != e.Height || w == 0 {
+			t.Errorf("entry %d: manifest says %dx%d, image is %dx%d", i, e.Width, e.Height, w, h)
+		}
+	}
+	if m.Diagrams[1].Title != "Second" {
+		t.Errorf("expected the second diagram's title, got %q", m.Diagrams[1].Title)
+	}
+}
+
+func TestIntegration_NameByTitleKeepsNumberedNames(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "doc.md")
+	// The first diagram's title slugs to the numbered name of the untitled second one
+	doc := "# Doc\n\n```mermaid\n---\ntitle: out 2\n---\ngraph TD; A-->B\n```\n\n```mermaid\ngraph LR; C-->D\n```\n"
+	if err := os.WriteFile(input, []byte(doc), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifestPath := filepath.Join(dir, "manifest.json")
+
+	cmd := NewRootCommand()
+	cmd.SetArgs([]string{"-q", "-i", input, "-o", filepath.Join(dir, "out.md"), "-e", "png", "--nameByTitle", "--manifest", manifestPath})
+	err := cmd.Execute()
+	if ExitCode(err) == exitBrowser {
+		t.Skip("Chrome/Chromium not installed")
+	}
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m renderManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("manifest isn't valid JSON: %v", err)
+	}
+	if len(m.Diagrams) != 2 {
+		t.Fatalf("expected 2 manifest entries, got %+v", m.Diagrams)
+	}
+	if m.Diagrams[0].File != "out-2-1.png" || m.Diagrams[1].File != "out-2.png" {
+		t.Errorf("files = %q, %q, want out-2-1.png, out-2.png", m.Diagrams[0].File, m.Diagrams[1].File)
+	}
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+func TestImageSize(t *testing.T) {
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		format        string
+		data          string
+		width, height int
+	}{
+		{"png", "png", pngData.String(), 40, 30},
+		{"svg attributes", "svg", `<svg xmlns="http://www.w3.org/2000/svg" width="120.4px" height="80"></svg>`, 120, 80},
+		{"svg viewBox", "svg", `<?xml version="1.0"?><svg width="100%" viewBox="0 0 250 90.6"><g/></svg>`, 250, 91},
+		{"svg without size", "svg", `<svg><g/></svg>`, 0, 0},
+		{"pdf", "pdf", "%PDF-1.4", 0, 0},
+		{"broken png", "png", "not a png", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h := imageSize(tt.format, []byte(tt.data))
+			if w != tt.width || h != tt.height {
+				t.Errorf("imageSize() = %dx%d, want %dx%d", w, h, tt.width, tt.height)
+			}
+		})
+	}
+}
+
+func TestRenderManifest_MatchesRenderedDiagrams(t *testing.T) {
+	dir := t.TempDir()
+	manifestPath := filepath.Join(dir, "manifest.json")
+	diagrams := []struct {
+		definition string
+		file       string
+		svg        string
+		title      string
+	}{
+		{"graph TD; A-->B", filepath.Join(dir, "img", "doc-1.svg"), `<svg width="100" height="50"/>`, "Flow"},
+		{"sequenceDiagram\n  A->>B: hi", filepath.Join(dir, "img", "doc-2.svg"), `<svg viewBox="0 0 300 200"/>`, ""},
+	}
+
+	m := &renderManifest{Diagrams: []manifestEntry{}}
+	for i, d := range diagrams {
+		result := &renderer.RenderResult{Data: []byte(d.svg), Title: d.title}
+		m.Diagrams = append(m.Diagrams, newManifestEntry(manifestPath, i+1, d.definition, d.file, "svg", result))
+	}
+	if err := writeRenderManifest(manifestPath, m); err != nil {
+		t.Fatalf("writeRenderManifest: %v", err)
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got renderManifest
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("manifest isn't valid JSON: %v", err)
+	}
+	want := []manifestEntry{
+		{Index: 1, File: "img/doc-1.svg", Width: 100, Height: 50, Title: "Flow", Hash: "c18237e0a535bdb73d9c241d24e7905bd72b7e64ca3ece4b5c241eb4fd8c7546"},
+		{Index: 2, File: "img/doc-2.svg", Width: 300, Height: 200, Hash: "a3a056692648b4081dc3dc13362dd4c0376be65032c102f47dd0add92ca691d2"},
+	}
+	if !reflect.DeepEqual(got.Diagrams, want) {
+		t.Errorf("manifest = %+v, want %+v", got.Diagrams, want)
+	}
+}
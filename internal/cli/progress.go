@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// isTerminal reports whether f is attached to a terminal.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// progress reports per-diagram progress while rendering markdown input.
+// On a terminal it rewrites a single status line in place; otherwise it
+// falls back to plain line-by-line logging.
+type progress struct {
+	w      io.Writer
+	total  int
+	quiet  bool
+	tty    bool
+	active bool
+}
+
+// newProgress creates a progress reporter for total diagrams writing to stderr.
+func newProgress(total int, quiet bool) *progress {
+	return &progress{
+		w:     os.Stderr,
+		total: total,
+		quiet: quiet,
+		tty:   isTerminal(os.Stderr),
+	}
+}
+
+// step reports that diagram n (1-based) is being rendered.
+func (p *progress) step(n int) {
+	if p.quiet {
+		return
+	}
+	line := fmt.Sprintf("[%d/%d] rendering diagram %d...", n, p.total, n)
+	if p.tty {
+		// \r returns to the start of the line and \033[K clears the rest of it
+		fmt.Fprintf(p.w, "\r\033[K%s", line)
+		p.active = true
+	} else {
+		fmt.Fprintln(p.w, line)
+	}
+}
+
+// clear erases the in-place status line, if one is showing.
+func (p *progress) clear() {
+	if !p.active {
+		return
+	}
+	fmt.Fprint(p.w, "\r\033[K")
+	p.active = false
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProgress_NonTTY(t *testing.T) {
+	var buf bytes.Buffer
+	p := &progress{w: &buf, total: 2}
+
+	p.step(1)
+	p.step(2)
+	p.clear()
+
+	want := "[1/2] rendering diagram 1...\n[2/2] rendering diagram 2...\n"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestProgress_TTY(t *testing.T) {
+	var buf bytes.Buffer
+	p := &progress{w: &buf, total: 3, tty: true}
+
+	p.step(1)
+	p.step(2)
+	p.clear()
+	p.clear()
+
+	want := "\r\033[K[1/3] rendering diagram 1...\r\033[K[2/3] rendering diagram 2...\r\033[K"
+	if buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestProgress_Quiet(t *testing.T) {
+	var buf bytes.Buffer
+	p := &progress{w: &buf, total: 1, quiet: true, tty: true}
+
+	p.step(1)
+	p.clear()
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no output in quiet mode, got %q", buf.String())
+	}
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/coolamit/mermaid-cli/internal/config"
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+	"github.com/coolamit/mermaid-cli/web"
+	"github.com/spf13/cobra"
+)
+
+// selfTestDefinition is the sample diagram `self-test` renders.
+const selfTestDefinition = "graph TD; A-->B"
+
+// newSelfTestCommand creates the `self-test` subcommand, which renders a built-in sample
+// diagram in memory to check that the browser starts and rendering works.
+func newSelfTestCommand() *cobra.Command {
+	var browserConfigFile string
+	var timeout int
+
+	cmd := &cobra.Command{
+		Use:   "self-test",
+		Short: "Check that the browser starts and a sample diagram renders",
+		Long: "Renders a built-in `" + selfTestDefinition + "` diagram to memory, checks that the result " +
+			"is a valid SVG and prints PASS or FAIL with diagnostics. Nothing is written to disk.\n\n" +
+			"Exit codes: 0 = pass, 4 = the sample failed to render, 5 = the browser couldn't be started.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			browserConfig, err := config.LoadBrowserConfig(browserConfigFile)
+			if err != nil {
+				return usageError(err)
+			}
+
+			opts := renderer.RenderOpts{
+				MermaidConfig:   config.MermaidConfig{"theme": "default"},
+				BackgroundColor: "white",
+				Scale:           1,
+				Timeout:         renderTimeout(timeout, browserConfig),
+			}
+
+			r := renderer.NewRenderer(renderer.NewBrowser(browserConfig))
+			defer r.Close()
+
+			return selfTest(context.Background(), r, opts, browserConfig, cmd.OutOrStdout())
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	cmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError(err)
+	})
+
+	cmd.Flags().StringVarP(&browserConfigFile, "puppeteerConfigFile", "p", "", "JSON configuration file for the browser")
+	cmd.Flags().IntVar(&timeout, "timeout", 0, "Render timeout in milliseconds. Default: the browser config \"timeout\", else 60000")
+
+	return cmd
+}
+
+// selfTest renders the sample diagram with r, writes a PASS/FAIL report with diagnostics
+// to w and returns an error carrying the exit code if the test failed.
+func selfTest(ctx context.Context, r checkRenderer, opts renderer.RenderOpts, browserConfig *config.BrowserConfig, w io.Writer) error {
+	mermaid, _ := web.EmbeddedVersions()
+	browser := browserConfig.ExecutablePath
+	if browser == "" {
+		browser = "auto-detected"
+	}
+	fmt.Fprintf(w, "mmd-cli %s, mermaid %s\n", Version, orUnknown(mermaid))
+	fmt.Fprintf(w, "browser: %s\n", browser)
+
+	start := time.Now()
+	result, err := r.Render(ctx, selfTestDefinition, "svg", opts)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err == nil {
+		err = checkSVG(result.Data)
+	}
+
+	if err != nil {
+		fmt.Fprintf(w, "FAIL: %v\n", err)
+		if errors.Is(err, renderer.ErrBrowserStart) {
+			fmt.Fprintln(w, "hint: install Chrome or Chromium, or point \"executablePath\" in a --puppeteerConfigFile at it")
+			return &exitError{code: exitBrowser, err: fmt.Errorf("self-test failed: %w", err)}
+		}
+		return &exitError{code: exitRender, err: fmt.Errorf("self-test failed: %w", err)}
+	}
+
+	fmt.Fprintf(w, "PASS: rendered %d bytes of SVG in %s\n", len(result.Data), elapsed)
+	return nil
+}
+
+// checkSVG reports whether data parses as markup with an <svg> root element. HTML
+// labels inside foreignObject are serialized as HTML, so HTML entities and void
+// elements such as <br> are allowed.
+func checkSVG(data []byte) error {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+	root := ""
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("output is not valid SVG: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok && root == "" {
+			root = start.Name.Local
+		}
+	}
+	if root != "svg" {
+		return fmt.Errorf("output is not valid SVG: root element is %q, want \"svg\"", root)
+	}
+	return nil
+}
+
+// orUnknown returns v, or "unknown" if it is empty.
+func orUnknown(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return v
+}
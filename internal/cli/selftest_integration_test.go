@@ -0,0 +1,27 @@
+//go:build integration
+
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIntegration_SelfTest(t *testing.T) {
+	var out bytes.Buffer
+	cmd := NewRootCommand()
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"self-test"})
+
+	err := cmd.Execute()
+	if ExitCode(err) == exitBrowser {
+		t.Skip("Chrome/Chromium not installed")
+	}
+	if err != nil {
+		t.Fatalf("self-test failed: %v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "PASS: rendered") {
+		t.Errorf("expected a PASS line, got %q", out.String())
+	}
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/coolamit/mermaid-cli/internal/config"
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+// selfTestRenderer returns a fixed result or error.
+type selfTestRenderer struct {
+	data []byte
+	err  error
+}
+
+func (r selfTestRenderer) Render(ctx context.Context, definition string, outputFormat string, opts renderer.RenderOpts) (*renderer.RenderResult, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &renderer.RenderResult{Data: r.data}, nil
+}
+
+func TestSelfTest(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg"><g><foreignObject><div>A<br>&nbsp;</div></foreignObject></g></svg>`
+	tests := []struct {
+		name     string
+		r        selfTestRenderer
+		wantCode int
+		wantOut  string
+	}{
+		{"pass", selfTestRenderer{data: []byte(svg)}, exitOK, "PASS: rendered"},
+		{"render error", selfTestRenderer{err: errors.New("mermaid rendering error: boom")}, exitRender, "FAIL: mermaid rendering error: boom"},
+		{"browser error", selfTestRenderer{err: fmt.Errorf("%w: exec: chrome not found", renderer.ErrBrowserStart)}, exitBrowser, "hint: install Chrome"},
+		{"not svg", selfTestRenderer{data: []byte("<html></html>")}, exitRender, `FAIL: output is not valid SVG: root element is "html"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := selfTest(context.Background(), tt.r, renderer.RenderOpts{}, &config.BrowserConfig{ExecutablePath: "/opt/chrome"}, &out)
+			if got := ExitCode(err); got != tt.wantCode {
+				t.Errorf("exit code = %d, want %d (err: %v)", got, tt.wantCode, err)
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("output %q doesn't contain %q", out.String(), tt.wantOut)
+			}
+			if !strings.Contains(out.String(), "browser: /opt/chrome") {
+				t.Errorf("expected the browser path in the diagnostics, got %q", out.String())
+			}
+		})
+	}
+}
+
+func TestCheckSVG(t *testing.T) {
+	valid := []string{
+		`<svg/>`,
+		`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><rect/></svg>`,
+	}
+	for _, s := range valid {
+		if err := checkSVG([]byte(s)); err != nil {
+			t.Errorf("checkSVG(%q): unexpected error: %v", s, err)
+		}
+	}
+	invalid := []string{"", "not markup", `<div><svg/></div>`}
+	for _, s := range invalid {
+		if err := checkSVG([]byte(s)); err == nil {
+			t.Errorf("checkSVG(%q): expected an error", s)
+		}
+	}
+}
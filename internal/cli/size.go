@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// byteSizeRegex matches upper-cased sizes like "1024", "512B", "10MB", "10MIB", "1.5G".
+var byteSizeRegex = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(?:([KMG])(?:I?B)?|B)?$`)
+
+// parseByteSize parses a human-readable size into bytes. Units are binary (1KB = 1024 bytes)
+// and case-insensitive; a bare number is taken as bytes.
+func parseByteSize(s string) (int64, error) {
+	m := byteSizeRegex.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size %q, expected a number with an optional unit (B, KB, MB, GB)", s)
+	}
+
+	n, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q: %w", s, err)
+	}
+
+	switch m[2] {
+	case "K":
+		n *= 1 << 10
+	case "M":
+		n *= 1 << 20
+	case "G":
+		n *= 1 << 30
+	}
+
+	return int64(n), nil
+}
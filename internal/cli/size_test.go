@@ -0,0 +1,36 @@
+package cli
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]int64{
+		"1024":   1024,
+		"0":      0,
+		"512B":   512,
+		"1KB":    1024,
+		"1kb":    1024,
+		"10MB":   10 << 20,
+		"10M":    10 << 20,
+		"10MiB":  10 << 20,
+		"1.5GB":  3 << 29,
+		" 2 MB ": 2 << 20,
+	}
+	for input, want := range tests {
+		got, err := parseByteSize(input)
+		if err != nil {
+			t.Errorf("parseByteSize(%q): unexpected error: %v", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("parseByteSize(%q) = %d, want %d", input, got, want)
+		}
+	}
+}
+
+func TestParseByteSize_Invalid(t *testing.T) {
+	for _, input := range []string{"", "MB", "-1MB", "10TB", "ten", "10I", "10IB", "10KI", "10BB"} {
+		if _, err := parseByteSize(input); err == nil {
+			t.Errorf("parseByteSize(%q): expected error", input)
+		}
+	}
+}
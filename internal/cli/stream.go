@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+// defaultStreamDelimiter separates diagrams on stdin in --stream mode.
+const defaultStreamDelimiter = "---MMDC---"
+
+// maxStreamLine bounds a single line of --stream input.
+const maxStreamLine = 16 << 20
+
+// validateStreamFlags checks that --stream reads stdin and isn't combined with options
+// that pick or rewrite a single input.
+func validateStreamFlags(flags *Flags) error {
+	if !flags.Stream {
+		if flags.StreamDelimiter != defaultStreamDelimiter && flags.StreamDelimiter != "" {
+			return usageError(fmt.Errorf("--streamDelimiter can only be used with --stream"))
+		}
+		return nil
+	}
+
+	conflicts := []struct {
+		name string
+		set  bool
+	}{
+		{"--input", flags.Input != "" && flags.Input != "-"},
+		{"--inputDir", flags.InputDir != ""},
+		{"--definition", flags.Definition != ""},
+		{"--frames", len(flags.Frames) > 0},
+		{"--artefacts", flags.Artefacts != ""},
+		{"--diagram", flags.Diagram > 0},
+		{"--incremental", flags.Incremental},
+		{"--dumpHtml", flags.DumpHTML != ""},
+		{"--output -", flags.Output == "-"},
+	}
+	for _, c := range conflicts {
+		if c.set {
+			return usageError(fmt.Errorf("%s can't be used with --stream", c.name))
+		}
+	}
+	if strings.TrimSpace(flags.StreamDelimiter) == "" {
+		return usageError(fmt.Errorf("--streamDelimiter can't be empty"))
+	}
+	return nil
+}
+
+// splitStream reads r line by line and calls fn with each chunk of text between lines
+// equal to delimiter (ignoring surrounding whitespace), as soon as the chunk is complete.
+// Blank chunks are skipped. It stops at the first error returned by fn.
+func splitStream(r io.Reader, delimiter string, fn func(chunk string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStreamLine)
+	delimiter = strings.TrimSpace(delimiter)
+
+	var chunk strings.Builder
+	flush := func() error {
+		text := chunk.String()
+		chunk.Reset()
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		return fn(text)
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == delimiter {
+			if err := flush(); err != nil {
+				return err
+			}
+			continue
+		}
+		chunk.WriteString(line)
+		chunk.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return flush()
+}
+
+// renderStream renders each diagram read from in with the same renderer, so the browser
+// stays warm between them. For every diagram it writes one line to out as soon as it is
+// done: the numbered output file it was written to (out-1.svg, ...), its data URI with
+// asDataURI, or "error: <message>". Failures don't stop the stream.
+func renderStream(ctx context.Context, r diagramRenderer, in io.Reader, out io.Writer, delimiter, output, outputFormat string, asDataURI bool, opts renderer.RenderOpts, summary *renderSummary) error {
+	n, failed := 0, 0
+	code := exitRender
+	err := splitStream(in, delimiter, func(definition string) error {
+		n++
+		line, err := renderStreamChunk(ctx, r, definition, numberedPath(output, n), outputFormat, asDataURI, opts, summary)
+		if err != nil {
+			failed++
+			if errors.Is(err, renderer.ErrBrowserStart) {
+				code = exitBrowser
+			}
+			// Keep one line per diagram, whatever the message contains
+			line = "error: " + strings.Join(strings.Fields(err.Error()), " ")
+		}
+		if _, err := fmt.Fprintln(out, line); err != nil {
+			return fmt.Errorf("failed to write to stdout: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return &exitError{code: code, err: fmt.Errorf("%d of %d diagrams failed to render", failed, n)}
+	}
+	return nil
+}
+
+// renderStreamChunk renders one diagram of a --stream run and returns its output line.
+func renderStreamChunk(ctx context.Context, r diagramRenderer, definition, outputFile, outputFormat string, asDataURI bool, opts renderer.RenderOpts, summary *renderSummary) (string, error) {
+	result, err := r.Render(ctx, definition, outputFormat, opts)
+	if err != nil {
+		return "", err
+	}
+	if asDataURI {
+		summary.diagrams++
+		summary.bytes += int64(len(result.Data))
+		return dataURI(outputFormat, result.Data), nil
+	}
+	if err := summary.writeDiagram(outputFile, result.Data); err != nil {
+		return "", fmt.Errorf("failed to write output file %q: %w", outputFile, err)
+	}
+	return outputFile, nil
+}
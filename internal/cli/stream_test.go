@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+func TestSplitStream(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		delimiter string
+		want      []string
+	}{
+		{"single chunk", "graph TD; A-->B\n", "---MMDC---", []string{"graph TD; A-->B\n"}},
+		{
+			"delimited",
+			"graph TD; A-->B\n---MMDC---\nsequenceDiagram\n  A->>B: hi\n---MMDC---\n",
+			"---MMDC---",
+			[]string{"graph TD; A-->B\n", "sequenceDiagram\n  A->>B: hi\n"},
+		},
+		{"no trailing newline", "graph TD; A\n---MMDC---\ngraph TD; B", "---MMDC---", []string{"graph TD; A\n", "graph TD; B\n"}},
+		{"blank chunks skipped", "---MMDC---\n\n---MMDC---\ngraph TD; A\n---MMDC---\n  \n", "---MMDC---", []string{"graph TD; A\n"}},
+		{"delimiter with whitespace", "graph TD; A\n  ---MMDC---\r\ngraph TD; B\n", "---MMDC---", []string{"graph TD; A\n", "graph TD; B\n"}},
+		{"delimiter must be the whole line", "graph TD; A-->B %% ---MMDC---\n", "---MMDC---", []string{"graph TD; A-->B %% ---MMDC---\n"}},
+		{"form feed", "graph TD; A\n\f\ngraph TD; B\n", "\f", []string{"graph TD; A\n", "graph TD; B\n"}},
+		{"empty input", "", "---MMDC---", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := splitStream(strings.NewReader(tt.input), tt.delimiter, func(chunk string) error {
+				got = append(got, chunk)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+				t.Errorf("chunks = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitStream_StopsOnError(t *testing.T) {
+	stop := errors.New("stop")
+	calls := 0
+	err := splitStream(strings.NewReader("a\n---\nb\n---\nc\n"), "---", func(chunk string) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("error = %v, want %v", err, stop)
+	}
+	if calls != 1 {
+		t.Errorf("fn called %d times, want 1", calls)
+	}
+}
+
+func TestRenderStream(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.svg")
+	in := strings.NewReader("graph TD; A-->B\n---MMDC---\ngraph TD; broken\n---MMDC---\ngraph TD; C-->D\n")
+	var out bytes.Buffer
+
+	summary := newRenderSummary()
+	err := renderStream(context.Background(), closableCheckRenderer{}, in, &out, defaultStreamDelimiter, output, "svg", false, renderer.RenderOpts{}, summary)
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 diagrams failed") {
+		t.Errorf("error = %v, want 1 of 3 failed", err)
+	}
+	if got := ExitCode(err); got != exitRender {
+		t.Errorf("exit code = %d, want %d", got, exitRender)
+	}
+
+	want := numberedPath(output, 1) + "\nerror: mermaid rendering error: Parse error\n" + numberedPath(output, 3) + "\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	for _, n := range []int{1, 3} {
+		if _, err := os.Stat(numberedPath(output, n)); err != nil {
+			t.Errorf("expected diagram %d to be written: %v", n, err)
+		}
+	}
+	if summary.diagrams != 2 {
+		t.Errorf("summary counted %d diagrams, want 2", summary.diagrams)
+	}
+}
+
+func TestRenderStream_DataURI(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.svg")
+	var out bytes.Buffer
+
+	err := renderStream(context.Background(), closableCheckRenderer{}, strings.NewReader("graph TD; A-->B\n"), &out, defaultStreamDelimiter, output, "svg", true, renderer.RenderOpts{}, newRenderSummary())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := dataURI("svg", []byte("<svg/>")) + "\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+	if _, err := os.Stat(numberedPath(output, 1)); !os.IsNotExist(err) {
+		t.Error("expected no output file with data URIs")
+	}
+}
+
+func TestValidateStreamFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		flags   Flags
+		wantErr string
+	}{
+		{"not streaming", Flags{StreamDelimiter: defaultStreamDelimiter}, ""},
+		{"stdin", Flags{Stream: true, Input: "-", StreamDelimiter: defaultStreamDelimiter}, ""},
+		{"custom delimiter", Flags{Stream: true, StreamDelimiter: "%%%"}, ""},
+		{"delimiter alone", Flags{StreamDelimiter: "%%%"}, "--streamDelimiter can only be used with --stream"},
+		{"input file", Flags{Stream: true, Input: "a.mmd", StreamDelimiter: defaultStreamDelimiter}, "--input can't be used with --stream"},
+		{"definition", Flags{Stream: true, Definition: "graph TD; A", StreamDelimiter: defaultStreamDelimiter}, "--definition can't be used"},
+		{"stdout", Flags{Stream: true, Output: "-", StreamDelimiter: defaultStreamDelimiter}, "--output - can't be used"},
+		{"blank delimiter", Flags{Stream: true, StreamDelimiter: " "}, "can't be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateStreamFlags(&tt.flags)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if got := ExitCode(err); got != exitUsage {
+				t.Errorf("exit code = %d, want %d", got, exitUsage)
+			}
+		})
+	}
+}
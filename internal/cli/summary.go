@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/coolamit/mermaid-cli/internal/rendercache"
+)
+
+// renderSummary accumulates statistics over a run for the final summary line.
+type renderSummary struct {
+	start    time.Time
+	diagrams int
+	bytes    int64
+	markdown string
+	// cache is the render cache in use, if any, for reporting hits
+	cache *rendercache.Cache
+	// write stores an output file; it writes to disk unless output is bundled
+	write func(path string, data []byte) error
+}
+
+// newRenderSummary starts timing a run.
+func newRenderSummary() *renderSummary {
+	return &renderSummary{start: time.Now(), write: writeFile}
+}
+
+// writeFile writes an output file to disk.
+func writeFile(path string, data []byte) error {
+	return os.WriteFile(path, data, 0644)
+}
+
+// writeDiagram writes a rendered diagram to path and records it in the summary.
+func (s *renderSummary) writeDiagram(path string, data []byte) error {
+	if err := s.write(path, data); err != nil {
+		return err
+	}
+	s.diagrams++
+	s.bytes += int64(len(data))
+	return nil
+}
+
+// writeMarkdown writes the rewritten markdown to path and records it in the summary.
+func (s *renderSummary) writeMarkdown(path string, data []byte) error {
+	if err := s.write(path, data); err != nil {
+		return err
+	}
+	s.markdown = path
+	s.bytes += int64(len(data))
+	return nil
+}
+
+// String formats the summary as a single line, e.g.
+// "Rendered 3 diagrams (12.4 KB) in 1.52s, markdown: docs/out.md".
+func (s *renderSummary) String() string {
+	noun := "diagrams"
+	if s.diagrams == 1 {
+		noun = "diagram"
+	}
+	line := fmt.Sprintf("Rendered %d %s (%s) in %s", s.diagrams, noun, formatBytes(s.bytes), time.Since(s.start).Round(10*time.Millisecond))
+	if s.cache != nil {
+		if hits, _ := s.cache.Stats(); hits > 0 {
+			line += fmt.Sprintf(", %d from cache", hits)
+		}
+	}
+	if s.markdown != "" {
+		line += ", markdown: " + s.markdown
+	}
+	return line
+}
+
+// formatBytes renders a byte count in B, KB or MB.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
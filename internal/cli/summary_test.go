@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderSummary_CountsMatchWrittenFiles(t *testing.T) {
+	dir := t.TempDir()
+	s := newRenderSummary()
+
+	for _, name := range []string{"out-1.svg", "out-2.svg", "out-3.svg"} {
+		if err := s.writeDiagram(filepath.Join(dir, name), []byte("<svg></svg>")); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	mdPath := filepath.Join(dir, "out.md")
+	if err := s.writeMarkdown(mdPath, []byte("# doc\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	if s.diagrams != len(entries)-1 {
+		t.Errorf("expected %d diagrams, got %d", len(entries)-1, s.diagrams)
+	}
+	if s.bytes != 3*11+6 {
+		t.Errorf("expected %d bytes, got %d", 3*11+6, s.bytes)
+	}
+
+	line := s.String()
+	if !strings.HasPrefix(line, "Rendered 3 diagrams (39 B) in ") {
+		t.Errorf("unexpected summary %q", line)
+	}
+	if !strings.HasSuffix(line, ", markdown: "+mdPath) {
+		t.Errorf("expected markdown path in summary, got %q", line)
+	}
+}
+
+func TestRenderSummary_Single(t *testing.T) {
+	s := newRenderSummary()
+	if err := s.writeDiagram(filepath.Join(t.TempDir(), "out.png"), make([]byte, 2048)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	line := s.String()
+	if !strings.HasPrefix(line, "Rendered 1 diagram (2.0 KB) in ") {
+		t.Errorf("unexpected summary %q", line)
+	}
+	if strings.Contains(line, "markdown") {
+		t.Errorf("expected no markdown path for single diagram, got %q", line)
+	}
+}
+
+func TestRenderSummary_WriteFailureNotCounted(t *testing.T) {
+	s := newRenderSummary()
+	if err := s.writeDiagram(filepath.Join(t.TempDir(), "missing", "out.svg"), []byte("x")); err == nil {
+		t.Fatal("expected error writing to missing directory")
+	}
+	if s.diagrams != 0 || s.bytes != 0 {
+		t.Errorf("expected failed write not to be counted, got %d diagrams / %d bytes", s.diagrams, s.bytes)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:       "0 B",
+		1023:    "1023 B",
+		1536:    "1.5 KB",
+		3 << 20: "3.0 MB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/coolamit/mermaid-cli/web"
+	"github.com/spf13/cobra"
+)
+
+// newVersionCommand creates the `version` subcommand, which reports the CLI version
+// along with the versions of the bundled mermaid and zenuml scripts.
+func newVersionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the mmd-cli version and the bundled mermaid and zenuml versions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mermaid, zenuml := web.EmbeddedVersions()
+			fmt.Fprint(cmd.OutOrStdout(), formatVersion(Version, mermaid, zenuml))
+			return nil
+		},
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+}
+
+// formatVersion renders the version report, with "unknown" for versions that couldn't be detected.
+func formatVersion(cli, mermaid, zenuml string) string {
+	return fmt.Sprintf("mmd-cli %s\nmermaid %s\nzenuml %s\n", orUnknown(cli), orUnknown(mermaid), orUnknown(zenuml))
+}
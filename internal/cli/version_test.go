@@ -0,0 +1,17 @@
+package cli
+
+import "testing"
+
+func TestFormatVersion(t *testing.T) {
+	got := formatVersion("v1.2.0", "11.12.2", "3.35.2")
+	want := "mmd-cli v1.2.0\nmermaid 11.12.2\nzenuml 3.35.2\n"
+	if got != want {
+		t.Errorf("formatVersion() = %q, want %q", got, want)
+	}
+
+	got = formatVersion("dev", "", "")
+	want = "mmd-cli dev\nmermaid unknown\nzenuml unknown\n"
+	if got != want {
+		t.Errorf("formatVersion() = %q, want %q", got, want)
+	}
+}
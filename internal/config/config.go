@@ -3,7 +3,14 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
 // MermaidConfig holds mermaid.js configuration options.
@@ -11,70 +18,400 @@ type MermaidConfig map[string]interface{}
 
 // BrowserConfig holds browser launch configuration.
 type BrowserConfig struct {
-	ExecutablePath string   `json:"executablePath,omitempty"`
-	Args           []string `json:"args,omitempty"`
-	Timeout        int      `json:"timeout,omitempty"`
-	Headless       string   `json:"headless,omitempty"`
+	ExecutablePath string       `json:"executablePath,omitempty"`
+	Args           []string     `json:"args,omitempty"`
+	Timeout        int          `json:"timeout,omitempty"`
+	Headless       HeadlessMode `json:"headless,omitempty"`
+	TabPoolSize    int          `json:"tabPool,omitempty"`
+	Sandbox        bool         `json:"sandbox,omitempty"`
 }
 
-// LoadMermaidConfig reads a mermaid config JSON file and merges it with defaults.
-func LoadMermaidConfig(configFile string, theme string) (MermaidConfig, error) {
-	cfg := MermaidConfig{"theme": theme}
+// HeadlessMode is the browser config "headless" value. It accepts either a string
+// ("new", "old", "true", "false") or a JSON boolean.
+type HeadlessMode string
 
-	if configFile == "" {
-		return cfg, nil
+// UnmarshalJSON accepts both `"new"` and `false` style values.
+func (h *HeadlessMode) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		*h = HeadlessMode(strconv.FormatBool(b))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("headless must be a string or boolean: %w", err)
+	}
+	*h = HeadlessMode(s)
+	return nil
+}
+
+// TimeoutDuration returns the configured Timeout (milliseconds) as a duration, or 0 if unset.
+func (c *BrowserConfig) TimeoutDuration() time.Duration {
+	return time.Duration(c.Timeout) * time.Millisecond
+}
+
+// DefaultSecurityLevel is the mermaid securityLevel used unless a config file or
+// ApplySecurityLevel sets one. strict encodes HTML in labels and disables click handlers,
+// which matters when rendering untrusted diagrams.
+const DefaultSecurityLevel = "strict"
+
+// securityLevels are the securityLevel values mermaid accepts.
+var securityLevels = []string{"strict", "loose", "antiscript", "sandbox"}
+
+// defaultMermaidConfig returns the config that config files are merged over.
+func defaultMermaidConfig(theme string) MermaidConfig {
+	return MermaidConfig{"theme": theme, "securityLevel": DefaultSecurityLevel}
+}
+
+// LoadMermaidConfig reads mermaid config files and deep-merges them, in order, over the
+// defaults (the theme and DefaultSecurityLevel), so later files override individual
+// nested keys of earlier ones.
+// A configFile of "-" reads the JSON from stdin. Files ending in .yaml/.yml are parsed as YAML.
+// Files ending in .jsonc, or every JSON file if jsonc is set, may contain // and /* */ comments.
+func LoadMermaidConfig(configFiles []string, theme string, jsonc bool) (MermaidConfig, error) {
+	return LoadMermaidConfigOver(defaultMermaidConfig(theme), configFiles, jsonc)
+}
+
+// LoadMermaidConfigOver is LoadMermaidConfig with the config files merged into cfg, such
+// as a BaseMermaidConfig with a preset, instead of the defaults.
+func LoadMermaidConfigOver(cfg MermaidConfig, configFiles []string, jsonc bool) (MermaidConfig, error) {
+	cfg, _, err := loadMermaidConfigFiles(cfg, configFiles, jsonc, false)
+	return cfg, err
+}
+
+// LoadMermaidConfigChecked is LoadMermaidConfigOver that also checks each config file
+// against the bundled schema of mermaid options (see ValidateMermaidConfig). It returns
+// the problems found, each naming its file, e.g. `config file "a.json": unknown key "them"`.
+func LoadMermaidConfigChecked(cfg MermaidConfig, configFiles []string, jsonc bool) (MermaidConfig, []string, error) {
+	return loadMermaidConfigFiles(cfg, configFiles, jsonc, true)
+}
+
+// loadMermaidConfigFiles merges configFiles into cfg, validating each one if validate is set.
+func loadMermaidConfigFiles(cfg MermaidConfig, configFiles []string, jsonc, validate bool) (MermaidConfig, []string, error) {
+	var problems []string
+	for _, configFile := range configFiles {
+		if configFile == "" {
+			continue
+		}
+
+		fileCfg, err := readMermaidConfig(configFile, jsonc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if validate {
+			for _, problem := range ValidateMermaidConfig(fileCfg) {
+				problems = append(problems, fmt.Sprintf("config file %q: %s", configFile, problem))
+			}
+		}
+		mergeConfig(cfg, fileCfg)
+	}
+
+	return cfg, problems, nil
+}
+
+// readMermaidConfig reads a single mermaid config file without applying any defaults.
+func readMermaidConfig(configFile string, jsonc bool) (map[string]interface{}, error) {
+	r, err := openConfig(configFile)
+	if err != nil {
+		return nil, err
 	}
+	defer r.Close()
 
-	data, err := os.ReadFile(configFile)
+	decode := decodeJSONConfig
+	switch {
+	case isYAML(configFile):
+		decode = decodeYAMLConfig
+	case jsonc || isJSONC(configFile):
+		decode = decodeJSONCConfig
+	}
+
+	fileCfg, err := decode(r)
 	if err != nil {
-		return nil, fmt.Errorf("configuration file %q doesn't exist", configFile)
+		return nil, fmt.Errorf("invalid config file %q: %w", configFile, err)
 	}
+	return fileCfg, nil
+}
+
+// isYAML reports whether a config file name has a YAML extension.
+func isYAML(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".yaml" || ext == ".yml"
+}
 
-	var fileCfg MermaidConfig
+// decodeJSONConfig reads a JSON config object from r.
+func decodeJSONConfig(r io.Reader) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	var fileCfg map[string]interface{}
 	if err := json.Unmarshal(data, &fileCfg); err != nil {
-		return nil, fmt.Errorf("invalid JSON in config file %q: %w", configFile, err)
+		return nil, fmt.Errorf("invalid JSON: %w", err)
 	}
+	return fileCfg, nil
+}
 
-	// Merge file config over defaults (file takes precedence)
-	for k, v := range fileCfg {
-		cfg[k] = v
+// decodeJSONCConfig reads a JSON config object with comments from r.
+func decodeJSONCConfig(r io.Reader) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
 	}
 
-	return cfg, nil
+	data, err = stripJSONComments(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSONC: %w", err)
+	}
+	var fileCfg map[string]interface{}
+	if err := json.Unmarshal(data, &fileCfg); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return fileCfg, nil
 }
 
-// LoadBrowserConfig reads a browser config JSON file.
-func LoadBrowserConfig(configFile string) (*BrowserConfig, error) {
-	cfg := &BrowserConfig{}
+// decodeYAMLConfig reads a YAML config mapping from r. Nested mappings decode to
+// map[string]interface{}, as with JSON.
+func decodeYAMLConfig(r io.Reader) (map[string]interface{}, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
 
-	if configFile == "" {
-		return cfg, nil
+	var fileCfg map[string]interface{}
+	if err := yaml.Unmarshal(data, &fileCfg); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	return fileCfg, nil
+}
+
+// mergeConfig deep-merges src into dst, which is how configs are combined everywhere
+// (defaults, config files, font family). Nested objects are merged key by key; any other
+// src value, including arrays and values whose type differs from dst's (object vs scalar),
+// replaces the dst value. Objects copied from src are cloned so dst never aliases src.
+func mergeConfig(dst, src MermaidConfig) {
+	for k, v := range src {
+		srcMap, srcIsMap := asMap(v)
+		dstMap, dstIsMap := asMap(dst[k])
+		if srcIsMap && dstIsMap {
+			mergeConfig(dstMap, srcMap)
+			continue
+		}
+		dst[k] = cloneValue(v)
+	}
+}
+
+// asMap returns v as a config object if it is one.
+func asMap(v interface{}) (MermaidConfig, bool) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return MermaidConfig(m), true
+	case MermaidConfig:
+		return m, true
+	}
+	return nil, false
+}
+
+// Clone returns a deep copy of c, so it can be changed without affecting c.
+func (c MermaidConfig) Clone() MermaidConfig {
+	if c == nil {
+		return nil
+	}
+	return MermaidConfig(cloneValue(map[string]interface{}(c)).(map[string]interface{}))
+}
+
+// cloneValue deep-copies objects and arrays so merged configs don't share them.
+func cloneValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			c[k] = cloneValue(e)
+		}
+		return c
+	case MermaidConfig:
+		return cloneValue(map[string]interface{}(t))
+	case []interface{}:
+		c := make([]interface{}, len(t))
+		for i, e := range t {
+			c[i] = cloneValue(e)
+		}
+		return c
+	}
+	return v
+}
+
+// DiscoverFileNames are the mermaid config files Discover looks for, in order of preference.
+var DiscoverFileNames = []string{".mermaidrc.json", ".mermaidrc.yaml", ".mermaidrc.yml", "mermaid.config.json"}
+
+// Discover looks for a mermaid config file in startDir and each of its parent directories,
+// returning the path of the first one found or "" if there is none.
+func Discover(startDir string) (string, error) {
+	return discover(startDir, "")
+}
+
+// discover is Discover, stopping after stopDir when it is an ancestor of startDir. An
+// empty stopDir walks up to the filesystem root.
+func discover(startDir, stopDir string) (string, error) {
+	dir, err := filepath.Abs(startDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %q: %w", startDir, err)
 	}
+	if stopDir != "" {
+		if stopDir, err = filepath.Abs(stopDir); err != nil {
+			return "", fmt.Errorf("failed to resolve %q: %w", stopDir, err)
+		}
+	}
+
+	for {
+		for _, name := range DiscoverFileNames {
+			p := filepath.Join(dir, name)
+			if info, err := os.Stat(p); err == nil && !info.IsDir() {
+				return p, nil
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir || dir == stopDir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
 
-	data, err := os.ReadFile(configFile)
+// openConfig opens a config file for reading, treating "-" as stdin.
+func openConfig(configFile string) (io.ReadCloser, error) {
+	if configFile == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	f, err := os.Open(configFile)
 	if err != nil {
 		return nil, fmt.Errorf("configuration file %q doesn't exist", configFile)
 	}
+	return f, nil
+}
 
-	if err := json.Unmarshal(data, cfg); err != nil {
-		return nil, fmt.Errorf("invalid JSON in browser config file %q: %w", configFile, err)
+// ApplyFontFamily sets fontFamily at the top level and in themeVariables, where mermaid's
+// themes read it from. A config that already sets fontFamily in either place (e.g. in a
+// config file) takes precedence, and the value is not applied at all, so the two never
+// disagree.
+func (c MermaidConfig) ApplyFontFamily(fontFamily string) {
+	if fontFamily == "" {
+		return
+	}
+	if _, ok := c["fontFamily"]; ok {
+		return
+	}
+	vars, _ := asMap(c["themeVariables"])
+	if _, ok := vars["fontFamily"]; ok {
+		return
 	}
 
-	return cfg, nil
+	// Merge the existing config over the font defaults so the rest of it is kept
+	merged := MermaidConfig{
+		"fontFamily":     fontFamily,
+		"themeVariables": map[string]interface{}{"fontFamily": fontFamily},
+	}
+	mergeConfig(merged, c)
+	for k, v := range merged {
+		c[k] = v
+	}
+}
+
+// themeBackgrounds are the page backgrounds of mermaid's built-in themes that aren't white.
+var themeBackgrounds = map[string]string{
+	"dark": "#333",
+	"base": "#f4f4f4",
+}
+
+// ThemeBackground returns the background the config's theme implies: themeVariables.background
+// if set, else the background of a built-in dark or tinted theme, else "".
+func (c MermaidConfig) ThemeBackground() string {
+	if vars, ok := asMap(c["themeVariables"]); ok {
+		if bg, ok := vars["background"].(string); ok && bg != "" {
+			return bg
+		}
+	}
+	theme, _ := c["theme"].(string)
+	return themeBackgrounds[theme]
+}
+
+// ValidateSecurityLevel checks that level is a securityLevel mermaid accepts.
+func ValidateSecurityLevel(level string) error {
+	for _, l := range securityLevels {
+		if level == l {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid securityLevel %q, must be one of %s", level, strings.Join(securityLevels, ", "))
+}
+
+// ApplySecurityLevel sets securityLevel, overriding the value from any config file.
+// An empty level leaves the config unchanged.
+func (c MermaidConfig) ApplySecurityLevel(level string) {
+	if level != "" {
+		c["securityLevel"] = level
+	}
+}
+
+// Sandboxed reports whether the config renders diagrams with securityLevel sandbox, in
+// which mermaid puts the diagram in a sandboxed iframe rather than the page.
+func (c MermaidConfig) Sandboxed() bool {
+	return c["securityLevel"] == "sandbox"
 }
 
-// LoadCSSFile reads a CSS file and returns its contents.
-func LoadCSSFile(cssFile string) (string, error) {
-	if cssFile == "" {
-		return "", nil
+// LoadBrowserConfig reads a browser config JSON file. A configFile of "-" reads the JSON from stdin.
+func LoadBrowserConfig(configFile string) (*BrowserConfig, error) {
+	if configFile == "" {
+		return &BrowserConfig{}, nil
 	}
 
-	data, err := os.ReadFile(cssFile)
+	r, err := openConfig(configFile)
 	if err != nil {
-		return "", fmt.Errorf("CSS file %q doesn't exist", cssFile)
+		return nil, err
 	}
+	defer r.Close()
 
-	return string(data), nil
+	cfg, err := loadBrowserConfigFrom(r)
+	if err != nil {
+		return nil, fmt.Errorf("invalid browser config file %q: %w", configFile, err)
+	}
+	return cfg, nil
+}
+
+// loadBrowserConfigFrom reads browser config JSON from r.
+func loadBrowserConfigFrom(r io.Reader) (*BrowserConfig, error) {
+	cfg := &BrowserConfig{}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// LoadCSSFile reads the given CSS files and returns their contents concatenated in
+// order, separated by newlines. Empty paths are skipped.
+func LoadCSSFile(cssFiles ...string) (string, error) {
+	var parts []string
+	for _, cssFile := range cssFiles {
+		if cssFile == "" {
+			continue
+		}
+		data, err := os.ReadFile(cssFile)
+		if err != nil {
+			return "", fmt.Errorf("CSS file %q doesn't exist", cssFile)
+		}
+		parts = append(parts, string(data))
+	}
+
+	return strings.Join(parts, "\n"), nil
 }
 
 // ToJSON serializes a MermaidConfig to JSON string.
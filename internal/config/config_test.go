@@ -1,16 +1,19 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 // --- LoadMermaidConfig ---
 
 func TestLoadMermaidConfig_EmptyFile(t *testing.T) {
-	cfg, err := LoadMermaidConfig("", "default")
+	cfg, err := LoadMermaidConfig(nil, "default", false)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -24,7 +27,7 @@ func TestLoadMermaidConfig_WithFile(t *testing.T) {
 	p := filepath.Join(dir, "config.json")
 	os.WriteFile(p, []byte(`{"theme":"dark","logLevel":"error"}`), 0644)
 
-	cfg, err := LoadMermaidConfig(p, "default")
+	cfg, err := LoadMermaidConfig([]string{p}, "default", false)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -38,7 +41,7 @@ func TestLoadMermaidConfig_WithFile(t *testing.T) {
 }
 
 func TestLoadMermaidConfig_MissingFile(t *testing.T) {
-	_, err := LoadMermaidConfig("/nonexistent/config.json", "default")
+	_, err := LoadMermaidConfig([]string{"/nonexistent/config.json"}, "default", false)
 	if err == nil {
 		t.Fatal("expected error for missing file, got nil")
 	}
@@ -49,7 +52,7 @@ func TestLoadMermaidConfig_InvalidJSON(t *testing.T) {
 	p := filepath.Join(dir, "bad.json")
 	os.WriteFile(p, []byte(`{not json}`), 0644)
 
-	_, err := LoadMermaidConfig(p, "default")
+	_, err := LoadMermaidConfig([]string{p}, "default", false)
 	if err == nil {
 		t.Fatal("expected error for invalid JSON, got nil")
 	}
@@ -58,6 +61,90 @@ func TestLoadMermaidConfig_InvalidJSON(t *testing.T) {
 	}
 }
 
+// writeMermaidConfig writes a config.json with content to a temp dir and returns its path.
+func writeMermaidConfig(t *testing.T, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoadMermaidConfig_NestedSections(t *testing.T) {
+	cfg, err := LoadMermaidConfig([]string{writeMermaidConfig(t, `{"theme":"forest","flowchart":{"curve":"basis"}}`)}, "default", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg["theme"] != "forest" {
+		t.Errorf("expected theme %q, got %q", "forest", cfg["theme"])
+	}
+	if _, ok := cfg["flowchart"].(map[string]interface{}); !ok {
+		t.Errorf("expected flowchart section, got %v", cfg["flowchart"])
+	}
+}
+
+func TestLoadMermaidConfig_KeepsDefaultTheme(t *testing.T) {
+	cfg, err := LoadMermaidConfig([]string{writeMermaidConfig(t, `{"logLevel":"error"}`)}, "neutral", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg["theme"] != "neutral" {
+		t.Errorf("expected theme %q, got %q", "neutral", cfg["theme"])
+	}
+}
+
+func TestLoadMermaidConfig_DefaultSecurityLevel(t *testing.T) {
+	cfg, err := LoadMermaidConfig(nil, "default", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg["securityLevel"] != "strict" {
+		t.Errorf("expected securityLevel %q, got %v", "strict", cfg["securityLevel"])
+	}
+
+	// A config file without securityLevel keeps the default
+	dir := t.TempDir()
+	p := filepath.Join(dir, "config.json")
+	os.WriteFile(p, []byte(`{"theme":"dark"}`), 0644)
+	cfg, err = LoadMermaidConfig([]string{p}, "default", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg["securityLevel"] != "strict" {
+		t.Errorf("expected securityLevel %q, got %v", "strict", cfg["securityLevel"])
+	}
+}
+
+func TestLoadMermaidConfig_ExplicitSecurityLevel(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "config.json")
+	os.WriteFile(p, []byte(`{"securityLevel":"loose"}`), 0644)
+
+	cfg, err := LoadMermaidConfig([]string{p}, "default", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg["securityLevel"] != "loose" {
+		t.Errorf("expected the config file's securityLevel %q, got %v", "loose", cfg["securityLevel"])
+	}
+
+	cfg, err = LoadMermaidConfig([]string{writeMermaidConfig(t, `{"securityLevel":"antiscript"}`)}, "default", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg["securityLevel"] != "antiscript" {
+		t.Errorf("expected the config's securityLevel %q, got %v", "antiscript", cfg["securityLevel"])
+	}
+}
+
+func TestLoadMermaidConfig_TruncatedJSON(t *testing.T) {
+	_, err := LoadMermaidConfig([]string{writeMermaidConfig(t, `{nope`)}, "default", false)
+	if err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("expected 'invalid JSON' error, got %v", err)
+	}
+}
+
 // --- LoadBrowserConfig ---
 
 func TestLoadBrowserConfig_EmptyFile(t *testing.T) {
@@ -117,6 +204,16 @@ func TestLoadBrowserConfig_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestLoadBrowserConfigFrom_Reader(t *testing.T) {
+	cfg, err := loadBrowserConfigFrom(strings.NewReader(`{"executablePath":"/opt/chrome","timeout":5000}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ExecutablePath != "/opt/chrome" || cfg.Timeout != 5000 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+}
+
 // --- LoadCSSFile ---
 
 func TestLoadCSSFile_Empty(t *testing.T) {
@@ -151,6 +248,45 @@ func TestLoadCSSFile_MissingFile(t *testing.T) {
 	}
 }
 
+func TestLoadCSSFile_Concatenates(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.css")
+	theme := filepath.Join(dir, "theme.css")
+	os.WriteFile(base, []byte("body { margin: 0; }"), 0644)
+	os.WriteFile(theme, []byte("svg { color: red; }"), 0644)
+
+	css, err := LoadCSSFile(base, theme)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "body { margin: 0; }\nsvg { color: red; }"; css != want {
+		t.Errorf("expected %q, got %q", want, css)
+	}
+
+	css, err = LoadCSSFile(theme, base)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "svg { color: red; }\nbody { margin: 0; }"; css != want {
+		t.Errorf("expected %q, got %q", want, css)
+	}
+}
+
+func TestLoadCSSFile_MissingFileAmongMany(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.css")
+	os.WriteFile(base, []byte("body { margin: 0; }"), 0644)
+	missing := filepath.Join(dir, "theme.css")
+
+	_, err := LoadCSSFile(base, missing)
+	if err == nil {
+		t.Fatal("expected error for missing file, got nil")
+	}
+	if !strings.Contains(err.Error(), missing) {
+		t.Errorf("expected error to name %q, got %v", missing, err)
+	}
+}
+
 // --- ToJSON ---
 
 func TestToJSON(t *testing.T) {
@@ -163,3 +299,749 @@ func TestToJSON(t *testing.T) {
 		t.Errorf("expected JSON to contain theme, got %q", j)
 	}
 }
+
+// --- ThemeBackground ---
+
+func TestThemeBackground(t *testing.T) {
+	tests := []struct {
+		cfg  MermaidConfig
+		want string
+	}{
+		{MermaidConfig{"theme": "default"}, ""},
+		{MermaidConfig{"theme": "forest"}, ""},
+		{MermaidConfig{"theme": "dark"}, "#333"},
+		{MermaidConfig{"theme": "base"}, "#f4f4f4"},
+		{MermaidConfig{"theme": "dark", "themeVariables": map[string]interface{}{"background": "#1e1e1e"}}, "#1e1e1e"},
+		{MermaidConfig{"theme": "default", "themeVariables": map[string]interface{}{"primaryColor": "#ff0000"}}, ""},
+		{MermaidConfig{}, ""},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.ThemeBackground(); got != tt.want {
+			t.Errorf("ThemeBackground(%v) = %q, want %q", tt.cfg, got, tt.want)
+		}
+	}
+}
+
+// --- ApplyFontFamily ---
+
+func TestApplyFontFamily(t *testing.T) {
+	cfg := MermaidConfig{"theme": "default"}
+	cfg.ApplyFontFamily("Inter, sans-serif")
+
+	if cfg["fontFamily"] != "Inter, sans-serif" {
+		t.Errorf("expected fontFamily %q, got %v", "Inter, sans-serif", cfg["fontFamily"])
+	}
+	themeVars, ok := cfg["themeVariables"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected themeVariables map, got %T", cfg["themeVariables"])
+	}
+	if themeVars["fontFamily"] != "Inter, sans-serif" {
+		t.Errorf("expected themeVariables.fontFamily %q, got %v", "Inter, sans-serif", themeVars["fontFamily"])
+	}
+}
+
+func TestApplyFontFamily_ConfigFileTakesPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "config.json")
+	os.WriteFile(p, []byte(`{"fontFamily":"Roboto","themeVariables":{"primaryColor":"#ff0000"}}`), 0644)
+
+	cfg, err := LoadMermaidConfig([]string{p}, "default", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.ApplyFontFamily("Inter")
+
+	if cfg["fontFamily"] != "Roboto" {
+		t.Errorf("expected config file fontFamily to win, got %v", cfg["fontFamily"])
+	}
+	themeVars := cfg["themeVariables"].(map[string]interface{})
+	if _, ok := themeVars["fontFamily"]; ok {
+		t.Errorf("expected the flag to be skipped entirely, got themeVariables.fontFamily %v", themeVars["fontFamily"])
+	}
+	if themeVars["primaryColor"] != "#ff0000" {
+		t.Errorf("expected existing themeVariables to be preserved, got %v", themeVars)
+	}
+}
+
+func TestApplyFontFamily_ThemeVariablesTakePrecedence(t *testing.T) {
+	cfg := MermaidConfig{"themeVariables": map[string]interface{}{"fontFamily": "Roboto"}}
+	cfg.ApplyFontFamily("Inter")
+
+	if _, ok := cfg["fontFamily"]; ok {
+		t.Errorf("expected the flag to be skipped entirely, got fontFamily %v", cfg["fontFamily"])
+	}
+	if themeVars := cfg["themeVariables"].(map[string]interface{}); themeVars["fontFamily"] != "Roboto" {
+		t.Errorf("expected config themeVariables.fontFamily to win, got %v", themeVars["fontFamily"])
+	}
+}
+
+func TestApplyFontFamily_Empty(t *testing.T) {
+	cfg := MermaidConfig{"theme": "default"}
+	cfg.ApplyFontFamily("")
+
+	if len(cfg) != 1 {
+		t.Errorf("expected config to be unchanged, got %v", cfg)
+	}
+}
+
+func TestApplySecurityLevel(t *testing.T) {
+	cfg := MermaidConfig{"securityLevel": "loose"}
+	cfg.ApplySecurityLevel("")
+	if cfg["securityLevel"] != "loose" {
+		t.Errorf("expected an empty level to leave the config unchanged, got %v", cfg["securityLevel"])
+	}
+
+	cfg.ApplySecurityLevel("strict")
+	if cfg["securityLevel"] != "strict" {
+		t.Errorf("expected securityLevel %q, got %v", "strict", cfg["securityLevel"])
+	}
+}
+
+func TestMermaidConfig_Sandboxed(t *testing.T) {
+	if !(MermaidConfig{"securityLevel": "sandbox"}).Sandboxed() {
+		t.Error("expected securityLevel sandbox to be sandboxed")
+	}
+	for _, cfg := range []MermaidConfig{{}, {"securityLevel": "strict"}, {"securityLevel": true}} {
+		if cfg.Sandboxed() {
+			t.Errorf("expected %v not to be sandboxed", cfg)
+		}
+	}
+}
+
+func TestValidateSecurityLevel(t *testing.T) {
+	for _, level := range []string{"strict", "loose", "antiscript", "sandbox"} {
+		if err := ValidateSecurityLevel(level); err != nil {
+			t.Errorf("expected %q to be valid, got %v", level, err)
+		}
+	}
+	for _, level := range []string{"", "Strict", "none"} {
+		if err := ValidateSecurityLevel(level); err == nil {
+			t.Errorf("expected %q to be invalid", level)
+		}
+	}
+}
+
+func TestBrowserConfig_TimeoutDuration(t *testing.T) {
+	cfg := &BrowserConfig{Timeout: 1500}
+	if got := cfg.TimeoutDuration(); got != 1500*time.Millisecond {
+		t.Errorf("expected 1.5s, got %s", got)
+	}
+	if got := (&BrowserConfig{}).TimeoutDuration(); got != 0 {
+		t.Errorf("expected 0 when unset, got %s", got)
+	}
+}
+
+func TestLoadBrowserConfig_HeadlessBool(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "browser.json")
+	os.WriteFile(p, []byte(`{"headless":false}`), 0644)
+
+	cfg, err := LoadBrowserConfig(p)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Headless != "false" {
+		t.Errorf("expected headless %q, got %q", "false", cfg.Headless)
+	}
+}
+
+func TestLoadBrowserConfig_HeadlessInvalidType(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "browser.json")
+	os.WriteFile(p, []byte(`{"headless":1}`), 0644)
+
+	if _, err := LoadBrowserConfig(p); err == nil {
+		t.Fatal("expected error for numeric headless value, got nil")
+	}
+}
+
+// --- YAML config ---
+
+func TestLoadMermaidConfig_YAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".mermaidrc.yaml")
+	content := "theme: forest\nflowchart:\n  curve: basis\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadMermaidConfig([]string{path}, "default", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg["theme"] != "forest" {
+		t.Errorf("expected theme 'forest', got %v", cfg["theme"])
+	}
+	flowchart, ok := cfg["flowchart"].(map[string]interface{})
+	if !ok || flowchart["curve"] != "basis" {
+		t.Errorf("expected nested flowchart config, got %#v", cfg["flowchart"])
+	}
+}
+
+func TestLoadMermaidConfig_InvalidYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("theme: [unclosed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadMermaidConfig([]string{path}, "default", false)
+	if err == nil || !strings.Contains(err.Error(), "invalid YAML") {
+		t.Errorf("expected invalid YAML error, got %v", err)
+	}
+}
+
+// --- JSONC config ---
+
+func TestStripJSONComments(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"no comments", `{"theme":"dark"}`, `{"theme":"dark"}`},
+		{"line comment", "// theme override\n{\"theme\":\"dark\"}", "                 \n{\"theme\":\"dark\"}"},
+		{"trailing line comment", `{"theme":"dark"} // end`, `{"theme":"dark"}       `},
+		{"block comment", `{/* a */"theme":"dark"}`, `{       "theme":"dark"}`},
+		{"multi-line block comment", "{/* a\nb */\"theme\":\"dark\"}", "{    \n    \"theme\":\"dark\"}"},
+		{"url in string", `{"fontUrl":"https://example.com/a.css"}`, `{"fontUrl":"https://example.com/a.css"}`},
+		{"comment markers in string", `{"a":"/* not */ // a comment"}`, `{"a":"/* not */ // a comment"}`},
+		{"escaped quote in string", `{"a":"say \"//\" here"} // c`, `{"a":"say \"//\" here"}     `},
+	}
+	for _, tt := range tests {
+		got, err := stripJSONComments([]byte(tt.in))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStripJSONComments_Unterminated(t *testing.T) {
+	if _, err := stripJSONComments([]byte(`{"theme":"dark"} /* open`)); err == nil {
+		t.Error("expected an error for an unterminated block comment")
+	}
+}
+
+func TestLoadMermaidConfig_JSONC(t *testing.T) {
+	content := `{
+  // theme override
+  "theme": "forest", /* inline */
+  "themeCSS": "@import url('https://example.com/font.css');",
+  "flowchart": {
+    /* multi
+       line */
+    "curve": "basis" // trailing
+  }
+}
+`
+	check := func(t *testing.T, cfg MermaidConfig) {
+		t.Helper()
+		if cfg["theme"] != "forest" {
+			t.Errorf("expected theme 'forest', got %v", cfg["theme"])
+		}
+		if cfg["themeCSS"] != "@import url('https://example.com/font.css');" {
+			t.Errorf("expected the URL in a string to be kept, got %v", cfg["themeCSS"])
+		}
+		flowchart, ok := cfg["flowchart"].(map[string]interface{})
+		if !ok || flowchart["curve"] != "basis" {
+			t.Errorf("expected nested flowchart config, got %#v", cfg["flowchart"])
+		}
+	}
+
+	dir := t.TempDir()
+	jsoncPath := filepath.Join(dir, "mermaid.jsonc")
+	jsonPath := filepath.Join(dir, "mermaid.json")
+	for _, p := range []string{jsoncPath, jsonPath} {
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("jsonc extension", func(t *testing.T) {
+		cfg, err := LoadMermaidConfig([]string{jsoncPath}, "default", false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		check(t, cfg)
+	})
+	t.Run("jsonc flag", func(t *testing.T) {
+		cfg, err := LoadMermaidConfig([]string{jsonPath}, "default", true)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		check(t, cfg)
+	})
+	t.Run("json without flag", func(t *testing.T) {
+		if _, err := LoadMermaidConfig([]string{jsonPath}, "default", false); err == nil {
+			t.Error("expected comments in a .json file to be rejected without --jsonc")
+		}
+	})
+}
+
+// --- Discover ---
+
+func TestDiscover(t *testing.T) {
+	// The walk stops at root, so config files above the temp dir can't be found
+	root := t.TempDir()
+	nested := filepath.Join(root, "docs", "guides", "deep")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing to find yet
+	if found, err := discover(nested, root); err != nil || found != "" {
+		t.Fatalf("expected no config, got %q (err %v)", found, err)
+	}
+
+	rootConfig := filepath.Join(root, "mermaid.config.json")
+	if err := os.WriteFile(rootConfig, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := discover(nested, root); found != rootConfig {
+		t.Errorf("expected %q from a parent directory, got %q", rootConfig, found)
+	}
+
+	// The nearest directory wins over parents
+	docsConfig := filepath.Join(root, "docs", ".mermaidrc.yaml")
+	if err := os.WriteFile(docsConfig, []byte("theme: dark\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := discover(nested, root); found != docsConfig {
+		t.Errorf("expected nearest config %q, got %q", docsConfig, found)
+	}
+
+	// Within a directory, .mermaidrc.json is preferred
+	docsJSON := filepath.Join(root, "docs", ".mermaidrc.json")
+	if err := os.WriteFile(docsJSON, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := discover(filepath.Join(root, "docs"), root); found != docsJSON {
+		t.Errorf("expected %q to take precedence, got %q", docsJSON, found)
+	}
+}
+
+func TestDiscover_IgnoresDirectories(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, ".mermaidrc.json"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := discover(root, root); found != "" {
+		t.Errorf("expected directory named like a config to be ignored, got %q", found)
+	}
+}
+
+func TestDiscover_StopsAtStopDir(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	nested := filepath.Join(project, "docs")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".mermaidrc.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := discover(nested, project); err != nil || found != "" {
+		t.Errorf("expected the walk to stop at %q, got %q (err %v)", project, found, err)
+	}
+	if found, _ := discover(nested, root); found != filepath.Join(root, ".mermaidrc.json") {
+		t.Errorf("expected the config in the stop directory itself, got %q", found)
+	}
+}
+
+// --- Multiple config files ---
+
+func TestLoadMermaidConfig_MergesFilesInOrder(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.json")
+	project := filepath.Join(dir, "project.json")
+	if err := os.WriteFile(base, []byte(`{"theme":"forest","flowchart":{"curve":"basis","padding":10},"themeVariables":{"primaryColor":"#fff"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(project, []byte(`{"flowchart":{"padding":20,"htmlLabels":false},"themeVariables":{"lineColor":"#333"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadMermaidConfig([]string{base, project}, "default", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg["theme"] != "forest" {
+		t.Errorf("expected theme from base file, got %v", cfg["theme"])
+	}
+	flowchart := cfg["flowchart"].(map[string]interface{})
+	if flowchart["curve"] != "basis" {
+		t.Errorf("expected curve kept from base file, got %v", flowchart["curve"])
+	}
+	if flowchart["padding"] != float64(20) {
+		t.Errorf("expected padding from the later file to win, got %v", flowchart["padding"])
+	}
+	if flowchart["htmlLabels"] != false {
+		t.Errorf("expected htmlLabels added by the later file, got %v", flowchart["htmlLabels"])
+	}
+	themeVars := cfg["themeVariables"].(map[string]interface{})
+	if themeVars["primaryColor"] != "#fff" || themeVars["lineColor"] != "#333" {
+		t.Errorf("expected themeVariables from both files, got %v", themeVars)
+	}
+}
+
+func TestLoadMermaidConfig_MissingSecondFile(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "base.json")
+	if err := os.WriteFile(base, []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadMermaidConfig([]string{base, "/nonexistent/project.json"}, "default", false); err == nil {
+		t.Error("expected error for a missing config file")
+	}
+}
+
+func TestMermaidConfig_Clone(t *testing.T) {
+	cfg := MermaidConfig{"theme": "default", "flowchart": map[string]interface{}{"curve": "basis"}}
+	clone := cfg.Clone()
+	clone["theme"] = "dark"
+	clone["flowchart"].(map[string]interface{})["curve"] = "linear"
+
+	if cfg["theme"] != "default" || cfg["flowchart"].(map[string]interface{})["curve"] != "basis" {
+		t.Errorf("expected the original to be unchanged, got %v", cfg)
+	}
+	if MermaidConfig(nil).Clone() != nil {
+		t.Error("expected a nil config to clone to nil")
+	}
+}
+
+func TestMergeConfig_Nested(t *testing.T) {
+	dst := MermaidConfig{
+		"theme":     "default",
+		"flowchart": map[string]interface{}{"curve": "basis", "nodeSpacing": float64(50)},
+	}
+	src := MermaidConfig{
+		"flowchart": map[string]interface{}{"curve": "linear", "htmlLabels": false},
+		"sequence":  map[string]interface{}{"mirrorActors": true},
+	}
+
+	mergeConfig(dst, src)
+
+	flowchart := dst["flowchart"].(map[string]interface{})
+	if flowchart["curve"] != "linear" || flowchart["nodeSpacing"] != float64(50) || flowchart["htmlLabels"] != false {
+		t.Errorf("unexpected flowchart after merge: %v", flowchart)
+	}
+	if dst["theme"] != "default" {
+		t.Errorf("expected untouched keys to be kept, got theme %v", dst["theme"])
+	}
+
+	// Objects added from src must not alias it
+	dst["sequence"].(map[string]interface{})["mirrorActors"] = false
+	if src["sequence"].(map[string]interface{})["mirrorActors"] != true {
+		t.Error("expected merged object to be a copy of src")
+	}
+}
+
+func TestMergeConfig_TypeConflicts(t *testing.T) {
+	dst := MermaidConfig{
+		"flowchart":      map[string]interface{}{"curve": "basis"},
+		"themeVariables": "dark",
+	}
+	src := MermaidConfig{
+		"flowchart":      false,
+		"themeVariables": map[string]interface{}{"primaryColor": "#fff"},
+	}
+
+	mergeConfig(dst, src)
+
+	if dst["flowchart"] != false {
+		t.Errorf("expected scalar to replace object, got %v", dst["flowchart"])
+	}
+	themeVars, ok := dst["themeVariables"].(map[string]interface{})
+	if !ok || themeVars["primaryColor"] != "#fff" {
+		t.Errorf("expected object to replace scalar, got %v", dst["themeVariables"])
+	}
+}
+
+func TestMergeConfig_ArraysReplaced(t *testing.T) {
+	dst := MermaidConfig{"secure": []interface{}{"secure", "securityLevel", "startOnLoad"}, "theme": "dark"}
+	src := MermaidConfig{"secure": []interface{}{"maxTextSize"}}
+
+	mergeConfig(dst, src)
+
+	if got := fmt.Sprint(dst["secure"]); got != "[maxTextSize]" {
+		t.Errorf("expected array to be replaced wholesale, got %s", got)
+	}
+	if dst["theme"] != "dark" {
+		t.Errorf("expected sibling keys to be kept, got %v", dst)
+	}
+}
+
+func TestLoadMermaidConfig_DeepMergesDefaults(t *testing.T) {
+	cfg, err := LoadMermaidConfig([]string{writeMermaidConfig(t, `{"flowchart":{"curve":"linear"}}`)}, "forest", false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg["theme"] != "forest" {
+		t.Errorf("expected default theme to be kept, got %v", cfg["theme"])
+	}
+	if cfg["flowchart"].(map[string]interface{})["curve"] != "linear" {
+		t.Errorf("unexpected flowchart %v", cfg["flowchart"])
+	}
+}
+
+// --- Presets ---
+
+func TestPresetNames(t *testing.T) {
+	want := []string{"github-dark", "github-light", "high-contrast"}
+	if got := PresetNames(); !reflect.DeepEqual(got, want) {
+		t.Errorf("PresetNames() = %v, want %v", got, want)
+	}
+}
+
+func TestBaseMermaidConfig_Presets(t *testing.T) {
+	backgrounds := map[string]string{
+		"github-light":  "#ffffff",
+		"github-dark":   "#0d1117",
+		"high-contrast": "#ffffff",
+	}
+	for _, name := range PresetNames() {
+		t.Run(name, func(t *testing.T) {
+			preset, err := LoadPreset(name)
+			if err != nil {
+				t.Fatalf("LoadPreset: %v", err)
+			}
+			if preset.CSS == "" {
+				t.Error("expected the preset to have CSS")
+			}
+			cfg := BaseMermaidConfig("default", preset, false)
+			if cfg["theme"] != "base" {
+				t.Errorf("theme = %v, want base", cfg["theme"])
+			}
+			if cfg["securityLevel"] != DefaultSecurityLevel {
+				t.Errorf("securityLevel = %v, want %s", cfg["securityLevel"], DefaultSecurityLevel)
+			}
+			vars, ok := cfg["themeVariables"].(map[string]any)
+			if !ok {
+				t.Fatalf("themeVariables missing: %v", cfg)
+			}
+			for _, key := range []string{"primaryColor", "primaryTextColor", "primaryBorderColor", "lineColor"} {
+				if _, ok := vars[key]; !ok {
+					t.Errorf("themeVariables.%s missing", key)
+				}
+			}
+			if got := cfg.ThemeBackground(); got != backgrounds[name] {
+				t.Errorf("ThemeBackground() = %q, want %q", got, backgrounds[name])
+			}
+		})
+	}
+}
+
+func TestBaseMermaidConfig_KeepTheme(t *testing.T) {
+	preset, err := LoadPreset("github-dark")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := BaseMermaidConfig("forest", preset, true)
+	if cfg["theme"] != "forest" {
+		t.Errorf("theme = %v, want forest", cfg["theme"])
+	}
+	if _, ok := cfg["themeVariables"]; !ok {
+		t.Error("expected the preset's themeVariables to be kept")
+	}
+}
+
+func TestBaseMermaidConfig_NoPreset(t *testing.T) {
+	cfg := BaseMermaidConfig("dark", nil, false)
+	if !reflect.DeepEqual(cfg, defaultMermaidConfig("dark")) {
+		t.Errorf("got %v, want the defaults", cfg)
+	}
+}
+
+func TestLoadMermaidConfigOver_FileOverridesPreset(t *testing.T) {
+	preset, err := LoadPreset("github-light")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"themeVariables":{"primaryColor":"#ff0000"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadMermaidConfigOver(BaseMermaidConfig("default", preset, false), []string{path}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	vars := cfg["themeVariables"].(map[string]any)
+	if vars["primaryColor"] != "#ff0000" {
+		t.Errorf("primaryColor = %v, want the config file's #ff0000", vars["primaryColor"])
+	}
+	if vars["lineColor"] != "#656d76" {
+		t.Errorf("lineColor = %v, want the preset's #656d76", vars["lineColor"])
+	}
+}
+
+func TestLoadPreset_Unknown(t *testing.T) {
+	_, err := LoadPreset("solarized")
+	if err == nil || !strings.Contains(err.Error(), "github-dark, github-light, high-contrast") {
+		t.Errorf("expected an error listing the presets, got %v", err)
+	}
+}
+
+// --- Schema validation ---
+
+func TestValidateMermaidConfig_Valid(t *testing.T) {
+	cfg := map[string]interface{}{
+		"theme":          "dark",
+		"look":           "handDrawn",
+		"securityLevel":  "loose",
+		"maxTextSize":    float64(90000),
+		"logLevel":       "debug",
+		"htmlLabels":     false,
+		"themeVariables": map[string]interface{}{"primaryColor": "#fff"},
+		"flowchart":      map[string]interface{}{"curve": "basis", "anyNestedKey": true},
+	}
+	if problems := ValidateMermaidConfig(cfg); problems != nil {
+		t.Errorf("expected no problems, got %v", problems)
+	}
+}
+
+func TestValidateMermaidConfig_Problems(t *testing.T) {
+	cfg := map[string]interface{}{
+		"them":        "dark",
+		"look":        "sketchy",
+		"htmlLabels":  "yes",
+		"maxTextSize": 1.5,
+		"flowchart":   "basis",
+	}
+	want := []string{
+		`flowchart must be object, got string`,
+		`htmlLabels must be boolean, got string`,
+		`look must be one of classic, handDrawn, got sketchy`,
+		`unknown key "them"`,
+	}
+	if got := ValidateMermaidConfig(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestValidateMermaidConfig_YAMLIntegers(t *testing.T) {
+	cfg, err := decodeYAMLConfig(strings.NewReader("maxTextSize: 90000\nlogLevel: 1\nflowchart:\n  curve: basis\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if problems := ValidateMermaidConfig(cfg); problems != nil {
+		t.Errorf("expected no problems, got %v", problems)
+	}
+}
+
+func TestValidateMermaidConfig_Presets(t *testing.T) {
+	for _, name := range PresetNames() {
+		preset, err := LoadPreset(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if problems := ValidateMermaidConfig(preset.Config); problems != nil {
+			t.Errorf("preset %s: %v", name, problems)
+		}
+	}
+}
+
+func TestLoadMermaidConfigChecked(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.json")
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(good, []byte(`{"theme":"forest"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte(`{"them":"dark"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, problems, err := LoadMermaidConfigChecked(defaultMermaidConfig("default"), []string{good, bad}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{fmt.Sprintf("config file %q: unknown key \"them\"", bad)}; !reflect.DeepEqual(problems, want) {
+		t.Errorf("problems = %q, want %q", problems, want)
+	}
+	// Problems don't stop the config from loading
+	if cfg["theme"] != "forest" || cfg["them"] != "dark" {
+		t.Errorf("unexpected config %v", cfg)
+	}
+}
+
+// --- Environment variable expansion ---
+
+func TestMermaidConfig_ExpandEnv(t *testing.T) {
+	t.Setenv("MMD_FONT_DIR", "/opt/fonts")
+	t.Setenv("MMD_COLOR", "#123456")
+	cfg := MermaidConfig{
+		"fontPath":       "${MMD_FONT_DIR}/Inter.ttf",
+		"themeVariables": map[string]interface{}{"primaryColor": "$MMD_COLOR", "fontSize": float64(16)},
+		"secure":         []interface{}{"${MMD_COLOR}", true},
+		"missing":        "a${MMD_UNSET_VAR}b",
+		"$MMD_COLOR":     "keys aren't expanded",
+	}
+	if err := cfg.ExpandEnv(false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := MermaidConfig{
+		"fontPath":       "/opt/fonts/Inter.ttf",
+		"themeVariables": map[string]interface{}{"primaryColor": "#123456", "fontSize": float64(16)},
+		"secure":         []interface{}{"#123456", true},
+		"missing":        "ab",
+		"$MMD_COLOR":     "keys aren't expanded",
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("got %v, want %v", cfg, want)
+	}
+}
+
+func TestMermaidConfig_ExpandEnvStrict(t *testing.T) {
+	cfg := MermaidConfig{"flowchart": map[string]interface{}{"curve": "${MMD_UNSET_VAR}"}}
+	err := cfg.ExpandEnv(true)
+	if err == nil || !strings.Contains(err.Error(), "MMD_UNSET_VAR is not set") {
+		t.Errorf("expected an unset variable error, got %v", err)
+	}
+}
+
+func TestExpandEnv_DollarEscape(t *testing.T) {
+	t.Setenv("MMD_PRICE", "5")
+	got, err := ExpandEnv("costs $$${MMD_PRICE}, not $$MMD_PRICE", true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "costs $5, not $MMD_PRICE"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBrowserConfig_ExpandEnv(t *testing.T) {
+	t.Setenv("MMD_CHROME", "/usr/bin/chromium")
+	t.Setenv("MMD_PROXY", "http://proxy:3128")
+	cfg := &BrowserConfig{ExecutablePath: "$MMD_CHROME", Args: []string{"--proxy-server=${MMD_PROXY}"}}
+	if err := cfg.ExpandEnv(true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ExecutablePath != "/usr/bin/chromium" || cfg.Args[0] != "--proxy-server=http://proxy:3128" {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if err := (&BrowserConfig{Args: []string{"$MMD_UNSET_VAR"}}).ExpandEnv(true); err == nil {
+		t.Error("expected an error for an unset variable in strict mode")
+	}
+}
+
+func TestExpandEnv_CSS(t *testing.T) {
+	t.Setenv("MMD_FONT_URL", "https://fonts.example.com/inter.woff2")
+	path := filepath.Join(t.TempDir(), "style.css")
+	if err := os.WriteFile(path, []byte(`@font-face { src: url("${MMD_FONT_URL}"); }`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	css, err := LoadCSSFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ExpandEnv(css, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != `@font-face { src: url("https://fonts.example.com/inter.woff2"); }` {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// ExpandEnv replaces ${VAR} and $VAR in s with the values of environment variables, as
+// os.ExpandEnv does, and $$ with a literal $. An unset variable expands to "", or is an
+// error if strict is set.
+func ExpandEnv(s string, strict bool) (string, error) {
+	var missing string
+	expanded := os.Expand(s, func(name string) string {
+		if name == "$" {
+			// os.Expand reads $$ as the shell's special variable $
+			return "$"
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok && missing == "" {
+			missing = name
+		}
+		return value
+	})
+	if strict && missing != "" {
+		return "", fmt.Errorf("environment variable %s is not set", missing)
+	}
+	return expanded, nil
+}
+
+// ExpandEnv expands environment variables (see ExpandEnv) in every string value of the
+// config, including those in nested objects and arrays. Keys are left alone.
+func (c MermaidConfig) ExpandEnv(strict bool) error {
+	for k, v := range c {
+		expanded, err := expandEnvValue(v, strict)
+		if err != nil {
+			return fmt.Errorf("config key %q: %w", k, err)
+		}
+		c[k] = expanded
+	}
+	return nil
+}
+
+// expandEnvValue expands environment variables in a decoded config value.
+func expandEnvValue(v interface{}, strict bool) (interface{}, error) {
+	if m, ok := asMap(v); ok {
+		if err := m.ExpandEnv(strict); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	switch v := v.(type) {
+	case string:
+		return ExpandEnv(v, strict)
+	case []interface{}:
+		for i, item := range v {
+			expanded, err := expandEnvValue(item, strict)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+	}
+	return v, nil
+}
+
+// ExpandEnv expands environment variables (see ExpandEnv) in the executable path and
+// the launch arguments.
+func (c *BrowserConfig) ExpandEnv(strict bool) error {
+	path, err := ExpandEnv(c.ExecutablePath, strict)
+	if err != nil {
+		return fmt.Errorf("browser config executablePath: %w", err)
+	}
+	c.ExecutablePath = path
+	for i, arg := range c.Args {
+		if c.Args[i], err = ExpandEnv(arg, strict); err != nil {
+			return fmt.Errorf("browser config args: %w", err)
+		}
+	}
+	return nil
+}
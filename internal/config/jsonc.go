@@ -0,0 +1,50 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// isJSONC reports whether a config file name has the JSON-with-comments extension.
+func isJSONC(name string) bool {
+	return strings.ToLower(filepath.Ext(name)) == ".jsonc"
+}
+
+// stripJSONComments removes // line comments and /* */ block comments from JSONC data,
+// leaving string values (e.g. "https://...") untouched. Comments are replaced with
+// spaces, keeping newlines, so JSON syntax errors still point at the right line and column.
+func stripJSONComments(data []byte) ([]byte, error) {
+	out := make([]byte, len(data))
+	copy(out, data)
+
+	inString := false
+	for i := 0; i < len(out); i++ {
+		switch {
+		case inString:
+			if out[i] == '\\' {
+				i++
+			} else if out[i] == '"' {
+				inString = false
+			}
+		case out[i] == '"':
+			inString = true
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := strings.Index(string(out[i+2:]), "*/")
+			if end < 0 {
+				return nil, errors.New("unterminated /* comment")
+			}
+			for j := i; j < i+2+end+2; j++ {
+				if out[j] != '\n' {
+					out[j] = ' '
+				}
+			}
+			i += 2 + end + 1
+		}
+	}
+	return out, nil
+}
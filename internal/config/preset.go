@@ -0,0 +1,63 @@
+package config
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+// presetFiles holds the built-in presets, one JSON file per preset.
+//
+//go:embed presets/*.json
+var presetFiles embed.FS
+
+// Preset is a built-in look for diagrams: a mermaid config, usually a theme and its
+// themeVariables, and CSS to go with it.
+type Preset struct {
+	Name   string
+	Config MermaidConfig `json:"mermaid"`
+	CSS    string        `json:"css"`
+}
+
+// PresetNames returns the names of the built-in presets, sorted.
+func PresetNames() []string {
+	entries, _ := fs.Glob(presetFiles, "presets/*.json")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(path.Base(entry), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadPreset returns the built-in preset called name.
+func LoadPreset(name string) (*Preset, error) {
+	data, err := presetFiles.ReadFile("presets/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown preset %q, must be one of %s", name, strings.Join(PresetNames(), ", "))
+	}
+	preset := &Preset{Name: name}
+	if err := json.Unmarshal(data, preset); err != nil {
+		return nil, fmt.Errorf("invalid preset %q: %w", name, err)
+	}
+	return preset, nil
+}
+
+// BaseMermaidConfig returns the config that config files are merged over: the defaults
+// (the theme and DefaultSecurityLevel) with preset, if not nil, merged over them. The
+// preset's theme replaces theme unless keepTheme is set, e.g. because --theme was given.
+func BaseMermaidConfig(theme string, preset *Preset, keepTheme bool) MermaidConfig {
+	cfg := defaultMermaidConfig(theme)
+	if preset == nil {
+		return cfg
+	}
+	mergeConfig(cfg, preset.Config.Clone())
+	if keepTheme {
+		cfg["theme"] = theme
+	}
+	return cfg
+}
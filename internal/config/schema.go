@@ -0,0 +1,158 @@
+package config
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// mermaidConfigSchema is a JSON schema of the mermaid config options, used to catch typos
+// such as "them" for "theme". It supports the type, enum, properties and
+// additionalProperties keywords, which is all the bundled schema uses.
+//
+//go:embed mermaid-config.schema.json
+var mermaidConfigSchema []byte
+
+// schema is a node of the bundled JSON schema.
+type schema struct {
+	Type                 schemaTypes        `json:"type"`
+	Enum                 []interface{}      `json:"enum"`
+	Properties           map[string]*schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+}
+
+// schemaTypes is a schema "type", which may be a single type name or a list of them.
+type schemaTypes []string
+
+// UnmarshalJSON accepts both `"string"` and `["string", "number"]`.
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = schemaTypes{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("schema type must be a string or a list of strings: %w", err)
+	}
+	*t = list
+	return nil
+}
+
+// loadMermaidConfigSchema parses the bundled schema.
+func loadMermaidConfigSchema() (*schema, error) {
+	var s schema
+	if err := json.Unmarshal(mermaidConfigSchema, &s); err != nil {
+		return nil, fmt.Errorf("invalid bundled config schema: %w", err)
+	}
+	return &s, nil
+}
+
+// ValidateMermaidConfig checks cfg against the bundled schema of mermaid options and
+// returns a description of each problem, e.g. `unknown key "them"`, sorted by key.
+// It returns nil if the config is valid.
+func ValidateMermaidConfig(cfg map[string]interface{}) []string {
+	s, err := loadMermaidConfigSchema()
+	if err != nil {
+		return []string{err.Error()}
+	}
+	var problems []string
+	s.validate("", cfg, &problems)
+	return problems
+}
+
+// validate appends the problems with value, found at path, to problems.
+func (s *schema) validate(path string, value interface{}, problems *[]string) {
+	name := path
+	if name == "" {
+		name = "config"
+	}
+	if len(s.Type) > 0 && !s.Type.matches(value) {
+		*problems = append(*problems, fmt.Sprintf("%s must be %s, got %s", name, strings.Join(s.Type, " or "), jsonType(value)))
+		return
+	}
+	if len(s.Enum) > 0 && !s.allows(value) {
+		*problems = append(*problems, fmt.Sprintf("%s must be one of %s, got %v", name, formatEnum(s.Enum), value))
+		return
+	}
+
+	obj, ok := asMap(value)
+	if !ok {
+		return
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		keyPath := k
+		if path != "" {
+			keyPath = path + "." + k
+		}
+		prop, known := s.Properties[k]
+		switch {
+		case known:
+			prop.validate(keyPath, obj[k], problems)
+		case s.AdditionalProperties != nil && !*s.AdditionalProperties:
+			*problems = append(*problems, fmt.Sprintf("unknown key %q", keyPath))
+		}
+	}
+}
+
+// matches reports whether value has one of the types.
+func (t schemaTypes) matches(value interface{}) bool {
+	actual := jsonType(value)
+	for _, want := range t {
+		if want == actual || (want == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether value is one of the schema's enum values.
+func (s *schema) allows(value interface{}) bool {
+	for _, v := range s.Enum {
+		if fmt.Sprint(v) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatEnum lists enum values for an error message, e.g. "classic, handDrawn".
+func formatEnum(values []interface{}) string {
+	names := make([]string, len(values))
+	for i, v := range values {
+		names[i] = fmt.Sprint(v)
+	}
+	return strings.Join(names, ", ")
+}
+
+// jsonType returns the JSON schema type name of a decoded JSON or YAML value.
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case int, int64, uint64:
+		return "integer"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	}
+	if _, ok := asMap(value); ok {
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
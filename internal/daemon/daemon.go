@@ -0,0 +1,286 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+// DefaultSocketPath returns the default Unix socket path for the render daemon.
+// MMDC_DAEMON_SOCKET overrides it; otherwise it lives in the user's runtime or temp directory.
+func DefaultSocketPath() string {
+	if p := os.Getenv("MMDC_DAEMON_SOCKET"); p != "" {
+		return p
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, fmt.Sprintf("mmd-cli-%d.sock", os.Getuid()))
+}
+
+// Request is a single render request sent from a client to the daemon.
+type Request struct {
+	Definition   string              `json:"definition"`
+	OutputFormat string              `json:"outputFormat"`
+	Opts         renderer.RenderOpts `json:"opts"`
+}
+
+// Response is the daemon's reply to a Request.
+type Response struct {
+	Data     []byte            `json:"data,omitempty"`
+	Title    string            `json:"title,omitempty"`
+	Desc     string            `json:"desc,omitempty"`
+	Console  []string          `json:"console,omitempty"`
+	Warnings []string          `json:"warnings,omitempty"`
+	Timings  *renderer.Timings `json:"timings,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// DiagramRenderer renders a single diagram. *renderer.Renderer satisfies it.
+type DiagramRenderer interface {
+	Render(ctx context.Context, definition string, outputFormat string, opts renderer.RenderOpts) (*renderer.RenderResult, error)
+}
+
+// requestReadTimeout bounds how long a connection may take to send its request, so an
+// idle client can't hold the server open.
+const requestReadTimeout = 30 * time.Second
+
+// Server listens on a Unix socket and renders diagrams using a long-lived renderer.
+type Server struct {
+	renderer     DiagramRenderer
+	listener     net.Listener
+	wg           sync.WaitGroup
+	shuttingDown atomic.Bool
+
+	mu           sync.Mutex
+	cancelRender context.CancelFunc
+	conns        map[net.Conn]struct{}
+}
+
+// NewServer creates a Server that renders with r.
+func NewServer(r DiagramRenderer) *Server {
+	return &Server{renderer: r, conns: make(map[net.Conn]struct{})}
+}
+
+// Listen binds the server to the socket path, removing a stale socket file if one exists.
+// Anything at the path that isn't a socket is left alone and reported as an error.
+func (s *Server) Listen(socketPath string) error {
+	if fi, err := os.Lstat(socketPath); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return fmt.Errorf("%q exists and is not a socket", socketPath)
+		}
+		// A live daemon would accept the connection; anything else is a stale socket.
+		if conn, err := net.Dial("unix", socketPath); err == nil {
+			conn.Close()
+			return fmt.Errorf("a daemon is already listening on %q", socketPath)
+		}
+		if err := os.Remove(socketPath); err != nil {
+			return fmt.Errorf("failed to remove stale socket %q: %w", socketPath, err)
+		}
+	}
+
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %q: %w", socketPath, err)
+	}
+	s.listener = l
+	return nil
+}
+
+// Serve accepts connections until the listener is closed. Each connection carries one request.
+// Renders run under ctx, which Shutdown cancels if its grace period runs out.
+func (s *Server) Serve(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	s.mu.Lock()
+	s.cancelRender = cancel
+	s.mu.Unlock()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				s.wg.Wait()
+				return nil
+			}
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+
+		if !s.track(conn) {
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer s.wg.Done()
+			defer s.untrack(conn)
+			s.handle(ctx, conn)
+		}()
+	}
+}
+
+// track registers an accepted connection with the wait group, unless shutdown has begun.
+// Holding mu while checking shuttingDown keeps every wg.Add ahead of Shutdown's wg.Wait.
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shuttingDown.Load() {
+		return false
+	}
+	s.wg.Add(1)
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+// untrack closes a connection once its request has been answered.
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	conn.Close()
+}
+
+// Close stops accepting new connections. In-flight requests finish before Serve returns.
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests to finish. Requests
+// not yet read when shutdown begins, including those of idle connections, are answered with
+// renderer.ErrShuttingDown. If ctx is done first, the remaining renders are cancelled, their
+// connections closed, and ctx's error is returned once they have returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shuttingDown.Store(true)
+	for conn := range s.conns {
+		// Wakes connections still waiting for a request; those already rendering no longer read
+		_ = conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+	if err := s.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if s.cancelRender != nil {
+			s.cancelRender()
+		}
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *Server) handle(ctx context.Context, conn net.Conn) {
+	var req Request
+	var resp Response
+
+	_ = conn.SetReadDeadline(time.Now().Add(requestReadTimeout))
+	err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req)
+	if s.shuttingDown.Load() {
+		resp.Error = renderer.ErrShuttingDown.Error()
+	} else if err != nil {
+		resp.Error = fmt.Sprintf("invalid request: %v", err)
+	} else if result, err := s.renderer.Render(ctx, req.Definition, req.OutputFormat, req.Opts); err != nil {
+		resp.Error = err.Error()
+	} else {
+		resp.Data = result.Data
+		resp.Title = result.Title
+		resp.Desc = result.Desc
+		resp.Console = result.Console
+		resp.Warnings = result.Warnings
+		resp.Timings = &result.Timings
+	}
+
+	_ = json.NewEncoder(conn).Encode(&resp)
+}
+
+// Client forwards render requests to a running daemon.
+type Client struct {
+	socketPath string
+}
+
+// NewClient creates a Client for the daemon listening on socketPath.
+func NewClient(socketPath string) *Client {
+	return &Client{socketPath: socketPath}
+}
+
+// Available reports whether a daemon is accepting connections on the socket.
+func (c *Client) Available() bool {
+	conn, err := net.Dial("unix", c.socketPath)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Render sends a render request to the daemon and waits for the result.
+func (c *Client) Render(ctx context.Context, definition string, outputFormat string, opts renderer.RenderOpts) (*renderer.RenderResult, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	req := Request{Definition: definition, OutputFormat: outputFormat, Opts: opts}
+	if err := json.NewEncoder(conn).Encode(&req); err != nil {
+		return nil, fmt.Errorf("failed to send request to daemon: %w", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read response from daemon: %w", err)
+	}
+	if resp.Error == renderer.ErrShuttingDown.Error() {
+		return nil, renderer.ErrShuttingDown
+	}
+	if msg, ok := strings.CutPrefix(resp.Error, renderer.ErrBrowserStart.Error()+": "); ok {
+		// Keep browser launch failures distinguishable from render errors across the socket
+		return nil, fmt.Errorf("%w: %s", renderer.ErrBrowserStart, msg)
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+
+	result := &renderer.RenderResult{Data: resp.Data, Title: resp.Title, Desc: resp.Desc, Console: resp.Console, Warnings: resp.Warnings}
+	if resp.Timings != nil {
+		result.Timings = *resp.Timings
+	}
+	return result, nil
+}
+
+// Close is a no-op; the daemon owns the browser. It exists so Client can stand in for a local renderer.
+func (c *Client) Close() {}
@@ -0,0 +1,340 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coolamit/mermaid-cli/internal/config"
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+// fakeRenderer echoes the request back so round-trips can be verified without a browser.
+type fakeRenderer struct {
+	lastOpts renderer.RenderOpts
+}
+
+func (f *fakeRenderer) Render(ctx context.Context, definition string, outputFormat string, opts renderer.RenderOpts) (*renderer.RenderResult, error) {
+	f.lastOpts = opts
+	if definition == "fail" {
+		return nil, errors.New("mermaid rendering error: boom")
+	}
+	return &renderer.RenderResult{
+		Data:     []byte(outputFormat + ":" + definition),
+		Title:    "title",
+		Desc:     "desc",
+		Timings:  renderer.Timings{PageBuild: time.Millisecond, Capture: 2 * time.Millisecond},
+		Warnings: []string{"failed to load icon packs: logos (HTTP 404)"},
+	}, nil
+}
+
+// startServer starts a daemon on a short temp socket path (Unix socket paths are length-limited).
+func startServer(t *testing.T, r DiagramRenderer) string {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "mmd")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socketPath := filepath.Join(dir, "d.sock")
+
+	server := NewServer(r)
+	if err := server.Listen(socketPath); err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(context.Background()) }()
+	t.Cleanup(func() {
+		server.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve returned error: %v", err)
+		}
+	})
+	return socketPath
+}
+
+func TestClientServer_RoundTrip(t *testing.T) {
+	fake := &fakeRenderer{}
+	socketPath := startServer(t, fake)
+
+	client := NewClient(socketPath)
+	if !client.Available() {
+		t.Fatal("expected daemon to be available")
+	}
+
+	opts := renderer.RenderOpts{
+		MermaidConfig:   config.MermaidConfig{"theme": "dark"},
+		BackgroundColor: "transparent",
+		Width:           1024,
+		Scale:           2,
+	}
+	result, err := client.Render(context.Background(), "graph TD; A-->B;", "png", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(result.Data) != "png:graph TD; A-->B;" {
+		t.Errorf("unexpected data %q", result.Data)
+	}
+	if result.Title != "title" || result.Desc != "desc" {
+		t.Errorf("unexpected metadata: title=%q desc=%q", result.Title, result.Desc)
+	}
+	if result.Timings.PageBuild != time.Millisecond || result.Timings.Capture != 2*time.Millisecond {
+		t.Errorf("timings not forwarded: %+v", result.Timings)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0] != "failed to load icon packs: logos (HTTP 404)" {
+		t.Errorf("warnings not forwarded: %q", result.Warnings)
+	}
+	if fake.lastOpts.MermaidConfig["theme"] != "dark" || fake.lastOpts.Width != 1024 || fake.lastOpts.Scale != 2 {
+		t.Errorf("render options not forwarded correctly: %+v", fake.lastOpts)
+	}
+}
+
+func TestClientServer_RenderError(t *testing.T) {
+	socketPath := startServer(t, &fakeRenderer{})
+
+	_, err := NewClient(socketPath).Render(context.Background(), "fail", "svg", renderer.RenderOpts{})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected daemon error to be forwarded, got %v", err)
+	}
+}
+
+func TestClient_Unavailable(t *testing.T) {
+	client := NewClient(filepath.Join(t.TempDir(), "missing.sock"))
+	if client.Available() {
+		t.Error("expected daemon to be unavailable")
+	}
+}
+
+func TestServer_ListenRemovesStaleSocket(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mmd")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "d.sock")
+
+	// Leave a socket file behind with nothing listening on it, as a crashed daemon would
+	stale, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to create socket: %v", err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	if _, err := os.Stat(socketPath); err != nil {
+		t.Fatalf("expected stale socket to remain: %v", err)
+	}
+
+	server := NewServer(&fakeRenderer{})
+	if err := server.Listen(socketPath); err != nil {
+		t.Fatalf("expected stale socket to be replaced, got %v", err)
+	}
+	server.Close()
+}
+
+func TestServer_ListenKeepsRegularFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "d.sock")
+	if err := os.WriteFile(path, []byte("keep me"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewServer(&fakeRenderer{}).Listen(path); err == nil {
+		t.Fatal("expected an error for a path that isn't a socket")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "keep me" {
+		t.Errorf("regular file was modified: %q, %v", data, err)
+	}
+}
+
+func TestServer_ListenAlreadyRunning(t *testing.T) {
+	socketPath := startServer(t, &fakeRenderer{})
+
+	if err := NewServer(&fakeRenderer{}).Listen(socketPath); err == nil {
+		t.Fatal("expected error when a daemon is already listening")
+	}
+}
+
+// blockingRenderer holds each render until release is closed or the context is cancelled.
+type blockingRenderer struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingRenderer) Render(ctx context.Context, definition string, outputFormat string, opts renderer.RenderOpts) (*renderer.RenderResult, error) {
+	b.started <- struct{}{}
+	select {
+	case <-b.release:
+		return &renderer.RenderResult{Data: []byte(definition)}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// listenServer starts a daemon without registering cleanup, for tests that shut it down themselves.
+func listenServer(t *testing.T, r DiagramRenderer) (*Server, string, chan error) {
+	t.Helper()
+	dir, err := os.MkdirTemp("", "mmd")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socketPath := filepath.Join(dir, "d.sock")
+
+	server := NewServer(r)
+	if err := server.Listen(socketPath); err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(context.Background()) }()
+	return server, socketPath, served
+}
+
+func TestServer_ShutdownDrainsInFlightRenders(t *testing.T) {
+	br := &blockingRenderer{started: make(chan struct{}, 1), release: make(chan struct{})}
+	server, socketPath, served := listenServer(t, br)
+	client := NewClient(socketPath)
+
+	type outcome struct {
+		result *renderer.RenderResult
+		err    error
+	}
+	inFlight := make(chan outcome, 1)
+	go func() {
+		result, err := client.Render(context.Background(), "graph TD; A-->B", "svg", renderer.RenderOpts{})
+		inFlight <- outcome{result, err}
+	}()
+	<-br.started
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- server.Shutdown(ctx)
+	}()
+
+	// Once shutdown has begun, new requests are turned away
+	deadline := time.Now().Add(time.Second)
+	for client.Available() {
+		if time.Now().After(deadline) {
+			t.Fatal("daemon still accepting connections after Shutdown")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := client.Render(context.Background(), "graph TD; C-->D", "svg", renderer.RenderOpts{}); err == nil {
+		t.Error("expected a request after shutdown began to fail")
+	}
+
+	close(br.release)
+	got := <-inFlight
+	if got.err != nil {
+		t.Fatalf("in-flight render failed: %v", got.err)
+	}
+	if string(got.result.Data) != "graph TD; A-->B" {
+		t.Errorf("unexpected result %q", got.result.Data)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown() = %v, want nil", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve() = %v, want nil", err)
+	}
+}
+
+func TestServer_ShutdownTimeoutCancelsRenders(t *testing.T) {
+	br := &blockingRenderer{started: make(chan struct{}, 1), release: make(chan struct{})}
+	server, socketPath, served := listenServer(t, br)
+	client := NewClient(socketPath)
+
+	inFlight := make(chan error, 1)
+	go func() {
+		_, err := client.Render(context.Background(), "graph TD; A-->B", "svg", renderer.RenderOpts{})
+		inFlight <- err
+	}()
+	<-br.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := server.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want context.DeadlineExceeded", err)
+	}
+	if err := <-inFlight; err == nil {
+		t.Error("expected the cancelled render to fail")
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve() = %v, want nil", err)
+	}
+}
+
+func TestServer_RejectsRequestsWhileShuttingDown(t *testing.T) {
+	server := NewServer(&fakeRenderer{})
+	server.shuttingDown.Store(true)
+
+	clientConn, serverConn := net.Pipe()
+	go func() {
+		defer serverConn.Close()
+		server.handle(context.Background(), serverConn)
+	}()
+
+	if err := json.NewEncoder(clientConn).Encode(&Request{Definition: "graph TD; A-->B", OutputFormat: "svg"}); err != nil {
+		t.Fatal(err)
+	}
+	var resp Response
+	if err := json.NewDecoder(clientConn).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != renderer.ErrShuttingDown.Error() || resp.Data != nil {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestServer_ShutdownIgnoresIdleConnections(t *testing.T) {
+	server, socketPath, served := listenServer(t, &fakeRenderer{})
+
+	idle, err := net.Dial("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer idle.Close()
+	deadline := time.Now().Add(time.Second)
+	for {
+		server.mu.Lock()
+		n := len(server.conns)
+		server.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("server never accepted the idle connection")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() = %v, want nil", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Shutdown() took %v with an idle connection open", elapsed)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve() = %v, want nil", err)
+	}
+
+	var resp Response
+	if err := json.NewDecoder(idle).Decode(&resp); err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if resp.Error != renderer.ErrShuttingDown.Error() {
+		t.Errorf("idle connection got %+v, want ErrShuttingDown", resp)
+	}
+}
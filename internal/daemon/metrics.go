@@ -0,0 +1,103 @@
+package daemon
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// TabCounter reports browser tab usage. *renderer.Renderer satisfies it.
+type TabCounter interface {
+	TabStats() (open, active int)
+}
+
+// Metrics holds the Prometheus metrics exposed by the daemon.
+type Metrics struct {
+	registry *prometheus.Registry
+	renders  *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// NewMetrics creates the daemon metrics, reading tab gauges from tabs when scraped.
+func NewMetrics(tabs TabCounter) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		renders: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mmd_renders_total",
+			Help: "Render requests handled, by output format.",
+		}, []string{"format"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mmd_render_errors_total",
+			Help: "Render requests that failed, by output format.",
+		}, []string{"format"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "mmd_render_duration_seconds",
+			Help:    "Time taken to render a diagram, including waiting for a free tab.",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"format"}),
+	}
+
+	m.registry.MustRegister(m.renders, m.errors, m.duration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "mmd_browser_tabs_open",
+			Help: "Browser tabs currently open, idle or rendering.",
+		}, func() float64 {
+			open, _ := tabs.TabStats()
+			return float64(open)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "mmd_browser_tabs_active",
+			Help: "Browser tabs currently rendering a diagram.",
+		}, func() float64 {
+			_, active := tabs.TabStats()
+			return float64(active)
+		}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// metricFormats are the output formats the renderer supports. Any other format a client
+// sends is counted as "other", so requests can't add label values without bound.
+var metricFormats = map[string]bool{"svg": true, "png": true, "jpeg": true, "pdf": true}
+
+// formatLabel returns the format label to record a render of outputFormat under.
+func formatLabel(outputFormat string) string {
+	if metricFormats[outputFormat] {
+		return outputFormat
+	}
+	return "other"
+}
+
+// Instrument wraps r so every render is counted and timed.
+func (m *Metrics) Instrument(r DiagramRenderer) DiagramRenderer {
+	return &instrumentedRenderer{next: r, metrics: m}
+}
+
+// instrumentedRenderer records metrics around each render of the wrapped renderer.
+type instrumentedRenderer struct {
+	next    DiagramRenderer
+	metrics *Metrics
+}
+
+func (r *instrumentedRenderer) Render(ctx context.Context, definition string, outputFormat string, opts renderer.RenderOpts) (*renderer.RenderResult, error) {
+	start := time.Now()
+	result, err := r.next.Render(ctx, definition, outputFormat, opts)
+
+	format := formatLabel(outputFormat)
+	r.metrics.renders.WithLabelValues(format).Inc()
+	r.metrics.duration.WithLabelValues(format).Observe(time.Since(start).Seconds())
+	if err != nil {
+		r.metrics.errors.WithLabelValues(format).Inc()
+	}
+	return result, err
+}
@@ -0,0 +1,85 @@
+package daemon
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+// fakeTabs reports fixed tab counts.
+type fakeTabs struct{ open, active int }
+
+func (f fakeTabs) TabStats() (int, int) { return f.open, f.active }
+
+// scrape returns the metrics page served by m.
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestMetrics_CountsRenders(t *testing.T) {
+	const n = 5
+	metrics := NewMetrics(fakeTabs{open: 3, active: 1})
+	socketPath := startServer(t, metrics.Instrument(&fakeRenderer{}))
+	client := NewClient(socketPath)
+
+	for i := 0; i < n; i++ {
+		if _, err := client.Render(context.Background(), "graph TD; A-->B", "svg", renderer.RenderOpts{}); err != nil {
+			t.Fatalf("render %d: %v", i, err)
+		}
+	}
+	if _, err := client.Render(context.Background(), "fail", "png", renderer.RenderOpts{}); err == nil {
+		t.Fatal("expected render error")
+	}
+
+	page := scrape(t, metrics)
+	for _, want := range []string{
+		`mmd_renders_total{format="svg"} 5`,
+		`mmd_renders_total{format="png"} 1`,
+		`mmd_render_errors_total{format="png"} 1`,
+		`mmd_render_duration_seconds_count{format="svg"} 5`,
+		`mmd_browser_tabs_open 3`,
+		`mmd_browser_tabs_active 1`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("metrics missing %q:\n%s", want, page)
+		}
+	}
+	if strings.Contains(page, `mmd_render_errors_total{format="svg"}`) {
+		t.Errorf("expected no svg errors:\n%s", page)
+	}
+}
+
+func TestMetrics_UnknownFormatsShareALabel(t *testing.T) {
+	metrics := NewMetrics(fakeTabs{})
+	socketPath := startServer(t, metrics.Instrument(&fakeRenderer{}))
+	client := NewClient(socketPath)
+
+	for _, format := range []string{"bmp", "tiff", "pdf"} {
+		if _, err := client.Render(context.Background(), "graph TD; A-->B", format, renderer.RenderOpts{}); err != nil {
+			t.Fatalf("render %s: %v", format, err)
+		}
+	}
+
+	page := scrape(t, metrics)
+	for _, want := range []string{`mmd_renders_total{format="other"} 2`, `mmd_renders_total{format="pdf"} 1`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("metrics missing %q:\n%s", want, page)
+		}
+	}
+	for _, unwanted := range []string{`format="bmp"`, `format="tiff"`} {
+		if strings.Contains(page, unwanted) {
+			t.Errorf("metrics have a %s label:\n%s", unwanted, page)
+		}
+	}
+}
@@ -2,28 +2,107 @@ package icons
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
 // IconPack represents an icon pack with a name and loader URL.
 type IconPack struct {
-	Name string
-	URL  string
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// Fallbacks are tried in order if URL can't be fetched
+	Fallbacks []string `json:"fallbacks,omitempty"`
 }
 
-// ParseIconPacks parses --iconPacks flags into IconPack structs.
+// CDNs that --iconCdn can select to serve @iconify-json packages. Both serve any npm
+// package, scoped or not, at an optional version; cdnjs doesn't mirror npm, so it can't.
+const (
+	CDNUnpkg    = "unpkg"
+	CDNJsdelivr = "jsdelivr"
+)
+
+// DefaultCDN is the CDN used when --iconCdn isn't set.
+const DefaultCDN = CDNUnpkg
+
+// cdnURLFormats maps each CDN to the URL of a package's icons.json, in fallback order.
+var cdnURLFormats = []struct {
+	name   string
+	format string
+}{
+	{CDNUnpkg, "https://unpkg.com/%s/icons.json"},
+	{CDNJsdelivr, "https://cdn.jsdelivr.net/npm/%s/icons.json"},
+}
+
+// ValidateCDN checks an --iconCdn value. An empty value selects DefaultCDN.
+func ValidateCDN(cdn string) error {
+	if cdn == "" {
+		return nil
+	}
+	for _, c := range cdnURLFormats {
+		if c.name == cdn {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid --iconCdn %q, must be %s or %s", cdn, CDNUnpkg, CDNJsdelivr)
+}
+
+// ParseIconPacks parses --iconPacks flags into IconPack structs, loading each package from
+// cdn and falling back to the other CDNs in turn. An empty cdn selects DefaultCDN.
 // Format: @iconify-json/logos -> name=logos, url=https://unpkg.com/@iconify-json/logos/icons.json
-func ParseIconPacks(packs []string) []IconPack {
+// An optional version pins the package: @iconify-json/logos@1.2.3 -> name=logos,
+// url=https://unpkg.com/@iconify-json/logos@1.2.3/icons.json
+// The name is the iconify prefix mermaid looks icons up by. It is derived by packName
+// unless given explicitly: @acme/custom-icons=acme -> name=acme
+func ParseIconPacks(packs []string, cdn string) []IconPack {
+	if cdn == "" {
+		cdn = DefaultCDN
+	}
 	result := make([]IconPack, 0, len(packs))
 	for _, pack := range packs {
-		parts := strings.Split(pack, "/")
-		name := parts[len(parts)-1]
-		url := fmt.Sprintf("https://unpkg.com/%s/icons.json", pack)
-		result = append(result, IconPack{Name: name, URL: url})
+		name := ""
+		if idx := strings.LastIndex(pack, "="); idx >= 0 && iconPrefixRegex.MatchString(pack[idx+1:]) {
+			pack, name = pack[:idx], pack[idx+1:]
+		}
+		if name == "" {
+			name = packName(pack)
+		}
+		iconPack := IconPack{Name: name}
+		for _, c := range cdnURLFormats {
+			url := fmt.Sprintf(c.format, pack)
+			if c.name == cdn {
+				iconPack.URL = url
+			} else {
+				iconPack.Fallbacks = append(iconPack.Fallbacks, url)
+			}
+		}
+		result = append(result, iconPack)
 	}
 	return result
 }
 
+// iconifyJSONScope is the npm scope of the official iconify icon sets, whose package
+// names are their icon prefixes.
+const iconifyJSONScope = "@iconify-json/"
+
+// iconPrefixRegex matches an iconify icon set prefix, e.g. "logos" or "simple-icons". It
+// tells an =name override apart from a version range such as ">=1.0".
+var iconPrefixRegex = regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`)
+
+// packName derives an icon pack's name from its package, ignoring any version:
+// @iconify-json/logos@1.2.3 -> logos, and otherwise the last path segment, e.g.
+// @acme/custom-icons -> custom-icons.
+func packName(pack string) string {
+	// Drop the version; a leading @ is a scope, not a version
+	if idx := strings.LastIndex(pack, "@"); idx > 0 && !strings.Contains(pack[idx:], "/") {
+		pack = pack[:idx]
+	}
+	if name, ok := strings.CutPrefix(pack, iconifyJSONScope); ok {
+		return name
+	}
+	parts := strings.Split(pack, "/")
+	return parts[len(parts)-1]
+}
+
 // ParseIconPacksNamesAndUrls parses --iconPacksNamesAndUrls flags.
 // Format: name#url
 func ParseIconPacksNamesAndUrls(packs []string) []IconPack {
@@ -40,20 +119,47 @@ func ParseIconPacksNamesAndUrls(packs []string) []IconPack {
 	return result
 }
 
-// GenerateIconPackJS generates JavaScript code to register icon packs with mermaid.
+// GenerateIconPackJS generates JavaScript code to register icon packs with mermaid. Each
+// pack's URLs are fetched in order until one succeeds. A pack that fails to load from all
+// of them is logged to the console and recorded in window.__mmd_icon_errors as
+// {name, error}, so the renderer can report it instead of the icon silently not rendering.
 func GenerateIconPackJS(packs []IconPack) string {
 	if len(packs) == 0 {
 		return ""
 	}
 
 	var sb strings.Builder
+	sb.WriteString(`const mmdFetchIconPack = async (urls) => {
+  let lastError;
+  for (const url of urls) {
+    try {
+      const res = await fetch(url);
+      if (!res.ok) throw new Error("HTTP " + res.status + " from " + url);
+      return await res.json();
+    } catch (e) {
+      lastError = e;
+    }
+  }
+  throw lastError;
+};
+const mmdLoadIconPack = (name, urls) => mmdFetchIconPack(urls)
+  .catch((e) => {
+    const error = (e && e.message) || String(e);
+    (window.__mmd_icon_errors = window.__mmd_icon_errors || []).push({ name, error });
+    console.error("Failed to fetch icon: " + name + ": " + error);
+  });
+`)
 	sb.WriteString("mermaid.registerIconPacks([\n")
 	for _, pack := range packs {
+		urls := make([]string, 0, 1+len(pack.Fallbacks))
+		for _, url := range append([]string{pack.URL}, pack.Fallbacks...) {
+			urls = append(urls, fmt.Sprintf("%q", url))
+		}
 		sb.WriteString(fmt.Sprintf(`  {
     name: %q,
-    loader: () => fetch(%q).then((res) => res.json()).catch(() => console.error("Failed to fetch icon: %s"))
+    loader: () => mmdLoadIconPack(%q, [%s])
   },
-`, pack.Name, pack.URL, pack.Name))
+`, pack.Name, pack.Name, strings.Join(urls, ", ")))
 	}
 	sb.WriteString("]);\n")
 	return sb.String()
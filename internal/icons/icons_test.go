@@ -1,6 +1,8 @@
 package icons
 
 import (
+	"fmt"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -8,7 +10,7 @@ import (
 // --- ParseIconPacks ---
 
 func TestParseIconPacks(t *testing.T) {
-	packs := ParseIconPacks([]string{"@iconify-json/logos"})
+	packs := ParseIconPacks([]string{"@iconify-json/logos"}, "")
 	if len(packs) != 1 {
 		t.Fatalf("expected 1 pack, got %d", len(packs))
 	}
@@ -22,7 +24,7 @@ func TestParseIconPacks(t *testing.T) {
 }
 
 func TestParseIconPacks_Multiple(t *testing.T) {
-	packs := ParseIconPacks([]string{"@iconify-json/logos", "@iconify-json/mdi"})
+	packs := ParseIconPacks([]string{"@iconify-json/logos", "@iconify-json/mdi"}, "")
 	if len(packs) != 2 {
 		t.Fatalf("expected 2 packs, got %d", len(packs))
 	}
@@ -35,12 +37,125 @@ func TestParseIconPacks_Multiple(t *testing.T) {
 }
 
 func TestParseIconPacks_Empty(t *testing.T) {
-	packs := ParseIconPacks([]string{})
+	packs := ParseIconPacks([]string{}, "")
 	if len(packs) != 0 {
 		t.Errorf("expected 0 packs, got %d", len(packs))
 	}
 }
 
+func TestParseIconPacks_Version(t *testing.T) {
+	tests := []struct {
+		pack string
+		name string
+		url  string
+	}{
+		{"@iconify-json/logos", "logos", "https://unpkg.com/@iconify-json/logos/icons.json"},
+		{"@iconify-json/logos@1.2.3", "logos", "https://unpkg.com/@iconify-json/logos@1.2.3/icons.json"},
+		{"@iconify-json/mdi@^1.1.0", "mdi", "https://unpkg.com/@iconify-json/mdi@^1.1.0/icons.json"},
+		{"logos@1.2.3", "logos", "https://unpkg.com/logos@1.2.3/icons.json"},
+	}
+	for _, tt := range tests {
+		packs := ParseIconPacks([]string{tt.pack}, "")
+		if len(packs) != 1 {
+			t.Fatalf("%s: expected 1 pack, got %d", tt.pack, len(packs))
+		}
+		if packs[0].Name != tt.name {
+			t.Errorf("%s: expected name %q, got %q", tt.pack, tt.name, packs[0].Name)
+		}
+		if packs[0].URL != tt.url {
+			t.Errorf("%s: expected URL %q, got %q", tt.pack, tt.url, packs[0].URL)
+		}
+	}
+
+	packs := ParseIconPacks([]string{"@iconify-json/logos@1.2.3"}, CDNUnpkg)
+	for _, fallback := range packs[0].Fallbacks {
+		if !strings.Contains(fallback, "@iconify-json/logos@1.2.3/") {
+			t.Errorf("expected fallback %q to keep the pinned version", fallback)
+		}
+	}
+}
+
+func TestParseIconPacks_Names(t *testing.T) {
+	tests := []struct {
+		pack string
+		name string
+		url  string
+	}{
+		{"@iconify-json/logos", "logos", "https://unpkg.com/@iconify-json/logos/icons.json"},
+		{"@iconify-json/simple-icons@1.2.3", "simple-icons", "https://unpkg.com/@iconify-json/simple-icons@1.2.3/icons.json"},
+		{"@acme/custom-icons", "custom-icons", "https://unpkg.com/@acme/custom-icons/icons.json"},
+		{"custom-icons", "custom-icons", "https://unpkg.com/custom-icons/icons.json"},
+		{"some/deep/path", "path", "https://unpkg.com/some/deep/path/icons.json"},
+		{"@acme/custom-icons=acme", "acme", "https://unpkg.com/@acme/custom-icons/icons.json"},
+		{"@acme/custom-icons@2.0.0=acme", "acme", "https://unpkg.com/@acme/custom-icons@2.0.0/icons.json"},
+		{"custom-icons=my-icons", "my-icons", "https://unpkg.com/custom-icons/icons.json"},
+		{"@iconify-json/logos@>=1.2", "logos", "https://unpkg.com/@iconify-json/logos@>=1.2/icons.json"},
+	}
+	for _, tt := range tests {
+		packs := ParseIconPacks([]string{tt.pack}, "")
+		if len(packs) != 1 {
+			t.Fatalf("%s: expected 1 pack, got %d", tt.pack, len(packs))
+		}
+		if packs[0].Name != tt.name {
+			t.Errorf("%s: expected name %q, got %q", tt.pack, tt.name, packs[0].Name)
+		}
+		if packs[0].URL != tt.url {
+			t.Errorf("%s: expected URL %q, got %q", tt.pack, tt.url, packs[0].URL)
+		}
+	}
+}
+
+func TestParseIconPacks_CDN(t *testing.T) {
+	tests := []struct {
+		cdn  string
+		want string
+	}{
+		{"", "https://unpkg.com/@iconify-json/logos/icons.json"},
+		{CDNUnpkg, "https://unpkg.com/@iconify-json/logos/icons.json"},
+		{CDNJsdelivr, "https://cdn.jsdelivr.net/npm/@iconify-json/logos/icons.json"},
+	}
+	for _, tt := range tests {
+		packs := ParseIconPacks([]string{"@iconify-json/logos"}, tt.cdn)
+		if len(packs) != 1 {
+			t.Fatalf("cdn %q: expected 1 pack, got %d", tt.cdn, len(packs))
+		}
+		if packs[0].URL != tt.want {
+			t.Errorf("cdn %q: expected URL %q, got %q", tt.cdn, tt.want, packs[0].URL)
+		}
+		if len(packs[0].Fallbacks) != 1 {
+			t.Errorf("cdn %q: expected 1 fallback, got %q", tt.cdn, packs[0].Fallbacks)
+		}
+		for _, fallback := range packs[0].Fallbacks {
+			if fallback == tt.want {
+				t.Errorf("cdn %q: primary URL repeated in fallbacks %q", tt.cdn, packs[0].Fallbacks)
+			}
+		}
+	}
+}
+
+func TestValidateCDN(t *testing.T) {
+	for _, cdn := range []string{"", CDNUnpkg, CDNJsdelivr} {
+		if err := ValidateCDN(cdn); err != nil {
+			t.Errorf("ValidateCDN(%q): unexpected error: %v", cdn, err)
+		}
+	}
+	for _, cdn := range []string{"fastly", "cdnjs"} {
+		if err := ValidateCDN(cdn); err == nil {
+			t.Errorf("ValidateCDN(%q): expected an error for an unsupported CDN", cdn)
+		}
+	}
+}
+
+func TestCDNURLFormats_ScopedPackage(t *testing.T) {
+	// Every CDN must serve a scoped, versioned npm package at its npm path
+	shape := regexp.MustCompile(`^https://[a-z.]+/(npm/)?@iconify-json/logos@1\.2\.3/icons\.json$`)
+	for _, c := range cdnURLFormats {
+		if url := fmt.Sprintf(c.format, "@iconify-json/logos@1.2.3"); !shape.MatchString(url) {
+			t.Errorf("%s: unexpected URL %q for a scoped package", c.name, url)
+		}
+	}
+}
+
 // --- ParseIconPacksNamesAndUrls ---
 
 func TestParseIconPacksNamesAndUrls(t *testing.T) {
@@ -108,3 +223,33 @@ func TestGenerateIconPackJS_Multiple(t *testing.T) {
 		t.Error("expected output to contain second pack name")
 	}
 }
+
+func TestGenerateIconPackJS_RecordsFailures(t *testing.T) {
+	packs := []IconPack{{Name: "logos", URL: "https://example.com/logos.json"}}
+	js := GenerateIconPackJS(packs)
+
+	for _, want := range []string{
+		`mmdLoadIconPack("logos", ["https://example.com/logos.json"])`,
+		"res.ok",
+		"window.__mmd_icon_errors",
+		"Failed to fetch icon: ",
+	} {
+		if !strings.Contains(js, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, js)
+		}
+	}
+}
+
+func TestGenerateIconPackJS_Fallbacks(t *testing.T) {
+	packs := ParseIconPacks([]string{"@iconify-json/logos"}, CDNJsdelivr)
+	js := GenerateIconPackJS(packs)
+
+	want := `mmdLoadIconPack("logos", ["https://cdn.jsdelivr.net/npm/@iconify-json/logos/icons.json", ` +
+		`"https://unpkg.com/@iconify-json/logos/icons.json"])`
+	if !strings.Contains(js, want) {
+		t.Errorf("expected the chosen CDN first, then the fallbacks, got:\n%s", js)
+	}
+	if !strings.Contains(js, "for (const url of urls)") {
+		t.Error("expected the loader to try each URL in turn")
+	}
+}
@@ -8,7 +8,16 @@ import (
 
 // mermaidBlockRegex matches ```mermaid ... ``` and :::mermaid ... ::: code blocks.
 // Mirrors the official CLI regex: /^[^\S\n]*[`:]{3}(?:mermaid)([^\S\n]*\r?\n([\s\S]*?))[`:]{3}[^\S\n]*$/gm
-var mermaidBlockRegex = regexp.MustCompile(`(?m)^[^\S\n]*[\x60:]{3}(?:mermaid)([^\S\n]*\r?\n([\s\S]*?))[\x60:]{3}[^\S\n]*$`)
+// extended to capture an optional attribute list after the language, e.g. ```mermaid {caption="Flow"},
+// and to accept Pandoc-style attribute fences such as :::{.mermaid} or ::: { .mermaid .wide }.
+var mermaidBlockRegex = regexp.MustCompile(`(?m)^[^\S\n]*[\x60:]{3}` +
+	`(?:mermaid[^\S\n]*(?:\{([^}\n]*)\}[^\S\n]*)?` +
+	`|[^\S\n]*\{((?:[^}\n]*[^\S\n])?\.mermaid(?:[^\S\n][^}\n]*)?)\}[^\S\n]*)` +
+	`\r?\n([\s\S]*?)[\x60:]{3}[^\S\n]*$`)
+
+// attrRegex matches key=value pairs in a code block attribute list. Values may be
+// double-quoted, single-quoted or bare.
+var attrRegex = regexp.MustCompile(`([\w.-]+)[^\S\n]*=[^\S\n]*(?:"([^"]*)"|'([^']*)'|([^\s"']+))`)
 
 // DiagramBlock represents a mermaid diagram found in markdown.
 type DiagramBlock struct {
@@ -18,6 +27,26 @@ type DiagramBlock struct {
 	Definition string
 	// Index is the 1-based index of this diagram in the markdown
 	Index int
+	// Attrs holds the key=value attributes from the fence info string, e.g. caption
+	Attrs map[string]string
+}
+
+// themeLineRegex matches a top-level `theme:` key in frontmatter.
+var themeLineRegex = regexp.MustCompile(`(?m)^theme:[^\S\n]*(.*?)[^\S\n]*$`)
+
+// Theme returns the mermaid theme requested for this block alone: the `theme` fence
+// attribute, e.g. ```mermaid {theme=dark}, or else a top-level `theme:` key in the
+// diagram's frontmatter. It returns "" if the block doesn't set one.
+func (b DiagramBlock) Theme() string {
+	if theme := b.Attrs["theme"]; theme != "" {
+		return theme
+	}
+	if fm := frontmatterRegex.FindStringSubmatch(b.Definition); fm != nil {
+		if m := themeLineRegex.FindStringSubmatch(fm[1]); m != nil {
+			return strings.Trim(m[1], `"'`)
+		}
+	}
+	return ""
 }
 
 // ExtractDiagrams finds all mermaid code blocks in markdown content.
@@ -28,19 +57,51 @@ func ExtractDiagrams(content string) []DiagramBlock {
 	for i, match := range matches {
 		blocks = append(blocks, DiagramBlock{
 			FullMatch:  match[0],
-			Definition: strings.TrimSpace(match[2]),
+			Definition: normalizeLineEndings(strings.TrimSpace(match[3])),
 			Index:      i + 1,
+			Attrs:      parseAttrs(match[1] + match[2]),
 		})
 	}
 
 	return blocks
 }
 
+// normalizeLineEndings turns the CRLF line endings of a definition from a Windows-authored
+// document into LF, which mermaid handles reliably.
+func normalizeLineEndings(s string) string {
+	return strings.ReplaceAll(s, "\r\n", "\n")
+}
+
+// LooksLikeMarkdownWithDiagrams reports whether content is markdown containing at least
+// one mermaid code block, as opposed to a bare mermaid definition.
+func LooksLikeMarkdownWithDiagrams(content string) bool {
+	return mermaidBlockRegex.MatchString(content)
+}
+
+// parseAttrs parses the key=value pairs of a fence attribute list such as
+// `caption="User flow" width=300`. Returns nil if there are none.
+func parseAttrs(s string) map[string]string {
+	matches := attrRegex.FindAllStringSubmatch(s, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+
+	attrs := make(map[string]string, len(matches))
+	for _, m := range matches {
+		attrs[m[1]] = m[2] + m[3] + m[4]
+	}
+	return attrs
+}
+
 // ImageRef holds information about a rendered diagram image.
 type ImageRef struct {
 	URL   string
 	Alt   string
 	Title string
+	// Index is the 1-based index of the diagram in the markdown, when known
+	Index int
+	// Data is the image payload, when known
+	Data []byte
 }
 
 // MarkdownImage creates a markdown image reference: ![alt](url "title")
@@ -57,17 +118,17 @@ func MarkdownImage(ref ImageRef) string {
 	return fmt.Sprintf("![%s](%s)", alt, ref.URL)
 }
 
-// ReplaceDiagrams replaces mermaid code blocks in markdown with image references.
-func ReplaceDiagrams(content string, images []ImageRef) string {
-	idx := 0
-	return mermaidBlockRegex.ReplaceAllStringFunc(content, func(match string) string {
-		if idx >= len(images) {
-			return match
-		}
-		img := images[idx]
-		idx++
-		return MarkdownImage(img)
-	})
+// replaceBlock returns the image reference that replaces the matched block. The image
+// is indented like the opening fence, so a block nested in a list item stays in it. The
+// match ends just before the newline after the closing fence, so for a CRLF document it
+// ends with "\r", which is kept to leave the document's line endings as they were.
+func replaceBlock(match string, ref ImageRef) string {
+	indent := match[:len(match)-len(strings.TrimLeft(match, " \t"))]
+	image := indent + MarkdownImage(ref)
+	if strings.HasSuffix(match, "\r") {
+		image += "\r"
+	}
+	return image
 }
 
 func escapeMarkdownAlt(s string) string {
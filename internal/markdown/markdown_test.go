@@ -32,6 +32,39 @@ func TestExtractDiagrams_Colon(t *testing.T) {
 	}
 }
 
+func TestExtractDiagrams_PandocDiv(t *testing.T) {
+	for _, md := range []string{
+		":::{.mermaid}\ngraph TD;\n  A-->B;\n:::",
+		":::{ .mermaid .foo }\ngraph TD;\n  A-->B;\n:::",
+		"::: {.mermaid caption=\"Flow\"}\ngraph TD;\n  A-->B;\n:::",
+	} {
+		blocks := ExtractDiagrams(md)
+		if len(blocks) != 1 {
+			t.Fatalf("%q: expected 1 block, got %d", md, len(blocks))
+		}
+		if blocks[0].Definition != "graph TD;\n  A-->B;" {
+			t.Errorf("%q: unexpected definition %q", md, blocks[0].Definition)
+		}
+	}
+
+	blocks := ExtractDiagrams("::: {.mermaid caption=\"Flow\"}\ngraph TD;\n  A-->B;\n:::")
+	if got := blocks[0].Attrs["caption"]; got != "Flow" {
+		t.Errorf("expected caption attribute, got %q", got)
+	}
+}
+
+func TestExtractDiagrams_PandocDivOtherClass(t *testing.T) {
+	for _, md := range []string{
+		":::{.warning}\nDon't do this.\n:::",
+		":::{.mermaid-like}\ngraph TD;\n:::",
+		":::{.notmermaid}\ngraph TD;\n:::",
+	} {
+		if blocks := ExtractDiagrams(md); len(blocks) != 0 {
+			t.Errorf("%q: expected no blocks, got %d", md, len(blocks))
+		}
+	}
+}
+
 func TestExtractDiagrams_Multiple(t *testing.T) {
 	md := "```mermaid\ngraph TD;\n  A-->B;\n```\n\nSome text\n\n```mermaid\nsequenceDiagram\n  Alice->>Bob: Hi\n```"
 	blocks := ExtractDiagrams(md)
@@ -79,6 +112,93 @@ Final paragraph.
 	}
 }
 
+func TestExtractDiagrams_CRLF(t *testing.T) {
+	md := "# Title\r\n\r\n```mermaid\r\ngraph TD;\r\n  A-->B;\r\n```\r\n\r\n:::mermaid\r\nsequenceDiagram\r\n  Alice->>Bob: Hi\r\n:::\r\n"
+	blocks := ExtractDiagrams(md)
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 blocks, got %d", len(blocks))
+	}
+	if blocks[0].Definition != "graph TD;\n  A-->B;" {
+		t.Errorf("unexpected definition %q", blocks[0].Definition)
+	}
+	if blocks[1].Definition != "sequenceDiagram\n  Alice->>Bob: Hi" {
+		t.Errorf("unexpected definition %q", blocks[1].Definition)
+	}
+}
+
+func TestExtractDiagrams_Attrs(t *testing.T) {
+	md := "```mermaid {caption=\"Flow\" width=300 alt='Sign up'}\ngraph TD;\n  A-->B;\n```"
+	blocks := ExtractDiagrams(md)
+	if len(blocks) != 1 {
+		t.Fatalf("expected 1 block, got %d", len(blocks))
+	}
+	want := map[string]string{"caption": "Flow", "width": "300", "alt": "Sign up"}
+	for k, v := range want {
+		if got := blocks[0].Attrs[k]; got != v {
+			t.Errorf("Attrs[%q] = %q, want %q", k, got, v)
+		}
+	}
+	if blocks[0].Definition != "graph TD;\n  A-->B;" {
+		t.Errorf("unexpected definition %q", blocks[0].Definition)
+	}
+}
+
+func TestExtractDiagrams_NoAttrs(t *testing.T) {
+	blocks := ExtractDiagrams("```mermaid\ngraph TD;\n  A-->B;\n```")
+	if len(blocks) != 1 {
+		t.Fatalf("expected 1 block, got %d", len(blocks))
+	}
+	if blocks[0].Attrs != nil {
+		t.Errorf("expected no attrs, got %v", blocks[0].Attrs)
+	}
+}
+
+func TestDiagramBlock_Theme(t *testing.T) {
+	content := "```mermaid {theme=dark}\nsequenceDiagram\n  A->>B: hi\n```\n\n" +
+		"```mermaid\n---\ntitle: Flow\ntheme: \"forest\"\n---\ngraph TD; A-->B\n```\n\n" +
+		"```mermaid {theme=neutral}\n---\ntheme: forest\n---\ngraph TD; A-->B\n```\n\n" +
+		"```mermaid\n---\nconfig:\n  theme: base\n---\ngraph TD; A-->B\n```\n\n" +
+		"```mermaid\ngraph TD; A-->B\n```\n"
+	blocks := ExtractDiagrams(content)
+	// The fence attribute wins over frontmatter; a nested config.theme is left to mermaid
+	want := []string{"dark", "forest", "neutral", "", ""}
+	if len(blocks) != len(want) {
+		t.Fatalf("expected %d blocks, got %d", len(want), len(blocks))
+	}
+	for i, b := range blocks {
+		if got := b.Theme(); got != want[i] {
+			t.Errorf("block %d: Theme() = %q, want %q", i+1, got, want[i])
+		}
+	}
+}
+
+func TestProcess_WithAttrs(t *testing.T) {
+	md := "Before\n```mermaid {caption=\"Flow\"}\ngraph TD;\n  A-->B;\n```\nAfter"
+	result := processWith(t, md, RenderResult{URL: "./out-1.svg"})
+	if strings.Contains(result, "```") || strings.Contains(result, "caption") {
+		t.Errorf("expected the whole fenced block to be replaced, got %q", result)
+	}
+	if !strings.Contains(result, `![Flow](./out-1.svg "Flow")`) {
+		t.Errorf("expected image reference with caption title, got %q", result)
+	}
+}
+
+// --- LooksLikeMarkdownWithDiagrams ---
+
+func TestLooksLikeMarkdownWithDiagrams(t *testing.T) {
+	tests := map[string]bool{
+		"# Doc\n\n```mermaid\ngraph TD;\n  A-->B;\n```\n": true,
+		":::mermaid\ngraph TD;\n  A-->B;\n:::":            true,
+		"graph TD;\n  A-->B;":                             false,
+		"# Doc\n\n```go\nfunc main() {}\n```\n":           false,
+	}
+	for content, want := range tests {
+		if got := LooksLikeMarkdownWithDiagrams(content); got != want {
+			t.Errorf("LooksLikeMarkdownWithDiagrams(%q) = %v, want %v", content, got, want)
+		}
+	}
+}
+
 // --- MarkdownImage ---
 
 func TestMarkdownImage_Basic(t *testing.T) {
@@ -119,12 +239,24 @@ func TestMarkdownImage_SpecialChars(t *testing.T) {
 	}
 }
 
-// --- ReplaceDiagrams ---
+// --- replaceBlock ---
+
+// processWith runs Process on md, rendering the nth block to results[n-1], and returns
+// the rewritten markdown.
+func processWith(t *testing.T, md string, results ...RenderResult) string {
+	t.Helper()
+	processed, err := Process(md, func(block DiagramBlock) (RenderResult, error) {
+		return results[block.Index-1], nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return processed.Content
+}
 
-func TestReplaceDiagrams(t *testing.T) {
+func TestReplaceBlock(t *testing.T) {
 	md := "Before\n\n```mermaid\ngraph TD;\n  A-->B;\n```\n\nAfter"
-	images := []ImageRef{{URL: "out.png", Alt: "Diagram 1"}}
-	result := ReplaceDiagrams(md, images)
+	result := processWith(t, md, RenderResult{URL: "out.png", Desc: "Diagram 1"})
 
 	if strings.Contains(result, "```mermaid") {
 		t.Error("expected mermaid block to be replaced")
@@ -137,33 +269,22 @@ func TestReplaceDiagrams(t *testing.T) {
 	}
 }
 
-func TestReplaceDiagrams_MoreImagesThanBlocks(t *testing.T) {
-	md := "```mermaid\ngraph TD;\n  A-->B;\n```"
-	images := []ImageRef{
-		{URL: "first.png", Alt: "First"},
-		{URL: "extra.png", Alt: "Extra"},
-	}
-	result := ReplaceDiagrams(md, images)
+func TestReplaceBlock_CRLF(t *testing.T) {
+	md := "Before\r\n\r\n```mermaid\r\ngraph TD;\r\n  A-->B;\r\n```\r\n\r\nAfter\r\n"
+	result := processWith(t, md, RenderResult{URL: "out.png", Desc: "Diagram 1"})
 
-	if !strings.Contains(result, "![First](first.png)") {
-		t.Errorf("expected first image, got %q", result)
-	}
-	// Extra image is simply ignored
-	if strings.Contains(result, "extra.png") {
-		t.Error("extra image should not appear in output")
+	want := "Before\r\n\r\n![Diagram 1](out.png)\r\n\r\nAfter\r\n"
+	if result != want {
+		t.Errorf("got %q, want %q", result, want)
 	}
 }
 
-func TestReplaceDiagrams_FewerImagesThanBlocks(t *testing.T) {
-	md := "```mermaid\ngraph TD;\n  A-->B;\n```\n\n```mermaid\nsequenceDiagram\n  Alice->>Bob: Hi\n```"
-	images := []ImageRef{{URL: "first.png", Alt: "First"}}
-	result := ReplaceDiagrams(md, images)
+func TestReplaceBlock_InList(t *testing.T) {
+	md := "1. Install\n2. Deploy:\n\n   ```mermaid\n   graph TD;\n     A-->B;\n   ```\n\n3. Verify\n"
+	result := processWith(t, md, RenderResult{URL: "out.png", Desc: "Deploy"})
 
-	if !strings.Contains(result, "![First](first.png)") {
-		t.Errorf("expected first block replaced, got %q", result)
-	}
-	// Second block should remain as-is
-	if !strings.Contains(result, "```mermaid") {
-		t.Error("expected unmatched mermaid block to be left as-is")
+	want := "1. Install\n2. Deploy:\n\n   ![Deploy](out.png)\n\n3. Verify\n"
+	if result != want {
+		t.Errorf("got %q, want %q", result, want)
 	}
 }
@@ -0,0 +1,107 @@
+package markdown
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RenderResult is one rendered diagram, as returned by the render function given to Process.
+type RenderResult struct {
+	// Data is the image payload
+	Data []byte
+	// URL is where the rewritten markdown references the image from
+	URL string
+	// Title and Desc are the diagram's accessible title and description, if any
+	Title string
+	Desc  string
+}
+
+// DiagramError is a diagram that failed to render.
+type DiagramError struct {
+	// Index is the 1-based index of the diagram in the markdown
+	Index int
+	Err   error
+}
+
+func (e *DiagramError) Error() string {
+	return fmt.Sprintf("failed to render diagram %d: %v", e.Index, e.Err)
+}
+
+func (e *DiagramError) Unwrap() error {
+	return e.Err
+}
+
+// stopError is a render error that ends Process; see Stop.
+type stopError struct {
+	err error
+}
+
+func (e *stopError) Error() string { return e.err.Error() }
+func (e *stopError) Unwrap() error { return e.err }
+
+// Stop wraps an error returned by the render function given to Process, so that the block
+// is recorded as failed and no further blocks are rendered.
+func Stop(err error) error {
+	return &stopError{err: err}
+}
+
+// ProcessResult is the outcome of Process.
+type ProcessResult struct {
+	// Content is the markdown with every rendered block replaced by an image reference.
+	// Blocks that failed to render are left as they were.
+	Content string
+	// Images holds one reference per rendered block, in document order
+	Images []ImageRef
+	// Errors holds one entry per block that failed to render, in document order
+	Errors []*DiagramError
+}
+
+// Process renders every mermaid block in content with render and rewrites the markdown to
+// reference the images. render is given the whole block so it can honor its attributes,
+// such as a per-block theme. A caption attribute takes precedence over the diagram's own
+// title, and serves as the alt text when the diagram has no description.
+//
+// Rendering continues past failures unless render wraps its error with Stop. If any block
+// failed, the returned error is the first failure; all of them are in ProcessResult.Errors.
+func Process(content string, render func(block DiagramBlock) (RenderResult, error)) (ProcessResult, error) {
+	var result ProcessResult
+	rendered := make(map[int]ImageRef)
+
+	for _, block := range ExtractDiagrams(content) {
+		r, err := render(block)
+		if err != nil {
+			var stop *stopError
+			if errors.As(err, &stop) {
+				result.Errors = append(result.Errors, &DiagramError{Index: block.Index, Err: stop.err})
+				break
+			}
+			result.Errors = append(result.Errors, &DiagramError{Index: block.Index, Err: err})
+			continue
+		}
+
+		caption := block.Attrs["caption"]
+		ref := ImageRef{URL: r.URL, Alt: r.Desc, Title: r.Title, Index: block.Index, Data: r.Data}
+		if caption != "" {
+			ref.Title = caption
+		}
+		if ref.Alt == "" {
+			ref.Alt = caption
+		}
+		result.Images = append(result.Images, ref)
+		rendered[block.Index] = ref
+	}
+
+	idx := 0
+	result.Content = mermaidBlockRegex.ReplaceAllStringFunc(content, func(match string) string {
+		idx++
+		if ref, ok := rendered[idx]; ok {
+			return replaceBlock(match, ref)
+		}
+		return match
+	})
+
+	if len(result.Errors) > 0 {
+		return result, result.Errors[0]
+	}
+	return result, nil
+}
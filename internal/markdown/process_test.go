@@ -0,0 +1,153 @@
+package markdown
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestProcess(t *testing.T) {
+	md := "# Doc\n\n```mermaid\ngraph TD; A-->B\n```\n\nText\n\n```mermaid {caption=\"Flow\"}\ngraph TD; C-->D\n```\n"
+
+	var got []string
+	result, err := Process(md, func(block DiagramBlock) (RenderResult, error) {
+		got = append(got, block.Definition)
+		return RenderResult{
+			Data:  []byte(fmt.Sprintf("<svg>%d</svg>", block.Index)),
+			URL:   fmt.Sprintf("./doc-%d.svg", block.Index),
+			Title: "Title",
+			Desc:  "Desc",
+		}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := []string{"graph TD; A-->B", "graph TD; C-->D"}; strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("rendered %q, want %q", got, want)
+	}
+
+	wantContent := "# Doc\n\n![Desc](./doc-1.svg \"Title\")\n\nText\n\n![Desc](./doc-2.svg \"Flow\")\n"
+	if result.Content != wantContent {
+		t.Errorf("Content = %q, want %q", result.Content, wantContent)
+	}
+
+	if len(result.Images) != 2 {
+		t.Fatalf("expected 2 images, got %d", len(result.Images))
+	}
+	for i, img := range result.Images {
+		if img.Index != i+1 {
+			t.Errorf("image %d: Index = %d", i, img.Index)
+		}
+		if want := fmt.Sprintf("<svg>%d</svg>", i+1); string(img.Data) != want {
+			t.Errorf("image %d: Data = %q, want %q", i, img.Data, want)
+		}
+	}
+	if result.Images[1].Title != "Flow" {
+		t.Errorf("expected the caption to override the title, got %q", result.Images[1].Title)
+	}
+	if len(result.Errors) != 0 {
+		t.Errorf("unexpected errors: %v", result.Errors)
+	}
+}
+
+func TestProcess_CRLF(t *testing.T) {
+	md := "# Doc\r\n\r\n```mermaid\r\ngraph TD;\r\n  A-->B\r\n```\r\nText\r\n"
+
+	result, err := Process(md, func(block DiagramBlock) (RenderResult, error) {
+		if block.Definition != "graph TD;\n  A-->B" {
+			t.Errorf("unexpected definition %q", block.Definition)
+		}
+		return RenderResult{URL: "./doc-1.svg"}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "# Doc\r\n\r\n![diagram](./doc-1.svg)\r\nText\r\n"; result.Content != want {
+		t.Errorf("Content = %q, want %q", result.Content, want)
+	}
+}
+
+func TestProcess_CaptionAsAlt(t *testing.T) {
+	md := "```mermaid {caption=\"Flow\"}\ngraph TD; A-->B\n```\n"
+	result, err := Process(md, func(block DiagramBlock) (RenderResult, error) {
+		return RenderResult{URL: "./doc-1.svg"}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "![Flow](./doc-1.svg \"Flow\")\n"; result.Content != want {
+		t.Errorf("Content = %q, want %q", result.Content, want)
+	}
+}
+
+func TestProcess_Errors(t *testing.T) {
+	md := "```mermaid\nbad\n```\n\n```mermaid\ngraph TD; A-->B\n```\n\n```mermaid\nworse\n```\n"
+	renderErr := errors.New("parse error")
+
+	result, err := Process(md, func(block DiagramBlock) (RenderResult, error) {
+		if block.Definition != "graph TD; A-->B" {
+			return RenderResult{}, renderErr
+		}
+		return RenderResult{URL: "./doc-2.svg"}, nil
+	})
+
+	if !errors.Is(err, renderErr) {
+		t.Fatalf("expected the render error, got %v", err)
+	}
+	if err.Error() != "failed to render diagram 1: parse error" {
+		t.Errorf("error = %q", err)
+	}
+
+	if len(result.Errors) != 2 || result.Errors[0].Index != 1 || result.Errors[1].Index != 3 {
+		t.Fatalf("expected errors for diagrams 1 and 3, got %v", result.Errors)
+	}
+	if len(result.Images) != 1 || result.Images[0].Index != 2 {
+		t.Fatalf("expected one image for diagram 2, got %+v", result.Images)
+	}
+
+	// Failed blocks are left in place; only the rendered one is replaced
+	want := "```mermaid\nbad\n```\n\n![diagram](./doc-2.svg)\n\n```mermaid\nworse\n```\n"
+	if result.Content != want {
+		t.Errorf("Content = %q, want %q", result.Content, want)
+	}
+}
+
+func TestProcess_Stop(t *testing.T) {
+	md := "```mermaid\ngraph TD; A-->B\n```\n\n```mermaid\nbad\n```\n\n```mermaid\ngraph TD; C-->D\n```\n"
+	renderErr := errors.New("parse error")
+
+	var rendered []int
+	result, err := Process(md, func(block DiagramBlock) (RenderResult, error) {
+		rendered = append(rendered, block.Index)
+		if block.Definition == "bad" {
+			return RenderResult{}, Stop(renderErr)
+		}
+		return RenderResult{URL: fmt.Sprintf("./doc-%d.svg", block.Index)}, nil
+	})
+
+	if !errors.Is(err, renderErr) || err.Error() != "failed to render diagram 2: parse error" {
+		t.Fatalf("expected the render error for diagram 2, got %v", err)
+	}
+	if len(rendered) != 2 {
+		t.Errorf("expected rendering to stop at the failed block, rendered %v", rendered)
+	}
+	if len(result.Errors) != 1 || len(result.Images) != 1 {
+		t.Errorf("expected one image and one error, got %+v", result)
+	}
+}
+
+func TestProcess_NoDiagrams(t *testing.T) {
+	md := "# Just text\n"
+	result, err := Process(md, func(block DiagramBlock) (RenderResult, error) {
+		t.Fatal("render should not be called")
+		return RenderResult{}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Content != md || len(result.Images) != 0 {
+		t.Errorf("expected content unchanged and no images, got %+v", result)
+	}
+}
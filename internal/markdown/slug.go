@@ -0,0 +1,71 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+// asciiFold maps common accented Latin letters to ASCII equivalents.
+var asciiFold = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ł': "l", 'ľ': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+	'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ţ': "t", 'þ': "th",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+}
+
+// Slugify converts a title into a filename-safe slug: lowercase ASCII letters and digits
+// separated by single hyphens, e.g. "User Flow: Sign-up (v2)" -> "user-flow-sign-up-v2".
+// Accented Latin letters are folded to ASCII and all other characters become separators.
+func Slugify(title string) string {
+	var sb strings.Builder
+	pendingHyphen := false
+
+	write := func(s string) {
+		if pendingHyphen && sb.Len() > 0 {
+			sb.WriteByte('-')
+		}
+		pendingHyphen = false
+		sb.WriteString(s)
+	}
+
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			write(string(r))
+		case asciiFold[r] != "":
+			write(asciiFold[r])
+		default:
+			pendingHyphen = true
+		}
+	}
+
+	return sb.String()
+}
+
+// frontmatterRegex matches a leading YAML frontmatter block in a mermaid definition.
+var frontmatterRegex = regexp.MustCompile(`^\s*---[^\S\n]*\r?\n([\s\S]*?)\r?\n---[^\S\n]*(?:\r?\n|$)`)
+
+// titleLineRegex matches a top-level `title:` key in frontmatter.
+var titleLineRegex = regexp.MustCompile(`(?m)^title:[^\S\n]*(.*?)[^\S\n]*$`)
+
+// accTitleRegex matches an `accTitle: ...` statement in a mermaid definition.
+var accTitleRegex = regexp.MustCompile(`(?m)^\s*accTitle\s*:\s*(.*?)\s*$`)
+
+// DiagramTitle returns the frontmatter `title` or `accTitle` of a mermaid definition,
+// or an empty string if it has neither.
+func DiagramTitle(definition string) string {
+	if fm := frontmatterRegex.FindStringSubmatch(definition); fm != nil {
+		if m := titleLineRegex.FindStringSubmatch(fm[1]); m != nil {
+			return strings.Trim(m[1], `"'`)
+		}
+	}
+	if m := accTitleRegex.FindStringSubmatch(definition); m != nil {
+		return m[1]
+	}
+	return ""
+}
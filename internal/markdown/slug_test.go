@@ -0,0 +1,57 @@
+package markdown
+
+import "testing"
+
+// --- Slugify ---
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"User Flow":                 "user-flow",
+		"User Flow: Sign-up (v2)":   "user-flow-sign-up-v2",
+		"  leading and trailing  ":  "leading-and-trailing",
+		"many   ---  separators":    "many-separators",
+		"Crème Brûlée":              "creme-brulee",
+		"Straße":                    "strasse",
+		"ÆSIR Øresund":              "aesir-oresund",
+		"日本語 title":                 "title",
+		"already-a-slug":            "already-a-slug",
+		"CamelCase123":              "camelcase123",
+		"":                          "",
+		"!!!":                       "",
+		"path/../traversal\\attack": "path-traversal-attack",
+	}
+	for input, want := range tests {
+		if got := Slugify(input); got != want {
+			t.Errorf("Slugify(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+// --- DiagramTitle ---
+
+func TestDiagramTitle_Frontmatter(t *testing.T) {
+	def := "---\ntitle: User Flow\nconfig:\n  theme: dark\n---\nflowchart TD\n  A-->B"
+	if got := DiagramTitle(def); got != "User Flow" {
+		t.Errorf("expected %q, got %q", "User Flow", got)
+	}
+}
+
+func TestDiagramTitle_FrontmatterQuoted(t *testing.T) {
+	def := "---\ntitle: \"Quoted: Title\"\n---\nflowchart TD\n  A-->B"
+	if got := DiagramTitle(def); got != "Quoted: Title" {
+		t.Errorf("expected %q, got %q", "Quoted: Title", got)
+	}
+}
+
+func TestDiagramTitle_AccTitle(t *testing.T) {
+	def := "flowchart TD\n  accTitle: Checkout Process\n  A-->B"
+	if got := DiagramTitle(def); got != "Checkout Process" {
+		t.Errorf("expected %q, got %q", "Checkout Process", got)
+	}
+}
+
+func TestDiagramTitle_None(t *testing.T) {
+	if got := DiagramTitle("flowchart TD\n  A-->B"); got != "" {
+		t.Errorf("expected empty title, got %q", got)
+	}
+}
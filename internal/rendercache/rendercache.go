@@ -0,0 +1,213 @@
+// Package rendercache stores rendered diagrams on disk, keyed by a hash of everything
+// that affects the output, so unchanged diagrams can be reused without a browser.
+package rendercache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+	"github.com/coolamit/mermaid-cli/web"
+)
+
+// keyVersion is bumped when the key or entry layout changes, invalidating existing entries.
+const keyVersion = 2
+
+// Version identifies the scripts and page template a render depends on: the mermaid
+// version plus a hash of the bundles and template, so swapping a bundle or upgrading
+// mmd-cli invalidates the cache even if the mermaid version string is unchanged.
+func Version(scripts web.Loader) string {
+	if scripts == nil {
+		scripts = web.Embedded()
+	}
+	h := sha256.New()
+	for _, part := range [][]byte{scripts.MermaidJS(), scripts.MermaidZenUMLJS(), []byte(web.TemplateHTML)} {
+		fmt.Fprintf(h, "%d:", len(part))
+		h.Write(part)
+	}
+	return web.MermaidVersion(scripts.MermaidJS()) + "+" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Key returns the cache key for rendering definition to format with opts. Options that
+// only affect how the render runs, not its output (timeouts, settle delay), are ignored.
+func Key(definition, format string, opts renderer.RenderOpts, version string) (string, error) {
+	opts.Timeout = 0
+	opts.SettleDelay = 0
+	opts.Scripts = nil
+
+	// encoding/json sorts map keys, so equal configs always serialize identically
+	optsJSON, err := json.Marshal(opts)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize render options: %w", err)
+	}
+
+	h := sha256.New()
+	for _, part := range []string{fmt.Sprint(keyVersion), version, format, string(optsJSON), definition} {
+		fmt.Fprintf(h, "%d:%s", len(part), part)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// entryMeta is stored next to the cached output. It keeps the render's warnings and
+// console messages, so a cache hit reports the same diagnostics as the render did.
+type entryMeta struct {
+	Format   string   `json:"format"`
+	Title    string   `json:"title,omitempty"`
+	Desc     string   `json:"desc,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	Console  []string `json:"console,omitempty"`
+}
+
+// Cache is a directory of rendered diagrams.
+type Cache struct {
+	dir     string
+	version string
+	hits    atomic.Int64
+	misses  atomic.Int64
+}
+
+// New opens the cache in dir, creating it if needed. scripts are the bundles renders
+// use; nil means the embedded ones.
+func New(dir string, scripts web.Loader) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Cache{dir: dir, version: Version(scripts)}, nil
+}
+
+// paths returns the data and metadata files of an entry, sharded by the key's first byte.
+func (c *Cache) paths(key string) (data, meta string) {
+	base := filepath.Join(c.dir, key[:2], key)
+	return base + ".out", base + ".json"
+}
+
+// Get returns the cached result for key. A missing entry is a miss, not an error.
+func (c *Cache) Get(key string) (*renderer.RenderResult, bool, error) {
+	dataPath, metaPath := c.paths(key)
+
+	metaJSON, err := os.ReadFile(metaPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	var meta entryMeta
+	if err := json.Unmarshal(metaJSON, &meta); err != nil {
+		// A corrupt entry is treated as missing and overwritten by the next Put
+		return nil, false, nil
+	}
+
+	data, err := os.ReadFile(dataPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	return &renderer.RenderResult{Data: data, Title: meta.Title, Desc: meta.Desc, Warnings: meta.Warnings, Console: meta.Console}, true, nil
+}
+
+// Put stores a result under key. The metadata is written last, so a partially
+// written entry is never read back.
+func (c *Cache) Put(key, format string, result *renderer.RenderResult) error {
+	dataPath, metaPath := c.paths(key)
+	if err := os.MkdirAll(filepath.Dir(dataPath), 0755); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+
+	metaJSON, err := json.Marshal(entryMeta{
+		Format:   format,
+		Title:    result.Title,
+		Desc:     result.Desc,
+		Warnings: result.Warnings,
+		Console:  result.Console,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := writeFileAtomic(dataPath, result.Data); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := writeFileAtomic(metaPath, metaJSON); err != nil {
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	return nil
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (c *Cache) Stats() (hits, misses int64) {
+	return c.hits.Load(), c.misses.Load()
+}
+
+// writeFileAtomic writes data to a temporary file and renames it into place.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// DiagramRenderer renders diagrams and releases its browser on Close.
+type DiagramRenderer interface {
+	Render(ctx context.Context, definition string, outputFormat string, opts renderer.RenderOpts) (*renderer.RenderResult, error)
+	Close()
+}
+
+// Wrap returns a renderer that serves cached results and stores new ones. Since the
+// browser starts lazily, a run where every diagram is cached never launches Chrome.
+func (c *Cache) Wrap(r DiagramRenderer) DiagramRenderer {
+	return &cachedRenderer{cache: c, next: r}
+}
+
+// cachedRenderer consults the cache before delegating to the wrapped renderer.
+type cachedRenderer struct {
+	cache *Cache
+	next  DiagramRenderer
+}
+
+func (r *cachedRenderer) Render(ctx context.Context, definition string, outputFormat string, opts renderer.RenderOpts) (*renderer.RenderResult, error) {
+	key, err := Key(definition, outputFormat, opts, r.cache.version)
+	if err != nil {
+		return nil, err
+	}
+
+	if result, ok, err := r.cache.Get(key); err != nil {
+		return nil, err
+	} else if ok {
+		r.cache.hits.Add(1)
+		return result, nil
+	}
+	r.cache.misses.Add(1)
+
+	result, err := r.next.Render(ctx, definition, outputFormat, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.cache.Put(key, outputFormat, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r *cachedRenderer) Close() {
+	r.next.Close()
+}
@@ -0,0 +1,211 @@
+package rendercache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coolamit/mermaid-cli/internal/config"
+	"github.com/coolamit/mermaid-cli/internal/renderer"
+)
+
+// countingRenderer counts renders and fails definitions equal to "broken".
+type countingRenderer struct {
+	calls  int
+	closed bool
+}
+
+func (c *countingRenderer) Render(ctx context.Context, definition string, outputFormat string, opts renderer.RenderOpts) (*renderer.RenderResult, error) {
+	c.calls++
+	if definition == "broken" {
+		return nil, errors.New("mermaid rendering error: Parse error")
+	}
+	return &renderer.RenderResult{
+		Data:     []byte(outputFormat + ":" + definition),
+		Title:    "title",
+		Desc:     "desc",
+		Warnings: []string{"failed to load icon packs: logos (HTTP 404)"},
+		Console:  []string{"[warn] deprecated option"},
+	}, nil
+}
+
+func (c *countingRenderer) Close() { c.closed = true }
+
+func TestKey_Stable(t *testing.T) {
+	opts := func() renderer.RenderOpts {
+		return renderer.RenderOpts{
+			MermaidConfig:   config.MermaidConfig{"theme": "dark", "flowchart": map[string]interface{}{"curve": "basis", "padding": 10}},
+			BackgroundColor: "white",
+			Width:           800,
+			Height:          600,
+			Scale:           1,
+		}
+	}
+
+	a, err := Key("graph TD; A-->B", "svg", opts(), "11.0.0+abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := Key("graph TD; A-->B", "svg", opts(), "11.0.0+abc")
+	if a != b {
+		t.Errorf("expected identical inputs to give the same key, got %s and %s", a, b)
+	}
+
+	// Options that don't change the output don't change the key
+	slow := opts()
+	slow.Timeout = time.Minute
+	slow.SettleDelay = time.Second
+	if k, _ := Key("graph TD; A-->B", "svg", slow, "11.0.0+abc"); k != a {
+		t.Error("expected timeout and settle delay to be ignored")
+	}
+
+	changed := map[string]func() (string, error){
+		"definition": func() (string, error) { return Key("graph TD; A-->C", "svg", opts(), "11.0.0+abc") },
+		"format":     func() (string, error) { return Key("graph TD; A-->B", "png", opts(), "11.0.0+abc") },
+		"version":    func() (string, error) { return Key("graph TD; A-->B", "svg", opts(), "11.1.0+def") },
+		"scale": func() (string, error) {
+			o := opts()
+			o.Scale = 2
+			return Key("graph TD; A-->B", "svg", o, "11.0.0+abc")
+		},
+		"nested config": func() (string, error) {
+			o := opts()
+			o.MermaidConfig["flowchart"] = map[string]interface{}{"curve": "linear", "padding": 10}
+			return Key("graph TD; A-->B", "svg", o, "11.0.0+abc")
+		},
+	}
+	for name, key := range changed {
+		k, err := key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if k == a {
+			t.Errorf("expected a different key when the %s changes", name)
+		}
+	}
+}
+
+func TestCache_HitAndMiss(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &countingRenderer{}
+	r := cache.Wrap(inner)
+	ctx := context.Background()
+
+	first, err := r.Render(ctx, "graph TD; A-->B", "svg", renderer.RenderOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := r.Render(ctx, "graph TD; A-->B", "svg", renderer.RenderOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 1 {
+		t.Errorf("expected the second render to be served from cache, got %d renders", inner.calls)
+	}
+	if string(second.Data) != string(first.Data) || second.Title != "title" || second.Desc != "desc" {
+		t.Errorf("cached result %+v doesn't match %+v", second, first)
+	}
+	if !reflect.DeepEqual(second.Warnings, first.Warnings) || !reflect.DeepEqual(second.Console, first.Console) {
+		t.Errorf("cached diagnostics %q, %q don't match %q, %q", second.Warnings, second.Console, first.Warnings, first.Console)
+	}
+
+	if _, err := r.Render(ctx, "graph TD; A-->B", "png", renderer.RenderOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("expected a different format to miss, got %d renders", inner.calls)
+	}
+	if hits, misses := cache.Stats(); hits != 1 || misses != 2 {
+		t.Errorf("Stats() = (%d, %d), want (1, 2)", hits, misses)
+	}
+
+	// A new cache over the same directory sees the stored entries
+	reopened, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner2 := &countingRenderer{}
+	if _, err := reopened.Wrap(inner2).Render(ctx, "graph TD; A-->B", "svg", renderer.RenderOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if inner2.calls != 0 {
+		t.Error("expected the entry to persist across runs")
+	}
+
+	r.Close()
+	if !inner.closed {
+		t.Error("expected Close to reach the wrapped renderer")
+	}
+}
+
+func TestCache_VersionChangeMisses(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := New(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &countingRenderer{}
+	if _, err := cache.Wrap(inner).Render(context.Background(), "graph TD; A-->B", "svg", renderer.RenderOpts{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cache.version = "99.0.0+upgraded"
+	if _, err := cache.Wrap(inner).Render(context.Background(), "graph TD; A-->B", "svg", renderer.RenderOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 2 {
+		t.Errorf("expected a mermaid upgrade to invalidate the cache, got %d renders", inner.calls)
+	}
+}
+
+func TestCache_ErrorsAreNotCached(t *testing.T) {
+	cache, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &countingRenderer{}
+	r := cache.Wrap(inner)
+	for i := 0; i < 2; i++ {
+		if _, err := r.Render(context.Background(), "broken", "svg", renderer.RenderOpts{}); err == nil {
+			t.Fatal("expected render error")
+		}
+	}
+	if inner.calls != 2 {
+		t.Errorf("expected failed renders to be retried, got %d renders", inner.calls)
+	}
+}
+
+func TestCache_CorruptEntryIsMiss(t *testing.T) {
+	cache, err := New(t.TempDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := Key("graph TD; A-->B", "svg", renderer.RenderOpts{}, cache.version)
+	if err := cache.Put(key, "svg", &renderer.RenderResult{Data: []byte("<svg/>")}); err != nil {
+		t.Fatal(err)
+	}
+	_, metaPath := cache.paths(key)
+	if err := os.WriteFile(metaPath, []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := cache.Get(key); ok || err != nil {
+		t.Errorf("Get() = (%v, %v), want a miss", ok, err)
+	}
+	if entries, _ := filepath.Glob(filepath.Join(filepath.Dir(metaPath), ".tmp-*")); len(entries) != 0 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	if Version(nil) != Version(nil) {
+		t.Error("expected the embedded bundles to give a stable version")
+	}
+}
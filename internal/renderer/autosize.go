@@ -0,0 +1,94 @@
+package renderer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/chromedp/chromedp"
+)
+
+// autoSizeViewport returns the viewport, in CSS pixels, that fits a diagram of the given
+// natural size. The width is the diagram's width clamped to [minWidth, maxWidth], where 0
+// leaves that side unbounded. A diagram wider than maxWidth is shrunk to fit (mermaid's
+// useMaxWidth SVGs scale with the page), so the height keeps the aspect ratio; a diagram
+// widened by minWidth keeps its natural size and only gains whitespace.
+func autoSizeViewport(natural *clipRect, minWidth, maxWidth int) (width, height int64) {
+	w := math.Ceil(natural.Width)
+	h := math.Ceil(natural.Height)
+	if maxWidth > 0 && w > float64(maxWidth) {
+		if natural.Width > 0 {
+			h = math.Ceil(natural.Height * float64(maxWidth) / natural.Width)
+		}
+		w = float64(maxWidth)
+	}
+	if minWidth > 0 && w < float64(minWidth) {
+		w = float64(minWidth)
+	}
+	return max(int64(w), 1), max(int64(h), 1)
+}
+
+// getSVGNaturalSize returns the size the diagram is drawn at: its viewBox, or the
+// rendered size when the SVG has no viewBox.
+func getSVGNaturalSize(ctx context.Context) (*clipRect, error) {
+	var sizeJSON string
+	err := chromedp.Run(ctx,
+		chromedp.Evaluate(`(() => {
+			const svg = `+findSVGJS+`;
+			if (!svg) return JSON.stringify({x:0, y:0, width:0, height:0});
+			const vb = svg.viewBox && svg.viewBox.baseVal;
+			if (vb && vb.width && vb.height) {
+				return JSON.stringify({x:0, y:0, width:vb.width, height:vb.height});
+			}
+			const rect = svg.getBoundingClientRect();
+			return JSON.stringify({x:0, y:0, width:rect.width, height:rect.height});
+		})()`, &sizeJSON),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure diagram size: %w", err)
+	}
+
+	var size clipRect
+	if err := json.Unmarshal([]byte(sizeJSON), &size); err != nil {
+		return nil, fmt.Errorf("failed to parse diagram size: %w", err)
+	}
+	return &size, nil
+}
+
+// applyAutoSize resizes the viewport to fit the rendered diagram within the
+// RenderOpts width bounds and returns the new viewport size.
+func applyAutoSize(ctx context.Context, opts RenderOpts) (width, height int64, err error) {
+	natural, err := getSVGNaturalSize(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	width, height = autoSizeViewport(natural, opts.MinWidth, opts.MaxWidth)
+	if err := chromedp.Run(ctx,
+		deviceMetrics(width, height, opts.Scale),
+	); err != nil {
+		return 0, 0, fmt.Errorf("failed to resize viewport to fit diagram: %w", err)
+	}
+	return width, height, nil
+}
+
+// autoSizeSVGDimensions returns the width and height attributes to give an auto-sized
+// SVG. Explicit SVGWidth/SVGHeight take precedence.
+func autoSizeSVGDimensions(opts RenderOpts, width, height int64) (string, string) {
+	if opts.SVGWidth != "" || opts.SVGHeight != "" {
+		return opts.SVGWidth, opts.SVGHeight
+	}
+	return strconv.FormatInt(width, 10), strconv.FormatInt(height, 10)
+}
+
+// ValidateAutoSize checks the --minWidth/--maxWidth bounds.
+func ValidateAutoSize(minWidth, maxWidth int) error {
+	if minWidth < 0 || maxWidth < 0 {
+		return fmt.Errorf("invalid width bounds %d-%d, must not be negative", minWidth, maxWidth)
+	}
+	if maxWidth > 0 && minWidth > maxWidth {
+		return fmt.Errorf("minimum width %d is larger than maximum width %d", minWidth, maxWidth)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package renderer
+
+import "testing"
+
+func TestAutoSizeViewport(t *testing.T) {
+	tests := []struct {
+		name         string
+		natural      clipRect
+		minWidth     int
+		maxWidth     int
+		wantW, wantH int64
+	}{
+		{"unbounded", clipRect{Width: 1234.2, Height: 456.7}, 0, 0, 1235, 457},
+		{"within bounds", clipRect{Width: 500, Height: 300}, 200, 1000, 500, 300},
+		{"clamped to max keeps aspect ratio", clipRect{Width: 2000, Height: 1000}, 0, 800, 800, 400},
+		{"clamped to max rounds height up", clipRect{Width: 3000, Height: 1001}, 0, 1000, 1000, 334},
+		{"widened to min keeps natural height", clipRect{Width: 120, Height: 80}, 400, 0, 400, 80},
+		{"min equals max", clipRect{Width: 120, Height: 80}, 640, 640, 640, 80},
+		{"empty diagram", clipRect{}, 0, 0, 1, 1},
+		{"empty diagram with min", clipRect{}, 300, 800, 300, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h := autoSizeViewport(&tt.natural, tt.minWidth, tt.maxWidth)
+			if w != tt.wantW || h != tt.wantH {
+				t.Errorf("autoSizeViewport(%+v, %d, %d) = %dx%d, want %dx%d", tt.natural, tt.minWidth, tt.maxWidth, w, h, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
+
+func TestAutoSizeSVGDimensions(t *testing.T) {
+	if w, h := autoSizeSVGDimensions(RenderOpts{}, 800, 400); w != "800" || h != "400" {
+		t.Errorf("expected 800x400, got %sx%s", w, h)
+	}
+	if w, h := autoSizeSVGDimensions(RenderOpts{SVGWidth: "10cm"}, 800, 400); w != "10cm" || h != "" {
+		t.Errorf("expected explicit --svgWidth to win, got %q x %q", w, h)
+	}
+}
+
+func TestValidateAutoSize(t *testing.T) {
+	for _, ok := range [][2]int{{0, 0}, {200, 0}, {0, 1200}, {400, 400}, {200, 1200}} {
+		if err := ValidateAutoSize(ok[0], ok[1]); err != nil {
+			t.Errorf("ValidateAutoSize(%d, %d) = %v, want nil", ok[0], ok[1], err)
+		}
+	}
+	for _, bad := range [][2]int{{-1, 0}, {0, -5}, {1200, 200}} {
+		if err := ValidateAutoSize(bad[0], bad[1]); err == nil {
+			t.Errorf("ValidateAutoSize(%d, %d) = nil, want error", bad[0], bad[1])
+		}
+	}
+}
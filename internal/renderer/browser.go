@@ -2,12 +2,22 @@ package renderer
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/chromedp/cdproto/emulation"
 	"github.com/chromedp/chromedp"
 	"github.com/coolamit/mermaid-cli/internal/config"
 )
 
+// chromePathEnv is the environment variable that can point at a custom Chrome/Chromium binary.
+const chromePathEnv = "MMDC_CHROME_PATH"
+
 // Browser manages a lazy-started headless Chrome instance that is reused across renders.
 type Browser struct {
 	mu            sync.Mutex
@@ -16,7 +26,12 @@ type Browser struct {
 	browserCtx    context.Context
 	browserCancel context.CancelFunc
 	started       bool
+	pool          *tabPool
+	renders       renderTracker
 	cfg           *config.BrowserConfig
+	launch        func(ctx context.Context) error // starts the process and sets the contexts; b.mu is held
+	newTab        func(browserCtx context.Context) (*tab, error)
+	resetTab      func(t *tab) error
 }
 
 // NewBrowser creates a new Browser manager with the given config.
@@ -24,31 +39,87 @@ func NewBrowser(cfg *config.BrowserConfig) *Browser {
 	if cfg == nil {
 		cfg = &config.BrowserConfig{}
 	}
-	return &Browser{cfg: cfg}
+	b := &Browser{cfg: cfg, newTab: openTab, resetTab: resetTab}
+	b.launch = b.launchChrome
+	return b
 }
 
-// Context returns a chromedp context, lazily starting the browser if needed.
+// Context returns a chromedp context, lazily starting the browser if needed. A browser
+// that has crashed or been closed underneath us is relaunched.
 func (b *Browser) Context(ctx context.Context) (context.Context, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if err := b.startLocked(ctx); err != nil {
+		return nil, err
+	}
+	return b.browserCtx, nil
+}
+
+// startLocked starts the browser and its tab pool unless a healthy one is running.
+// b.mu must be held.
+func (b *Browser) startLocked(ctx context.Context) error {
+	b.ensureHealthy()
 	if b.started {
-		return b.browserCtx, nil
+		return nil
 	}
 
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("disable-gpu", true),
-		chromedp.Flag("no-sandbox", true),
-		chromedp.Flag("disable-dev-shm-usage", true),
-		chromedp.Flag("disable-setuid-sandbox", true),
-	)
+	if err := b.launch(ctx); err != nil {
+		return err
+	}
+
+	poolSize := b.cfg.TabPoolSize
+	if poolSize <= 0 {
+		poolSize = DefaultTabPoolSize
+	}
+	browserCtx, newTab := b.browserCtx, b.newTab
+	b.pool = newTabPool(poolSize, func() (*tab, error) {
+		return newTab(browserCtx)
+	}, b.resetTab)
+
+	b.started = true
+	return nil
+}
+
+// ensureHealthy closes a started browser whose process has gone away, so that it is
+// launched again. chromedp cancels the browser context once the process exits or its
+// connection drops, so a tab that merely crashed or closed doesn't count; and since the
+// renders in flight in a gone browser have already failed, closing it aborts nothing.
+// b.mu must be held.
+func (b *Browser) ensureHealthy() {
+	if b.started && b.browserCtx.Err() != nil {
+		b.closeLocked()
+	}
+}
+
+// launchChrome starts Chrome with the configured options. b.mu must be held.
+func (b *Browser) launchChrome(ctx context.Context) error {
+	opts := chromedp.DefaultExecAllocatorOptions[:]
+	for name, value := range launchFlags(b.cfg.Sandbox) {
+		opts = append(opts, chromedp.Flag(name, value))
+	}
+
+	opts, err := applyHeadless(opts, string(b.cfg.Headless))
+	if err != nil {
+		return err
+	}
 
 	if b.cfg.ExecutablePath != "" {
 		opts = append(opts, chromedp.ExecPath(b.cfg.ExecutablePath))
+	} else if path := os.Getenv(chromePathEnv); path != "" {
+		opts = append(opts, chromedp.ExecPath(path))
+	}
+
+	if b.cfg.Timeout > 0 {
+		opts = append(opts, chromedp.WSURLReadTimeout(b.cfg.TimeoutDuration()))
 	}
 
 	for _, arg := range b.cfg.Args {
-		opts = append(opts, chromedp.Flag(arg, true))
+		name, value := parseBrowserArg(arg)
+		if name == "" {
+			continue
+		}
+		opts = append(opts, chromedp.Flag(name, value))
 	}
 
 	b.allocCtx, b.allocCancel = chromedp.NewExecAllocator(ctx, opts...)
@@ -57,22 +128,166 @@ func (b *Browser) Context(ctx context.Context) (context.Context, error) {
 	// Run a no-op to force the browser to start
 	if err := chromedp.Run(b.browserCtx); err != nil {
 		b.allocCancel()
+		if strings.Contains(err.Error(), "websocket url timeout reached") {
+			return fmt.Errorf("browser did not start within %s (set by \"timeout\" in the browser config): %w", b.cfg.TimeoutDuration(), err)
+		}
+		return friendlyBrowserError(err)
+	}
+	return nil
+}
+
+// AcquireTab returns the context of a pooled tab, starting the browser if needed, and a
+// function that must be called to return the tab to the pool once the render is done.
+func (b *Browser) AcquireTab(ctx context.Context) (context.Context, func(), error) {
+	if err := b.renders.begin(); err != nil {
+		return nil, nil, err
+	}
+
+	// The pool is taken under the same lock that starts the browser: once b.mu is
+	// released, a crash relaunch or Close may replace or drop it
+	b.mu.Lock()
+	err := b.startLocked(ctx)
+	pool := b.pool
+	b.mu.Unlock()
+	if err != nil {
+		b.renders.end()
+		return nil, nil, err
+	}
+	if pool == nil {
+		b.renders.end()
+		return nil, nil, errBrowserClosed
+	}
+
+	t, err := pool.acquire(ctx)
+	if err != nil {
+		b.renders.end()
+		return nil, nil, fmt.Errorf("failed to open browser tab: %w", err)
+	}
+	return t.ctx, func() {
+		pool.release(t)
+		b.renders.end()
+	}, nil
+}
+
+// Shutdown stops accepting renders, waits for in-flight ones to release their tabs and
+// then closes the browser. If ctx is done first, the browser is closed anyway, aborting
+// the remaining renders, and ctx's error is returned.
+func (b *Browser) Shutdown(ctx context.Context) error {
+	err := b.renders.drain(ctx)
+	b.Close()
+	return err
+}
+
+// crashed reports whether the browser was started and its process has since exited,
+// e.g. because Chrome crashed or was killed.
+func (b *Browser) crashed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.started && b.browserCtx.Err() != nil
+}
+
+// TabStats returns the number of open tabs and how many of them are rendering.
+// Both are 0 until the browser has started.
+func (b *Browser) TabStats() (open, active int) {
+	b.mu.Lock()
+	pool := b.pool
+	b.mu.Unlock()
+
+	if pool == nil {
+		return 0, 0
+	}
+	open, idle := pool.stats()
+	return open, open - idle
+}
+
+// openTab opens a new tab in the browser.
+func openTab(browserCtx context.Context) (*tab, error) {
+	ctx, cancel := chromedp.NewContext(browserCtx)
+	// Run a no-op to force the tab to be created
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
 		return nil, err
 	}
+	return &tab{ctx: ctx, cancel: cancel}, nil
+}
 
-	b.started = true
-	return b.browserCtx, nil
+// tabResetTimeout bounds how long resetting a tab for reuse may take.
+const tabResetTimeout = 5 * time.Second
+
+// resetTab clears a tab's content and the emulation overrides a render sets (the
+// viewport size and the transparent background), so it can be reused by the next render.
+func resetTab(t *tab) error {
+	ctx, cancel := context.WithTimeout(t.ctx, tabResetTimeout)
+	defer cancel()
+	return chromedp.Run(ctx,
+		emulation.ClearDeviceMetricsOverride(),
+		emulation.SetDefaultBackgroundColorOverride(),
+		chromedp.Navigate("about:blank"),
+	)
+}
+
+// launchFlags returns the Chrome flags mmd-cli always sets. The sandbox is disabled
+// unless sandbox is true, since it fails to start as root and in many containers;
+// hardened deployments rendering untrusted input should enable it.
+func launchFlags(sandbox bool) map[string]interface{} {
+	flags := map[string]interface{}{
+		"disable-gpu":           true,
+		"disable-dev-shm-usage": true,
+	}
+	if !sandbox {
+		flags["no-sandbox"] = true
+		flags["disable-setuid-sandbox"] = true
+	}
+	return flags
+}
+
+// applyHeadless adjusts the allocator options for the browser config's headless mode.
+// An empty value or "true" keeps chromedp's default headless mode, "false" launches a
+// visible browser (useful for debugging) and "new"/"old" select that headless implementation.
+func applyHeadless(opts []chromedp.ExecAllocatorOption, headless string) ([]chromedp.ExecAllocatorOption, error) {
+	switch strings.ToLower(strings.TrimSpace(headless)) {
+	case "", "true":
+		return opts, nil
+	case "false":
+		return append(opts, chromedp.Flag("headless", false)), nil
+	case "new":
+		return append(opts, chromedp.Flag("headless", "new")), nil
+	case "old":
+		return append(opts, chromedp.Flag("headless", "old")), nil
+	default:
+		return nil, fmt.Errorf("invalid headless mode %q in browser config, expected \"new\", \"old\", \"true\" or \"false\"", headless)
+	}
+}
+
+// parseBrowserArg splits a Chrome command-line flag into the name and value expected by
+// chromedp.Flag: "--lang=en-US" becomes ("lang", "en-US") and a bare "--no-sandbox"
+// becomes ("no-sandbox", true). Leading dashes are optional.
+func parseBrowserArg(arg string) (string, interface{}) {
+	arg = strings.TrimLeft(strings.TrimSpace(arg), "-")
+	if name, value, ok := strings.Cut(arg, "="); ok {
+		return name, value
+	}
+	return arg, true
 }
 
 // Close shuts down the browser.
 func (b *Browser) Close() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
+	b.closeLocked()
+}
 
+// closeLocked closes the tabs and the browser process. b.mu must be held.
+func (b *Browser) closeLocked() {
 	if !b.started {
 		return
 	}
 
+	if b.pool != nil {
+		b.pool.close()
+		b.pool = nil
+	}
+
 	if b.browserCancel != nil {
 		b.browserCancel()
 	}
@@ -81,3 +296,21 @@ func (b *Browser) Close() {
 	}
 	b.started = false
 }
+
+// friendlyBrowserError wraps a browser launch error with guidance on installing
+// Chrome/Chromium or pointing mmd-cli at a custom binary.
+func friendlyBrowserError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, exec.ErrNotFound) || strings.Contains(err.Error(), "executable file not found") {
+		return fmt.Errorf("could not find Chrome/Chromium, which mmd-cli requires to render diagrams.\n"+
+			"Install it (e.g. `brew install --cask chromium`, `apt install chromium-browser` or `apk add chromium`), "+
+			"or point mmd-cli at an existing binary with the %s environment variable or "+
+			"\"executablePath\" in a --puppeteerConfigFile JSON file: %w", chromePathEnv, err)
+	}
+
+	return fmt.Errorf("could not launch Chrome/Chromium. If the browser is installed in a non-standard location, "+
+		"set the %s environment variable or \"executablePath\" in a --puppeteerConfigFile JSON file: %w", chromePathEnv, err)
+}
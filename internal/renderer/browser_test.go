@@ -0,0 +1,310 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"github.com/coolamit/mermaid-cli/internal/config"
+)
+
+func TestFriendlyBrowserError_Nil(t *testing.T) {
+	if err := friendlyBrowserError(nil); err != nil {
+		t.Errorf("expected nil, got %v", err)
+	}
+}
+
+func TestFriendlyBrowserError_NotFound(t *testing.T) {
+	orig := &exec.Error{Name: "google-chrome", Err: exec.ErrNotFound}
+	err := friendlyBrowserError(fmt.Errorf("launch: %w", orig))
+
+	for _, want := range []string{"could not find Chrome/Chromium", "MMDC_CHROME_PATH", "--puppeteerConfigFile", "Install"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %q", want, err.Error())
+		}
+	}
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Error("expected original error to be wrapped")
+	}
+}
+
+func TestFriendlyBrowserError_NotFoundMessage(t *testing.T) {
+	err := friendlyBrowserError(errors.New(`exec: "chrome": executable file not found in $PATH`))
+	if !strings.Contains(err.Error(), "could not find Chrome/Chromium") {
+		t.Errorf("expected not-found guidance, got %q", err.Error())
+	}
+}
+
+func TestFriendlyBrowserError_OtherFailure(t *testing.T) {
+	orig := errors.New("websocket url timeout reached")
+	err := friendlyBrowserError(orig)
+
+	if strings.Contains(err.Error(), "could not find") {
+		t.Errorf("expected generic launch failure message, got %q", err.Error())
+	}
+	if !strings.Contains(err.Error(), "could not launch Chrome/Chromium") {
+		t.Errorf("expected launch failure message, got %q", err.Error())
+	}
+	if !errors.Is(err, orig) {
+		t.Error("expected original error to be wrapped")
+	}
+}
+
+func TestParseBrowserArg(t *testing.T) {
+	tests := []struct {
+		arg   string
+		name  string
+		value interface{}
+	}{
+		{"--no-sandbox", "no-sandbox", true},
+		{"--lang=en-US", "lang", "en-US"},
+		{"--window-size=800,600", "window-size", "800,600"},
+		{"disable-gpu", "disable-gpu", true},
+		{"--js-flags=--max-old-space-size=4096", "js-flags", "--max-old-space-size=4096"},
+		{"--proxy-server=", "proxy-server", ""},
+		{"  --mute-audio ", "mute-audio", true},
+	}
+	for _, tt := range tests {
+		name, value := parseBrowserArg(tt.arg)
+		if name != tt.name || value != tt.value {
+			t.Errorf("parseBrowserArg(%q) = (%q, %v), want (%q, %v)", tt.arg, name, value, tt.name, tt.value)
+		}
+	}
+}
+
+func TestBrowserContext_LaunchTimeout(t *testing.T) {
+	// A fake "browser" that never prints its DevTools websocket URL
+	dir := t.TempDir()
+	fake := filepath.Join(dir, "fake-chrome")
+	if err := os.WriteFile(fake, []byte("#!/bin/sh\nsleep 10\n"), 0755); err != nil {
+		t.Fatalf("failed to write fake browser: %v", err)
+	}
+
+	b := NewBrowser(&config.BrowserConfig{ExecutablePath: fake, Timeout: 50})
+	defer b.Close()
+
+	start := time.Now()
+	_, err := b.Context(context.Background())
+	if err == nil {
+		t.Fatal("expected launch timeout error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected launch to fail quickly, took %s", elapsed)
+	}
+	if !strings.Contains(err.Error(), "did not start within 50ms") {
+		t.Errorf("expected clear timeout error, got %v", err)
+	}
+}
+
+// launchArgs starts a fake browser with the given allocator options and returns the
+// command-line arguments it was invoked with.
+func launchArgs(t *testing.T, opts []chromedp.ExecAllocatorOption) string {
+	t.Helper()
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	fake := filepath.Join(dir, "fake-chrome")
+	script := fmt.Sprintf("#!/bin/sh\necho \"$@\" > %q\nsleep 10\n", argsFile)
+	if err := os.WriteFile(fake, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write fake browser: %v", err)
+	}
+
+	opts = append(opts, chromedp.ExecPath(fake), chromedp.WSURLReadTimeout(200*time.Millisecond))
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), opts...)
+	defer allocCancel()
+	ctx, cancel := chromedp.NewContext(allocCtx)
+	defer cancel()
+	_ = chromedp.Run(ctx)
+
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("fake browser did not record its arguments: %v", err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestApplyHeadless(t *testing.T) {
+	tests := []struct {
+		mode    string
+		want    string
+		notWant string
+	}{
+		{"", "--headless ", "--headless="},
+		{"true", "--headless ", "--headless="},
+		{"new", "--headless=new", ""},
+		{"old", "--headless=old", ""},
+		{"false", "", "--headless"},
+		{"False", "", "--headless"},
+	}
+	for _, tt := range tests {
+		opts, err := applyHeadless(chromedp.DefaultExecAllocatorOptions[:], tt.mode)
+		if err != nil {
+			t.Errorf("applyHeadless(%q): unexpected error: %v", tt.mode, err)
+			continue
+		}
+		args := launchArgs(t, opts) + " "
+		if tt.want != "" && !strings.Contains(args, tt.want) {
+			t.Errorf("applyHeadless(%q): expected %q in args %q", tt.mode, tt.want, args)
+		}
+		if tt.notWant != "" && strings.Contains(args, tt.notWant) {
+			t.Errorf("applyHeadless(%q): expected no %q in args %q", tt.mode, tt.notWant, args)
+		}
+	}
+}
+
+func TestApplyHeadless_Invalid(t *testing.T) {
+	if _, err := applyHeadless(nil, "sometimes"); err == nil {
+		t.Fatal("expected error for invalid headless mode, got nil")
+	}
+}
+
+func TestLaunchFlags(t *testing.T) {
+	flags := launchFlags(false)
+	for _, name := range []string{"no-sandbox", "disable-setuid-sandbox", "disable-gpu"} {
+		if flags[name] != true {
+			t.Errorf("expected %q by default, got %v", name, flags)
+		}
+	}
+
+	flags = launchFlags(true)
+	for _, name := range []string{"no-sandbox", "disable-setuid-sandbox"} {
+		if _, ok := flags[name]; ok {
+			t.Errorf("expected no %q with the sandbox enabled, got %v", name, flags)
+		}
+	}
+	if flags["disable-gpu"] != true {
+		t.Errorf("expected disable-gpu with the sandbox enabled, got %v", flags)
+	}
+}
+
+func TestBrowserTabStats_NotStarted(t *testing.T) {
+	b := NewBrowser(nil)
+	if open, active := b.TabStats(); open != 0 || active != 0 {
+		t.Errorf("TabStats() = (%d, %d), want (0, 0) before the browser starts", open, active)
+	}
+}
+
+// fakeLaunch replaces b's browser launch, and the tabs it opens, with plain contexts, and
+// returns the number of launches so far.
+func fakeLaunch(b *Browser) *atomic.Int32 {
+	var launches atomic.Int32
+	b.launch = func(ctx context.Context) error {
+		launches.Add(1)
+		b.allocCtx, b.allocCancel = context.WithCancel(ctx)
+		b.browserCtx, b.browserCancel = context.WithCancel(b.allocCtx)
+		return nil
+	}
+	b.newTab = func(browserCtx context.Context) (*tab, error) {
+		if err := browserCtx.Err(); err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithCancel(browserCtx)
+		return &tab{ctx: ctx, cancel: cancel}, nil
+	}
+	b.resetTab = func(t *tab) error { return t.ctx.Err() }
+	return &launches
+}
+
+func TestBrowserContext_RelaunchesClosedBrowser(t *testing.T) {
+	b := NewBrowser(nil)
+	launches := fakeLaunch(b)
+	defer b.Close()
+
+	first, err := b.Context(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again, _ := b.Context(context.Background()); again != first || launches.Load() != 1 {
+		t.Fatalf("expected a healthy browser to be reused, launched %d times", launches.Load())
+	}
+
+	// Simulate Chrome going away underneath us
+	b.browserCancel()
+	if !b.crashed() {
+		t.Error("expected the closed browser to be reported as crashed")
+	}
+
+	second, err := b.Context(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if launches.Load() != 2 {
+		t.Errorf("expected the browser to be relaunched, launched %d times", launches.Load())
+	}
+	if second == first || second.Err() != nil {
+		t.Error("expected a new, live browser context")
+	}
+}
+
+func TestBrowserContext_KeepsBrowserWhenATabCloses(t *testing.T) {
+	b := NewBrowser(nil)
+	launches := fakeLaunch(b)
+	defer b.Close()
+
+	first, err := b.Context(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A tab going away, e.g. with "target closed", leaves the browser and its other tabs running
+	_, closeTab := context.WithCancel(first)
+	closeTab()
+
+	if b.crashed() {
+		t.Error("expected a closed tab not to be reported as a crash")
+	}
+	if again, _ := b.Context(context.Background()); again != first || launches.Load() != 1 {
+		t.Errorf("expected the live browser to be reused, launched %d times", launches.Load())
+	}
+}
+
+func TestBrowserAcquireTab_ConcurrentCloseAndRelaunch(t *testing.T) {
+	b := NewBrowser(&config.BrowserConfig{TabPoolSize: 2})
+	fakeLaunch(b)
+	defer b.Close()
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// Errors are expected when the browser closes mid-acquire; panics are not
+				_, release, err := b.AcquireTab(context.Background())
+				if err == nil {
+					release()
+				}
+			}
+		}()
+	}
+
+	for deadline := time.Now().Add(200 * time.Millisecond); time.Now().Before(deadline); {
+		b.Close()
+		runtime.Gosched()
+		if _, err := b.Context(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// Simulate Chrome crashing, so the next start relaunches it
+		b.mu.Lock()
+		b.browserCancel()
+		b.mu.Unlock()
+		runtime.Gosched()
+	}
+	close(stop)
+	wg.Wait()
+}
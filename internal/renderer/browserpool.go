@@ -0,0 +1,251 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/coolamit/mermaid-cli/internal/config"
+)
+
+// TabSource hands out browser tabs to renders. It is a single *Browser, or a
+// *BrowserPool spreading renders over several browsers.
+type TabSource interface {
+	// AcquireTab returns the context of a tab and a function that must be called to
+	// return the tab once the render is done.
+	AcquireTab(ctx context.Context) (context.Context, func(), error)
+	// TabStats returns the number of open tabs and how many of them are rendering.
+	TabStats() (open, active int)
+	// Shutdown waits for in-flight renders to finish, up to ctx's deadline, then closes the browser.
+	Shutdown(ctx context.Context) error
+	// Close closes the browser, aborting in-flight renders.
+	Close()
+}
+
+// NewBrowsers returns n independent browsers for renders to share: a single Browser
+// when n is 1 or less, otherwise a BrowserPool.
+func NewBrowsers(cfg *config.BrowserConfig, n int) TabSource {
+	if n <= 1 {
+		return NewBrowser(cfg)
+	}
+	return NewBrowserPool(cfg, n)
+}
+
+// pooledBrowser is a browser in a BrowserPool. *Browser implements it.
+type pooledBrowser interface {
+	TabSource
+	// crashed reports whether the browser was started and has since died.
+	crashed() bool
+}
+
+// retireTimeout bounds how long a replaced browser may take to finish its in-flight
+// renders before it is closed.
+const retireTimeout = time.Minute
+
+// BrowserPool spreads renders over several independent browser processes, so a crash
+// only fails the renders in flight in that browser. Each render goes to the browser with
+// the fewest renders in flight, and a browser that crashed or can't open a tab is
+// replaced by a new one.
+type BrowserPool struct {
+	newBrowser func() pooledBrowser
+
+	mu       sync.Mutex
+	browsers []pooledBrowser
+	inFlight []int
+	next     int
+	closed   bool
+	retired  map[pooledBrowser]struct{} // replaced browsers still finishing their renders
+	retiring sync.WaitGroup
+}
+
+// NewBrowserPool creates a pool of n browsers started lazily with cfg, each with its own
+// pool of tabs.
+func NewBrowserPool(cfg *config.BrowserConfig, n int) *BrowserPool {
+	return newBrowserPool(n, func() pooledBrowser { return NewBrowser(cfg) })
+}
+
+// newBrowserPool creates a pool of n browsers made by newBrowser.
+func newBrowserPool(n int, newBrowser func() pooledBrowser) *BrowserPool {
+	if n < 1 {
+		n = 1
+	}
+	p := &BrowserPool{
+		newBrowser: newBrowser,
+		browsers:   make([]pooledBrowser, n),
+		inFlight:   make([]int, n),
+		retired:    make(map[pooledBrowser]struct{}),
+	}
+	for i := range p.browsers {
+		p.browsers[i] = newBrowser()
+	}
+	return p
+}
+
+// AcquireTab returns a tab from the least loaded browser. If that browser fails to
+// provide one, it is replaced and the new browser is tried once.
+func (p *BrowserPool) AcquireTab(ctx context.Context) (context.Context, func(), error) {
+	i, b, err := p.pick()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tabCtx, release, err := b.AcquireTab(ctx)
+	if err != nil && ctx.Err() == nil && !errors.Is(err, ErrShuttingDown) {
+		if b = p.restart(i, b); b != nil {
+			tabCtx, release, err = b.AcquireTab(ctx)
+		}
+	}
+	if err != nil {
+		p.done(i)
+		return nil, nil, err
+	}
+	return tabCtx, func() {
+		release()
+		p.done(i)
+	}, nil
+}
+
+// pick reserves the browser with the fewest renders in flight, replacing any that
+// crashed. Ties go round-robin so idle browsers share the work.
+func (p *BrowserPool) pick() (int, pooledBrowser, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return 0, nil, ErrShuttingDown
+	}
+
+	var crashed []pooledBrowser
+	for i, b := range p.browsers {
+		if b.crashed() {
+			crashed = append(crashed, p.replaceLocked(i))
+		}
+	}
+
+	best := -1
+	for k := range p.browsers {
+		i := (p.next + k) % len(p.browsers)
+		if best < 0 || p.inFlight[i] < p.inFlight[best] {
+			best = i
+		}
+	}
+	p.next = (best + 1) % len(p.browsers)
+	p.inFlight[best]++
+	b := p.browsers[best]
+	p.mu.Unlock()
+
+	// Their renders have already failed, so there is nothing to wait for
+	for _, old := range crashed {
+		old.Close()
+	}
+	return best, b, nil
+}
+
+// restart replaces the browser at i if it is still b, returning the browser now at i,
+// or nil if the pool has been closed.
+func (p *BrowserPool) restart(i int, b pooledBrowser) pooledBrowser {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	if p.browsers[i] == b {
+		p.retireLocked(p.replaceLocked(i))
+	}
+	return p.browsers[i]
+}
+
+// replaceLocked swaps the browser at i for a new one and returns the old one, which the
+// caller must close or retire.
+func (p *BrowserPool) replaceLocked(i int) pooledBrowser {
+	old := p.browsers[i]
+	p.browsers[i] = p.newBrowser()
+	return old
+}
+
+// retireLocked lets a replaced browser finish its in-flight renders, if it still can,
+// for up to retireTimeout, then closes it. Close and Shutdown also see to retired
+// browsers. p.mu must be held and the pool must not be closed, so that the retiring
+// count is never raised while Close or Shutdown waits on it.
+func (p *BrowserPool) retireLocked(b pooledBrowser) {
+	p.retired[b] = struct{}{}
+	p.retiring.Add(1)
+	go func() {
+		defer p.retiring.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), retireTimeout)
+		defer cancel()
+		_ = b.Shutdown(ctx)
+
+		p.mu.Lock()
+		delete(p.retired, b)
+		p.mu.Unlock()
+	}()
+}
+
+// closeLocked marks the pool closed and returns every browser it holds, current and
+// retired. p.mu must be held.
+func (p *BrowserPool) closeLocked() []pooledBrowser {
+	p.closed = true
+	browsers := append([]pooledBrowser(nil), p.browsers...)
+	for b := range p.retired {
+		browsers = append(browsers, b)
+	}
+	return browsers
+}
+
+// done marks a render on the browser at i as finished.
+func (p *BrowserPool) done(i int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.inFlight[i]--
+}
+
+// TabStats returns the number of open tabs and how many of them are rendering, across
+// all browsers.
+func (p *BrowserPool) TabStats() (open, active int) {
+	p.mu.Lock()
+	browsers := append([]pooledBrowser(nil), p.browsers...)
+	p.mu.Unlock()
+
+	for _, b := range browsers {
+		o, a := b.TabStats()
+		open += o
+		active += a
+	}
+	return open, active
+}
+
+// Shutdown stops accepting renders and shuts every browser down, including replaced ones
+// still finishing their renders, waiting for their in-flight renders up to ctx's deadline.
+// It returns ctx's error if any had to be aborted.
+func (p *BrowserPool) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	browsers := p.closeLocked()
+	p.mu.Unlock()
+
+	errs := make([]error, len(browsers))
+	var wg sync.WaitGroup
+	for i, b := range browsers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = b.Shutdown(ctx)
+		}()
+	}
+	wg.Wait()
+	p.retiring.Wait()
+	return errors.Join(errs...)
+}
+
+// Close closes every browser, including replaced ones still finishing their renders,
+// aborting in-flight renders.
+func (p *BrowserPool) Close() {
+	p.mu.Lock()
+	browsers := p.closeLocked()
+	p.mu.Unlock()
+
+	for _, b := range browsers {
+		b.Close()
+	}
+	p.retiring.Wait()
+}
@@ -0,0 +1,275 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeBrowser hands out fake tabs and can be made to crash or fail.
+type fakeBrowser struct {
+	id       int
+	acquired atomic.Int32
+	active   atomic.Int32
+	dead     atomic.Bool
+	failTabs atomic.Bool
+	shutdown atomic.Bool
+	closed   atomic.Bool
+
+	hold      chan struct{} // if set, Shutdown waits for it to close, or for Close or ctx
+	killed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (f *fakeBrowser) AcquireTab(ctx context.Context) (context.Context, func(), error) {
+	if f.failTabs.Load() {
+		return nil, nil, errors.New("failed to open browser tab: websocket closed")
+	}
+	f.acquired.Add(1)
+	f.active.Add(1)
+	return ctx, func() { f.active.Add(-1) }, nil
+}
+
+func (f *fakeBrowser) TabStats() (open, active int) {
+	return 1, int(f.active.Load())
+}
+
+func (f *fakeBrowser) Shutdown(ctx context.Context) error {
+	f.shutdown.Store(true)
+	if f.hold == nil {
+		return nil
+	}
+	select {
+	case <-f.hold:
+		return nil
+	case <-f.killed:
+		return nil
+	case <-ctx.Done():
+		f.Close()
+		return ctx.Err()
+	}
+}
+
+func (f *fakeBrowser) Close() {
+	f.shutdown.Store(true)
+	f.closed.Store(true)
+	f.closeOnce.Do(func() { close(f.killed) })
+}
+
+func (f *fakeBrowser) crashed() bool {
+	return f.dead.Load()
+}
+
+// fakeBrowsers records every browser a pool creates.
+type fakeBrowsers struct {
+	mu      sync.Mutex
+	created []*fakeBrowser
+}
+
+func (f *fakeBrowsers) newBrowser() pooledBrowser {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	b := &fakeBrowser{id: len(f.created), killed: make(chan struct{})}
+	f.created = append(f.created, b)
+	return b
+}
+
+func (f *fakeBrowsers) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.created)
+}
+
+func TestBrowserPool_DistributesWork(t *testing.T) {
+	f := &fakeBrowsers{}
+	pool := newBrowserPool(3, f.newBrowser)
+
+	// Sequential renders go round-robin
+	for i := 0; i < 6; i++ {
+		_, release, err := pool.AcquireTab(context.Background())
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		release()
+	}
+	for _, b := range f.created {
+		if got := b.acquired.Load(); got != 2 {
+			t.Errorf("browser %d got %d renders, want 2", b.id, got)
+		}
+	}
+
+	// Concurrent renders go to the least loaded browser
+	var releases []func()
+	for i := 0; i < 6; i++ {
+		_, release, err := pool.AcquireTab(context.Background())
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		releases = append(releases, release)
+	}
+	for _, b := range f.created {
+		if got := b.active.Load(); got != 2 {
+			t.Errorf("browser %d has %d renders in flight, want 2", b.id, got)
+		}
+	}
+	if open, active := pool.TabStats(); open != 3 || active != 6 {
+		t.Errorf("TabStats() = (%d, %d), want (3, 6)", open, active)
+	}
+	for _, release := range releases {
+		release()
+	}
+}
+
+func TestBrowserPool_RestartsCrashedBrowser(t *testing.T) {
+	f := &fakeBrowsers{}
+	pool := newBrowserPool(2, f.newBrowser)
+
+	crashed := f.created[0]
+	crashed.dead.Store(true)
+
+	for i := 0; i < 4; i++ {
+		_, release, err := pool.AcquireTab(context.Background())
+		if err != nil {
+			t.Fatalf("acquire: %v", err)
+		}
+		release()
+	}
+
+	if got := f.count(); got != 3 {
+		t.Fatalf("expected the crashed browser to be replaced, created %d browsers", got)
+	}
+	if crashed.acquired.Load() != 0 {
+		t.Error("expected no renders on the crashed browser")
+	}
+	if !crashed.shutdown.Load() {
+		t.Error("expected the crashed browser to be shut down")
+	}
+	if got := f.created[2].acquired.Load(); got == 0 {
+		t.Error("expected the replacement browser to take renders")
+	}
+}
+
+func TestBrowserPool_RestartsBrowserThatFailsToOpenTab(t *testing.T) {
+	f := &fakeBrowsers{}
+	pool := newBrowserPool(1, f.newBrowser)
+	f.created[0].failTabs.Store(true)
+
+	_, release, err := pool.AcquireTab(context.Background())
+	if err != nil {
+		t.Fatalf("expected the render to succeed on a restarted browser, got %v", err)
+	}
+	release()
+	if got := f.count(); got != 2 {
+		t.Errorf("expected the failing browser to be replaced, created %d browsers", got)
+	}
+}
+
+func TestBrowserPool_CloseClosesRetiredBrowser(t *testing.T) {
+	f := &fakeBrowsers{}
+	pool := newBrowserPool(1, f.newBrowser)
+	old := f.created[0]
+	old.hold = make(chan struct{})
+	old.failTabs.Store(true)
+
+	_, release, err := pool.AcquireTab(context.Background())
+	if err != nil {
+		t.Fatalf("expected the render to succeed on a restarted browser, got %v", err)
+	}
+	release()
+	deadline := time.Now().Add(time.Second)
+	for !old.shutdown.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the replaced browser to be shut down")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if old.closed.Load() {
+		t.Fatal("expected the replaced browser to be draining, not closed")
+	}
+
+	// Close kills the retired browser rather than waiting for it
+	done := make(chan struct{})
+	go func() {
+		pool.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() did not return while a retired browser was draining")
+	}
+	if !old.closed.Load() {
+		t.Error("expected Close to close the retired browser")
+	}
+}
+
+func TestBrowserPool_ShutdownWaitsForRetiredBrowser(t *testing.T) {
+	f := &fakeBrowsers{}
+	pool := newBrowserPool(1, f.newBrowser)
+	old := f.created[0]
+	old.hold = make(chan struct{})
+	old.failTabs.Store(true)
+
+	_, release, err := pool.AcquireTab(context.Background())
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want context.DeadlineExceeded", err)
+	}
+	if !old.closed.Load() {
+		t.Error("expected the retired browser to be closed once the deadline passed")
+	}
+}
+
+func TestBrowserPool_FailsWhenRestartFails(t *testing.T) {
+	f := &fakeBrowsers{}
+	pool := newBrowserPool(1, func() pooledBrowser {
+		b := f.newBrowser().(*fakeBrowser)
+		b.failTabs.Store(true)
+		return b
+	})
+
+	if _, _, err := pool.AcquireTab(context.Background()); err == nil {
+		t.Fatal("expected an error when no browser can open a tab")
+	}
+	if got := f.count(); got != 2 {
+		t.Errorf("expected a single restart, created %d browsers", got)
+	}
+	if _, active := pool.TabStats(); active != 0 {
+		t.Errorf("expected no renders in flight, got %d", active)
+	}
+}
+
+func TestBrowserPool_Shutdown(t *testing.T) {
+	f := &fakeBrowsers{}
+	pool := newBrowserPool(2, f.newBrowser)
+
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, b := range f.created {
+		if !b.shutdown.Load() {
+			t.Errorf("browser %d was not shut down", b.id)
+		}
+	}
+	if _, _, err := pool.AcquireTab(context.Background()); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("expected ErrShuttingDown after Shutdown, got %v", err)
+	}
+}
+
+func TestNewBrowsers(t *testing.T) {
+	if _, ok := NewBrowsers(nil, 1).(*Browser); !ok {
+		t.Error("expected a single Browser for n=1")
+	}
+	if _, ok := NewBrowsers(nil, 3).(*BrowserPool); !ok {
+		t.Error("expected a BrowserPool for n=3")
+	}
+}
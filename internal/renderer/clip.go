@@ -0,0 +1,91 @@
+package renderer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Clip is a rectangle of a diagram to capture, in CSS pixels relative to the top-left
+// corner of the rendered SVG.
+type Clip struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+}
+
+// String formats c the way ParseClip reads it, e.g. "10,20,300,200".
+func (c Clip) String() string {
+	return strings.Join([]string{
+		strconv.FormatFloat(c.X, 'f', -1, 64),
+		strconv.FormatFloat(c.Y, 'f', -1, 64),
+		strconv.FormatFloat(c.Width, 'f', -1, 64),
+		strconv.FormatFloat(c.Height, 'f', -1, 64),
+	}, ",")
+}
+
+// ParseClip parses a --clip value of the form X,Y,W,H, e.g. "10,20,300,200". The offset
+// can't be negative and the size must be positive.
+func ParseClip(s string) (*Clip, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("invalid --clip %q, expected X,Y,W,H", s)
+	}
+	var v [4]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --clip %q, expected X,Y,W,H: %q is not a number", s, strings.TrimSpace(part))
+		}
+		v[i] = f
+	}
+	clip := &Clip{X: v[0], Y: v[1], Width: v[2], Height: v[3]}
+	if clip.X < 0 || clip.Y < 0 {
+		return nil, fmt.Errorf("invalid --clip %q, X and Y can't be negative", s)
+	}
+	if clip.Width <= 0 || clip.Height <= 0 {
+		return nil, fmt.Errorf("invalid --clip %q, W and H must be positive", s)
+	}
+	return clip, nil
+}
+
+// clipBounds returns the page rectangle of clip within the SVG at svgBounds, or an error
+// if clip doesn't fit inside the diagram.
+func clipBounds(svgBounds *clipRect, clip *Clip) (*clipRect, error) {
+	if clip.X+clip.Width > svgBounds.Width || clip.Y+clip.Height > svgBounds.Height {
+		return nil, fmt.Errorf("clip region %s is outside the diagram, which is %gx%g", clip, svgBounds.Width, svgBounds.Height)
+	}
+	return &clipRect{
+		X:      svgBounds.X + clip.X,
+		Y:      svgBounds.Y + clip.Y,
+		Width:  clip.Width,
+		Height: clip.Height,
+	}, nil
+}
+
+// captureBounds returns the page rectangle to capture: the SVG bounds, or the part of
+// them selected by opts.Clip.
+func captureBounds(ctx context.Context, opts RenderOpts) (*clipRect, error) {
+	bounds, err := getSVGBounds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Clip == nil {
+		return bounds, nil
+	}
+	return clipBounds(bounds, opts.Clip)
+}
+
+// shiftToOrigin moves the diagram so that the page point at (x, y) is drawn at the top-left
+// corner of the page, which is where a PDF page starts printing.
+func shiftToOrigin(ctx context.Context, x, y float64) error {
+	script := fmt.Sprintf(`document.getElementById('container').style.transform = 'translate(%gpx, %gpx)'`, -x, -y)
+	if err := chromedp.Run(ctx, chromedp.Evaluate(script, nil)); err != nil {
+		return fmt.Errorf("failed to position clip region: %w", err)
+	}
+	return nil
+}
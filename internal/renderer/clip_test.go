@@ -0,0 +1,81 @@
+package renderer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseClip(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Clip
+	}{
+		{"10,20,300,200", Clip{X: 10, Y: 20, Width: 300, Height: 200}},
+		{"0, 0, 12.5, 8", Clip{Width: 12.5, Height: 8}},
+	}
+	for _, tt := range tests {
+		got, err := ParseClip(tt.in)
+		if err != nil {
+			t.Errorf("ParseClip(%q): unexpected error: %v", tt.in, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("ParseClip(%q) = %+v, want %+v", tt.in, *got, tt.want)
+		}
+	}
+}
+
+func TestParseClip_Invalid(t *testing.T) {
+	for _, in := range []string{"", "10,20,300", "10,20,300,200,5", "a,20,300,200", "-1,0,10,10", "0,-1,10,10", "0,0,0,10", "0,0,10,-5"} {
+		if _, err := ParseClip(in); err == nil {
+			t.Errorf("ParseClip(%q): expected an error", in)
+		}
+	}
+}
+
+func TestClipString(t *testing.T) {
+	if got := (Clip{X: 10, Y: 20.5, Width: 300, Height: 200}).String(); got != "10,20.5,300,200" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestClipBounds(t *testing.T) {
+	// An 800x600 diagram drawn 8px in from the page corner
+	svg := &clipRect{X: 8, Y: 8, Width: 800, Height: 600}
+
+	got, err := clipBounds(svg, &Clip{X: 100, Y: 50, Width: 200, Height: 150})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := clipRect{X: 108, Y: 58, Width: 200, Height: 150}
+	if *got != want {
+		t.Errorf("got %+v, want %+v", *got, want)
+	}
+
+	// A clip covering the whole diagram is the diagram itself
+	got, err = clipBounds(svg, &Clip{Width: 800, Height: 600})
+	if err != nil {
+		t.Fatalf("unexpected error for the full diagram: %v", err)
+	}
+	if *got != *svg {
+		t.Errorf("got %+v, want %+v", *got, *svg)
+	}
+}
+
+func TestClipBounds_Outside(t *testing.T) {
+	svg := &clipRect{X: 8, Y: 8, Width: 800, Height: 600}
+	for _, clip := range []Clip{
+		{X: 700, Y: 0, Width: 200, Height: 100},
+		{X: 0, Y: 550, Width: 100, Height: 100},
+		{X: 900, Y: 700, Width: 10, Height: 10},
+	} {
+		_, err := clipBounds(svg, &clip)
+		if err == nil {
+			t.Errorf("clipBounds(%+v): expected an error", clip)
+			continue
+		}
+		if !strings.Contains(err.Error(), "800x600") {
+			t.Errorf("expected the diagram size in the error, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package renderer
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/chromedp/cdproto/cdp"
+)
+
+// namedColors maps the CSS basic color keywords (plus a few common extras) to RGB values.
+var namedColors = map[string][3]int64{
+	"black":   {0, 0, 0},
+	"silver":  {192, 192, 192},
+	"gray":    {128, 128, 128},
+	"grey":    {128, 128, 128},
+	"white":   {255, 255, 255},
+	"maroon":  {128, 0, 0},
+	"red":     {255, 0, 0},
+	"purple":  {128, 0, 128},
+	"fuchsia": {255, 0, 255},
+	"magenta": {255, 0, 255},
+	"green":   {0, 128, 0},
+	"lime":    {0, 255, 0},
+	"olive":   {128, 128, 0},
+	"yellow":  {255, 255, 0},
+	"navy":    {0, 0, 128},
+	"blue":    {0, 0, 255},
+	"teal":    {0, 128, 128},
+	"aqua":    {0, 255, 255},
+	"cyan":    {0, 255, 255},
+	"orange":  {255, 165, 0},
+	"pink":    {255, 192, 203},
+	"brown":   {165, 42, 42},
+}
+
+// parseColor parses a CSS color string into an RGBA value.
+// Supported forms: #RGB, #RGBA, #RRGGBB, #RRGGBBAA, rgb(), rgba(), transparent and basic named colors.
+// The second return value is false if the color could not be parsed.
+func parseColor(s string) (*cdp.RGBA, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return nil, false
+	}
+
+	if s == "transparent" {
+		return &cdp.RGBA{R: 0, G: 0, B: 0, A: 0}, true
+	}
+
+	if rgb, ok := namedColors[s]; ok {
+		return &cdp.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 1}, true
+	}
+
+	if strings.HasPrefix(s, "#") {
+		return parseHexColor(s[1:])
+	}
+
+	if strings.HasPrefix(s, "rgb(") || strings.HasPrefix(s, "rgba(") {
+		return parseRGBFunc(s)
+	}
+
+	return nil, false
+}
+
+// parseHexColor parses the hex digits of a #RGB, #RGBA, #RRGGBB or #RRGGBBAA color.
+func parseHexColor(hex string) (*cdp.RGBA, bool) {
+	switch len(hex) {
+	case 3, 4:
+		// Expand shorthand: "f0a" -> "ff00aa"
+		var sb strings.Builder
+		for _, c := range hex {
+			sb.WriteRune(c)
+			sb.WriteRune(c)
+		}
+		hex = sb.String()
+	case 6, 8:
+	default:
+		return nil, false
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, false
+	}
+
+	if len(hex) == 6 {
+		return &cdp.RGBA{R: int64(v >> 16 & 0xff), G: int64(v >> 8 & 0xff), B: int64(v & 0xff), A: 1}, true
+	}
+	return &cdp.RGBA{
+		R: int64(v >> 24 & 0xff),
+		G: int64(v >> 16 & 0xff),
+		B: int64(v >> 8 & 0xff),
+		A: float64(v&0xff) / 255.0,
+	}, true
+}
+
+// parseRGBFunc parses rgb(r, g, b), rgba(r, g, b, a) and the space-separated rgb(r g b / a) syntax.
+func parseRGBFunc(s string) (*cdp.RGBA, bool) {
+	open := strings.Index(s, "(")
+	if open < 0 || !strings.HasSuffix(s, ")") {
+		return nil, false
+	}
+	body := s[open+1 : len(s)-1]
+
+	var parts []string
+	if strings.Contains(body, ",") {
+		parts = strings.Split(body, ",")
+	} else {
+		body = strings.Replace(body, "/", " ", 1)
+		parts = strings.Fields(body)
+	}
+	if len(parts) != 3 && len(parts) != 4 {
+		return nil, false
+	}
+
+	var channels [3]int64
+	for i := 0; i < 3; i++ {
+		c, ok := parseColorChannel(strings.TrimSpace(parts[i]))
+		if !ok {
+			return nil, false
+		}
+		channels[i] = c
+	}
+
+	alpha := 1.0
+	if len(parts) == 4 {
+		a, ok := parseAlpha(strings.TrimSpace(parts[3]))
+		if !ok {
+			return nil, false
+		}
+		alpha = a
+	}
+
+	return &cdp.RGBA{R: channels[0], G: channels[1], B: channels[2], A: alpha}, true
+}
+
+// parseColorChannel parses an rgb() channel given as 0-255 or a percentage, clamping to range.
+func parseColorChannel(s string) (int64, bool) {
+	if strings.HasSuffix(s, "%") {
+		f, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil {
+			return 0, false
+		}
+		return int64(clamp(f, 0, 100)*255/100 + 0.5), true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return int64(clamp(f, 0, 255) + 0.5), true
+}
+
+// parseAlpha parses an alpha value given as 0-1 or a percentage, clamping to range.
+func parseAlpha(s string) (float64, bool) {
+	if strings.HasSuffix(s, "%") {
+		f, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil {
+			return 0, false
+		}
+		return clamp(f, 0, 100) / 100, true
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return clamp(f, 0, 1), true
+}
+
+func clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+// needsTransparentPage reports whether the background color has any transparency,
+// in which case the page's default white background must be overridden so it
+// does not show through the diagram's own (semi-)transparent background. Background
+// images count too, since a gradient may have translucent stops.
+func needsTransparentPage(backgroundColor string) bool {
+	if isBackgroundImage(backgroundColor) {
+		return true
+	}
+	c, ok := parseColor(backgroundColor)
+	return ok && c.A < 1
+}
+
+// backgroundImageRegex matches the CSS image functions that can appear in a background value.
+var backgroundImageRegex = regexp.MustCompile(`(?i)\b(?:(?:repeating-)?(?:linear|radial|conic)-gradient|url|image-set|cross-fade)\s*\(`)
+
+// isBackgroundImage reports whether a --backgroundColor value is a CSS background image,
+// such as a gradient, rather than a plain color. An SVG's background-color can't hold
+// one, so the page template paints it behind the SVG instead.
+func isBackgroundImage(background string) bool {
+	return backgroundImageRegex.MatchString(background)
+}
@@ -0,0 +1,109 @@
+package renderer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input   string
+		r, g, b int64
+		a       float64
+	}{
+		{"transparent", 0, 0, 0, 0},
+		{"TRANSPARENT", 0, 0, 0, 0},
+		{"white", 255, 255, 255, 1},
+		{"Red", 255, 0, 0, 1},
+		{"#fff", 255, 255, 255, 1},
+		{"#f00a", 255, 0, 0, 170.0 / 255.0},
+		{"#F0F0F0", 240, 240, 240, 1},
+		{"#00000080", 0, 0, 0, 128.0 / 255.0},
+		{"#12345600", 0x12, 0x34, 0x56, 0},
+		{"rgb(10, 20, 30)", 10, 20, 30, 1},
+		{"rgba(10,20,30,0.5)", 10, 20, 30, 0.5},
+		{"rgba(10, 20, 30, 50%)", 10, 20, 30, 0.5},
+		{"rgb(100%, 0%, 50%)", 255, 0, 128, 1},
+		{"rgb(10 20 30 / 0.25)", 10, 20, 30, 0.25},
+		{"rgb(300, -5, 0)", 255, 0, 0, 1},
+		{"  #abc  ", 0xaa, 0xbb, 0xcc, 1},
+	}
+
+	for _, tt := range tests {
+		c, ok := parseColor(tt.input)
+		if !ok {
+			t.Errorf("parseColor(%q): expected ok", tt.input)
+			continue
+		}
+		if c.R != tt.r || c.G != tt.g || c.B != tt.b {
+			t.Errorf("parseColor(%q): expected rgb(%d,%d,%d), got rgb(%d,%d,%d)", tt.input, tt.r, tt.g, tt.b, c.R, c.G, c.B)
+		}
+		if math.Abs(c.A-tt.a) > 1e-9 {
+			t.Errorf("parseColor(%q): expected alpha %v, got %v", tt.input, tt.a, c.A)
+		}
+	}
+}
+
+func TestParseColor_Invalid(t *testing.T) {
+	inputs := []string{
+		"",
+		"#",
+		"#12",
+		"#12345",
+		"#1234567",
+		"#ggg",
+		"notacolor",
+		"rgb(1, 2)",
+		"rgb(1, 2, 3, 4, 5)",
+		"rgb(a, b, c)",
+		"rgba(1, 2, 3, x)",
+		"rgb(1, 2, 3",
+	}
+	for _, input := range inputs {
+		if _, ok := parseColor(input); ok {
+			t.Errorf("parseColor(%q): expected failure", input)
+		}
+	}
+}
+
+func TestNeedsTransparentPage(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"transparent", true},
+		{"#00000080", true},
+		{"rgba(0, 0, 0, 0.5)", true},
+		{"white", false},
+		{"#F0F0F0", false},
+		{"rgb(1, 2, 3)", false},
+		{"var(--bg)", false},
+		{"linear-gradient(#fff, #000)", true},
+	}
+	for _, tt := range tests {
+		if got := needsTransparentPage(tt.input); got != tt.want {
+			t.Errorf("needsTransparentPage(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIsBackgroundImage(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"linear-gradient(to right, red, blue)", true},
+		{"Radial-Gradient(circle, #fff, #000)", true},
+		{"repeating-conic-gradient(red 0 15deg, blue 0 30deg)", true},
+		{"url(bg.png)", true},
+		{"white", false},
+		{"rgba(0, 0, 0, 0.5)", false},
+		{"transparent", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := isBackgroundImage(tt.input); got != tt.want {
+			t.Errorf("isBackgroundImage(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package renderer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// maxConsoleErrors caps how many console errors are appended to a render error.
+const maxConsoleErrors = 5
+
+// consoleEntry is a single message logged by the page.
+type consoleEntry struct {
+	level string
+	text  string
+}
+
+func (e consoleEntry) String() string {
+	return fmt.Sprintf("[%s] %s", e.level, e.text)
+}
+
+// isError reports whether the entry is an error rather than informational output.
+func (e consoleEntry) isError() bool {
+	return e.level == "error" || e.level == "assert" || e.level == "exception"
+}
+
+// consoleLog collects console messages, uncaught exceptions and browser log entries
+// (e.g. failed resource loads) from a tab during a render.
+type consoleLog struct {
+	mu      sync.Mutex
+	entries []consoleEntry
+}
+
+// listen records events from the tab until ctx is done.
+func (c *consoleLog) listen(ctx context.Context) {
+	chromedp.ListenTarget(ctx, func(ev any) {
+		switch ev := ev.(type) {
+		case *runtime.EventConsoleAPICalled:
+			c.add(string(ev.Type), formatConsoleArgs(ev.Args))
+		case *runtime.EventExceptionThrown:
+			text := ev.ExceptionDetails.Text
+			if ex := ev.ExceptionDetails.Exception; ex != nil && ex.Description != "" {
+				text = ex.Description
+			}
+			c.add("exception", text)
+		case *log.EventEntryAdded:
+			text := ev.Entry.Text
+			if ev.Entry.URL != "" {
+				text += " (" + ev.Entry.URL + ")"
+			}
+			c.add(string(ev.Entry.Level), text)
+		}
+	})
+}
+
+func (c *consoleLog) add(level, text string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, consoleEntry{level: level, text: text})
+}
+
+// lines returns every recorded message, oldest first.
+func (c *consoleLog) lines() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lines := make([]string, 0, len(c.entries))
+	for _, e := range c.entries {
+		lines = append(lines, e.String())
+	}
+	return lines
+}
+
+// recentErrors returns up to n of the most recent error messages, oldest first.
+func (c *consoleLog) recentErrors(n int) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []string
+	for i := len(c.entries) - 1; i >= 0 && len(errs) < n; i-- {
+		if c.entries[i].isError() {
+			errs = append(errs, c.entries[i].String())
+		}
+	}
+	for i, j := 0, len(errs)-1; i < j; i, j = i+1, j-1 {
+		errs[i], errs[j] = errs[j], errs[i]
+	}
+	return errs
+}
+
+// annotate appends the most recent console errors to a render error, if there are any.
+func (c *consoleLog) annotate(err error) error {
+	errs := c.recentErrors(maxConsoleErrors)
+	if len(errs) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w\nbrowser console errors:\n  %s", err, strings.Join(errs, "\n  "))
+}
+
+// formatConsoleArgs joins console call arguments the way the DevTools console prints them.
+func formatConsoleArgs(args []*runtime.RemoteObject) string {
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch {
+		case len(arg.Value) > 0:
+			var s string
+			if err := json.Unmarshal([]byte(arg.Value), &s); err == nil {
+				parts = append(parts, s)
+			} else {
+				parts = append(parts, string(arg.Value))
+			}
+		case arg.UnserializableValue != "":
+			parts = append(parts, string(arg.UnserializableValue))
+		case arg.Description != "":
+			parts = append(parts, arg.Description)
+		default:
+			parts = append(parts, string(arg.Type))
+		}
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,67 @@
+package renderer
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/runtime"
+)
+
+func TestFormatConsoleArgs(t *testing.T) {
+	args := []*runtime.RemoteObject{
+		{Type: runtime.TypeString, Value: []byte(`"Failed to fetch icon:"`)},
+		{Type: runtime.TypeNumber, Value: []byte(`42`)},
+		{Type: runtime.TypeNumber, UnserializableValue: "NaN"},
+		{Type: runtime.TypeObject, Description: "Error: boom"},
+		{Type: runtime.TypeUndefined},
+	}
+	got := formatConsoleArgs(args)
+	want := "Failed to fetch icon: 42 NaN Error: boom undefined"
+	if got != want {
+		t.Errorf("formatConsoleArgs() = %q, want %q", got, want)
+	}
+}
+
+func TestConsoleLogRecentErrors(t *testing.T) {
+	c := &consoleLog{}
+	c.add("log", "starting")
+	for i := 0; i < maxConsoleErrors+2; i++ {
+		c.add("error", string(rune('a'+i)))
+	}
+	c.add("warning", "deprecated")
+
+	errs := c.recentErrors(maxConsoleErrors)
+	if len(errs) != maxConsoleErrors {
+		t.Fatalf("expected %d errors, got %d: %v", maxConsoleErrors, len(errs), errs)
+	}
+	if errs[0] != "[error] c" || errs[len(errs)-1] != "[error] g" {
+		t.Errorf("expected the most recent errors oldest first, got %v", errs)
+	}
+
+	if lines := c.lines(); len(lines) != maxConsoleErrors+4 || lines[0] != "[log] starting" {
+		t.Errorf("unexpected lines %v", lines)
+	}
+}
+
+func TestConsoleLogAnnotate(t *testing.T) {
+	base := errors.New("mermaid rendering error: bad")
+
+	c := &consoleLog{}
+	c.add("info", "hello")
+	if err := c.annotate(base); err != base {
+		t.Errorf("expected error unchanged without console errors, got %v", err)
+	}
+
+	c.add("error", "Failed to fetch icon: logos")
+	c.add("exception", "TypeError: x is undefined")
+	err := c.annotate(base)
+	if !errors.Is(err, base) {
+		t.Error("expected annotated error to wrap the original")
+	}
+	for _, want := range []string{"browser console errors:", "[error] Failed to fetch icon: logos", "[exception] TypeError"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package renderer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultWidth and DefaultHeight are the viewport size used when neither the options
+// nor the diagram type set one.
+const (
+	DefaultWidth  = 800
+	DefaultHeight = 600
+)
+
+// viewportDefault is the viewport a diagram type renders best in.
+type viewportDefault struct {
+	width, height int
+}
+
+// diagramTypeViewports holds the diagram types that lay out wider or taller than the
+// default viewport allows, which clips them before a PNG is resized to the diagram.
+var diagramTypeViewports = map[string]viewportDefault{
+	"gitGraph": {1200, 600},
+	"timeline": {1400, 600},
+	"mindmap":  {1200, 1000},
+	"gantt":    {1200, 600},
+}
+
+// knownDiagramTypes are the canonical diagram types built into mermaid.
+var knownDiagramTypes = []string{
+	"architecture", "block", "c4", "classDiagram", "erDiagram", "flowchart", "gantt",
+	"gitGraph", "journey", "kanban", "mindmap", "packet", "pie", "quadrantChart", "radar",
+	"requirementDiagram", "sankey", "sequenceDiagram", "stateDiagram", "timeline", "xychart",
+}
+
+// externalDiagramTypes are the diagram types the page registers from external bundles,
+// available unless the bundle is left out.
+var externalDiagramTypes = []string{"zenuml"}
+
+// diagramTypeAliases maps alternative keywords to the canonical diagram type.
+var diagramTypeAliases = map[string]string{
+	"graph":             "flowchart",
+	"flowchart-elk":     "flowchart",
+	"classDiagram-v2":   "classDiagram",
+	"stateDiagram-v2":   "stateDiagram",
+	"C4Context":         "c4",
+	"C4Container":       "c4",
+	"C4Component":       "c4",
+	"C4Dynamic":         "c4",
+	"C4Deployment":      "c4",
+	"sankey-beta":       "sankey",
+	"xychart-beta":      "xychart",
+	"block-beta":        "block",
+	"packet-beta":       "packet",
+	"architecture-beta": "architecture",
+	"radar-beta":        "radar",
+}
+
+// definitionFrontmatterRegex matches a leading YAML frontmatter block.
+var definitionFrontmatterRegex = regexp.MustCompile(`^\s*---[^\S\n]*\r?\n[\s\S]*?\r?\n---[^\S\n]*(?:\r?\n|$)`)
+
+// detectDiagramType returns the diagram type named by the first keyword of a mermaid
+// definition, after any frontmatter, directives and comments, e.g. "gitGraph" or
+// "flowchart" for `graph TD`. Unknown keywords are returned as they are, and an empty
+// definition yields "".
+func detectDiagramType(def string) string {
+	def = definitionFrontmatterRegex.ReplaceAllString(def, "")
+	for _, line := range strings.Split(def, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "%%") {
+			continue
+		}
+		keyword := line
+		if i := strings.IndexFunc(line, func(r rune) bool { return r == ' ' || r == '\t' || r == ';' || r == ':' }); i >= 0 {
+			keyword = line[:i]
+		}
+		return canonicalDiagramType(keyword)
+	}
+	return ""
+}
+
+// canonicalDiagramType maps a diagram keyword to its canonical type, e.g. graph -> flowchart.
+func canonicalDiagramType(keyword string) string {
+	if canonical, ok := diagramTypeAliases[keyword]; ok {
+		return canonical
+	}
+	return keyword
+}
+
+// ValidateDiagramType checks that a --diagramType value names a diagram type mermaid
+// knows, either built in or registered from an external bundle. noZenUML leaves the
+// zenuml bundle out, so zenuml isn't registered.
+func ValidateDiagramType(diagramType string, noZenUML bool) error {
+	canonical := canonicalDiagramType(diagramType)
+	for _, t := range knownDiagramTypes {
+		if canonical == t {
+			return nil
+		}
+	}
+	for _, t := range externalDiagramTypes {
+		if canonical == t {
+			if noZenUML {
+				return fmt.Errorf("diagram type %q is not registered when zenuml is disabled", diagramType)
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown diagram type %q, must be one of %s", diagramType, strings.Join(append(append([]string{}, knownDiagramTypes...), externalDiagramTypes...), ", "))
+}
+
+// DiagramType returns the diagram type that sizes a render: opts.DiagramType if set,
+// otherwise the type detected from the definition.
+func DiagramType(definition string, opts RenderOpts) string {
+	if opts.DiagramType != "" {
+		return canonicalDiagramType(opts.DiagramType)
+	}
+	return detectDiagramType(definition)
+}
+
+// ViewportSize returns the viewport to render definition in: the size set in opts, with
+// zero dimensions filled in from the diagram type's default, or else the global default.
+func ViewportSize(definition string, opts RenderOpts) (width, height int) {
+	width, height = opts.Width, opts.Height
+	if width > 0 && height > 0 {
+		return width, height
+	}
+	size, ok := diagramTypeViewports[DiagramType(definition, opts)]
+	if !ok {
+		size = viewportDefault{DefaultWidth, DefaultHeight}
+	}
+	if width <= 0 {
+		width = size.width
+	}
+	if height <= 0 {
+		height = size.height
+	}
+	return width, height
+}
@@ -0,0 +1,95 @@
+package renderer
+
+import "testing"
+
+func TestDetectDiagramType(t *testing.T) {
+	tests := []struct {
+		def  string
+		want string
+	}{
+		{"graph TD; A-->B", "flowchart"},
+		{"flowchart LR\n  A --> B", "flowchart"},
+		{"sequenceDiagram\n  Alice->>Bob: Hi", "sequenceDiagram"},
+		{"classDiagram-v2\n  class A", "classDiagram"},
+		{"stateDiagram-v2\n  [*] --> A", "stateDiagram"},
+		{"gitGraph\n  commit", "gitGraph"},
+		{"gitGraph:\n  commit", "gitGraph"},
+		{"timeline\n  title History", "timeline"},
+		{"mindmap\n  root((x))", "mindmap"},
+		{"gantt\n  title Plan", "gantt"},
+		{"pie title Pets", "pie"},
+		{"C4Context\n  title System", "c4"},
+		{"xychart-beta\n  x-axis [a]", "xychart"},
+		{"\n\n  %% a comment\n%%{init: {\"theme\": \"dark\"}}%%\ngitGraph\n  commit", "gitGraph"},
+		{"---\ntitle: History\n---\ntimeline\n  2020 : a", "timeline"},
+		{"myCustomDiagram\n  a", "myCustomDiagram"},
+		{"", ""},
+		{"%% only a comment", ""},
+	}
+	for _, tt := range tests {
+		if got := detectDiagramType(tt.def); got != tt.want {
+			t.Errorf("detectDiagramType(%q) = %q, want %q", tt.def, got, tt.want)
+		}
+	}
+}
+
+func TestViewportSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		def           string
+		width, height int
+		wantW, wantH  int
+	}{
+		{"default", "graph TD; A-->B", 0, 0, DefaultWidth, DefaultHeight},
+		{"type default", "gitGraph\n  commit", 0, 0, 1200, 600},
+		{"explicit size wins", "gitGraph\n  commit", 640, 480, 640, 480},
+		{"explicit width only", "mindmap\n  root", 640, 0, 640, 1000},
+		{"explicit height only", "timeline\n  2020 : a", 0, 300, 1400, 300},
+	}
+	for _, tt := range tests {
+		w, h := ViewportSize(tt.def, RenderOpts{Width: tt.width, Height: tt.height})
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("%s: viewportSize = %dx%d, want %dx%d", tt.name, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func TestDiagramType_OverrideTakesPrecedence(t *testing.T) {
+	def := "myTimeline\n  2020 : a"
+	if got := DiagramType(def, RenderOpts{}); got != "myTimeline" {
+		t.Errorf("detected type = %q, want %q", got, "myTimeline")
+	}
+	if got := DiagramType(def, RenderOpts{DiagramType: "timeline"}); got != "timeline" {
+		t.Errorf("overridden type = %q, want %q", got, "timeline")
+	}
+	if got := DiagramType("gitGraph\n  commit", RenderOpts{DiagramType: "graph"}); got != "flowchart" {
+		t.Errorf("aliased override = %q, want %q", got, "flowchart")
+	}
+
+	// The override picks the sizing defaults, and explicit dimensions still win over it
+	if w, h := ViewportSize(def, RenderOpts{DiagramType: "timeline"}); w != 1400 || h != 600 {
+		t.Errorf("ViewportSize with override = %dx%d, want 1400x600", w, h)
+	}
+	if w, h := ViewportSize("gitGraph\n  commit", RenderOpts{DiagramType: "flowchart"}); w != DefaultWidth || h != DefaultHeight {
+		t.Errorf("ViewportSize with flowchart override = %dx%d, want %dx%d", w, h, DefaultWidth, DefaultHeight)
+	}
+	if w, h := ViewportSize(def, RenderOpts{DiagramType: "timeline", Width: 500}); w != 500 || h != 600 {
+		t.Errorf("ViewportSize with override and width = %dx%d, want 500x600", w, h)
+	}
+}
+
+func TestValidateDiagramType(t *testing.T) {
+	for _, valid := range []string{"gitGraph", "flowchart", "graph", "stateDiagram-v2", "C4Context", "zenuml"} {
+		if err := ValidateDiagramType(valid, false); err != nil {
+			t.Errorf("ValidateDiagramType(%q): unexpected error: %v", valid, err)
+		}
+	}
+	for _, invalid := range []string{"gitgraph", "myDiagram", ""} {
+		if err := ValidateDiagramType(invalid, false); err == nil {
+			t.Errorf("ValidateDiagramType(%q): expected an error", invalid)
+		}
+	}
+	if err := ValidateDiagramType("zenuml", true); err == nil {
+		t.Error("expected zenuml to be rejected when zenuml is disabled")
+	}
+}
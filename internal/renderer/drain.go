@@ -0,0 +1,63 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrShuttingDown is returned for renders started after a Browser, or the render daemon
+// in front of it, began shutting down.
+var ErrShuttingDown = errors.New("renderer is shutting down")
+
+// renderTracker counts in-flight renders so the browser is only closed once they finish.
+type renderTracker struct {
+	mu       sync.Mutex
+	active   int
+	draining bool
+	drained  chan struct{}
+}
+
+// begin registers a render, failing once draining has started.
+func (t *renderTracker) begin() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.draining {
+		return ErrShuttingDown
+	}
+	t.active++
+	return nil
+}
+
+// end marks a render as finished.
+func (t *renderTracker) end() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.active--
+	if t.active == 0 && t.drained != nil {
+		close(t.drained)
+		t.drained = nil
+	}
+}
+
+// drain stops new renders and waits until the in-flight ones end or ctx is done.
+func (t *renderTracker) drain(ctx context.Context) error {
+	t.mu.Lock()
+	t.draining = true
+	if t.active == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.drained == nil {
+		t.drained = make(chan struct{})
+	}
+	drained := t.drained
+	t.mu.Unlock()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
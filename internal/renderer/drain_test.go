@@ -0,0 +1,72 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRenderTracker_DrainWaitsForRenders(t *testing.T) {
+	var tr renderTracker
+	if err := tr.begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	drained := make(chan error, 1)
+	go func() { drained <- tr.drain(context.Background()) }()
+
+	// New renders are refused as soon as draining starts
+	deadline := time.Now().Add(time.Second)
+	for tr.begin() == nil {
+		tr.end()
+		if time.Now().After(deadline) {
+			t.Fatal("expected begin to fail while draining")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := tr.begin(); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("begin() = %v, want ErrShuttingDown", err)
+	}
+
+	select {
+	case err := <-drained:
+		t.Fatalf("drain returned %v before the render ended", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	tr.end()
+	if err := <-drained; err != nil {
+		t.Errorf("drain() = %v, want nil", err)
+	}
+}
+
+func TestRenderTracker_DrainIdle(t *testing.T) {
+	var tr renderTracker
+	if err := tr.drain(context.Background()); err != nil {
+		t.Errorf("drain() = %v, want nil", err)
+	}
+}
+
+func TestRenderTracker_DrainTimeout(t *testing.T) {
+	var tr renderTracker
+	if err := tr.begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := tr.drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("drain() = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestBrowserShutdown_RefusesNewRenders(t *testing.T) {
+	b := NewBrowser(nil)
+	if err := b.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.AcquireTab(context.Background()); !errors.Is(err, ErrShuttingDown) {
+		t.Errorf("AcquireTab() after Shutdown = %v, want ErrShuttingDown", err)
+	}
+}
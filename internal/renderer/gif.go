@@ -0,0 +1,66 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"time"
+)
+
+// DefaultFrameDelay is how long each frame of an animated GIF is shown unless
+// --frameDelay is given.
+const DefaultFrameDelay = time.Second
+
+// maxGIFColors is the largest palette a GIF frame can hold.
+const maxGIFColors = 256
+
+// EncodeGIF assembles PNG frames, in order, into an animated GIF that loops forever and
+// shows each frame for delay. Frames of different sizes are centered on a transparent
+// canvas the size of the largest, and each is cleared before the next is drawn.
+func EncodeGIF(frames [][]byte, delay time.Duration) ([]byte, error) {
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("an animation needs at least one frame")
+	}
+
+	images := make([]image.Image, len(frames))
+	var canvas image.Point
+	for i, data := range frames {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode frame %d: %w", i+1, err)
+		}
+		images[i] = img
+		size := img.Bounds().Size()
+		canvas = image.Point{X: max(canvas.X, size.X), Y: max(canvas.Y, size.Y)}
+	}
+
+	// GIF delays are in hundredths of a second
+	centis := max(1, int((delay+5*time.Millisecond)/(10*time.Millisecond)))
+	anim := &gif.GIF{Config: image.Config{Width: canvas.X, Height: canvas.Y}}
+	for _, img := range images {
+		anim.Image = append(anim.Image, palettedImage(centerFrame(img, canvas), maxGIFColors))
+		anim.Delay = append(anim.Delay, centis)
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		return nil, fmt.Errorf("failed to encode GIF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// centerFrame draws img in the middle of a transparent canvas of the given size.
+func centerFrame(img image.Image, canvas image.Point) image.Image {
+	size := img.Bounds().Size()
+	if size == canvas {
+		return img
+	}
+	out := image.NewNRGBA(image.Rectangle{Max: canvas})
+	offset := image.Point{X: (canvas.X - size.X) / 2, Y: (canvas.Y - size.Y) / 2}
+	draw.Draw(out, image.Rectangle{Min: offset, Max: offset.Add(size)}, img, img.Bounds().Min, draw.Src)
+	return out
+}
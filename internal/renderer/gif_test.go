@@ -0,0 +1,65 @@
+package renderer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+	"time"
+)
+
+func TestEncodeGIF(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	blue := color.NRGBA{0, 0, 255, 255}
+	frames := [][]byte{
+		solidImage(t, 40, 30, red, "png"),
+		solidImage(t, 20, 10, blue, "png"),
+	}
+
+	data, err := EncodeGIF(frames, 1500*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	anim, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("expected a GIF: %v", err)
+	}
+	if len(anim.Image) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(anim.Image))
+	}
+	if anim.Config.Width != 40 || anim.Config.Height != 30 {
+		t.Errorf("canvas = %dx%d, want the largest frame's 40x30", anim.Config.Width, anim.Config.Height)
+	}
+	if anim.LoopCount != 0 {
+		t.Errorf("LoopCount = %d, want 0 (forever)", anim.LoopCount)
+	}
+	for i, d := range anim.Delay {
+		if d != 150 {
+			t.Errorf("frame %d: delay = %d, want 150", i+1, d)
+		}
+	}
+
+	if got := color.NRGBAModel.Convert(anim.Image[0].At(0, 0)); got != red {
+		t.Errorf("frame 1: got %v, want red", got)
+	}
+	// The smaller frame is centered: x 10-29, y 10-19
+	second := anim.Image[1]
+	if got := color.NRGBAModel.Convert(second.At(20, 15)); got != blue {
+		t.Errorf("frame 2 center: got %v, want blue", got)
+	}
+	for _, p := range []image.Point{{0, 0}, {9, 15}, {30, 15}, {20, 9}, {20, 20}} {
+		if _, _, _, a := second.At(p.X, p.Y).RGBA(); a != 0 {
+			t.Errorf("frame 2 at %v: expected transparent, got %v", p, second.At(p.X, p.Y))
+		}
+	}
+}
+
+func TestEncodeGIF_Errors(t *testing.T) {
+	if _, err := EncodeGIF(nil, time.Second); err == nil {
+		t.Error("expected an error for no frames")
+	}
+	if _, err := EncodeGIF([][]byte{[]byte("not a png")}, time.Second); err == nil {
+		t.Error("expected an error for a frame that isn't a PNG")
+	}
+}
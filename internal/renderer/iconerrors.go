@@ -0,0 +1,55 @@
+package renderer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// iconLoadError is an icon pack that failed to load, as recorded by the page's icon
+// pack loader in window.__mmd_icon_errors.
+type iconLoadError struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// readIconErrors returns the icon packs that failed to load while the page rendered.
+func readIconErrors(ctx context.Context) ([]iconLoadError, error) {
+	var errorsJSON string
+	if err := chromedp.Run(ctx,
+		chromedp.Evaluate(`JSON.stringify(window.__mmd_icon_errors || [])`, &errorsJSON),
+	); err != nil {
+		return nil, fmt.Errorf("failed to read icon pack errors: %w", err)
+	}
+	return parseIconErrors(errorsJSON)
+}
+
+// parseIconErrors decodes window.__mmd_icon_errors, keeping the first failure of each pack.
+func parseIconErrors(errorsJSON string) ([]iconLoadError, error) {
+	var all []iconLoadError
+	if err := json.Unmarshal([]byte(errorsJSON), &all); err != nil {
+		return nil, fmt.Errorf("failed to parse icon pack errors: %w", err)
+	}
+	seen := make(map[string]bool, len(all))
+	var failed []iconLoadError
+	for _, e := range all {
+		if !seen[e.Name] {
+			seen[e.Name] = true
+			failed = append(failed, e)
+		}
+	}
+	return failed, nil
+}
+
+// iconErrorsMessage names the failed packs and why, e.g.
+// "failed to load icon packs: logos (HTTP 404), mdi (Failed to fetch)".
+func iconErrorsMessage(failed []iconLoadError) string {
+	parts := make([]string, len(failed))
+	for i, e := range failed {
+		parts[i] = fmt.Sprintf("%s (%s)", e.Name, e.Error)
+	}
+	return "failed to load icon packs: " + strings.Join(parts, ", ")
+}
@@ -0,0 +1,43 @@
+package renderer
+
+import "testing"
+
+func TestParseIconErrors(t *testing.T) {
+	failed, err := parseIconErrors(`[{"name":"logos","error":"HTTP 404"},{"name":"mdi","error":"Failed to fetch"},{"name":"logos","error":"HTTP 404"}]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []iconLoadError{{Name: "logos", Error: "HTTP 404"}, {Name: "mdi", Error: "Failed to fetch"}}
+	if len(failed) != len(want) {
+		t.Fatalf("expected %d failures, got %+v", len(want), failed)
+	}
+	for i := range want {
+		if failed[i] != want[i] {
+			t.Errorf("failure %d = %+v, want %+v", i, failed[i], want[i])
+		}
+	}
+}
+
+func TestParseIconErrors_None(t *testing.T) {
+	failed, err := parseIconErrors("[]")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(failed) != 0 {
+		t.Errorf("expected no failures, got %+v", failed)
+	}
+}
+
+func TestParseIconErrors_Invalid(t *testing.T) {
+	if _, err := parseIconErrors("not json"); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+func TestIconErrorsMessage(t *testing.T) {
+	got := iconErrorsMessage([]iconLoadError{{Name: "logos", Error: "HTTP 404"}, {Name: "mdi", Error: "Failed to fetch"}})
+	want := "failed to load icon packs: logos (HTTP 404), mdi (Failed to fetch)"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
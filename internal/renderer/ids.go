@@ -0,0 +1,112 @@
+package renderer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// idAttrRegex matches an id attribute, with the value in group 2.
+var idAttrRegex = regexp.MustCompile(`(\sid=")([^"]*)(")`)
+
+// idURLRefRegex matches a url(#marker) reference, in an attribute or CSS, with the id in
+// group 2.
+var idURLRefRegex = regexp.MustCompile(`(url\(\s*['"]?#)([\w-]+)`)
+
+// idHrefRegex matches an href or xlink:href attribute pointing at a fragment, with the id
+// in group 2.
+var idHrefRegex = regexp.MustCompile(`(\s(?:xlink:)?href="#)([\w-]+)(")`)
+
+// styleElementRegex matches a <style> element, with its CSS in group 2.
+var styleElementRegex = regexp.MustCompile(`(?s)(<style\b[^>]*>)(.*?)(</style>)`)
+
+// cssPreludeRegex matches the selector list of a CSS rule: the text before a '{' back to
+// the end of the previous rule or declaration. Colors such as #fff are in declarations,
+// so they never match.
+var cssPreludeRegex = regexp.MustCompile(`[^{};]*\{`)
+
+// cssIDSelectorRegex matches an id selector such as #my-svg, with the id in group 1.
+var cssIDSelectorRegex = regexp.MustCompile(`#([\w-]+)`)
+
+// idNameRegex matches the ids normalizeSVGIDs renames: those the reference regexes can
+// find the references to.
+var idNameRegex = regexp.MustCompile(`^[\w-]+$`)
+
+// idListAttrRegex matches the attributes that hold a space-separated list of ids.
+var idListAttrRegex = regexp.MustCompile(`(\saria-(?:labelledby|describedby|controls|owns)=")([^"]*)(")`)
+
+// normalizeSVGIDs rewrites the ids mermaid generates, some of which are random, to
+// stable values derived from the SVG's content, and updates the references to them, so
+// that two renders of the same diagram are byte-identical. The root element keeps its
+// id (--svgId), which styles and scripts around the SVG may use; the others become
+// <root id>-<hash>-<n>, numbered in document order, where hash depends on everything in
+// the SVG except the ids.
+func normalizeSVGIDs(svgXML string) string {
+	loc := svgRootTagRegex.FindStringIndex(svgXML)
+	if loc == nil {
+		return svgXML
+	}
+	root := "svg"
+	if m := idAttrRegex.FindStringSubmatch(svgXML[loc[0]:loc[1]]); m != nil && m[2] != "" {
+		root = m[2]
+	}
+
+	ids := make(map[string]int)
+	for _, m := range idAttrRegex.FindAllStringSubmatch(svgXML[loc[1]:], -1) {
+		if _, seen := ids[m[2]]; !seen && idNameRegex.MatchString(m[2]) && m[2] != root {
+			ids[m[2]] = len(ids)
+		}
+	}
+	if len(ids) == 0 {
+		return svgXML
+	}
+
+	placeholders := renameSVGIDs(svgXML, ids, func(n int) string { return "id" + strconv.Itoa(n) })
+	sum := sha256.Sum256([]byte(placeholders))
+	prefix := root + "-" + hex.EncodeToString(sum[:4]) + "-"
+	return renameSVGIDs(svgXML, ids, func(n int) string { return prefix + strconv.Itoa(n) })
+}
+
+// renameSVGIDs renames the ids in ids, and the references to them, to name(n), where n is
+// the id's number in ids. References are only looked for where a fragment can appear:
+// url(#…), href="#…" and the selectors of <style> rules. Other ids, and text that merely
+// looks like a reference, such as a color or a "#tag" in a label, are left alone.
+func renameSVGIDs(svgXML string, ids map[string]int, name func(n int) string) string {
+	rename := func(id string) string {
+		if n, ok := ids[id]; ok {
+			return name(n)
+		}
+		return id
+	}
+	svgXML = idAttrRegex.ReplaceAllStringFunc(svgXML, func(attr string) string {
+		m := idAttrRegex.FindStringSubmatch(attr)
+		return m[1] + rename(m[2]) + m[3]
+	})
+	svgXML = idListAttrRegex.ReplaceAllStringFunc(svgXML, func(attr string) string {
+		m := idListAttrRegex.FindStringSubmatch(attr)
+		list := strings.Fields(m[2])
+		for i, id := range list {
+			list[i] = rename(id)
+		}
+		return m[1] + strings.Join(list, " ") + m[3]
+	})
+	svgXML = idURLRefRegex.ReplaceAllStringFunc(svgXML, func(ref string) string {
+		m := idURLRefRegex.FindStringSubmatch(ref)
+		return m[1] + rename(m[2])
+	})
+	svgXML = idHrefRegex.ReplaceAllStringFunc(svgXML, func(attr string) string {
+		m := idHrefRegex.FindStringSubmatch(attr)
+		return m[1] + rename(m[2]) + m[3]
+	})
+	return styleElementRegex.ReplaceAllStringFunc(svgXML, func(style string) string {
+		m := styleElementRegex.FindStringSubmatch(style)
+		css := cssPreludeRegex.ReplaceAllStringFunc(m[2], func(prelude string) string {
+			return cssIDSelectorRegex.ReplaceAllStringFunc(prelude, func(sel string) string {
+				return "#" + rename(sel[1:])
+			})
+		})
+		return m[1] + css + m[3]
+	})
+}
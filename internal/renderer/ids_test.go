@@ -0,0 +1,95 @@
+package renderer
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// randomIDSVG is an SVG as mermaid renders it, with generated ids that vary between renders.
+func randomIDSVG(marker, clip string) string {
+	return `<svg id="my-svg" viewBox="0 0 100 50" aria-labelledby="chart-title-` + marker + `">` +
+		`<title id="chart-title-` + marker + `">Flow</title>` +
+		`<style>#my-svg .edge{marker-end:url(#` + marker + `);}#` + clip + ` rect{fill:#fff;}</style>` +
+		`<defs><marker id="` + marker + `"><path d="M0,0 L10,5"/></marker><clipPath id="` + clip + `"><rect/></clipPath></defs>` +
+		`<g id="flowchart-A-0" clip-path="url(#` + clip + `)"><path marker-end="url(#` + marker + `)"/></g>` +
+		`<use href="#flowchart-A-0"/><use xlink:href="#flowchart-A-0"/><text>#flowchart-A-0-ish</text></svg>`
+}
+
+func TestNormalizeSVGIDs_Deterministic(t *testing.T) {
+	a := normalizeSVGIDs(randomIDSVG("marker-1712345", "clip-4821"))
+	b := normalizeSVGIDs(randomIDSVG("marker-9876543", "clip-1234"))
+	if a != b {
+		t.Errorf("expected renders with different generated ids to normalize the same\n%s\n%s", a, b)
+	}
+}
+
+func TestNormalizeSVGIDs_UpdatesReferences(t *testing.T) {
+	out := normalizeSVGIDs(randomIDSVG("marker-1712345", "clip-0"))
+
+	if !strings.HasPrefix(out, `<svg id="my-svg" `) || !strings.Contains(out, "#my-svg .edge") {
+		t.Errorf("expected the root id and its CSS to be kept, got %q", out)
+	}
+	for _, old := range []string{"marker-1712345", "clip-0", "flowchart-A-0"} {
+		if regexp.MustCompile(`["#]` + old + `["()\s{]`).MatchString(out) {
+			t.Errorf("expected id %q and its references to be renamed, got %q", old, out)
+		}
+	}
+
+	prefix := regexp.MustCompile(`<marker id="(my-svg-[0-9a-f]{8}-)1">`).FindStringSubmatch(out)
+	if prefix == nil {
+		t.Fatalf("expected the marker to be renamed to my-svg-<hash>-1, got %q", out)
+	}
+	p := prefix[1]
+	for _, want := range []string{
+		`aria-labelledby="` + p + `0"`,
+		`<title id="` + p + `0">`,
+		`marker-end:url(#` + p + `1);}#` + p + `2 rect{fill:#fff;}`,
+		`<clipPath id="` + p + `2">`,
+		`<g id="` + p + `3" clip-path="url(#` + p + `2)"><path marker-end="url(#` + p + `1)"/>`,
+		`<use href="#` + p + `3"/><use xlink:href="#` + p + `3"/>`,
+		`<text>#flowchart-A-0-ish</text>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in %q", want, out)
+		}
+	}
+}
+
+func TestNormalizeSVGIDs_IgnoresLookalikes(t *testing.T) {
+	// Ids that equal a color or a "#tag" in a label must only be renamed where referenced
+	svg := `<svg id="my-svg"><style>#fff .label{fill:#fff;stroke:#todo}</style>` +
+		`<g id="fff" fill="#fff"><text>#todo and #fff</text></g><g id="todo"/>` +
+		`<use href="#todo"/><path marker-end="url(#fff)"/></svg>`
+	out := normalizeSVGIDs(svg)
+
+	prefix := regexp.MustCompile(`<g id="(my-svg-[0-9a-f]{8}-)0"`).FindStringSubmatch(out)
+	if prefix == nil {
+		t.Fatalf("expected the ids to be renamed, got %q", out)
+	}
+	p := prefix[1]
+	for _, want := range []string{
+		`<style>#` + p + `0 .label{fill:#fff;stroke:#todo}</style>`,
+		`<g id="` + p + `0" fill="#fff"><text>#todo and #fff</text></g><g id="` + p + `1"/>`,
+		`<use href="#` + p + `1"/><path marker-end="url(#` + p + `0)"/>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in %q", want, out)
+		}
+	}
+}
+
+func TestNormalizeSVGIDs_ContentDerived(t *testing.T) {
+	a := normalizeSVGIDs(randomIDSVG("m1", "c1"))
+	b := normalizeSVGIDs(strings.Replace(randomIDSVG("m1", "c1"), "Flow", "Other", 1))
+	if a[:200] == b[:200] {
+		t.Errorf("expected different diagrams to get different ids, got %q", a[:200])
+	}
+}
+
+func TestNormalizeSVGIDs_NoIDs(t *testing.T) {
+	svg := `<svg viewBox="0 0 1 1"><rect/></svg>`
+	if out := normalizeSVGIDs(svg); out != svg {
+		t.Errorf("expected an SVG without ids to be unchanged, got %q", out)
+	}
+}
@@ -0,0 +1,399 @@
+//go:build integration
+
+package renderer
+
+import (
+	"bytes"
+	"context"
+	"image/color"
+	"image/png"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coolamit/mermaid-cli/internal/config"
+	"github.com/coolamit/mermaid-cli/internal/icons"
+)
+
+// newIntegrationRenderer starts a real browser, skipping the test if Chrome isn't installed.
+func newIntegrationRenderer(t *testing.T) *Renderer {
+	t.Helper()
+	b := NewBrowser(&config.BrowserConfig{})
+	r := NewRenderer(b)
+	if _, err := b.Context(context.Background()); err != nil {
+		if strings.Contains(err.Error(), "could not find Chrome") {
+			t.Skip("Chrome/Chromium not installed")
+		}
+		t.Fatalf("failed to start browser: %v", err)
+	}
+	t.Cleanup(r.Close)
+	return r
+}
+
+func renderPNGSize(t *testing.T, r *Renderer, scale float64) (int, int) {
+	t.Helper()
+	opts := defaultOpts()
+	opts.Width = 800
+	opts.Height = 600
+	opts.Scale = scale
+
+	result, err := r.Render(context.Background(), "graph TD;\n  A-->B;\n  B-->C;", "png", opts)
+	if err != nil {
+		t.Fatalf("render at scale %v failed: %v", scale, err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatalf("failed to decode PNG at scale %v: %v", scale, err)
+	}
+	return cfg.Width, cfg.Height
+}
+
+func TestIntegration_PNGScale(t *testing.T) {
+	r := newIntegrationRenderer(t)
+
+	w1, h1 := renderPNGSize(t, r, 1)
+	w2, h2 := renderPNGSize(t, r, 2)
+
+	// Allow a pixel of rounding per edge
+	if abs(w2-2*w1) > 2 || abs(h2-2*h1) > 2 {
+		t.Errorf("expected scale 2 to be ~2x scale 1 (%dx%d), got %dx%d", w1, h1, w2, h2)
+	}
+}
+
+func TestIntegration_PNGClip(t *testing.T) {
+	r := newIntegrationRenderer(t)
+
+	opts := defaultOpts()
+	opts.Scale = 2
+	opts.Clip = &Clip{X: 5, Y: 5, Width: 40, Height: 30}
+	result, err := r.Render(context.Background(), "graph TD;\n  A-->B;\n  B-->C;", "png", opts)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatalf("failed to decode PNG: %v", err)
+	}
+	if cfg.Width != 80 || cfg.Height != 60 {
+		t.Errorf("expected the 40x30 clip at scale 2 to be 80x60, got %dx%d", cfg.Width, cfg.Height)
+	}
+
+	opts.Clip = &Clip{Width: 100000, Height: 10}
+	if _, err := r.Render(context.Background(), "graph TD;\n  A-->B;", "png", opts); err == nil || !strings.Contains(err.Error(), "outside the diagram") {
+		t.Errorf("expected a clip outside the diagram to fail, got %v", err)
+	}
+}
+
+func TestIntegration_PNGWatermark(t *testing.T) {
+	r := newIntegrationRenderer(t)
+
+	opts := defaultOpts()
+	wm, err := NewWatermark(solidImage(t, 16, 16, color.NRGBA{255, 0, 0, 255}, "png"), WatermarkBottomRight, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Watermark = wm
+	result, err := r.Render(context.Background(), "graph TD;\n  A-->B;\n  B-->C;", "png", opts)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatalf("failed to decode PNG: %v", err)
+	}
+	b := img.Bounds()
+	got := color.NRGBAModel.Convert(img.At(b.Max.X-watermarkMargin-8, b.Max.Y-watermarkMargin-8)).(color.NRGBA)
+	if got != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("expected the watermark in the bottom-right corner, got %v", got)
+	}
+}
+
+func TestIntegration_DeterministicIDs(t *testing.T) {
+	r := newIntegrationRenderer(t)
+
+	opts := defaultOpts()
+	opts.DeterministicIDs = true
+	definition := "sequenceDiagram\n  Alice->>Bob: Hello\n  Bob-->>Alice: Hi"
+	first, err := r.Render(context.Background(), definition, "svg", opts)
+	if err != nil {
+		t.Fatalf("first render failed: %v", err)
+	}
+	second, err := r.Render(context.Background(), definition, "svg", opts)
+	if err != nil {
+		t.Fatalf("second render failed: %v", err)
+	}
+	if !bytes.Equal(first.Data, second.Data) {
+		t.Errorf("expected two renders of the same definition to be byte-identical\n%s\n%s", first.Data, second.Data)
+	}
+}
+
+func TestIntegration_SandboxSecurityLevel(t *testing.T) {
+	r := newIntegrationRenderer(t)
+
+	opts := defaultOpts()
+	opts.MermaidConfig = config.MermaidConfig{"theme": "default", "securityLevel": "sandbox"}
+	result, err := r.Render(context.Background(), "graph TD;\n  A[Start]-->B[End];", "svg", opts)
+	if err != nil {
+		t.Fatalf("sandboxed render failed: %v", err)
+	}
+	svg := string(result.Data)
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, "Start") {
+		t.Errorf("expected the SVG from the sandboxed iframe, got %.200q", svg)
+	}
+
+	result, err = r.Render(context.Background(), "graph TD;\n  A[Start]-->B[End];", "png", opts)
+	if err != nil {
+		t.Fatalf("sandboxed PNG render failed: %v", err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(result.Data))
+	if err != nil {
+		t.Fatalf("failed to decode PNG: %v", err)
+	}
+	if cfg.Width < 20 || cfg.Height < 20 {
+		t.Errorf("expected the PNG to capture the diagram, got %dx%d", cfg.Width, cfg.Height)
+	}
+}
+
+func TestIntegration_WaitForFunction(t *testing.T) {
+	r := newIntegrationRenderer(t)
+
+	// Stands in for a plugin that finishes drawing after the SVG appears: the first
+	// check schedules the change, and the render must wait for it
+	opts := defaultOpts()
+	opts.WaitForFunction = `(() => {
+		const svg = document.querySelector('#container svg');
+		if (!window.__mmdTestScheduled) {
+			window.__mmdTestScheduled = true;
+			setTimeout(() => svg.setAttribute('data-drawn', 'yes'), 300);
+		}
+		return svg.getAttribute('data-drawn') === 'yes';
+	})()`
+	result, err := r.Render(context.Background(), "graph TD;\n  A-->B;", "svg", opts)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if !strings.Contains(string(result.Data), `data-drawn="yes"`) {
+		t.Errorf("expected the capture to wait for --waitForFunction, got %.200q", result.Data)
+	}
+}
+
+func TestIntegration_WaitForTimesOut(t *testing.T) {
+	r := newIntegrationRenderer(t)
+
+	opts := defaultOpts()
+	opts.WaitFor = "#container .never-drawn"
+	opts.Timeout = 2 * time.Second
+	_, err := r.Render(context.Background(), "graph TD;\n  A-->B;", "svg", opts)
+	if err == nil || !strings.Contains(err.Error(), "--waitFor") {
+		t.Errorf("expected a --waitFor timeout, got %v", err)
+	}
+}
+
+func TestIntegration_ReusedTabIDs(t *testing.T) {
+	// A single tab, reused by each render in turn
+	b := NewBrowser(&config.BrowserConfig{TabPoolSize: 1})
+	r := NewRenderer(b)
+	if _, err := b.Context(context.Background()); err != nil {
+		if strings.Contains(err.Error(), "could not find Chrome") {
+			t.Skip("Chrome/Chromium not installed")
+		}
+		t.Fatalf("failed to start browser: %v", err)
+	}
+	t.Cleanup(r.Close)
+
+	rootID := regexp.MustCompile(`^<svg[^>]*\sid="([^"]*)"`)
+	opts := defaultOpts()
+	opts.DeterministicIDs = true
+	render := func(definition string) (string, string) {
+		t.Helper()
+		result, err := r.Render(context.Background(), definition, "svg", opts)
+		if err != nil {
+			t.Fatalf("render of %q failed: %v", definition, err)
+		}
+		m := rootID.FindStringSubmatch(string(result.Data))
+		if m == nil {
+			t.Fatalf("no root id in %.200q", result.Data)
+		}
+		return string(result.Data), m[1]
+	}
+
+	first, firstID := render("graph TD;\n  A-->B;")
+	second, secondID := render("graph TD;\n  C-->D;")
+	if firstID == secondID {
+		t.Errorf("expected different diagrams to get different ids, both got %q", firstID)
+	}
+	if strings.Contains(second, firstID) {
+		t.Errorf("expected nothing of the first render (%s) in the second, got %.300q", firstID, second)
+	}
+	if again, _ := render("graph TD;\n  A-->B;"); again != first {
+		t.Errorf("expected re-rendering in the reused tab to give the same SVG\n%s\n%s", first, again)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func TestIntegration_ConsoleErrorOnFailedIconFetch(t *testing.T) {
+	r := newIntegrationRenderer(t)
+
+	opts := defaultOpts()
+	opts.IconPacks = []icons.IconPack{{Name: "broken", URL: "http://127.0.0.1:1/icons.json"}}
+	definition := "architecture-beta\n  service db(broken:database)[Database]"
+
+	// The render may succeed with a placeholder icon or fail; either way the fetch
+	// failure must be reported from the browser console.
+	var output string
+	result, err := r.Render(context.Background(), definition, "svg", opts)
+	if err != nil {
+		output = err.Error()
+	} else {
+		output = strings.Join(result.Console, "\n")
+	}
+	if !strings.Contains(output, "Failed to fetch icon: broken") {
+		t.Errorf("expected the failed icon fetch in console output, got %q", output)
+	}
+}
+
+func TestIntegration_IconPackFailureWarning(t *testing.T) {
+	r := newIntegrationRenderer(t)
+
+	opts := defaultOpts()
+	opts.IconPacks = []icons.IconPack{{Name: "broken", URL: "http://127.0.0.1:1/icons.json"}}
+	definition := "architecture-beta\n  service db(broken:database)[Database]"
+
+	result, err := r.Render(context.Background(), definition, "svg", opts)
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "broken") {
+		t.Errorf("expected a warning naming the broken pack, got %q", result.Warnings)
+	}
+
+	opts.StrictIcons = true
+	if _, err := r.Render(context.Background(), definition, "svg", opts); err == nil || !strings.Contains(err.Error(), "failed to load icon packs: broken") {
+		t.Errorf("expected --strictIcons to fail the render, got %v", err)
+	}
+}
+
+func TestIntegration_SettleDelay(t *testing.T) {
+	r := newIntegrationRenderer(t)
+
+	opts := defaultOpts()
+	opts.SettleDelay = 300 * time.Millisecond
+
+	start := time.Now()
+	if _, err := r.Render(context.Background(), "graph TD;\n  A-->B;", "svg", opts); err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < opts.SettleDelay {
+		t.Errorf("expected render to take at least the settle delay %s, took %s", opts.SettleDelay, elapsed)
+	}
+}
+
+func renderPDF(t *testing.T, r *Renderer, scale float64) []byte {
+	t.Helper()
+	opts := defaultOpts()
+	opts.Width = 800
+	opts.Height = 600
+	opts.Scale = scale
+	opts.PdfFit = true
+	// A drop shadow is rasterized when printing, so its resolution follows the device scale
+	opts.CSS = ".node rect { filter: drop-shadow(2px 2px 2px #888); }"
+
+	result, err := r.Render(context.Background(), "graph TD;\n  A-->B;\n  B-->C;", "pdf", opts)
+	if err != nil {
+		t.Fatalf("render at scale %v failed: %v", scale, err)
+	}
+	if !bytes.HasPrefix(result.Data, []byte("%PDF-")) {
+		t.Fatalf("expected a PDF at scale %v", scale)
+	}
+	return result.Data
+}
+
+func TestIntegration_PDFScale(t *testing.T) {
+	r := newIntegrationRenderer(t)
+
+	pdf1 := renderPDF(t, r, 1)
+	pdf2 := renderPDF(t, r, 2)
+	t.Logf("PDF size at scale 1: %d bytes, at scale 2: %d bytes", len(pdf1), len(pdf2))
+
+	// Rough signal: rasterized parts carry more pixels at a higher scale
+	if len(pdf2) < len(pdf1) {
+		t.Errorf("expected the scale 2 PDF (%d bytes) to be at least as large as scale 1 (%d bytes)", len(pdf2), len(pdf1))
+	}
+
+	// The paper size comes from CSS pixels and must not change with the scale
+	mediaBox := regexp.MustCompile(`/MediaBox\s*\[[^\]]*\]`)
+	if box1, box2 := mediaBox.Find(pdf1), mediaBox.Find(pdf2); !bytes.Equal(box1, box2) {
+		t.Errorf("expected the same page size at both scales, got %s and %s", box1, box2)
+	}
+}
+
+func TestIntegration_PDFDPI(t *testing.T) {
+	r := newIntegrationRenderer(t)
+
+	// The page width is the last-but-one number in "/MediaBox [0 0 w h]"
+	mediaBox := regexp.MustCompile(`/MediaBox\s*\[\s*[\d.]+\s+[\d.]+\s+([\d.]+)\s+([\d.]+)\s*\]`)
+	pageWidth := func(dpi int) float64 {
+		opts := defaultOpts()
+		opts.PdfFit = true
+		opts.DPI = dpi
+		result, err := r.Render(context.Background(), "graph TD;\n  A-->B;", "pdf", opts)
+		if err != nil {
+			t.Fatalf("render at %d DPI failed: %v", dpi, err)
+		}
+		m := mediaBox.FindSubmatch(result.Data)
+		if m == nil {
+			t.Fatalf("no MediaBox in the PDF at %d DPI", dpi)
+		}
+		w, err := strconv.ParseFloat(string(m[1]), 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return w
+	}
+
+	// Doubling the DPI halves the paper size; allow a point for rounding
+	w96, w192 := pageWidth(96), pageWidth(192)
+	if math.Abs(w96/2-w192) > 1 {
+		t.Errorf("expected the 192 DPI page to be half as wide as at 96 DPI, got %v and %v points", w192, w96)
+	}
+}
+
+func TestIntegration_Timings(t *testing.T) {
+	r := newIntegrationRenderer(t)
+
+	start := time.Now()
+	result, err := r.Render(context.Background(), "graph TD; A-->B", "png", defaultOpts())
+	if err != nil {
+		t.Fatalf("render failed: %v", err)
+	}
+	elapsed := time.Since(start)
+
+	// Phases run one after another, so each is measured and together they fit in the render
+	tm := result.Timings
+	phases := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"page build", tm.PageBuild},
+		{"navigate", tm.Navigate},
+		{"render wait", tm.RenderWait},
+		{"capture", tm.Capture},
+	}
+	for _, p := range phases {
+		if p.d <= 0 {
+			t.Errorf("%s took %s, expected it to be measured", p.name, p.d)
+		}
+	}
+	if tm.Total() > elapsed {
+		t.Errorf("phases total %s, longer than the whole render (%s)", tm.Total(), elapsed)
+	}
+}
@@ -0,0 +1,116 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// errBrowserClosed is returned for tabs requested from a browser that was closed, e.g.
+// because it crashed or the renderer shut down, after the request started.
+var errBrowserClosed = errors.New("browser closed")
+
+// DefaultTabPoolSize is the number of tabs a Browser keeps when BrowserConfig.TabPoolSize is unset.
+const DefaultTabPoolSize = 4
+
+// tab is a reusable browser tab.
+type tab struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// tabPool hands out at most size tabs at a time. Tabs are created lazily, reset when
+// released and reused by later renders; tabs that fail to reset are discarded.
+type tabPool struct {
+	slots  chan struct{}
+	newTab func() (*tab, error)
+	reset  func(*tab) error
+
+	mu     sync.Mutex
+	idle   []*tab
+	open   int
+	closed bool
+}
+
+// newTabPool creates a pool of up to size tabs using newTab to open and reset to recycle them.
+func newTabPool(size int, newTab func() (*tab, error), reset func(*tab) error) *tabPool {
+	if size < 1 {
+		size = 1
+	}
+	return &tabPool{
+		slots:  make(chan struct{}, size),
+		newTab: newTab,
+		reset:  reset,
+	}
+}
+
+// acquire returns an idle tab or opens a new one, blocking while all tabs are in use.
+// Once the pool is closed it returns errBrowserClosed.
+func (p *tabPool) acquire(ctx context.Context) (*tab, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		<-p.slots
+		return nil, errBrowserClosed
+	}
+	if n := len(p.idle); n > 0 {
+		t := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+		return t, nil
+	}
+	p.open++
+	p.mu.Unlock()
+
+	t, err := p.newTab()
+	if err != nil {
+		p.mu.Lock()
+		p.open--
+		p.mu.Unlock()
+		<-p.slots
+		return nil, err
+	}
+	return t, nil
+}
+
+// release resets a tab and returns it to the pool, discarding it if the reset fails
+// or the pool has been closed.
+func (p *tabPool) release(t *tab) {
+	defer func() { <-p.slots }()
+
+	healthy := p.reset(t) == nil
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if healthy && !p.closed {
+		p.idle = append(p.idle, t)
+		return
+	}
+	p.open--
+	t.cancel()
+}
+
+// close closes all idle tabs. Tabs still in use are closed when released.
+func (p *tabPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, t := range p.idle {
+		t.cancel()
+	}
+	p.open -= len(p.idle)
+	p.idle = nil
+	p.closed = true
+}
+
+// stats returns the number of open tabs and how many of them are idle.
+func (p *tabPool) stats() (open, idle int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.open, len(p.idle)
+}
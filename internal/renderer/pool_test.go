@@ -0,0 +1,158 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeTabs opens fake tabs and records how many are open at once.
+type fakeTabs struct {
+	opened   atomic.Int32
+	inUse    atomic.Int32
+	maxInUse atomic.Int32
+	resetErr error
+}
+
+func (f *fakeTabs) newTab() (*tab, error) {
+	f.opened.Add(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	return &tab{ctx: ctx, cancel: cancel}, nil
+}
+
+func (f *fakeTabs) reset(*tab) error {
+	return f.resetErr
+}
+
+func (f *fakeTabs) use() {
+	n := f.inUse.Add(1)
+	for {
+		peak := f.maxInUse.Load()
+		if n <= peak || f.maxInUse.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	f.inUse.Add(-1)
+}
+
+func TestTabPoolLimitsConcurrency(t *testing.T) {
+	const size = 3
+	f := &fakeTabs{}
+	pool := newTabPool(size, f.newTab, f.reset)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tb, err := pool.acquire(context.Background())
+			if err != nil {
+				t.Errorf("acquire: %v", err)
+				return
+			}
+			f.use()
+			pool.release(tb)
+		}()
+	}
+	wg.Wait()
+
+	if got := f.maxInUse.Load(); got > size {
+		t.Errorf("max concurrent tabs = %d, want at most %d", got, size)
+	}
+	if got := f.opened.Load(); got > size {
+		t.Errorf("opened %d tabs, want at most %d", got, size)
+	}
+	open, idle := pool.stats()
+	if open != idle || open == 0 || open > size {
+		t.Errorf("stats() = (%d open, %d idle), want all open tabs back in the pool", open, idle)
+	}
+}
+
+func TestTabPoolReusesReleasedTabs(t *testing.T) {
+	f := &fakeTabs{}
+	pool := newTabPool(2, f.newTab, f.reset)
+
+	first, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.release(first)
+
+	second, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second != first {
+		t.Error("expected the released tab to be reused")
+	}
+	if got := f.opened.Load(); got != 1 {
+		t.Errorf("opened %d tabs, want 1", got)
+	}
+}
+
+func TestTabPoolDiscardsTabsThatFailToReset(t *testing.T) {
+	f := &fakeTabs{resetErr: errors.New("navigation failed")}
+	pool := newTabPool(2, f.newTab, f.reset)
+
+	tb, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.release(tb)
+
+	if tb.ctx.Err() == nil {
+		t.Error("expected the tab to be closed")
+	}
+	if open, idle := pool.stats(); open != 0 || idle != 0 {
+		t.Errorf("stats() = (%d, %d), want (0, 0)", open, idle)
+	}
+}
+
+func TestTabPoolAcquireHonorsContext(t *testing.T) {
+	f := &fakeTabs{}
+	pool := newTabPool(1, f.newTab, f.reset)
+
+	held, err := pool.acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.release(held)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("acquire() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestTabPoolClose(t *testing.T) {
+	f := &fakeTabs{}
+	pool := newTabPool(2, f.newTab, f.reset)
+
+	idle, _ := pool.acquire(context.Background())
+	busy, _ := pool.acquire(context.Background())
+	pool.release(idle)
+
+	pool.close()
+	if idle.ctx.Err() == nil {
+		t.Error("expected idle tab to be closed")
+	}
+	if busy.ctx.Err() != nil {
+		t.Error("expected in-use tab to stay open until released")
+	}
+
+	pool.release(busy)
+	if busy.ctx.Err() == nil {
+		t.Error("expected tab released after close to be closed")
+	}
+	if open, _ := pool.stats(); open != 0 {
+		t.Errorf("open = %d, want 0", open)
+	}
+	if _, err := pool.acquire(context.Background()); !errors.Is(err, errBrowserClosed) {
+		t.Errorf("acquire() after close error = %v, want errBrowserClosed", err)
+	}
+}
@@ -0,0 +1,175 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"sort"
+)
+
+// MaxPNGColors is the largest palette a paletted PNG can hold.
+const MaxPNGColors = 256
+
+// ValidatePNGColors checks a --pngColors value: 0 (off) or a palette size from 2 to 256.
+func ValidatePNGColors(n int) error {
+	if n != 0 && (n < 2 || n > MaxPNGColors) {
+		return fmt.Errorf("invalid --pngColors %d, must be between 2 and %d", n, MaxPNGColors)
+	}
+	return nil
+}
+
+// optimizePNG applies the PNG post-processing requested in opts to a captured screenshot.
+// Without --pngColors the capture is returned as is.
+func optimizePNG(data []byte, opts RenderOpts) ([]byte, error) {
+	if opts.PNGColors <= 0 {
+		return data, nil
+	}
+	return quantizePNG(data, opts.PNGColors)
+}
+
+// quantizePNG reduces a PNG to a palette of at most n colors, chosen by median cut, and
+// re-encodes it as a paletted PNG with the best compression.
+func quantizePNG(data []byte, n int) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PNG for quantization: %w", err)
+	}
+
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&buf, palettedImage(img, n)); err != nil {
+		return nil, fmt.Errorf("failed to encode quantized PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// palettedImage converts img to a paletted image with at most n colors, chosen by median cut.
+func palettedImage(img image.Image, n int) *image.Paletted {
+	bounds := img.Bounds()
+	counts := make(map[color.NRGBA]int)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			counts[color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)]++
+		}
+	}
+
+	palette := medianCut(counts, n)
+
+	// Map each distinct color once; antialiased edges repeat the same few shades a lot
+	index := make(map[color.NRGBA]uint8, len(counts))
+	for c := range counts {
+		index[c] = uint8(palette.Index(c))
+	}
+
+	out := image.NewPaletted(bounds, palette)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			out.SetColorIndex(x, y, index[color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)])
+		}
+	}
+	return out
+}
+
+// colorCount is a distinct color and the number of pixels that have it.
+type colorCount struct {
+	c [4]uint8
+	n int
+}
+
+// medianCut picks a palette of at most n colors for the given color histogram. Images
+// with n colors or fewer keep exactly their own colors.
+func medianCut(counts map[color.NRGBA]int, n int) color.Palette {
+	colors := make([]colorCount, 0, len(counts))
+	for c, k := range counts {
+		colors = append(colors, colorCount{c: [4]uint8{c.R, c.G, c.B, c.A}, n: k})
+	}
+	// Map iteration order is random; sort so the palette is deterministic
+	sort.Slice(colors, func(i, j int) bool {
+		a, b := colors[i].c, colors[j].c
+		for ch := range a {
+			if a[ch] != b[ch] {
+				return a[ch] < b[ch]
+			}
+		}
+		return false
+	})
+
+	boxes := [][]colorCount{colors}
+	for len(boxes) < n {
+		// Split the box with the widest channel range
+		best, bestChannel, bestSpan := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if ch, span := widestChannel(box); span > bestSpan {
+				best, bestChannel, bestSpan = i, ch, span
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		box := boxes[best]
+		sort.SliceStable(box, func(i, j int) bool { return box[i].c[bestChannel] < box[j].c[bestChannel] })
+		split := medianIndex(box)
+		boxes = append(boxes, box[split:])
+		boxes[best] = box[:split]
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		palette = append(palette, averageColor(box))
+	}
+	return palette
+}
+
+// widestChannel returns the RGBA channel with the largest value range in box, and the range.
+func widestChannel(box []colorCount) (channel, span int) {
+	for ch := 0; ch < 4; ch++ {
+		lo, hi := box[0].c[ch], box[0].c[ch]
+		for _, cc := range box[1:] {
+			lo, hi = min(lo, cc.c[ch]), max(hi, cc.c[ch])
+		}
+		if s := int(hi) - int(lo); s > span {
+			channel, span = ch, s
+		}
+	}
+	return channel, span
+}
+
+// medianIndex returns where to split a sorted box so each half covers about as many
+// pixels, keeping at least one color on either side.
+func medianIndex(box []colorCount) int {
+	total := 0
+	for _, cc := range box {
+		total += cc.n
+	}
+	seen := 0
+	for i, cc := range box[:len(box)-1] {
+		seen += cc.n
+		if seen*2 >= total {
+			return i + 1
+		}
+	}
+	return len(box) - 1
+}
+
+// averageColor returns the pixel-weighted average color of box.
+func averageColor(box []colorCount) color.NRGBA {
+	var sum [4]int
+	total := 0
+	for _, cc := range box {
+		for ch := range sum {
+			sum[ch] += int(cc.c[ch]) * cc.n
+		}
+		total += cc.n
+	}
+	var avg [4]uint8
+	for ch := range sum {
+		avg[ch] = uint8((sum[ch] + total/2) / total)
+	}
+	return color.NRGBA{R: avg[0], G: avg[1], B: avg[2], A: avg[3]}
+}
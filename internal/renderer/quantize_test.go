@@ -0,0 +1,123 @@
+package renderer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// gradientPNG encodes a w x h image in which every pixel has a different color.
+func gradientPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: uint8((x + y) % 256), A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// distinctColors decodes a PNG and returns its set of colors.
+func distinctColors(t *testing.T, data []byte) map[color.NRGBA]bool {
+	t.Helper()
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to decode PNG: %v", err)
+	}
+	colors := map[color.NRGBA]bool{}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			colors[color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)] = true
+		}
+	}
+	return colors
+}
+
+func TestQuantizePNG(t *testing.T) {
+	src := gradientPNG(t, 64, 64)
+	if n := len(distinctColors(t, src)); n < 1000 {
+		t.Fatalf("synthetic image has only %d colors", n)
+	}
+
+	for _, n := range []int{2, 8, 16, 256} {
+		out, err := quantizePNG(src, n)
+		if err != nil {
+			t.Fatalf("quantizePNG(%d): unexpected error: %v", n, err)
+		}
+		if got := len(distinctColors(t, out)); got > n {
+			t.Errorf("quantizePNG(%d): output has %d colors", n, got)
+		}
+
+		img, err := png.Decode(bytes.NewReader(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := img.(*image.Paletted); !ok {
+			t.Errorf("quantizePNG(%d): expected a paletted PNG, got %T", n, img)
+		}
+		if img.Bounds() != image.Rect(0, 0, 64, 64) {
+			t.Errorf("quantizePNG(%d): bounds = %v", n, img.Bounds())
+		}
+	}
+}
+
+func TestQuantizePNG_KeepsFewColorsExactly(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 1))
+	want := []color.NRGBA{{255, 255, 255, 255}, {0, 0, 0, 255}, {200, 10, 10, 255}, {0, 0, 0, 0}}
+	for x, c := range want {
+		img.SetNRGBA(x, 0, c)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := quantizePNG(buf.Bytes(), 16)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := distinctColors(t, out)
+	for _, c := range want {
+		if !got[c] {
+			t.Errorf("expected color %v to be kept, got %v", c, got)
+		}
+	}
+}
+
+func TestQuantizePNG_InvalidData(t *testing.T) {
+	if _, err := quantizePNG([]byte("not a png"), 8); err == nil {
+		t.Error("expected an error for invalid PNG data")
+	}
+}
+
+func TestOptimizePNG_NoOpWithoutColors(t *testing.T) {
+	src := gradientPNG(t, 8, 8)
+	out, err := optimizePNG(src, RenderOpts{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(out, src) {
+		t.Error("expected the PNG to be returned unchanged without --pngColors")
+	}
+}
+
+func TestValidatePNGColors(t *testing.T) {
+	for _, n := range []int{0, 2, 16, 256} {
+		if err := ValidatePNGColors(n); err != nil {
+			t.Errorf("ValidatePNGColors(%d): unexpected error: %v", n, err)
+		}
+	}
+	for _, n := range []int{-1, 1, 257} {
+		if err := ValidatePNGColors(n); err == nil {
+			t.Errorf("ValidatePNGColors(%d): expected an error", n)
+		}
+	}
+}
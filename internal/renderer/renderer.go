@@ -3,57 +3,131 @@ package renderer
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/chromedp/cdproto/cdp"
 	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/log"
 	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
 	"github.com/chromedp/chromedp"
 )
 
+// DefaultRenderTimeout is the per-render timeout used when RenderOpts.Timeout is unset.
+const DefaultRenderTimeout = 60 * time.Second
+
+// ErrBrowserStart wraps errors from launching the browser or opening a tab, as opposed
+// to errors rendering the diagram itself.
+var ErrBrowserStart = errors.New("failed to start browser")
+
 // RenderResult contains the output of rendering a mermaid diagram.
 type RenderResult struct {
 	Data  []byte
 	Title string
 	Desc  string
+	// Console holds the messages the page logged while rendering, e.g. "[error] Failed to fetch icon: logos"
+	Console []string
+	// Warnings holds problems that didn't stop the render, e.g. icon packs that failed to load
+	Warnings []string
+	// Timings holds how long each phase of the render took; zero for results that
+	// weren't rendered, such as cache hits
+	Timings Timings
+}
+
+// Timings are the durations of the phases of a render, in the order they run.
+type Timings struct {
+	// PageBuild is building the HTML page around the definition
+	PageBuild time.Duration `json:"pageBuild"`
+	// Navigate is setting the viewport and loading the page into the tab
+	Navigate time.Duration `json:"navigate"`
+	// RenderWait is waiting for mermaid to render and for images and fonts to load
+	RenderWait time.Duration `json:"renderWait"`
+	// Capture is extracting the SVG or taking the screenshot or PDF
+	Capture time.Duration `json:"capture"`
+}
+
+// Total returns the time spent in all phases.
+func (t Timings) Total() time.Duration {
+	return t.PageBuild + t.Navigate + t.RenderWait + t.Capture
+}
+
+// String formats the timings for logging, e.g.
+// "page build 4ms, navigate 35ms, render 310ms, capture 22ms".
+func (t Timings) String() string {
+	r := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+	return fmt.Sprintf("page build %s, navigate %s, render %s, capture %s", r(t.PageBuild), r(t.Navigate), r(t.RenderWait), r(t.Capture))
 }
 
 // Renderer handles mermaid diagram rendering via chromedp.
 type Renderer struct {
-	browser *Browser
+	browser TabSource
 }
 
-// NewRenderer creates a new Renderer with the given browser.
-func NewRenderer(browser *Browser) *Renderer {
+// NewRenderer creates a new Renderer with the given browser or pool of browsers.
+func NewRenderer(browser TabSource) *Renderer {
 	return &Renderer{browser: browser}
 }
 
 // Render renders a mermaid diagram to the specified output format.
 func (r *Renderer) Render(ctx context.Context, definition string, outputFormat string, opts RenderOpts) (*RenderResult, error) {
-	browserCtx, err := r.browser.Context(ctx)
+	// Borrow a tab from the pool; it is reset and returned even if the render fails or panics
+	tabCtx, releaseTab, err := r.browser.AcquireTab(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to start browser: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrBrowserStart, err)
 	}
-
-	// Create a new tab
-	tabCtx, tabCancel := chromedp.NewContext(browserCtx)
-	defer tabCancel()
+	defer releaseTab()
 
 	// Set timeout
-	tabCtx, timeoutCancel := context.WithTimeout(tabCtx, 60*time.Second)
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultRenderTimeout
+	}
+	tabCtx, timeoutCancel := context.WithTimeout(tabCtx, timeout)
 	defer timeoutCancel()
 
+	// Capture console output for --verbose and to explain failures; the listener
+	// is removed when the timeout context is cancelled.
+	console := &consoleLog{}
+	console.listen(tabCtx)
+
+	result, err := render(tabCtx, definition, outputFormat, opts, timeout)
+	if err != nil {
+		return nil, console.annotate(err)
+	}
+	result.Console = console.lines()
+	return result, nil
+}
+
+// render renders a diagram in a tab that has already been set up by Render.
+func render(tabCtx context.Context, definition string, outputFormat string, opts RenderOpts, timeout time.Duration) (*RenderResult, error) {
+	// Enable the log domain so failed resource loads are reported alongside console messages
+	if err := chromedp.Run(tabCtx, log.Enable()); err != nil {
+		return nil, fmt.Errorf("failed to enable browser log: %w", err)
+	}
+
+	var timings Timings
+	phase := time.Now()
+
 	// Build the HTML page
 	pageHTML, err := BuildPageHTML(definition, opts)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build page HTML: %w", err)
 	}
+	timings.PageBuild, phase = time.Since(phase), time.Now()
 
-	// Set viewport
+	// Set viewport; unset dimensions default to a size suited to the diagram type
+	width, height := ViewportSize(definition, opts)
+	scale := opts.Scale
+	if outputFormat == "pdf" {
+		scale = pdfDeviceScale(opts)
+	}
 	if err := chromedp.Run(tabCtx,
-		emulation.SetDeviceMetricsOverride(int64(opts.Width), int64(opts.Height), float64(opts.Scale), false),
+		deviceMetrics(int64(width), int64(height), scale),
 	); err != nil {
 		return nil, fmt.Errorf("failed to set viewport: %w", err)
 	}
@@ -76,16 +150,24 @@ func (r *Renderer) Render(ctx context.Context, definition string, outputFormat s
 	})); err != nil {
 		return nil, fmt.Errorf("failed to set page content: %w", err)
 	}
-
-	// Wait for rendering to complete
-	if err := chromedp.Run(tabCtx,
-		chromedp.WaitReady("#container svg", chromedp.ByQuery),
-	); err != nil {
+	timings.Navigate, phase = time.Since(phase), time.Now()
+
+	// Wait for rendering to complete. A sandboxed diagram is in an iframe, which
+	// selectors don't reach into, so the page is polled for it instead, until the
+	// render timeout rather than the poll's own.
+	var wait chromedp.Action = chromedp.WaitReady("#container svg", chromedp.ByQuery)
+	if opts.MermaidConfig.Sandboxed() {
+		wait = chromedp.Poll(findSVGJS+" !== null", nil, chromedp.WithPollingTimeout(0))
+	}
+	if err := chromedp.Run(tabCtx, wait); err != nil {
 		// Check if there was a render error
 		var resultJSON string
 		_ = chromedp.Run(tabCtx,
 			chromedp.Evaluate(`JSON.stringify(window.__mmd_result || {})`, &resultJSON),
 		)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("mermaid rendering timed out after %s: %w\nrender result: %s", timeout, err, resultJSON)
+		}
 		return nil, fmt.Errorf("mermaid rendering failed (waited for SVG): %w\nrender result: %s", err, resultJSON)
 	}
 
@@ -110,6 +192,41 @@ func (r *Renderer) Render(ctx context.Context, definition string, outputFormat s
 		return nil, fmt.Errorf("mermaid rendering error: %s", renderResult.Error)
 	}
 
+	if err := waitForReady(tabCtx, opts, timeout); err != nil {
+		return nil, err
+	}
+
+	// An icon pack that failed to load leaves its icons blank, so report it
+	var warnings []string
+	if len(opts.IconPacks) > 0 {
+		failed, err := readIconErrors(tabCtx)
+		if err != nil {
+			return nil, err
+		}
+		if len(failed) > 0 {
+			if opts.StrictIcons {
+				return nil, errors.New(iconErrorsMessage(failed))
+			}
+			warnings = append(warnings, iconErrorsMessage(failed))
+		}
+	}
+
+	if err := waitForAssets(tabCtx, opts.SettleDelay); err != nil {
+		return nil, err
+	}
+
+	// Fit the viewport to the diagram; PNG, JPEG and PDF captures measure the SVG
+	// at this size, and SVG output gets it as its width and height.
+	var autoWidth, autoHeight int64
+	if opts.AutoSize {
+		autoWidth, autoHeight, err = applyAutoSize(tabCtx, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	timings.RenderWait, phase = time.Since(phase), time.Now()
+
 	result := &RenderResult{}
 	if renderResult.Title != nil {
 		result.Title = *renderResult.Title
@@ -130,13 +247,64 @@ func (r *Renderer) Render(ctx context.Context, definition string, outputFormat s
 		if err != nil {
 			return nil, err
 		}
-		result.Data = data
+		if opts.Sanitize {
+			data = []byte(sanitizeSVG(string(data)))
+		}
+		if opts.CleanSVG {
+			data = []byte(cleanSVG(string(data), opts.CleanSVGAttrs))
+		}
+		if opts.DeterministicIDs {
+			data = []byte(normalizeSVGIDs(string(data)))
+		}
+		if opts.Watermark != nil {
+			svg, err := watermarkSVG(string(data), opts.Watermark)
+			if err != nil {
+				return nil, err
+			}
+			data = []byte(svg)
+		}
+		switch {
+		case opts.AutoSize:
+			width, height := autoSizeSVGDimensions(opts, autoWidth, autoHeight)
+			data = []byte(setSVGDimensions(string(data), width, height))
+		case opts.SVGWidth != "" || opts.SVGHeight != "":
+			data = []byte(setSVGDimensions(string(data), opts.SVGWidth, opts.SVGHeight))
+		}
+		if opts.SVGDecl {
+			data = []byte(addXMLDeclaration(string(data)))
+		}
+		svg, err := applySVGMode(string(data), opts.SVGMode)
+		if err != nil {
+			return nil, err
+		}
+		result.Data = []byte(svg)
 
 	case "png":
-		data, err := capturePNG(tabCtx, opts)
+		data, err := captureScreenshot(tabCtx, opts, page.CaptureScreenshotFormatPng)
 		if err != nil {
 			return nil, err
 		}
+		if opts.Watermark != nil {
+			if data, err = watermarkRaster(data, outputFormat, opts.Watermark, opts.Scale); err != nil {
+				return nil, err
+			}
+		}
+		data, err = optimizePNG(data, opts)
+		if err != nil {
+			return nil, err
+		}
+		result.Data = data
+
+	case "jpeg":
+		data, err := captureScreenshot(tabCtx, opts, page.CaptureScreenshotFormatJpeg)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Watermark != nil {
+			if data, err = watermarkRaster(data, outputFormat, opts.Watermark, opts.Scale); err != nil {
+				return nil, err
+			}
+		}
 		result.Data = data
 
 	case "pdf":
@@ -150,9 +318,98 @@ func (r *Renderer) Render(ctx context.Context, definition string, outputFormat s
 		return nil, fmt.Errorf("unsupported output format: %s", outputFormat)
 	}
 
+	timings.Capture = time.Since(phase)
+	result.Timings = timings
+	result.Warnings = warnings
+
+	if err := checkOutputSize(len(result.Data), opts.MaxOutputBytes); err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
 
+// findSVGJS evaluates to the rendered <svg> element, or null. It is in #container, or
+// with securityLevel sandbox in the document of the iframe there.
+const findSVGJS = `((frame) => frame ? frame.contentDocument && frame.contentDocument.querySelector('svg') : document.querySelector('#container svg'))(document.querySelector('#container iframe'))`
+
+// assetLoadTimeout bounds how long to wait for web fonts and <image> elements in the SVG.
+const assetLoadTimeout = 5 * time.Second
+
+// waitForAssetsJS resolves once document.fonts is ready and every <image> in the SVG
+// has loaded or failed, or after the timeout (in ms) passed as its argument. SVG images
+// have no load state of their own, so each href is loaded through an Image object,
+// which is served from the cache if the SVG already fetched it.
+const waitForAssetsJS = `((timeoutMs) => {
+  const svg = ` + findSVGJS + `;
+  const images = [...(svg ? svg.querySelectorAll('image') : [])].map((img) => {
+    const href = img.href ? img.href.baseVal : img.getAttribute('xlink:href');
+    if (!href) return Promise.resolve();
+    return new Promise((resolve) => {
+      const probe = new Image();
+      probe.onload = probe.onerror = resolve;
+      probe.src = href;
+    });
+  });
+  const loaded = Promise.all([document.fonts.ready, ...images]);
+  const timeout = new Promise((resolve) => setTimeout(resolve, timeoutMs));
+  return Promise.race([loaded, timeout]).then(() => true);
+})(%d)`
+
+// waitForAssets waits for fonts and images used by the rendered SVG to load, then for
+// settleDelay, so captures don't show fallback fonts or blank icons.
+func waitForAssets(ctx context.Context, settleDelay time.Duration) error {
+	var done bool
+	if err := chromedp.Run(ctx,
+		chromedp.Evaluate(fmt.Sprintf(waitForAssetsJS, assetLoadTimeout.Milliseconds()), &done, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+			return p.WithAwaitPromise(true)
+		}),
+	); err != nil {
+		return fmt.Errorf("failed waiting for fonts and images to load: %w", err)
+	}
+
+	if settleDelay > 0 {
+		if err := chromedp.Run(ctx, chromedp.Sleep(settleDelay)); err != nil {
+			return fmt.Errorf("failed waiting for the settle delay: %w", err)
+		}
+	}
+	return nil
+}
+
+// waitForReady waits for the custom readiness conditions in opts, for diagrams such as
+// those of external plugins that keep drawing after the SVG appears: the WaitFor
+// selector to match an element, then the WaitForFunction expression to be truthy. Both
+// wait until the render timeout.
+func waitForReady(ctx context.Context, opts RenderOpts, timeout time.Duration) error {
+	if opts.WaitFor != "" {
+		if err := chromedp.Run(ctx, chromedp.WaitReady(opts.WaitFor, chromedp.ByQuery)); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return fmt.Errorf("timed out after %s waiting for --waitFor %q: %w", timeout, opts.WaitFor, err)
+			}
+			return fmt.Errorf("failed waiting for --waitFor %q: %w", opts.WaitFor, err)
+		}
+	}
+	if opts.WaitForFunction != "" {
+		if err := chromedp.Run(ctx, chromedp.Poll(opts.WaitForFunction, nil, chromedp.WithPollingTimeout(0))); err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return fmt.Errorf("timed out after %s waiting for --waitForFunction %q: %w", timeout, opts.WaitForFunction, err)
+			}
+			return fmt.Errorf("failed waiting for --waitForFunction %q: %w", opts.WaitForFunction, err)
+		}
+	}
+	return nil
+}
+
+// TabStats returns the number of open browser tabs and how many of them are rendering.
+func (r *Renderer) TabStats() (open, active int) {
+	return r.browser.TabStats()
+}
+
+// Shutdown waits for in-flight renders to finish, up to ctx's deadline, then closes the browser.
+func (r *Renderer) Shutdown(ctx context.Context) error {
+	return r.browser.Shutdown(ctx)
+}
+
 // Close closes the browser.
 func (r *Renderer) Close() {
 	r.browser.Close()
@@ -163,7 +420,7 @@ func extractSVG(ctx context.Context) ([]byte, error) {
 	var svgXML string
 	err := chromedp.Run(ctx,
 		chromedp.Evaluate(`(() => {
-			const svg = document.querySelector('#container svg');
+			const svg = `+findSVGJS+`;
 			if (!svg) return '';
 			const serializer = new XMLSerializer();
 			return serializer.serializeToString(svg);
@@ -183,7 +440,7 @@ func extractSVGFit(ctx context.Context) ([]byte, error) {
 	var svgXML string
 	err := chromedp.Run(ctx,
 		chromedp.Evaluate(`(() => {
-			const svg = document.querySelector('#container svg');
+			const svg = `+findSVGJS+`;
 			if (!svg) return '';
 			const viewBox = svg.getAttribute('viewBox');
 			if (viewBox) {
@@ -220,12 +477,15 @@ func getSVGBounds(ctx context.Context) (*clipRect, error) {
 	var boundsJSON string
 	err := chromedp.Run(ctx,
 		chromedp.Evaluate(`(() => {
-			const svg = document.querySelector('#container svg');
+			const svg = `+findSVGJS+`;
 			if (!svg) return JSON.stringify({x:0, y:0, width:800, height:600});
 			const rect = svg.getBoundingClientRect();
+			// In a sandboxed iframe the rect is relative to the iframe
+			const frame = svg.ownerDocument.defaultView.frameElement;
+			const offset = frame ? frame.getBoundingClientRect() : {left: 0, top: 0};
 			return JSON.stringify({
-				x: Math.floor(rect.left),
-				y: Math.floor(rect.top),
+				x: Math.floor(offset.left + rect.left),
+				y: Math.floor(offset.top + rect.top),
 				width: Math.ceil(rect.width),
 				height: Math.ceil(rect.height)
 			});
@@ -242,45 +502,110 @@ func getSVGBounds(ctx context.Context) (*clipRect, error) {
 	return &bounds, nil
 }
 
-// capturePNG captures a PNG screenshot clipped to the SVG bounds.
-func capturePNG(ctx context.Context, opts RenderOpts) ([]byte, error) {
-	bounds, err := getSVGBounds(ctx)
+// captureGeometry describes the viewport and clip used to capture a screenshot.
+type captureGeometry struct {
+	ViewportWidth  int64
+	ViewportHeight int64
+	DeviceScale    float64
+	Clip           *page.Viewport
+}
+
+// deviceMetrics emulates a viewport of width x height CSS pixels at the given device
+// scale factor, e.g. 1.5 for a retina-style export. A scale of 0 or less means 1.
+func deviceMetrics(width, height int64, scale float64) *emulation.SetDeviceMetricsOverrideParams {
+	if scale <= 0 {
+		scale = 1
+	}
+	return emulation.SetDeviceMetricsOverride(width, height, scale, false)
+}
+
+// computeCaptureGeometry sizes the viewport to fit the SVG bounds (in CSS pixels) and
+// applies the scale factor through the clip. Chrome multiplies the clip scale by the
+// device scale factor, so the device scale is reset to 1 for the capture to apply the
+// scale exactly once: the image is bounds.Width*scale by bounds.Height*scale pixels.
+func computeCaptureGeometry(bounds *clipRect, scale float64) captureGeometry {
+	if scale <= 0 {
+		scale = 1
+	}
+	return captureGeometry{
+		ViewportWidth:  int64(math.Ceil(bounds.X + bounds.Width)),
+		ViewportHeight: int64(math.Ceil(bounds.Y + bounds.Height)),
+		DeviceScale:    1,
+		Clip: &page.Viewport{
+			X:      bounds.X,
+			Y:      bounds.Y,
+			Width:  bounds.Width,
+			Height: bounds.Height,
+			Scale:  scale,
+		},
+	}
+}
+
+// outputSize returns the pixel dimensions of the image produced by the geometry.
+func (g captureGeometry) outputSize() (width, height int64) {
+	factor := g.Clip.Scale * g.DeviceScale
+	return int64(math.Round(g.Clip.Width * factor)), int64(math.Round(g.Clip.Height * factor))
+}
+
+// maxCaptureDimension is the largest image side, in pixels, captured when an output size limit is set.
+const maxCaptureDimension = 16384
+
+// checkCaptureDimensions rejects captures whose image would exceed maxCaptureDimension on either side,
+// before Chrome allocates the screenshot.
+func checkCaptureDimensions(geom captureGeometry) error {
+	w, h := geom.outputSize()
+	if w > maxCaptureDimension || h > maxCaptureDimension {
+		return fmt.Errorf("rendered image would be %dx%d pixels, exceeding the %dpx limit", w, h, maxCaptureDimension)
+	}
+	return nil
+}
+
+// checkOutputSize returns an error if size exceeds maxBytes. A maxBytes of 0 disables the check.
+func checkOutputSize(size int, maxBytes int64) error {
+	if maxBytes > 0 && int64(size) > maxBytes {
+		return fmt.Errorf("rendered output is %d bytes, exceeding the maximum output size of %d bytes", size, maxBytes)
+	}
+	return nil
+}
+
+// captureScreenshot captures a PNG or JPEG screenshot clipped to the SVG bounds, or to
+// opts.Clip within them.
+func captureScreenshot(ctx context.Context, opts RenderOpts, format page.CaptureScreenshotFormat) ([]byte, error) {
+	bounds, err := captureBounds(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 
 	// Resize viewport to fit the SVG
-	newWidth := int64(bounds.X + bounds.Width)
-	newHeight := int64(bounds.Y + bounds.Height)
+	geom := computeCaptureGeometry(bounds, opts.Scale)
+	if opts.MaxOutputBytes > 0 {
+		if err := checkCaptureDimensions(geom); err != nil {
+			return nil, err
+		}
+	}
 	if err := chromedp.Run(ctx,
-		emulation.SetDeviceMetricsOverride(newWidth, newHeight, float64(opts.Scale), false),
+		emulation.SetDeviceMetricsOverride(geom.ViewportWidth, geom.ViewportHeight, geom.DeviceScale, false),
 	); err != nil {
-		return nil, fmt.Errorf("failed to resize viewport for PNG: %w", err)
+		return nil, fmt.Errorf("failed to resize viewport for %s: %w", format, err)
 	}
 
 	// Small delay to let the resize settle
 	time.Sleep(100 * time.Millisecond)
 
-	clip := &page.Viewport{
-		X:      bounds.X,
-		Y:      bounds.Y,
-		Width:  bounds.Width,
-		Height: bounds.Height,
-		Scale:  1,
-	}
+	clip := geom.Clip
 
-	// Set transparent background if requested
-	if opts.BackgroundColor == "transparent" {
-		if err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
-			return emulation.SetDefaultBackgroundColorOverride().WithColor(&cdp.RGBA{R: 0, G: 0, B: 0, A: 0}).Do(ctx)
-		})); err != nil {
-			return nil, fmt.Errorf("failed to set transparent background: %w", err)
+	// Backgrounds with any alpha need a transparent page (see setTransparentPage). JPEG
+	// has no alpha channel, so it keeps the page's default white background.
+	transparentPage := format != page.CaptureScreenshotFormatJpeg && needsTransparentPage(opts.BackgroundColor)
+	if transparentPage {
+		if err := setTransparentPage(ctx); err != nil {
+			return nil, err
 		}
 	}
 
 	var buf []byte
 	captureParams := page.CaptureScreenshot().
-		WithFormat(page.CaptureScreenshotFormatPng).
+		WithFormat(format).
 		WithClip(clip).
 		WithCaptureBeyondViewport(true)
 
@@ -289,50 +614,154 @@ func capturePNG(ctx context.Context, opts RenderOpts) ([]byte, error) {
 		buf, err = captureParams.Do(ctx)
 		return err
 	})); err != nil {
-		return nil, fmt.Errorf("failed to capture PNG: %w", err)
+		return nil, fmt.Errorf("failed to capture %s: %w", format, err)
 	}
 
-	// Reset background color override
-	if opts.BackgroundColor == "transparent" {
-		_ = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
-			return emulation.SetDefaultBackgroundColorOverride().Do(ctx)
-		}))
+	if transparentPage {
+		resetPageBackground(ctx)
 	}
 
 	return buf, nil
 }
 
+// pageRangesRegex matches Chrome page ranges such as "1-3,5", "2-" or "-4".
+var pageRangesRegex = regexp.MustCompile(`^\s*(?:\d+|\d+-\d*|-\d+)(?:\s*,\s*(?:\d+|\d+-\d*|-\d+))*\s*$`)
+
+// ValidatePageRanges checks that s is a comma-separated list of pages or page ranges, e.g. "1-3,5".
+func ValidatePageRanges(s string) error {
+	if !pageRangesRegex.MatchString(s) {
+		return fmt.Errorf("invalid page ranges %q, expected pages or ranges like \"1-3,5\"", s)
+	}
+	return nil
+}
+
+// pdfPageRanges returns the page ranges to print. An explicit PageRanges wins; otherwise
+// PdfFit forces a single page and the default lets Chrome emit every page.
+func pdfPageRanges(opts RenderOpts) string {
+	if opts.PageRanges != "" {
+		return strings.ReplaceAll(opts.PageRanges, " ", "")
+	}
+	if opts.PdfFit {
+		return "1-1"
+	}
+	return ""
+}
+
+// DefaultDPI is the resolution CSS pixels map to: 96 per inch.
+const DefaultDPI = 96
+
+// Chrome prints at a scale between 0.1 and 2, which bounds the DPI a PDF can use.
+const (
+	MinDPI = DefaultDPI / 2
+	MaxDPI = DefaultDPI * 10
+)
+
+// ValidateDPI checks a --dpi value: 0 (the default of 96) or from MinDPI to MaxDPI.
+func ValidateDPI(dpi int) error {
+	if dpi != 0 && (dpi < MinDPI || dpi > MaxDPI) {
+		return fmt.Errorf("invalid --dpi %d, must be between %d and %d", dpi, MinDPI, MaxDPI)
+	}
+	return nil
+}
+
+// pdfDPI returns the DPI a PDF is printed at, defaulting to DefaultDPI.
+func pdfDPI(opts RenderOpts) float64 {
+	if opts.DPI <= 0 {
+		return DefaultDPI
+	}
+	return float64(opts.DPI)
+}
+
+// pdfPrintScale returns the factor Chrome prints the page at so that one CSS pixel is
+// one dot at the PDF's DPI, e.g. 0.32 at 300 DPI.
+func pdfPrintScale(opts RenderOpts) float64 {
+	return DefaultDPI / pdfDPI(opts)
+}
+
+// pdfDeviceScale returns the device scale factor for PDF output. Printing at a higher
+// DPI shrinks the page, so the parts Chrome rasterizes into the PDF are rendered at
+// proportionally more pixels to keep their resolution, on top of --scale.
+func pdfDeviceScale(opts RenderOpts) float64 {
+	scale := opts.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+	return scale * pdfDPI(opts) / DefaultDPI
+}
+
+// pdfPaperSize returns the paper size in inches that fits bounds, keeping its offset as
+// a margin on both sides. Bounds are in CSS pixels, which don't depend on the device
+// scale factor; each is one dot at the PDF's DPI.
+func pdfPaperSize(bounds *clipRect, opts RenderOpts) (width, height float64) {
+	dpi := pdfDPI(opts)
+	width = (math.Ceil(bounds.Width) + bounds.X*2) / dpi
+	height = (math.Ceil(bounds.Height) + bounds.Y*2) / dpi
+	return width, height
+}
+
+// setTransparentPage makes the page's default background transparent, for backgrounds
+// with any alpha. The SVG's own background style carries the actual color, so the page
+// underneath must be fully transparent to avoid compositing the color twice.
+func setTransparentPage(ctx context.Context) error {
+	if err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		return emulation.SetDefaultBackgroundColorOverride().WithColor(&cdp.RGBA{R: 0, G: 0, B: 0, A: 0}).Do(ctx)
+	})); err != nil {
+		return fmt.Errorf("failed to set transparent background: %w", err)
+	}
+	return nil
+}
+
+// resetPageBackground restores the page's default background after setTransparentPage.
+func resetPageBackground(ctx context.Context) {
+	_ = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		return emulation.SetDefaultBackgroundColorOverride().Do(ctx)
+	}))
+}
+
 // capturePDF captures a PDF of the page.
 func capturePDF(ctx context.Context, opts RenderOpts) ([]byte, error) {
-	// Set transparent background if requested
-	if opts.BackgroundColor == "transparent" {
-		if err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
-			return emulation.SetDefaultBackgroundColorOverride().WithColor(&cdp.RGBA{R: 0, G: 0, B: 0, A: 0}).Do(ctx)
-		})); err != nil {
-			return nil, fmt.Errorf("failed to set transparent background: %w", err)
+	// Backgrounds with any alpha need a transparent page (see setTransparentPage)
+	transparentPage := needsTransparentPage(opts.BackgroundColor)
+	if transparentPage {
+		if err := setTransparentPage(ctx); err != nil {
+			return nil, err
 		}
 	}
 
+	// The device metrics set in render stay in effect while printing, so with --scale
+	// and --dpi the parts Chrome rasterizes into the PDF (images, filters, shadows) get
+	// that pixel density. Vector content is unaffected.
 	printParams := page.PrintToPDF()
 
 	if opts.PdfFit {
-		bounds, err := getSVGBounds(ctx)
+		bounds, err := captureBounds(ctx, opts)
 		if err != nil {
 			return nil, err
 		}
+		if opts.Clip != nil {
+			// Print just the clip region: move it to the page origin and drop the margin
+			if err := shiftToOrigin(ctx, bounds.X, bounds.Y); err != nil {
+				return nil, err
+			}
+			bounds = &clipRect{Width: bounds.Width, Height: bounds.Height}
+		}
 
-		// Convert px to inches (96 DPI)
-		widthInches := (math.Ceil(bounds.Width) + bounds.X*2) / 96.0
-		heightInches := (math.Ceil(bounds.Height) + bounds.Y*2) / 96.0
-
+		widthInches, heightInches := pdfPaperSize(bounds, opts)
 		printParams = printParams.
 			WithPaperWidth(widthInches).
 			WithPaperHeight(heightInches).
 			WithMarginTop(0).
 			WithMarginBottom(0).
 			WithMarginLeft(0).
-			WithMarginRight(0).
-			WithPageRanges("1-1")
+			WithMarginRight(0)
+	}
+
+	if ranges := pdfPageRanges(opts); ranges != "" {
+		printParams = printParams.WithPageRanges(ranges)
+	}
+
+	if opts.DPI > 0 && opts.DPI != DefaultDPI {
+		printParams = printParams.WithScale(pdfPrintScale(opts))
 	}
 
 	printParams = printParams.WithPrintBackground(true)
@@ -346,11 +775,8 @@ func capturePDF(ctx context.Context, opts RenderOpts) ([]byte, error) {
 		return nil, fmt.Errorf("failed to generate PDF: %w", err)
 	}
 
-	// Reset background color override
-	if opts.BackgroundColor == "transparent" {
-		_ = chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
-			return emulation.SetDefaultBackgroundColorOverride().Do(ctx)
-		}))
+	if transparentPage {
+		resetPageBackground(ctx)
 	}
 
 	return buf, nil
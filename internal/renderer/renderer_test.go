@@ -0,0 +1,222 @@
+package renderer
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestComputeCaptureGeometry_ScaleDoublesOutput(t *testing.T) {
+	bounds := &clipRect{X: 8, Y: 8, Width: 300, Height: 150}
+
+	w1, h1 := computeCaptureGeometry(bounds, 1).outputSize()
+	w2, h2 := computeCaptureGeometry(bounds, 2).outputSize()
+
+	if w1 != 300 || h1 != 150 {
+		t.Errorf("expected 300x150 at scale 1, got %dx%d", w1, h1)
+	}
+	if w2 != 2*w1 || h2 != 2*h1 {
+		t.Errorf("expected scale 2 to double output to %dx%d, got %dx%d", 2*w1, 2*h1, w2, h2)
+	}
+}
+
+func TestComputeCaptureGeometry_Viewport(t *testing.T) {
+	bounds := &clipRect{X: 8, Y: 4, Width: 300.5, Height: 150}
+	geom := computeCaptureGeometry(bounds, 3)
+
+	// The logical viewport must contain the whole diagram regardless of scale
+	if geom.ViewportWidth != 309 || geom.ViewportHeight != 154 {
+		t.Errorf("expected viewport 309x154, got %dx%d", geom.ViewportWidth, geom.ViewportHeight)
+	}
+	// Scale is applied once, through the clip
+	if geom.DeviceScale != 1 || geom.Clip.Scale != 3 {
+		t.Errorf("expected device scale 1 and clip scale 3, got %v and %v", geom.DeviceScale, geom.Clip.Scale)
+	}
+	if geom.Clip.X != 8 || geom.Clip.Y != 4 {
+		t.Errorf("expected clip origin (8,4), got (%v,%v)", geom.Clip.X, geom.Clip.Y)
+	}
+}
+
+func TestComputeCaptureGeometry_FractionalScale(t *testing.T) {
+	geom := computeCaptureGeometry(&clipRect{X: 8, Y: 8, Width: 300, Height: 151}, 1.5)
+
+	if geom.Clip.Scale != 1.5 {
+		t.Errorf("expected clip scale 1.5, got %v", geom.Clip.Scale)
+	}
+	if w, h := geom.outputSize(); w != 450 || h != 227 {
+		t.Errorf("expected 450x227 at scale 1.5, got %dx%d", w, h)
+	}
+}
+
+func TestDeviceMetrics(t *testing.T) {
+	tests := []struct {
+		scale float64
+		want  float64
+	}{
+		{1.5, 1.5},
+		{2, 2},
+		{2.5, 2.5},
+		{0, 1},
+		{-1, 1},
+	}
+	for _, tt := range tests {
+		params := deviceMetrics(800, 600, tt.scale)
+		if params.DeviceScaleFactor != tt.want || params.Width != 800 || params.Height != 600 {
+			t.Errorf("deviceMetrics(800, 600, %v) = %+v, want device scale %v", tt.scale, params, tt.want)
+		}
+	}
+}
+
+func TestPDFPaperSize(t *testing.T) {
+	// 8px margins around a 464.5x184px diagram: (465+16)/96 x (184+16)/96 inches
+	w, h := pdfPaperSize(&clipRect{X: 8, Y: 8, Width: 464.5, Height: 184}, RenderOpts{})
+	if math.Abs(w-481.0/96) > 1e-9 || math.Abs(h-200.0/96) > 1e-9 {
+		t.Errorf("expected %.4fx%.4f in, got %.4fx%.4f", 481.0/96, 200.0/96, w, h)
+	}
+}
+
+func TestPDFPaperSize_DPI(t *testing.T) {
+	// A 900x600px diagram without margins is 3x2 inches at 300 DPI
+	bounds := &clipRect{Width: 900, Height: 600}
+	tests := []struct {
+		dpi  int
+		w, h float64
+	}{
+		{0, 900.0 / 96, 600.0 / 96},
+		{96, 900.0 / 96, 600.0 / 96},
+		{150, 6, 4},
+		{300, 3, 2},
+	}
+	for _, tt := range tests {
+		w, h := pdfPaperSize(bounds, RenderOpts{DPI: tt.dpi})
+		if math.Abs(w-tt.w) > 1e-9 || math.Abs(h-tt.h) > 1e-9 {
+			t.Errorf("dpi %d: expected %.4fx%.4f in, got %.4fx%.4f", tt.dpi, tt.w, tt.h, w, h)
+		}
+	}
+}
+
+func TestPDFDPIScales(t *testing.T) {
+	tests := []struct {
+		opts        RenderOpts
+		printScale  float64
+		deviceScale float64
+	}{
+		{RenderOpts{}, 1, 1},
+		{RenderOpts{DPI: 96, Scale: 2}, 1, 2},
+		{RenderOpts{DPI: 192}, 0.5, 2},
+		{RenderOpts{DPI: 300}, 0.32, 3.125},
+		{RenderOpts{DPI: 300, Scale: 2}, 0.32, 6.25},
+	}
+	for _, tt := range tests {
+		if got := pdfPrintScale(tt.opts); math.Abs(got-tt.printScale) > 1e-9 {
+			t.Errorf("pdfPrintScale(%+v) = %v, want %v", tt.opts, got, tt.printScale)
+		}
+		if got := pdfDeviceScale(tt.opts); math.Abs(got-tt.deviceScale) > 1e-9 {
+			t.Errorf("pdfDeviceScale(%+v) = %v, want %v", tt.opts, got, tt.deviceScale)
+		}
+	}
+}
+
+func TestValidateDPI(t *testing.T) {
+	for _, dpi := range []int{0, MinDPI, 96, 300, MaxDPI} {
+		if err := ValidateDPI(dpi); err != nil {
+			t.Errorf("ValidateDPI(%d): unexpected error: %v", dpi, err)
+		}
+	}
+	for _, dpi := range []int{-1, MinDPI - 1, MaxDPI + 1} {
+		if err := ValidateDPI(dpi); err == nil {
+			t.Errorf("ValidateDPI(%d): expected an error", dpi)
+		}
+	}
+}
+
+func TestComputeCaptureGeometry_InvalidScale(t *testing.T) {
+	geom := computeCaptureGeometry(&clipRect{Width: 100, Height: 100}, 0)
+	if geom.Clip.Scale != 1 {
+		t.Errorf("expected scale to default to 1, got %v", geom.Clip.Scale)
+	}
+}
+
+func TestCheckOutputSize(t *testing.T) {
+	if err := checkOutputSize(1000, 0); err != nil {
+		t.Errorf("expected no limit when maxBytes is 0, got %v", err)
+	}
+	if err := checkOutputSize(1000, 1000); err != nil {
+		t.Errorf("expected size equal to limit to pass, got %v", err)
+	}
+	if err := checkOutputSize(1001, 1000); err == nil {
+		t.Error("expected error when size exceeds limit")
+	}
+}
+
+func TestCheckCaptureDimensions(t *testing.T) {
+	ok := computeCaptureGeometry(&clipRect{Width: 8000, Height: 600}, 2)
+	if err := checkCaptureDimensions(ok); err != nil {
+		t.Errorf("expected 16000px wide capture to pass, got %v", err)
+	}
+
+	tooWide := computeCaptureGeometry(&clipRect{Width: 9000, Height: 600}, 2)
+	if err := checkCaptureDimensions(tooWide); err == nil {
+		t.Error("expected error for 18000px wide capture")
+	}
+
+	tooTall := computeCaptureGeometry(&clipRect{Width: 100, Height: 20000}, 1)
+	if err := checkCaptureDimensions(tooTall); err == nil {
+		t.Error("expected error for 20000px tall capture")
+	}
+}
+
+func TestPdfPageRanges(t *testing.T) {
+	tests := []struct {
+		name string
+		opts RenderOpts
+		want string
+	}{
+		{"default lets Chrome paginate", RenderOpts{}, ""},
+		{"pdfFit forces single page", RenderOpts{PdfFit: true}, "1-1"},
+		{"explicit ranges", RenderOpts{PageRanges: "1-3,5"}, "1-3,5"},
+		{"explicit ranges override pdfFit", RenderOpts{PdfFit: true, PageRanges: "2-4"}, "2-4"},
+		{"spaces are stripped", RenderOpts{PageRanges: " 1-2, 4 "}, "1-2,4"},
+	}
+	for _, tt := range tests {
+		if tt.opts.PageRanges != "" {
+			if err := ValidatePageRanges(tt.opts.PageRanges); err != nil {
+				t.Fatalf("%s: test ranges must be valid: %v", tt.name, err)
+			}
+		}
+		if got := pdfPageRanges(tt.opts); got != tt.want {
+			t.Errorf("%s: pdfPageRanges() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidatePageRanges(t *testing.T) {
+	for _, v := range []string{"1", "1-3", "1-3,5", "2-", "-4", "1, 3-4"} {
+		if err := ValidatePageRanges(v); err != nil {
+			t.Errorf("expected %q to be valid, got %v", v, err)
+		}
+	}
+	for _, v := range []string{"", "a", "1-3;5", "1,,2", "--3", "1-2-3"} {
+		if err := ValidatePageRanges(v); err == nil {
+			t.Errorf("expected %q to be invalid", v)
+		}
+	}
+}
+
+func TestTimings(t *testing.T) {
+	tm := Timings{
+		PageBuild:  4 * time.Millisecond,
+		Navigate:   35 * time.Millisecond,
+		RenderWait: 310*time.Millisecond + 400*time.Microsecond,
+		Capture:    22 * time.Millisecond,
+	}
+	if got, want := tm.Total(), 371*time.Millisecond+400*time.Microsecond; got != want {
+		t.Errorf("Total() = %s, want %s", got, want)
+	}
+	if got, want := tm.String(), "page build 4ms, navigate 35ms, render 310ms, capture 22ms"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if (Timings{}).Total() != 0 {
+		t.Error("expected zero timings to total 0")
+	}
+}
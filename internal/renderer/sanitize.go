@@ -0,0 +1,90 @@
+package renderer
+
+import (
+	"regexp"
+	"strings"
+)
+
+// startTagRegex matches an element start tag. XMLSerializer escapes "<" and ">" in
+// attribute values and text, so they only delimit tags in serialized SVG.
+var startTagRegex = regexp.MustCompile(`<([A-Za-z][\w:.-]*)((?:\s[^<>]*?)?)(/?)>`)
+
+// tagAttrRegex matches one attribute inside a start tag.
+var tagAttrRegex = regexp.MustCompile(`([^\s=/]+)\s*=\s*("[^"]*"|'[^']*')`)
+
+// scriptElementRegex matches a <script> element, with or without a namespace prefix.
+var scriptElementRegex = regexp.MustCompile(`(?is)<(?:[\w.-]+:)?script\b[^>]*/>|<(?:[\w.-]+:)?script\b[^>]*>.*?</(?:[\w.-]+:)?script\s*>`)
+
+// sanitizeSVG neutralizes scripts in serialized SVG so it can be embedded in untrusted
+// contexts: it removes <script> elements, inline event handlers (onclick, onload, ...) and
+// attributes holding javascript: or vbscript: URLs, such as links and <set>/<animate> values.
+func sanitizeSVG(svgXML string) string {
+	svgXML = scriptElementRegex.ReplaceAllString(svgXML, "")
+	return rewriteStartTags(svgXML, unsafeAttr)
+}
+
+// cleanSVG strips attributes that only add weight or carry scripts from serialized SVG:
+// inline event handlers, script URLs, empty class and style attributes, and any attribute
+// named in extra (e.g. aria-roledescription). Geometry and text content are left as is.
+func cleanSVG(svgXML string, extra []string) string {
+	remove := make(map[string]bool, len(extra))
+	for _, name := range extra {
+		remove[strings.ToLower(name)] = true
+	}
+
+	return rewriteStartTags(svgXML, func(name, value string) bool {
+		lower := strings.ToLower(name)
+		switch {
+		case remove[lower], unsafeAttr(name, value):
+			return true
+		case lower == "class" || lower == "style":
+			return strings.TrimSpace(value) == ""
+		}
+		return false
+	})
+}
+
+// rewriteStartTags rewrites every start tag without the attributes drop reports, with
+// single spaces between the remaining ones.
+func rewriteStartTags(svgXML string, drop func(name, value string) bool) string {
+	return startTagRegex.ReplaceAllStringFunc(svgXML, func(tag string) string {
+		m := startTagRegex.FindStringSubmatch(tag)
+		name, attrs, selfClosing := m[1], m[2], m[3]
+
+		var b strings.Builder
+		b.WriteString("<" + name)
+		for _, attr := range tagAttrRegex.FindAllStringSubmatch(attrs, -1) {
+			if drop(attr[1], attr[2][1:len(attr[2])-1]) {
+				continue
+			}
+			b.WriteString(" " + attr[1] + "=" + attr[2])
+		}
+		b.WriteString(selfClosing + ">")
+		return b.String()
+	})
+}
+
+// unsafeAttr reports whether an attribute can run script: an event handler, or a value
+// that is a script URL.
+func unsafeAttr(name, value string) bool {
+	lower := strings.ToLower(name)
+	if strings.HasPrefix(lower, "on") && len(lower) > 2 {
+		return true
+	}
+	return isScriptURL(value)
+}
+
+// isScriptURL reports whether value is a javascript: or vbscript: URL. Like browsers, it
+// ignores whitespace and control characters, which can be used to disguise the scheme,
+// and the numeric character references for them.
+func isScriptURL(value string) bool {
+	value = strings.NewReplacer("&#9;", "", "&#x9;", "", "&#10;", "", "&#xA;", "", "&#xa;", "", "&#13;", "", "&#xD;", "", "&#xd;", "").Replace(value)
+	var b strings.Builder
+	for _, r := range value {
+		if r > ' ' && r != 0x7f {
+			b.WriteRune(r)
+		}
+	}
+	scheme := strings.ToLower(b.String())
+	return strings.HasPrefix(scheme, "javascript:") || strings.HasPrefix(scheme, "vbscript:")
+}
@@ -0,0 +1,119 @@
+package renderer
+
+import (
+	"strings"
+	"testing"
+)
+
+const dirtySVG = `<svg xmlns="http://www.w3.org/2000/svg" id="my-svg" width="100%" class="" aria-roledescription="flowchart-v2" onload="alert(1)" viewBox="0 0 218.5 174">` +
+	`<style>#my-svg .node > rect { fill: #eee; }</style>` +
+	`<g class="node" style="" transform="translate(10, 20)" onclick="callback()">` +
+	`<rect x="-5" y="-5" width="10" height="10" rx="2" ONMOUSEOVER="x()"/>` +
+	`<path d="M0,0 L10,10" stroke-width="2" class="flowchart-link"/>` +
+	`<a xlink:href="javascript:alert(1)"><text x="1" y="2">A &gt; B</text></a>` +
+	`<a href="https://example.com"><text>link</text></a>` +
+	`</g></svg>`
+
+func TestCleanSVG_RemovesTargetedAttributes(t *testing.T) {
+	out := cleanSVG(dirtySVG, nil)
+
+	for _, unwanted := range []string{"onload=", "onclick=", "ONMOUSEOVER=", `class=""`, `style=""`, "javascript:"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("expected %q to be removed, got %q", unwanted, out)
+		}
+	}
+	// Not in the default set
+	if !strings.Contains(out, `aria-roledescription="flowchart-v2"`) {
+		t.Errorf("expected aria-roledescription to be kept by default, got %q", out)
+	}
+}
+
+func TestCleanSVG_PreservesGeometryAndContent(t *testing.T) {
+	out := cleanSVG(dirtySVG, []string{"aria-roledescription"})
+
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" id="my-svg" width="100%" viewBox="0 0 218.5 174">`,
+		`<g class="node" transform="translate(10, 20)">`,
+		`<rect x="-5" y="-5" width="10" height="10" rx="2"/>`,
+		`<path d="M0,0 L10,10" stroke-width="2" class="flowchart-link"/>`,
+		`<style>#my-svg .node > rect { fill: #eee; }</style>`,
+		`<text x="1" y="2">A &gt; B</text>`,
+		`<a href="https://example.com">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got %q", want, out)
+		}
+	}
+}
+
+func TestCleanSVG_ExtraAttributes(t *testing.T) {
+	out := cleanSVG(dirtySVG, []string{"Aria-RoleDescription"})
+	if strings.Contains(out, "aria-roledescription") {
+		t.Errorf("expected extra attributes to be matched case-insensitively, got %q", out)
+	}
+}
+
+func TestCleanSVG_CleanInputUnchanged(t *testing.T) {
+	if out := cleanSVG(sampleSVG, nil); out != sampleSVG {
+		t.Errorf("expected clean SVG to be unchanged, got %q", out)
+	}
+}
+
+const scriptedSVG = `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 100 50" onload="steal()">` +
+	`<script>alert(document.cookie)</script>` +
+	`<script type="text/javascript" src="https://evil.example/x.js"/>` +
+	`<svg:script xmlns:svg="http://www.w3.org/2000/svg">alert(2)</svg:script>` +
+	`<a xlink:href="javascript:alert(1)"><text x="5" y="20">click</text></a>` +
+	`<a href=" JaVa&#x9;Script:alert(3)"><rect width="10" height="10" onmouseover="x()"/></a>` +
+	`<set attributeName="href" to="javascript:alert(4)"/>` +
+	`<a href="https://example.com/docs"><text class="label">docs</text></a>` +
+	`</svg>`
+
+func TestSanitizeSVG_RemovesScripts(t *testing.T) {
+	out := sanitizeSVG(scriptedSVG)
+
+	for _, unwanted := range []string{"<script", "</script>", "svg:script", "alert(", "onload=", "onmouseover=", "javascript:", "JaVa"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("expected %q to be removed, got %q", unwanted, out)
+		}
+	}
+}
+
+func TestSanitizeSVG_KeepsSafeContent(t *testing.T) {
+	out := sanitizeSVG(scriptedSVG)
+
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 100 50">`,
+		`<text x="5" y="20">click</text>`,
+		`<rect width="10" height="10"/>`,
+		`<a href="https://example.com/docs"><text class="label">docs</text></a>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got %q", want, out)
+		}
+	}
+	// Empty attributes are cleanSVG's business, not a security concern
+	if in := `<g class=""><rect width="1"/></g>`; sanitizeSVG(in) != in {
+		t.Errorf("expected sanitizing to leave %q unchanged, got %q", in, sanitizeSVG(in))
+	}
+}
+
+func TestIsScriptURL(t *testing.T) {
+	tests := map[string]bool{
+		"javascript:alert(1)":    true,
+		" JAVASCRIPT:alert(1)":   true,
+		"java\tscript:alert(1)":  true,
+		"java&#10;script:alert":  true,
+		"vbscript:msgbox":        true,
+		"https://example.com":    false,
+		"#flowchart-A-0":         false,
+		"javascript-guide.html":  false,
+		"docs/javascript:intro":  false,
+		"data:image/png;base64,": false,
+	}
+	for value, want := range tests {
+		if got := isScriptURL(value); got != want {
+			t.Errorf("isScriptURL(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package renderer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// svgLengthRegex matches an SVG length with an optional absolute or relative unit, e.g. 300, 300px, 80mm, 10cm.
+var svgLengthRegex = regexp.MustCompile(`^\d+(?:\.\d+)?(?:px|mm|cm|in|pt|pc|em|ex|%)?$`)
+
+// svgRootTagRegex matches the opening tag of the root <svg> element.
+var svgRootTagRegex = regexp.MustCompile(`<svg\b[^>]*>`)
+
+// xmlDeclaration is prepended to SVG output by addXMLDeclaration.
+const xmlDeclaration = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+
+// svgNamespace is the namespace of SVG elements.
+const svgNamespace = "http://www.w3.org/2000/svg"
+
+// xlinkNamespace is the namespace of the xlink:href attributes mermaid uses for icons and links.
+const xlinkNamespace = "http://www.w3.org/1999/xlink"
+
+// xmlnsAttrRegex matches a default namespace declaration (but not a prefixed one like xmlns:xlink).
+var xmlnsAttrRegex = regexp.MustCompile(`\sxmlns\s*=`)
+
+// xlinkNSAttrRegex matches an xmlns:xlink namespace declaration.
+var xlinkNSAttrRegex = regexp.MustCompile(`\sxmlns:xlink\s*=`)
+
+// namespaceAttrRegex matches the xmlns and xmlns:xlink declarations, with their values.
+var namespaceAttrRegex = regexp.MustCompile(`\s+xmlns(?::xlink)?\s*=\s*(?:"[^"]*"|'[^']*')`)
+
+// xmlDeclarationRegex matches a leading XML declaration and the whitespace after it.
+var xmlDeclarationRegex = regexp.MustCompile(`^\x{feff}?\s*<\?xml\b[^>]*\?>\s*`)
+
+// SVG modes: standalone makes SVG output a self-contained XML document, inline strips
+// what an HTML5 page doesn't need, for embedding with innerHTML.
+const (
+	SVGModeStandalone = "standalone"
+	SVGModeInline     = "inline"
+)
+
+// ValidateSVGMode checks that mode is empty or one of the SVG modes.
+func ValidateSVGMode(mode string) error {
+	switch mode {
+	case "", SVGModeStandalone, SVGModeInline:
+		return nil
+	}
+	return fmt.Errorf("invalid SVG mode %q, must be %q or %q", mode, SVGModeStandalone, SVGModeInline)
+}
+
+// maxWidthStyleRegex matches a max-width declaration inside a style attribute.
+var maxWidthStyleRegex = regexp.MustCompile(`max-width:\s*[^;"]*;?\s*`)
+
+// ValidateSVGLength checks that s is a valid SVG length such as "300px", "80mm" or "10cm".
+func ValidateSVGLength(s string) error {
+	if !svgLengthRegex.MatchString(s) {
+		return fmt.Errorf("invalid SVG length %q, expected a number with an optional unit (px, mm, cm, in, pt, pc, em, ex, %%)", s)
+	}
+	return nil
+}
+
+// setSVGDimensions overrides the width and/or height attributes on the root <svg> element
+// and removes any max-width style so the explicit size is honored. Empty values are left untouched.
+func setSVGDimensions(svgXML string, width, height string) string {
+	if width == "" && height == "" {
+		return svgXML
+	}
+
+	loc := svgRootTagRegex.FindStringIndex(svgXML)
+	if loc == nil {
+		return svgXML
+	}
+
+	tag := svgXML[loc[0]:loc[1]]
+	if width != "" {
+		tag = setAttr(tag, "width", width)
+	}
+	if height != "" {
+		tag = setAttr(tag, "height", height)
+	}
+	tag = removeMaxWidthStyle(tag)
+
+	return svgXML[:loc[0]] + tag + svgXML[loc[1]:]
+}
+
+// addXMLDeclaration turns a serialized <svg> element into a standalone XML document: it
+// prepends the XML declaration and declares the SVG namespace on the root element, unless
+// either is already there.
+func addXMLDeclaration(svgXML string) string {
+	if loc := svgRootTagRegex.FindStringIndex(svgXML); loc != nil {
+		tag := svgXML[loc[0]:loc[1]]
+		if !xmlnsAttrRegex.MatchString(tag) {
+			svgXML = svgXML[:loc[0]] + setAttr(tag, "xmlns", svgNamespace) + svgXML[loc[1]:]
+		}
+	}
+	if strings.HasPrefix(strings.TrimLeft(svgXML, "\ufeff \t\r\n"), "<?xml") {
+		return svgXML
+	}
+	return xmlDeclaration + svgXML
+}
+
+// applySVGMode post-processes SVG output for the given mode. An empty mode leaves it as is.
+func applySVGMode(svgXML, mode string) (string, error) {
+	switch mode {
+	case "":
+		return svgXML, nil
+	case SVGModeStandalone:
+		return standaloneSVG(svgXML), nil
+	case SVGModeInline:
+		return inlineSVG(svgXML), nil
+	}
+	return "", ValidateSVGMode(mode)
+}
+
+// standaloneSVG makes SVG output a standalone XML document: it adds the XML declaration
+// and declares the SVG and xlink namespaces on the root element, unless already there.
+func standaloneSVG(svgXML string) string {
+	svgXML = addXMLDeclaration(svgXML)
+	if loc := svgRootTagRegex.FindStringIndex(svgXML); loc != nil {
+		tag := svgXML[loc[0]:loc[1]]
+		if !xlinkNSAttrRegex.MatchString(tag) {
+			svgXML = svgXML[:loc[0]] + setAttr(tag, "xmlns:xlink", xlinkNamespace) + svgXML[loc[1]:]
+		}
+	}
+	return svgXML
+}
+
+// inlineSVG makes SVG output an HTML5 fragment: it drops the XML declaration and the
+// xmlns and xmlns:xlink declarations on the root element, which the HTML parser supplies.
+func inlineSVG(svgXML string) string {
+	svgXML = xmlDeclarationRegex.ReplaceAllString(svgXML, "")
+	if loc := svgRootTagRegex.FindStringIndex(svgXML); loc != nil {
+		tag := namespaceAttrRegex.ReplaceAllString(svgXML[loc[0]:loc[1]], "")
+		svgXML = svgXML[:loc[0]] + tag + svgXML[loc[1]:]
+	}
+	return svgXML
+}
+
+// setAttr sets (or adds) an attribute on a single start tag.
+func setAttr(tag, name, value string) string {
+	attrRegex := regexp.MustCompile(`\s` + regexp.QuoteMeta(name) + `="[^"]*"`)
+	replacement := fmt.Sprintf(` %s="%s"`, name, value)
+	if attrRegex.MatchString(tag) {
+		return attrRegex.ReplaceAllLiteralString(tag, replacement)
+	}
+
+	end := len(tag) - 1
+	if strings.HasSuffix(tag, "/>") {
+		end = len(tag) - 2
+	}
+	return tag[:end] + replacement + tag[end:]
+}
+
+// removeMaxWidthStyle strips max-width from a start tag's style attribute,
+// dropping the attribute entirely if nothing else remains.
+func removeMaxWidthStyle(tag string) string {
+	styleRegex := regexp.MustCompile(`\sstyle="([^"]*)"`)
+	return styleRegex.ReplaceAllStringFunc(tag, func(attr string) string {
+		style := styleRegex.FindStringSubmatch(attr)[1]
+		style = strings.TrimSpace(maxWidthStyleRegex.ReplaceAllString(style, ""))
+		if style == "" {
+			return ""
+		}
+		return fmt.Sprintf(` style="%s"`, style)
+	})
+}
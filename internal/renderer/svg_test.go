@@ -0,0 +1,170 @@
+package renderer
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleSVG = `<svg id="my-svg" width="100%" xmlns="http://www.w3.org/2000/svg" style="max-width: 218.5px; background-color: white;" viewBox="0 0 218.5 174"><g><rect width="10" height="10"/></g></svg>`
+
+// --- ValidateSVGLength ---
+
+func TestValidateSVGLength(t *testing.T) {
+	valid := []string{"300", "300px", "80mm", "10cm", "2.5in", "12pt", "100%"}
+	for _, v := range valid {
+		if err := ValidateSVGLength(v); err != nil {
+			t.Errorf("expected %q to be valid, got %v", v, err)
+		}
+	}
+
+	invalid := []string{"", "px", "-10px", "10 px", "10furlongs", "auto"}
+	for _, v := range invalid {
+		if err := ValidateSVGLength(v); err == nil {
+			t.Errorf("expected %q to be invalid", v)
+		}
+	}
+}
+
+// --- setSVGDimensions ---
+
+func TestSetSVGDimensions_WidthAndHeight(t *testing.T) {
+	out := setSVGDimensions(sampleSVG, "80mm", "60mm")
+
+	if !strings.Contains(out, `width="80mm"`) {
+		t.Errorf("expected width to be replaced, got %q", out)
+	}
+	if !strings.Contains(out, `height="60mm"`) {
+		t.Errorf("expected height to be added, got %q", out)
+	}
+	if strings.Contains(out, "max-width") {
+		t.Errorf("expected max-width to be removed, got %q", out)
+	}
+	if !strings.Contains(out, `style="background-color: white;"`) {
+		t.Errorf("expected other styles to be preserved, got %q", out)
+	}
+	// Child elements must not be touched
+	if !strings.Contains(out, `<rect width="10" height="10"/>`) {
+		t.Errorf("expected child element attributes to be preserved, got %q", out)
+	}
+}
+
+func TestSetSVGDimensions_WidthOnly(t *testing.T) {
+	out := setSVGDimensions(sampleSVG, "300px", "")
+
+	if !strings.Contains(out, `<svg id="my-svg" width="300px"`) {
+		t.Errorf("expected width to be replaced in place, got %q", out)
+	}
+	if root := svgRootTagRegex.FindString(out); strings.Contains(root, "height=") {
+		t.Errorf("expected height not to be added, got %q", root)
+	}
+}
+
+func TestSetSVGDimensions_StyleOnlyMaxWidth(t *testing.T) {
+	svg := `<svg width="100%" style="max-width: 50px;"></svg>`
+	out := setSVGDimensions(svg, "", "10cm")
+
+	want := `<svg width="100%" height="10cm"></svg>`
+	if out != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+}
+
+func TestSetSVGDimensions_Empty(t *testing.T) {
+	if out := setSVGDimensions(sampleSVG, "", ""); out != sampleSVG {
+		t.Errorf("expected SVG to be unchanged, got %q", out)
+	}
+}
+
+// --- addXMLDeclaration ---
+
+func TestAddXMLDeclaration(t *testing.T) {
+	out := addXMLDeclaration(sampleSVG)
+
+	if !strings.HasPrefix(out, `<?xml version="1.0" encoding="UTF-8"?>`+"\n<svg ") {
+		t.Errorf("expected output to start with the XML declaration, got %q", out)
+	}
+	if strings.Count(out, `xmlns="http://www.w3.org/2000/svg"`) != 1 {
+		t.Errorf("expected exactly one SVG namespace declaration, got %q", out)
+	}
+}
+
+func TestAddXMLDeclaration_AddsNamespace(t *testing.T) {
+	in := `<svg id="my-svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 10 10"><g xmlns="http://www.w3.org/2000/svg"/></svg>`
+	out := addXMLDeclaration(in)
+
+	root := svgRootTagRegex.FindString(out)
+	if !strings.Contains(root, ` xmlns="http://www.w3.org/2000/svg"`) {
+		t.Errorf("expected the root element to declare the SVG namespace, got %q", root)
+	}
+	if !strings.Contains(root, `xmlns:xlink="http://www.w3.org/1999/xlink"`) {
+		t.Errorf("expected prefixed namespaces to be preserved, got %q", root)
+	}
+}
+
+func TestAddXMLDeclaration_Idempotent(t *testing.T) {
+	once := addXMLDeclaration(sampleSVG)
+	if twice := addXMLDeclaration(once); twice != once {
+		t.Errorf("expected a second pass to change nothing, got %q", twice)
+	}
+
+	existing := `<?xml version="1.0"?>` + sampleSVG
+	if out := addXMLDeclaration(existing); out != existing {
+		t.Errorf("expected an existing declaration to be kept, got %q", out)
+	}
+}
+
+func TestApplySVGMode_Standalone(t *testing.T) {
+	in := `<svg id="my-svg" width="100%" viewBox="0 0 10 10"><a xlink:href="#x"><rect/></a></svg>`
+	out, err := applySVGMode(in, SVGModeStandalone)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasPrefix(out, `<?xml version="1.0" encoding="UTF-8"?>`+"\n<svg ") {
+		t.Errorf("expected the XML declaration, got %q", out)
+	}
+	root := svgRootTagRegex.FindString(out)
+	if !strings.Contains(root, ` xmlns="http://www.w3.org/2000/svg"`) || !strings.Contains(root, ` xmlns:xlink="http://www.w3.org/1999/xlink"`) {
+		t.Errorf("expected the SVG and xlink namespaces on the root element, got %q", root)
+	}
+	if again, _ := applySVGMode(out, SVGModeStandalone); again != out {
+		t.Errorf("expected a second pass to change nothing, got %q", again)
+	}
+}
+
+func TestApplySVGMode_Inline(t *testing.T) {
+	in := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<svg id="my-svg" xmlns="http://www.w3.org/2000/svg" xmlns:xlink='http://www.w3.org/1999/xlink' viewBox="0 0 10 10">` +
+		`<g xmlns="http://www.w3.org/2000/svg"><a xlink:href="#x"><rect/></a></g></svg>`
+	out, err := applySVGMode(in, SVGModeInline)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `<svg id="my-svg" viewBox="0 0 10 10">` +
+		`<g xmlns="http://www.w3.org/2000/svg"><a xlink:href="#x"><rect/></a></g></svg>`
+	if out != want {
+		t.Errorf("applySVGMode(inline) = %q, want %q", out, want)
+	}
+}
+
+func TestApplySVGMode_None(t *testing.T) {
+	out, err := applySVGMode(sampleSVG, "")
+	if err != nil || out != sampleSVG {
+		t.Errorf("expected SVG unchanged without a mode, got %q, %v", out, err)
+	}
+	if _, err := applySVGMode(sampleSVG, "embedded"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
+
+func TestValidateSVGMode(t *testing.T) {
+	for _, mode := range []string{"", "standalone", "inline"} {
+		if err := ValidateSVGMode(mode); err != nil {
+			t.Errorf("ValidateSVGMode(%q): unexpected error: %v", mode, err)
+		}
+	}
+	if err := ValidateSVGMode("Inline"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
@@ -1,9 +1,15 @@
 package renderer
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"html"
+	"regexp"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/coolamit/mermaid-cli/internal/config"
 	"github.com/coolamit/mermaid-cli/internal/icons"
@@ -12,16 +18,118 @@ import (
 
 // RenderOpts contains all options needed to render a mermaid diagram.
 type RenderOpts struct {
-	MermaidConfig   config.MermaidConfig
-	BackgroundColor string
-	CSS             string
-	SVGId           string
-	Width           int
-	Height          int
-	Scale           int
-	PdfFit          bool
-	SvgFit          bool
-	IconPacks       []icons.IconPack
+	MermaidConfig    config.MermaidConfig `json:"mermaidConfig,omitempty"`
+	BackgroundColor  string               `json:"backgroundColor,omitempty"`
+	CSS              string               `json:"css,omitempty"`
+	CSSScope         string               `json:"cssScope,omitempty"`
+	SVGId            string               `json:"svgId,omitempty"`
+	Width            int                  `json:"width,omitempty"`
+	Height           int                  `json:"height,omitempty"`
+	Scale            float64              `json:"scale,omitempty"`
+	PdfFit           bool                 `json:"pdfFit,omitempty"`
+	DPI              int                  `json:"dpi,omitempty"`
+	Clip             *Clip                `json:"clip,omitempty"`
+	PageRanges       string               `json:"pageRanges,omitempty"`
+	SvgFit           bool                 `json:"svgFit,omitempty"`
+	SVGWidth         string               `json:"svgWidth,omitempty"`
+	SVGHeight        string               `json:"svgHeight,omitempty"`
+	SVGDecl          bool                 `json:"svgDecl,omitempty"`
+	SVGMode          string               `json:"svgMode,omitempty"`
+	Sanitize         bool                 `json:"sanitize,omitempty"`
+	CleanSVG         bool                 `json:"cleanSvg,omitempty"`
+	CleanSVGAttrs    []string             `json:"cleanSvgAttrs,omitempty"`
+	DeterministicIDs bool                 `json:"deterministicIds,omitempty"`
+	AutoSize         bool                 `json:"autoSize,omitempty"`
+	MinWidth         int                  `json:"minWidth,omitempty"`
+	MaxWidth         int                  `json:"maxWidth,omitempty"`
+	NoZenUML         bool                 `json:"noZenuml,omitempty"`
+	DiagramType      string               `json:"diagramType,omitempty"`
+	PNGColors        int                  `json:"pngColors,omitempty"`
+	Watermark        *Watermark           `json:"watermark,omitempty"`
+	IconPacks        []icons.IconPack     `json:"iconPacks,omitempty"`
+	StrictIcons      bool                 `json:"strictIcons,omitempty"`
+	FontURLs         []string             `json:"fontUrls,omitempty"`
+	MaxOutputBytes   int64                `json:"maxOutputBytes,omitempty"`
+	Timeout          time.Duration        `json:"timeout,omitempty"`
+	SettleDelay      time.Duration        `json:"settleDelay,omitempty"`
+	WaitFor          string               `json:"waitFor,omitempty"`
+	WaitForFunction  string               `json:"waitForFunction,omitempty"`
+	PostScript       string               `json:"postScript,omitempty"`
+	// Set records which of the options above were given explicitly, e.g. on the command
+	// line, rather than left at their defaults. It describes the options and doesn't
+	// change the output, so it isn't part of the cache key or daemon requests.
+	Set OptionSet `json:"-"`
+	// Scripts supplies the mermaid bundles. Nil means the embedded bundles.
+	Scripts web.Loader `json:"-"`
+}
+
+// OptionSet is a set of RenderOpts fields that were given explicitly.
+type OptionSet uint8
+
+// Options recorded in RenderOpts.Set.
+const (
+	SetWidth OptionSet = 1 << iota
+	SetHeight
+	SetScale
+	SetBackgroundColor
+)
+
+// Has reports whether all of the options in o are in s.
+func (s OptionSet) Has(o OptionSet) bool {
+	return s&o == o
+}
+
+// CSS scopes: svg appends the CSS to the rendered SVG, so it travels with SVG output;
+// page puts it in the document head, where it also styles the container and applies
+// while mermaid measures text.
+const (
+	CSSScopeSVG  = "svg"
+	CSSScopePage = "page"
+)
+
+// scriptEndRegex matches "</script" in JavaScript inlined into the page, which would end
+// the <script> element early; "<\/script" means the same in JavaScript strings and regexes.
+var scriptEndRegex = regexp.MustCompile(`(?i)</(script)`)
+
+// pageTemplate is the HTML page that hosts mermaid.js, parsed from the embedded web/template.html.
+var pageTemplate = template.Must(template.New("page").Parse(web.TemplateHTML))
+
+// pageData holds the values substituted into pageTemplate. Values are inserted verbatim
+// (text/template does no escaping), so anything that is not already JS is pre-encoded as JSON.
+type pageData struct {
+	FontLinks           string
+	WaitForFontsJSON    string
+	SandboxJSON         string
+	MermaidJS           string
+	ZenUML              bool
+	MermaidZenUMLJS     string
+	IconPackJS          string
+	PageCSS             string
+	ContainerBackground string
+	MermaidConfigJSON   string
+	DefinitionJSON      string
+	SVGIdJSON           string
+	BackgroundColorJSON string
+	CSSJSON             string
+	PostScript          string
+}
+
+// defaultSVGId returns the id of the rendered <svg> element when RenderOpts.SVGId is
+// empty: "my-svg-" and a hash of what the rendered SVG's content depends on, the
+// definition, mermaid config and CSS. Mermaid scopes its styles to the id
+// (#my-svg-1a2b3c4d .node), so diagrams embedded in the same page, or rendered one after
+// another in a reused tab, need different ids; deriving it from the content rather than
+// a counter keeps the output of a given render the same across runs, and options that
+// only affect the capture, such as the scale or output size, leave it unchanged.
+func defaultSVGId(definition string, opts RenderOpts) string {
+	h := sha256.New()
+	for _, part := range []interface{}{definition, opts.MermaidConfig, opts.CSS} {
+		if data, err := json.Marshal(part); err == nil {
+			h.Write(data)
+		}
+		h.Write([]byte{0})
+	}
+	return "my-svg-" + hex.EncodeToString(h.Sum(nil)[:4])
 }
 
 // BuildPageHTML constructs the full HTML page with embedded mermaid.js, config, and diagram.
@@ -36,98 +144,92 @@ func BuildPageHTML(definition string, opts RenderOpts) (string, error) {
 		return "", fmt.Errorf("failed to serialize diagram definition: %w", err)
 	}
 
-	svgIdJSON, err := json.Marshal(opts.SVGId)
+	svgId := opts.SVGId
+	if svgId == "" {
+		svgId = defaultSVGId(definition, opts)
+	}
+	svgIdJSON, err := json.Marshal(svgId)
 	if err != nil {
 		return "", fmt.Errorf("failed to serialize svgId: %w", err)
 	}
 
-	bgColorJSON, err := json.Marshal(opts.BackgroundColor)
+	// A transparent background sets no style at all, so an SVG inherits the background of
+	// the page it is embedded in. A background image such as a gradient goes on the
+	// container instead, which hugs the SVG so screenshots clipped to it include the image.
+	svgBackground, containerBackground := opts.BackgroundColor, ""
+	if strings.EqualFold(strings.TrimSpace(svgBackground), "transparent") {
+		svgBackground = ""
+	} else if isBackgroundImage(svgBackground) {
+		svgBackground, containerBackground = "", strings.TrimSpace(svgBackground)
+	}
+
+	bgColorJSON, err := json.Marshal(svgBackground)
 	if err != nil {
 		return "", fmt.Errorf("failed to serialize backgroundColor: %w", err)
 	}
 
-	cssJSON, err := json.Marshal(opts.CSS)
+	svgCSS, pageCSS := opts.CSS, ""
+	switch opts.CSSScope {
+	case "", CSSScopeSVG:
+	case CSSScopePage:
+		// "</" would end the <style> element early; "<\/" means the same in CSS
+		svgCSS, pageCSS = "", strings.ReplaceAll(opts.CSS, "</", `<\/`)
+	default:
+		return "", fmt.Errorf("invalid CSS scope %q, must be %q or %q", opts.CSSScope, CSSScopePage, CSSScopeSVG)
+	}
+
+	cssJSON, err := json.Marshal(svgCSS)
 	if err != nil {
 		return "", fmt.Errorf("failed to serialize CSS: %w", err)
 	}
 
-	iconPackJS := icons.GenerateIconPackJS(opts.IconPacks)
+	// Stylesheets such as Google Fonts are linked from the page head; the page waits
+	// for them and their fonts to load before mermaid measures any text
+	var fontLinks strings.Builder
+	for _, u := range opts.FontURLs {
+		fmt.Fprintf(&fontLinks, "  <link rel=\"stylesheet\" href=\"%s\">\n", html.EscapeString(u))
+	}
+	waitForFonts := "false"
+	if len(opts.FontURLs) > 0 {
+		waitForFonts = "true"
+	}
+
+	sandbox := "false"
+	if opts.MermaidConfig.Sandboxed() {
+		sandbox = "true"
+	}
+
+	scripts := opts.Scripts
+	if scripts == nil {
+		scripts = web.Embedded()
+	}
+
+	data := pageData{
+		FontLinks:           fontLinks.String(),
+		WaitForFontsJSON:    waitForFonts,
+		SandboxJSON:         sandbox,
+		MermaidJS:           string(scripts.MermaidJS()),
+		ZenUML:              !opts.NoZenUML,
+		IconPackJS:          icons.GenerateIconPackJS(opts.IconPacks),
+		PageCSS:             pageCSS,
+		ContainerBackground: html.EscapeString(containerBackground),
+		MermaidConfigJSON:   mermaidConfigJSON,
+		DefinitionJSON:      string(definitionJSON),
+		SVGIdJSON:           string(svgIdJSON),
+		BackgroundColorJSON: string(bgColorJSON),
+		CSSJSON:             string(cssJSON),
+		PostScript:          scriptEndRegex.ReplaceAllString(opts.PostScript, `<\/$1`),
+	}
+
+	// Without zenuml the page skips its bundle and the external diagram registration
+	if data.ZenUML {
+		data.MermaidZenUMLJS = string(scripts.MermaidZenUMLJS())
+	}
 
-	// Build the full HTML page
 	var sb strings.Builder
-	sb.WriteString(`<!DOCTYPE html>
-<html>
-<head>
-  <style>
-    body { margin: 0; padding: 0; font-family: sans-serif; }
-  </style>
-</head>
-<body>
-  <div id="container"></div>
-  <script>`)
-	// Embed mermaid.js inline
-	sb.Write(web.MermaidJS)
-	sb.WriteString(`</script>
-  <script>`)
-	// Embed mermaid-zenuml.js inline
-	sb.Write(web.MermaidZenUMLJS)
-	sb.WriteString(`</script>
-  <script>
-    async function renderDiagram() {
-      try {
-        const zenuml = globalThis['mermaid-zenuml'];
-        if (zenuml && zenuml.default) {
-          await mermaid.registerExternalDiagrams([zenuml.default]);
-        } else if (zenuml) {
-          await mermaid.registerExternalDiagrams([zenuml]);
-        }
-`)
-	sb.WriteString(iconPackJS)
-	sb.WriteString(fmt.Sprintf(`
-        mermaid.initialize({ startOnLoad: false, ...%s });
-
-        const definition = %s;
-        const svgId = %s || 'my-svg';
-        const backgroundColor = %s;
-        const myCSS = %s;
-
-        const container = document.getElementById('container');
-        const { svg: svgText } = await mermaid.render(svgId, definition, container);
-        container.innerHTML = svgText;
-
-        const svg = container.getElementsByTagName('svg')[0];
-        if (svg && svg.style) {
-          svg.style.backgroundColor = backgroundColor;
-        }
-
-        if (myCSS) {
-          const style = document.createElementNS('http://www.w3.org/2000/svg', 'style');
-          style.appendChild(document.createTextNode(myCSS));
-          svg.appendChild(style);
-        }
-
-        // Extract metadata
-        let title = null;
-        let desc = null;
-        if (svg.firstChild && svg.firstChild.nodeName === 'title') {
-          title = svg.firstChild.textContent;
-        }
-        for (const node of svg.children) {
-          if (node.nodeName === 'desc') {
-            desc = node.textContent;
-            break;
-          }
-        }
-
-        window.__mmd_result = { title, desc, success: true };
-      } catch (e) {
-        window.__mmd_result = { error: e.message || String(e), success: false };
-      }
-    }
-    renderDiagram();
-  </script>
-</body>
-</html>`, mermaidConfigJSON, string(definitionJSON), string(svgIdJSON), string(bgColorJSON), string(cssJSON)))
+	if err := pageTemplate.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render page template: %w", err)
+	}
 
 	return sb.String(), nil
 }
@@ -1,11 +1,13 @@
 package renderer
 
 import (
+	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/coolamit/mermaid-cli/internal/config"
 	"github.com/coolamit/mermaid-cli/internal/icons"
+	"github.com/coolamit/mermaid-cli/web"
 )
 
 func defaultOpts() RenderOpts {
@@ -51,6 +53,64 @@ func TestBuildPageHTML_WithCSS(t *testing.T) {
 	}
 }
 
+func TestBuildPageHTML_CSSScope(t *testing.T) {
+	css := "#container { padding: 8px; }"
+	head := func(html string) string { return html[:strings.Index(html, "</head>")] }
+
+	for _, scope := range []string{"", CSSScopeSVG} {
+		opts := defaultOpts()
+		opts.CSS = css
+		opts.CSSScope = scope
+
+		html, err := BuildPageHTML("graph TD; A-->B;", opts)
+		if err != nil {
+			t.Fatalf("scope %q: unexpected error: %v", scope, err)
+		}
+		if strings.Contains(head(html), css) {
+			t.Errorf("scope %q: expected CSS not to be in <head>", scope)
+		}
+		if !strings.Contains(html, `const myCSS = "#container { padding: 8px; }";`) {
+			t.Errorf("scope %q: expected CSS to be appended to the SVG <style>", scope)
+		}
+	}
+
+	opts := defaultOpts()
+	opts.CSS = css
+	opts.CSSScope = CSSScopePage
+	html, err := BuildPageHTML("graph TD; A-->B;", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(head(html), "<style>"+css+"</style>") {
+		t.Error("expected page-scoped CSS in <head>")
+	}
+	if !strings.Contains(html, `const myCSS = "";`) {
+		t.Error("expected page-scoped CSS not to be appended to the SVG")
+	}
+}
+
+func TestBuildPageHTML_PageCSSCannotCloseStyle(t *testing.T) {
+	opts := defaultOpts()
+	opts.CSS = "/* </style><script>alert(1)</script> */"
+	opts.CSSScope = CSSScopePage
+
+	html, err := BuildPageHTML("graph TD; A-->B;", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(html, "</style><script>alert(1)") {
+		t.Error("expected </ in page CSS to be escaped")
+	}
+}
+
+func TestBuildPageHTML_InvalidCSSScope(t *testing.T) {
+	opts := defaultOpts()
+	opts.CSSScope = "document"
+	if _, err := BuildPageHTML("graph TD; A-->B;", opts); err == nil {
+		t.Error("expected error for unknown CSS scope")
+	}
+}
+
 func TestBuildPageHTML_WithIconPacks(t *testing.T) {
 	opts := defaultOpts()
 	opts.IconPacks = []icons.IconPack{
@@ -82,6 +142,158 @@ func TestBuildPageHTML_WithSVGId(t *testing.T) {
 	}
 }
 
+func TestDefaultSVGId(t *testing.T) {
+	opts := defaultOpts()
+	id := defaultSVGId("graph TD; A-->B;", opts)
+	if !regexp.MustCompile(`^my-svg-[0-9a-f]{8}$`).MatchString(id) {
+		t.Errorf("unexpected default id %q", id)
+	}
+	if again := defaultSVGId("graph TD; A-->B;", opts); again != id {
+		t.Errorf("expected the same diagram to get the same id, got %q and %q", id, again)
+	}
+	if other := defaultSVGId("graph TD; A-->C;", opts); other == id {
+		t.Errorf("expected another diagram to get another id, both got %q", id)
+	}
+	dark := defaultOpts()
+	dark.MermaidConfig = config.MermaidConfig{"theme": "dark"}
+	if other := defaultSVGId("graph TD; A-->B;", dark); other == id {
+		t.Errorf("expected the same diagram with another config to get another id, both got %q", id)
+	}
+	styled := defaultOpts()
+	styled.CSS = ".node { fill: red; }"
+	if other := defaultSVGId("graph TD; A-->B;", styled); other == id {
+		t.Errorf("expected the same diagram with other CSS to get another id, both got %q", id)
+	}
+	scaled := defaultOpts()
+	scaled.Scale, scaled.Width = 3, 2000
+	if other := defaultSVGId("graph TD; A-->B;", scaled); other != id {
+		t.Errorf("expected capture options not to change the id, got %q and %q", id, other)
+	}
+}
+
+func TestBuildPageHTML_BackgroundColor(t *testing.T) {
+	opts := defaultOpts()
+	opts.BackgroundColor = "#F0F0F0"
+
+	html, err := BuildPageHTML("graph TD; A-->B;", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(html, `const backgroundColor = "#F0F0F0";`) {
+		t.Error("expected background color to be passed to the page")
+	}
+}
+
+func TestBuildPageHTML_TransparentBackground(t *testing.T) {
+	for _, bg := range []string{"transparent", "Transparent", " transparent "} {
+		opts := defaultOpts()
+		opts.BackgroundColor = bg
+
+		html, err := BuildPageHTML("graph TD; A-->B;", opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		// An empty color skips setting svg.style.backgroundColor entirely
+		if !strings.Contains(html, `const backgroundColor = "";`) {
+			t.Errorf("%q: expected no background color to be passed to the page", bg)
+		}
+		if !strings.Contains(html, "if (svg && svg.style && backgroundColor)") {
+			t.Errorf("%q: expected the background style to be guarded", bg)
+		}
+	}
+}
+
+func TestBuildPageHTML_GradientBackground(t *testing.T) {
+	opts := defaultOpts()
+	opts.BackgroundColor = "linear-gradient(to right, #fff, #eee)"
+
+	html, err := BuildPageHTML("graph TD; A-->B;", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(html, `<div id="container" style="display: inline-block; vertical-align: top; background: linear-gradient(to right, #fff, #eee);">`) {
+		t.Error("expected the gradient as a background style on the container")
+	}
+	if !strings.Contains(html, `const backgroundColor = "";`) {
+		t.Error("expected the gradient not to be set as the SVG background color")
+	}
+}
+
+func TestBuildPageHTML_ContainerBackgroundEscaped(t *testing.T) {
+	opts := defaultOpts()
+	opts.BackgroundColor = `url("bg.png")"><script>alert(1)</script>`
+
+	html, err := BuildPageHTML("graph TD; A-->B;", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(html, "<script>alert(1)") {
+		t.Error("expected the container background to be HTML-escaped")
+	}
+}
+
+func TestBuildPageHTML_PlainColorLeavesContainerUnstyled(t *testing.T) {
+	html, err := BuildPageHTML("graph TD; A-->B;", defaultOpts())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(html, `<div id="container"></div>`) {
+		t.Error("expected no container style for a plain background color")
+	}
+}
+
+func TestBuildPageHTML_FontURLs(t *testing.T) {
+	opts := defaultOpts()
+	opts.FontURLs = []string{"https://fonts.googleapis.com/css2?family=Inter&display=swap"}
+
+	html, err := BuildPageHTML("graph TD; A-->B;", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	link := `<link rel="stylesheet" href="https://fonts.googleapis.com/css2?family=Inter&amp;display=swap">`
+	headEnd := strings.Index(html, "</head>")
+	if i := strings.Index(html, link); i < 0 || i > headEnd {
+		t.Errorf("expected font stylesheet link in the page head")
+	}
+	// The page awaits document.fonts.ready before rendering when fonts are linked
+	if !strings.Contains(html, "if (true) {") || !strings.Contains(html, "await document.fonts.ready") {
+		t.Error("expected the page to wait for fonts to load")
+	}
+}
+
+func TestBuildPageHTML_NoFontURLs(t *testing.T) {
+	html, err := BuildPageHTML("graph TD; A-->B;", defaultOpts())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(html, "<link") {
+		t.Error("expected no stylesheet links without font URLs")
+	}
+	if !strings.Contains(html, "if (false) {") {
+		t.Error("expected the font wait to be disabled")
+	}
+}
+
+func TestBuildPageHTML_Sandbox(t *testing.T) {
+	html, err := BuildPageHTML("graph TD; A-->B;", defaultOpts())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(html, "const sandboxed = false;") {
+		t.Error("expected the diagram to render into the page by default")
+	}
+
+	opts := defaultOpts()
+	opts.MermaidConfig = config.MermaidConfig{"securityLevel": "sandbox"}
+	html, err = BuildPageHTML("graph TD; A-->B;", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(html, "const sandboxed = true;") {
+		t.Error("expected securityLevel sandbox to render into an iframe")
+	}
+}
+
 func TestBuildPageHTML_SpecialChars(t *testing.T) {
 	// Definition contains literal quotes and a backslash
 	definition := "graph TD; A[\"Node with quotes and \\\\backslash\"]-->B;"
@@ -100,3 +312,112 @@ func TestBuildPageHTML_SpecialChars(t *testing.T) {
 		t.Errorf("expected JSON-escaped backslash in output")
 	}
 }
+
+func TestBuildPageHTML_UsesTemplate(t *testing.T) {
+	html, err := BuildPageHTML("graph TD; A-->B;", defaultOpts())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(html, "{{.") {
+		t.Error("expected all template placeholders to be substituted")
+	}
+	if !strings.Contains(html, "renderDiagram();") {
+		t.Error("expected render script from web/template.html in output")
+	}
+	if !regexp.MustCompile(`const svgId = "my-svg-[0-9a-f]{8}";`).MatchString(html) {
+		t.Error("expected JSON-encoded svgId in output")
+	}
+}
+
+func TestBuildPageHTML_PostScript(t *testing.T) {
+	html, err := BuildPageHTML("graph TD; A-->B;", defaultOpts())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(html, "--postScript") {
+		t.Error("expected no post-render hook without a script")
+	}
+
+	opts := defaultOpts()
+	opts.PostScript = `svg.setAttribute('data-note', '</script><b>');`
+	html, err = BuildPageHTML("graph TD; A-->B;", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	script := `svg.setAttribute('data-note', '<\/script><b>');`
+	i := strings.Index(html, script)
+	if i < 0 {
+		t.Fatalf("expected the post-render script in the page, with </script escaped")
+	}
+	// It runs after the SVG is in place and before the result is reported
+	if i < strings.Index(html, "mermaid.render(") || i > strings.Index(html, "window.__mmd_result = { title") {
+		t.Error("expected the post-render script between mermaid.render and the render result")
+	}
+}
+
+// stubScripts is a web.Loader returning fixed bundles.
+type stubScripts struct{}
+
+func (stubScripts) MermaidJS() []byte       { return []byte("/* custom mermaid bundle v0.0.1 */") }
+func (stubScripts) MermaidZenUMLJS() []byte { return []byte("/* custom zenuml bundle */") }
+
+func TestBuildPageHTML_CustomScripts(t *testing.T) {
+	opts := defaultOpts()
+	opts.Scripts = stubScripts{}
+
+	html, err := BuildPageHTML("graph TD; A-->B;", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(html, "<script>/* custom mermaid bundle v0.0.1 */</script>") {
+		t.Error("expected custom mermaid bundle in output")
+	}
+	if !strings.Contains(html, "<script>/* custom zenuml bundle */</script>") {
+		t.Error("expected custom zenuml bundle in output")
+	}
+	if strings.Contains(html, string(web.MermaidJS[:200])) {
+		t.Error("expected embedded mermaid bundle not to be used")
+	}
+}
+
+func TestBuildPageHTML_ZenUML(t *testing.T) {
+	opts := defaultOpts()
+	opts.Scripts = stubScripts{}
+
+	html, err := BuildPageHTML("graph TD; A-->B;", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"/* custom zenuml bundle */", "registerExternalDiagrams"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in output by default", want)
+		}
+	}
+
+	opts.NoZenUML = true
+	html, err = BuildPageHTML("graph TD; A-->B;", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, unwanted := range []string{"/* custom zenuml bundle */", "mermaid-zenuml", "registerExternalDiagrams"} {
+		if strings.Contains(html, unwanted) {
+			t.Errorf("expected no %q in output with NoZenUML", unwanted)
+		}
+	}
+	if !strings.Contains(html, "<script>/* custom mermaid bundle v0.0.1 */</script>") {
+		t.Error("expected mermaid bundle in output with NoZenUML")
+	}
+}
+
+func TestOptionSetHas(t *testing.T) {
+	set := SetWidth | SetScale
+	if !set.Has(SetWidth) || !set.Has(SetScale) || !set.Has(SetWidth|SetScale) {
+		t.Errorf("expected %b to have width and scale", set)
+	}
+	if set.Has(SetHeight) || set.Has(SetWidth|SetHeight) {
+		t.Errorf("expected %b not to have height", set)
+	}
+	if !OptionSet(0).Has(0) || OptionSet(0).Has(SetBackgroundColor) {
+		t.Error("expected the empty set to have only the empty set")
+	}
+}
@@ -0,0 +1,200 @@
+package renderer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Watermark positions: the corner of the output the watermark is drawn in.
+const (
+	WatermarkTopLeft     = "top-left"
+	WatermarkTopRight    = "top-right"
+	WatermarkBottomLeft  = "bottom-left"
+	WatermarkBottomRight = "bottom-right"
+)
+
+// DefaultWatermarkPosition is the corner used unless --watermarkPosition is given.
+const DefaultWatermarkPosition = WatermarkBottomRight
+
+// watermarkMargin is the gap between the watermark and the edges of the output, in CSS
+// pixels: user units for SVG, and multiplied by the scale for PNG and JPEG like the
+// watermark itself.
+const watermarkMargin = 10
+
+// watermarkJPEGQuality is the quality JPEG output is re-encoded at after watermarking.
+// The watermark is drawn after Chrome has encoded the capture, so a second encode can't
+// be avoided; Chrome captures at a quality of 80, and re-encoding above that keeps the
+// added loss small without making the file much bigger.
+const watermarkJPEGQuality = 90
+
+// Watermark is an image, such as a logo, drawn over a corner of PNG, JPEG and SVG output.
+type Watermark struct {
+	// Image is the PNG or JPEG watermark file
+	Image []byte `json:"image"`
+	// Position is one of the Watermark* corners
+	Position string `json:"position"`
+	// Opacity is from 0 (invisible) to 1 (opaque)
+	Opacity float64 `json:"opacity"`
+}
+
+// NewWatermark checks the --watermark options and returns the watermark they describe.
+// An empty position means DefaultWatermarkPosition.
+func NewWatermark(data []byte, position string, opacity float64) (*Watermark, error) {
+	if position == "" {
+		position = DefaultWatermarkPosition
+	}
+	switch position {
+	case WatermarkTopLeft, WatermarkTopRight, WatermarkBottomLeft, WatermarkBottomRight:
+	default:
+		return nil, fmt.Errorf("invalid --watermarkPosition %q, must be %s, %s, %s or %s", position,
+			WatermarkTopLeft, WatermarkTopRight, WatermarkBottomLeft, WatermarkBottomRight)
+	}
+	if opacity < 0 || opacity > 1 {
+		return nil, fmt.Errorf("invalid --watermarkOpacity %g, must be between 0 and 1", opacity)
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("invalid watermark image, must be a PNG or JPEG: %w", err)
+	}
+	return &Watermark{Image: data, Position: position, Opacity: opacity}, nil
+}
+
+// watermarkOrigin returns where the top-left corner of a watermark of size mark goes on
+// canvas, margin away from the edges of the corner given by position. A watermark that
+// doesn't fit is kept inside the canvas from its top-left corner.
+func watermarkOrigin(canvas image.Rectangle, mark image.Point, position string, margin int) image.Point {
+	x := canvas.Max.X - margin - mark.X
+	if position == WatermarkTopLeft || position == WatermarkBottomLeft {
+		x = canvas.Min.X + margin
+	}
+	y := canvas.Max.Y - margin - mark.Y
+	if position == WatermarkTopLeft || position == WatermarkTopRight {
+		y = canvas.Min.Y + margin
+	}
+	return image.Point{X: max(canvas.Min.X, x), Y: max(canvas.Min.Y, y)}
+}
+
+// watermarkRaster draws wm over a PNG or JPEG capture and re-encodes it in the same format.
+// The capture is scale times the size of the diagram in CSS pixels, so the watermark and
+// its margin are scaled to match, keeping the same size relative to the diagram as in SVG
+// output.
+func watermarkRaster(data []byte, format string, wm *Watermark, scale float64) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s for watermarking: %w", format, err)
+	}
+	mark, _, err := image.Decode(bytes.NewReader(wm.Image))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode watermark: %w", err)
+	}
+	if scale <= 0 {
+		scale = 1
+	}
+	if scale != 1 {
+		size := mark.Bounds().Size()
+		mark = scaleImage(mark, max(1, int(math.Round(float64(size.X)*scale))), max(1, int(math.Round(float64(size.Y)*scale))))
+	}
+
+	out := image.NewNRGBA(src.Bounds())
+	draw.Draw(out, out.Bounds(), src, src.Bounds().Min, draw.Src)
+	origin := watermarkOrigin(out.Bounds(), mark.Bounds().Size(), wm.Position, int(math.Round(watermarkMargin*scale)))
+	target := image.Rectangle{Min: origin, Max: origin.Add(mark.Bounds().Size())}
+	opacity := image.NewUniform(color.Alpha{A: uint8(wm.Opacity*255 + 0.5)})
+	draw.DrawMask(out, target, mark, mark.Bounds().Min, opacity, image.Point{}, draw.Over)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, out, &jpeg.Options{Quality: watermarkJPEGQuality})
+	} else {
+		err = png.Encode(&buf, out)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode watermarked %s: %w", format, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// scaleImage resizes img to w x h pixels with bilinear filtering. Colors are blended
+// premultiplied, so transparent pixels don't darken the edges of a logo.
+func scaleImage(img image.Image, w, h int) *image.RGBA {
+	b := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	at := func(x, y int) color.RGBA {
+		x = min(max(x, 0), b.Dx()-1)
+		y = min(max(y, 0), b.Dy()-1)
+		return color.RGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.RGBA)
+	}
+	for y := 0; y < h; y++ {
+		sy := (float64(y)+0.5)*float64(b.Dy())/float64(h) - 0.5
+		y0 := int(math.Floor(sy))
+		fy := sy - float64(y0)
+		for x := 0; x < w; x++ {
+			sx := (float64(x)+0.5)*float64(b.Dx())/float64(w) - 0.5
+			x0 := int(math.Floor(sx))
+			fx := sx - float64(x0)
+			p00, p10, p01, p11 := at(x0, y0), at(x0+1, y0), at(x0, y0+1), at(x0+1, y0+1)
+			mix := func(c00, c10, c01, c11 uint8) uint8 {
+				top := float64(c00)*(1-fx) + float64(c10)*fx
+				bottom := float64(c01)*(1-fx) + float64(c11)*fx
+				return uint8(top*(1-fy) + bottom*fy + 0.5)
+			}
+			out.SetRGBA(x, y, color.RGBA{
+				R: mix(p00.R, p10.R, p01.R, p11.R),
+				G: mix(p00.G, p10.G, p01.G, p11.G),
+				B: mix(p00.B, p10.B, p01.B, p11.B),
+				A: mix(p00.A, p10.A, p01.A, p11.A),
+			})
+		}
+	}
+	return out
+}
+
+// svgViewBoxRegex matches the viewBox attribute of a start tag.
+var svgViewBoxRegex = regexp.MustCompile(`\sviewBox="([^"]*)"`)
+
+// watermarkSVG adds wm to SVG output as an <image> at the end of the root element,
+// placed in the corner of its viewBox.
+func watermarkSVG(svgXML string, wm *Watermark) (string, error) {
+	loc := svgRootTagRegex.FindStringIndex(svgXML)
+	end := strings.LastIndex(svgXML, "</svg>")
+	if loc == nil || end < loc[1] {
+		return "", fmt.Errorf("failed to watermark SVG: no <svg> element")
+	}
+	m := svgViewBoxRegex.FindStringSubmatch(svgXML[loc[0]:loc[1]])
+	if m == nil {
+		return "", fmt.Errorf("failed to watermark SVG: the <svg> element has no viewBox")
+	}
+	var box [4]float64
+	fields := strings.Fields(strings.ReplaceAll(m[1], ",", " "))
+	if len(fields) != 4 {
+		return "", fmt.Errorf("failed to watermark SVG: invalid viewBox %q", m[1])
+	}
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return "", fmt.Errorf("failed to watermark SVG: invalid viewBox %q", m[1])
+		}
+		box[i] = v
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(wm.Image))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode watermark: %w", err)
+	}
+
+	canvas := image.Rect(int(box[0]), int(box[1]), int(box[0]+box[2]), int(box[1]+box[3]))
+	origin := watermarkOrigin(canvas, image.Point{X: cfg.Width, Y: cfg.Height}, wm.Position, watermarkMargin)
+	href := "data:" + http.DetectContentType(wm.Image) + ";base64," + base64.StdEncoding.EncodeToString(wm.Image)
+	img := fmt.Sprintf(`<image class="mmd-watermark" x="%d" y="%d" width="%d" height="%d" opacity="%s" href="%s"/>`,
+		origin.X, origin.Y, cfg.Width, cfg.Height, strconv.FormatFloat(wm.Opacity, 'g', -1, 64), href)
+	return svgXML[:end] + img + svgXML[end:], nil
+}
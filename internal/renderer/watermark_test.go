@@ -0,0 +1,218 @@
+package renderer
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// solidImage encodes a w x h image filled with c, as a PNG or JPEG.
+func solidImage(t *testing.T, w, h int, c color.NRGBA, format string) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	if format == "jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWatermarkOrigin(t *testing.T) {
+	canvas := image.Rect(0, 0, 200, 100)
+	mark := image.Pt(30, 20)
+	tests := []struct {
+		position string
+		want     image.Point
+	}{
+		{WatermarkTopLeft, image.Pt(10, 10)},
+		{WatermarkTopRight, image.Pt(160, 10)},
+		{WatermarkBottomLeft, image.Pt(10, 70)},
+		{WatermarkBottomRight, image.Pt(160, 70)},
+	}
+	for _, tt := range tests {
+		if got := watermarkOrigin(canvas, mark, tt.position, watermarkMargin); got != tt.want {
+			t.Errorf("%s: origin = %v, want %v", tt.position, got, tt.want)
+		}
+	}
+}
+
+func TestWatermarkOrigin_OffsetCanvas(t *testing.T) {
+	// An SVG viewBox need not start at 0,0
+	canvas := image.Rect(-8, -8, 92, 42)
+	if got, want := watermarkOrigin(canvas, image.Pt(20, 10), WatermarkBottomRight, watermarkMargin), image.Pt(62, 22); got != want {
+		t.Errorf("origin = %v, want %v", got, want)
+	}
+	if got, want := watermarkOrigin(canvas, image.Pt(20, 10), WatermarkTopLeft, watermarkMargin), image.Pt(2, 2); got != want {
+		t.Errorf("origin = %v, want %v", got, want)
+	}
+}
+
+func TestWatermarkOrigin_TooLarge(t *testing.T) {
+	got := watermarkOrigin(image.Rect(0, 0, 50, 40), image.Pt(80, 60), WatermarkBottomRight, watermarkMargin)
+	if got != image.Pt(0, 0) {
+		t.Errorf("origin = %v, want the canvas's top-left corner", got)
+	}
+}
+
+func TestWatermarkRaster_Placement(t *testing.T) {
+	white := color.NRGBA{255, 255, 255, 255}
+	red := color.NRGBA{255, 0, 0, 255}
+	src := solidImage(t, 100, 80, white, "png")
+	wm, err := NewWatermark(solidImage(t, 20, 10, red, "png"), WatermarkBottomRight, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := watermarkRaster(src, "png", wm, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 100, 80) {
+		t.Fatalf("bounds = %v, want the capture's", img.Bounds())
+	}
+	// The watermark covers x 70-89, y 60-69
+	for _, p := range []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{70, 60, red}, {89, 69, red}, {80, 65, red},
+		{69, 60, white}, {90, 69, white}, {70, 59, white}, {89, 70, white}, {0, 0, white}, {99, 79, white},
+	} {
+		if got := color.NRGBAModel.Convert(img.At(p.x, p.y)).(color.NRGBA); got != p.want {
+			t.Errorf("pixel (%d,%d) = %v, want %v", p.x, p.y, got, p.want)
+		}
+	}
+}
+
+func TestWatermarkRaster_Scale(t *testing.T) {
+	white := color.NRGBA{255, 255, 255, 255}
+	red := color.NRGBA{255, 0, 0, 255}
+	src := solidImage(t, 200, 160, white, "png")
+	wm, err := NewWatermark(solidImage(t, 20, 10, red, "png"), WatermarkBottomRight, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := watermarkRaster(src, "png", wm, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// At scale 2 the watermark is 40x20 and 20 pixels from the edges: x 140-179, y 120-139
+	for _, p := range []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{140, 120, red}, {179, 139, red}, {160, 130, red},
+		{139, 120, white}, {180, 139, white}, {140, 119, white}, {179, 140, white},
+	} {
+		if got := color.NRGBAModel.Convert(img.At(p.x, p.y)).(color.NRGBA); got != p.want {
+			t.Errorf("pixel (%d,%d) = %v, want %v", p.x, p.y, got, p.want)
+		}
+	}
+}
+
+func TestWatermarkRaster_Opacity(t *testing.T) {
+	src := solidImage(t, 40, 40, color.NRGBA{255, 255, 255, 255}, "png")
+	wm, err := NewWatermark(solidImage(t, 10, 10, color.NRGBA{0, 0, 0, 255}, "png"), WatermarkTopLeft, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := watermarkRaster(src, "png", wm, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := color.NRGBAModel.Convert(img.At(12, 12)).(color.NRGBA)
+	if got.R < 120 || got.R > 135 || got.R != got.G || got.A != 255 {
+		t.Errorf("half-transparent black over white = %v, want mid grey", got)
+	}
+}
+
+func TestWatermarkRaster_JPEG(t *testing.T) {
+	src := solidImage(t, 60, 40, color.NRGBA{255, 255, 255, 255}, "jpeg")
+	wm, err := NewWatermark(solidImage(t, 10, 10, color.NRGBA{0, 0, 255, 255}, "png"), WatermarkTopRight, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := watermarkRaster(src, "jpeg", wm, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("expected JPEG output: %v", err)
+	}
+	if r, _, b, _ := img.At(45, 15).RGBA(); b>>8 < 200 || r>>8 > 60 {
+		t.Errorf("expected blue at (45,15), got %v", img.At(45, 15))
+	}
+}
+
+func TestWatermarkSVG(t *testing.T) {
+	mark := solidImage(t, 24, 12, color.NRGBA{0, 0, 0, 255}, "png")
+	wm, err := NewWatermark(mark, WatermarkBottomLeft, 0.4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svg := `<svg id="d" viewBox="-8 -8 216 116" style="max-width: 216px;"><g></g></svg>`
+	out, err := watermarkSVG(svg, wm)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `<image class="mmd-watermark" x="2" y="86" width="24" height="12" opacity="0.4" href="data:image/png;base64,`
+	if !strings.Contains(out, want) {
+		t.Errorf("expected %s in %s", want, out)
+	}
+	if !strings.HasSuffix(out, `"/></svg>`) || !strings.HasPrefix(out, `<svg id="d"`) {
+		t.Errorf("expected the image at the end of the root element, got %s", out)
+	}
+
+	if _, err := watermarkSVG(`<svg width="10" height="10"></svg>`, wm); err == nil {
+		t.Error("expected an error for an SVG without a viewBox")
+	}
+}
+
+func TestNewWatermark(t *testing.T) {
+	mark := solidImage(t, 4, 4, color.NRGBA{0, 0, 0, 255}, "png")
+	wm, err := NewWatermark(mark, "", 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wm.Position != DefaultWatermarkPosition {
+		t.Errorf("position = %q, want the default", wm.Position)
+	}
+	if _, err := NewWatermark(mark, "center", 1); err == nil {
+		t.Error("expected an error for an unknown position")
+	}
+	if _, err := NewWatermark(mark, WatermarkTopLeft, 1.5); err == nil {
+		t.Error("expected an error for an opacity above 1")
+	}
+	if _, err := NewWatermark([]byte("<svg/>"), WatermarkTopLeft, 1); err == nil {
+		t.Error("expected an error for an image that isn't a PNG or JPEG")
+	}
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// Loader supplies the JavaScript bundles injected into the render page.
+type Loader interface {
+	MermaidJS() []byte
+	MermaidZenUMLJS() []byte
+}
+
+// scripts is a Loader backed by in-memory bundles.
+type scripts struct {
+	mermaid []byte
+	zenuml  []byte
+}
+
+func (s *scripts) MermaidJS() []byte       { return s.mermaid }
+func (s *scripts) MermaidZenUMLJS() []byte { return s.zenuml }
+
+// Embedded returns a Loader serving the bundles compiled into the binary.
+func Embedded() Loader {
+	return &scripts{mermaid: MermaidJS, zenuml: MermaidZenUMLJS}
+}
+
+// NewFileLoader returns a Loader that reads replacement bundles from disk.
+// An empty path keeps the corresponding embedded bundle.
+func NewFileLoader(mermaidPath, zenumlPath string) (Loader, error) {
+	s := &scripts{mermaid: MermaidJS, zenuml: MermaidZenUMLJS}
+
+	if mermaidPath != "" {
+		data, err := readBundle(mermaidPath)
+		if err != nil {
+			return nil, err
+		}
+		s.mermaid = data
+	}
+
+	if zenumlPath != "" {
+		data, err := readBundle(zenumlPath)
+		if err != nil {
+			return nil, err
+		}
+		s.zenuml = data
+	}
+
+	return s, nil
+}
+
+// readBundle reads a JS bundle and checks it is non-empty and plausibly a mermaid build.
+func readBundle(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("JS bundle %q doesn't exist", path)
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, fmt.Errorf("JS bundle %q is empty", path)
+	}
+	if !bytes.Contains(data, []byte("mermaid")) {
+		return nil, fmt.Errorf("JS bundle %q doesn't look like a mermaid build", path)
+	}
+	return data, nil
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEmbedded(t *testing.T) {
+	l := Embedded()
+	if !bytes.Equal(l.MermaidJS(), MermaidJS) {
+		t.Error("expected embedded mermaid.js")
+	}
+	if !bytes.Equal(l.MermaidZenUMLJS(), MermaidZenUMLJS) {
+		t.Error("expected embedded mermaid-zenuml.js")
+	}
+}
+
+func TestNewFileLoader_NoPaths(t *testing.T) {
+	l, err := NewFileLoader("", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(l.MermaidJS(), MermaidJS) || !bytes.Equal(l.MermaidZenUMLJS(), MermaidZenUMLJS) {
+		t.Error("expected embedded bundles when no paths are given")
+	}
+}
+
+func TestNewFileLoader_Custom(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "mermaid.js")
+	os.WriteFile(p, []byte("window.mermaid = {/* custom */};"), 0644)
+
+	l, err := NewFileLoader(p, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(l.MermaidJS()) != "window.mermaid = {/* custom */};" {
+		t.Errorf("expected custom bundle, got %q", l.MermaidJS())
+	}
+	if !bytes.Equal(l.MermaidZenUMLJS(), MermaidZenUMLJS) {
+		t.Error("expected embedded zenuml bundle to be kept")
+	}
+}
+
+func TestNewFileLoader_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.js")
+	os.WriteFile(empty, []byte("  \n"), 0644)
+	notMermaid := filepath.Join(dir, "other.js")
+	os.WriteFile(notMermaid, []byte("console.log('hi');"), 0644)
+
+	tests := []struct {
+		mermaid, zenuml string
+		want            string
+	}{
+		{"/nonexistent/mermaid.js", "", "doesn't exist"},
+		{empty, "", "is empty"},
+		{"", notMermaid, "doesn't look like a mermaid build"},
+	}
+	for _, tt := range tests {
+		_, err := NewFileLoader(tt.mermaid, tt.zenuml)
+		if err == nil {
+			t.Errorf("expected error for (%q, %q)", tt.mermaid, tt.zenuml)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("expected error containing %q, got %v", tt.want, err)
+		}
+	}
+}
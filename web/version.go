@@ -0,0 +1,39 @@
+package web
+
+import (
+	"regexp"
+	"sync"
+)
+
+// mermaidVersionRegex matches the package metadata esbuild inlines into the mermaid bundle.
+var mermaidVersionRegex = regexp.MustCompile(`name:"mermaid",version:"([^"]+)"`)
+
+// zenumlVersionRegex matches the @zenuml/core module path esbuild records in the zenuml bundle.
+var zenumlVersionRegex = regexp.MustCompile(`@zenuml\+core@([0-9][^/\s"]*)/`)
+
+// MermaidVersion returns the version of a mermaid.js bundle, or "" if it can't be determined.
+func MermaidVersion(js []byte) string {
+	if m := mermaidVersionRegex.FindSubmatch(js); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// ZenUMLVersion returns the @zenuml/core version of a mermaid-zenuml.js bundle,
+// or "" if it can't be determined.
+func ZenUMLVersion(js []byte) string {
+	if m := zenumlVersionRegex.FindSubmatch(js); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+var embeddedVersions = sync.OnceValues(func() (string, string) {
+	return MermaidVersion(MermaidJS), ZenUMLVersion(MermaidZenUMLJS)
+})
+
+// EmbeddedVersions returns the versions of the bundled mermaid and zenuml scripts.
+// The bundles are scanned once and the result is cached.
+func EmbeddedVersions() (mermaid, zenuml string) {
+	return embeddedVersions()
+}
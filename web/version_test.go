@@ -0,0 +1,30 @@
+package web
+
+import "testing"
+
+func TestMermaidVersion(t *testing.T) {
+	js := []byte(`var y4={name:"mermaid",version:"11.4.0",description:"Markdown-ish"}`)
+	if got := MermaidVersion(js); got != "11.4.0" {
+		t.Errorf("MermaidVersion() = %q, want 11.4.0", got)
+	}
+	if got := MermaidVersion([]byte("console.log('mermaid')")); got != "" {
+		t.Errorf("expected empty version for unknown bundle, got %q", got)
+	}
+}
+
+func TestZenUMLVersion(t *testing.T) {
+	js := []byte(`// ../../node_modules/.pnpm/@zenuml+core@3.35.2/node_modules/@zenuml/core/dist/zenuml.esm.mjs`)
+	if got := ZenUMLVersion(js); got != "3.35.2" {
+		t.Errorf("ZenUMLVersion() = %q, want 3.35.2", got)
+	}
+}
+
+func TestEmbeddedVersions(t *testing.T) {
+	mermaid, zenuml := EmbeddedVersions()
+	if mermaid == "" {
+		t.Error("expected the embedded mermaid bundle to report a version")
+	}
+	if zenuml == "" {
+		t.Error("expected the embedded zenuml bundle to report a version")
+	}
+}